@@ -18,6 +18,11 @@ package commands
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
 
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -31,6 +36,19 @@ import (
 
 var networkCmdName = "network"
 
+const (
+	networkFormatTable = "table"
+	networkFormatJSON  = "json"
+)
+
+// networkFormatFlag selects how the network commands display their result; JSON stays the default, as scripts
+// parse it
+var networkFormatFlag = cli.StringFlag{
+	Name:  "output",
+	Value: networkFormatJSON,
+	Usage: "Output format of the result (json or table)",
+}
+
 // NetworkCmd command
 var NetworkCmd = cli.Command{
 	Name:    "network",
@@ -53,9 +71,15 @@ var networkList = cli.Command{
 			Name:  "all",
 			Usage: "List all Networks on tenant (not only those created by SafeScale)",
 		},
+		networkFormatFlag,
 	},
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", networkCmdName, c.Command.Name, c.Args())
+		format, err := networkOutputFormat(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
 		networks, err := client.New().Network.List(c.Bool("all"), temporal.GetExecutionTimeout())
 		if err != nil {
 			return clitools.FailureResponse(
@@ -68,7 +92,7 @@ var networkList = cli.Command{
 				),
 			)
 		}
-		return clitools.SuccessResponse(networks.GetNetworks())
+		return displayNetworks(os.Stdout, format, networks.GetNetworks())
 	},
 }
 
@@ -132,12 +156,19 @@ var networkInspect = cli.Command{
 	Aliases:   []string{"show"},
 	Usage:     "inspect NETWORK",
 	ArgsUsage: "<network_name>",
+	Flags: []cli.Flag{
+		networkFormatFlag,
+	},
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", networkCmdName, c.Command.Name, c.Args())
 		if c.NArg() != 1 {
 			_ = cli.ShowSubcommandHelp(c)
 			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument <network_name>."))
 		}
+		format, err := networkOutputFormat(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
 
 		network, err := client.New().Network.Inspect(c.Args().First(), temporal.GetExecutionTimeout())
 		if err != nil {
@@ -186,10 +217,58 @@ var networkInspect = cli.Command{
 			delete(mapped, "virtual_ip")
 		}
 
-		return clitools.SuccessResponse(mapped)
+		return displayNetwork(os.Stdout, format, mapped)
 	},
 }
 
+// networkOutputFormat returns the output format requested with --output
+func networkOutputFormat(c *cli.Context) (string, error) {
+	switch format := c.String("output"); format {
+	case "", networkFormatJSON:
+		return networkFormatJSON, nil
+	case networkFormatTable:
+		return format, nil
+	default:
+		return "", clitools.ExitOnInvalidOption(fmt.Sprintf("unsupported output format '%s' (expected json or table)", format))
+	}
+}
+
+// displayNetworks displays the list of networks in the requested format;
+// the json format uses the standard response of the safescale commands
+func displayNetworks(w io.Writer, format string, networks []*pb.Network) error {
+	if format == networkFormatJSON {
+		return clitools.SuccessResponseTo(w, networks)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tNAME\tCIDR\tGATEWAY ID\tSECONDARY GATEWAY ID")
+	for _, n := range networks {
+		_, _ = fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%s\t%s\n", n.GetId(), n.GetName(), n.GetCidr(), n.GetGatewayId(), n.GetSecondaryGatewayId(),
+		)
+	}
+	return tw.Flush()
+}
+
+// displayNetwork displays the inspected network in the requested format, one field per line in table format
+func displayNetwork(w io.Writer, format string, mapped map[string]interface{}) error {
+	if format == networkFormatJSON {
+		return clitools.SuccessResponseTo(w, mapped)
+	}
+
+	keys := make([]string, 0, len(mapped))
+	for k := range mapped {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(tw, "%s\t%v\n", k, mapped[k])
+	}
+	return tw.Flush()
+}
+
 var networkCreate = cli.Command{
 	Name:      "create",
 	Aliases:   []string{"new"},
@@ -224,6 +303,18 @@ var networkCreate = cli.Command{
 			Name:  "keep-on-failure, k",
 			Usage: "If set, the abstract are not deleted on failure (default: not set)",
 		},
+		cli.IntSliceFlag{
+			Name:  "ingress-port",
+			Usage: "TCP port to open on the gateways in addition to SSH; can be repeated",
+		},
+		cli.IntFlag{
+			Name:  "mtu",
+			Usage: "MTU of the network, between 576 and 9000 (default: the one of the provider)",
+		},
+		cli.IntFlag{
+			Name:  "expected-hosts",
+			Usage: "Number of hosts expected in the network, gateways excluded, used to check the CIDR is large enough (default: unknown)",
+		},
 		cli.StringFlag{
 			Name: "S, sizing",
 			Usage: `Describe sizing of network gateway in format "<component><operator><value>[,...]" where:
@@ -271,6 +362,10 @@ var networkCreate = cli.Command{
 		if err != nil {
 			return err
 		}
+		var ingressPorts []int32
+		for _, port := range c.IntSlice("ingress-port") {
+			ingressPorts = append(ingressPorts, int32(port))
+		}
 		netdef := pb.NetworkDefinition{
 			Cidr:     c.String("cidr"),
 			Name:     c.Args().Get(0),
@@ -281,7 +376,10 @@ var networkCreate = cli.Command{
 				Name:    c.String("gwname"),
 				Sizing:  def.Sizing,
 			},
-			KeepOnFailure: c.Bool("keep-on-failure"),
+			KeepOnFailure:          c.Bool("keep-on-failure"),
+			AdditionalIngressPorts: ingressPorts,
+			Mtu:                    int32(c.Int("mtu")),
+			ExpectedHostCount:      int32(c.Int("expected-hosts")),
 		}
 		network, err := client.New().Network.Create(&netdef, temporal.GetExecutionTimeout())
 		if err != nil {
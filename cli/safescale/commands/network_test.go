@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+)
+
+var twoNetworks = []*pb.Network{
+	{Id: "id-1", Name: "net-1", Cidr: "192.168.0.0/24", GatewayId: "gw-1"},
+	{Id: "id-2", Name: "net-2", Cidr: "192.168.1.0/24", GatewayId: "gw-2", SecondaryGatewayId: "gw-2b"},
+}
+
+func TestDisplayNetworks_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, displayNetworks(&buf, networkFormatJSON, twoNetworks))
+
+	var decoded struct {
+		Status string                   `json:"status"`
+		Result []map[string]interface{} `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "success", decoded.Status)
+	require.Len(t, decoded.Result, 2)
+	for _, key := range []string{"id", "name", "cidr", "gateway_id"} {
+		assert.Contains(t, decoded.Result[0], key)
+		assert.Contains(t, decoded.Result[1], key)
+	}
+	assert.Equal(t, "gw-2b", decoded.Result[1]["secondary_gateway_id"])
+}
+
+func TestDisplayNetworks_Table(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, displayNetworks(&buf, networkFormatTable, twoNetworks))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+	assert.Contains(t, string(lines[0]), "GATEWAY ID")
+	assert.Contains(t, string(lines[1]), "net-1")
+	assert.Contains(t, string(lines[2]), "gw-2b")
+}
+
+func TestDisplayNetwork_Table(t *testing.T) {
+	var buf bytes.Buffer
+	mapped := map[string]interface{}{"name": "net-1", "cidr": "192.168.0.0/24"}
+	require.NoError(t, displayNetwork(&buf, networkFormatTable, mapped))
+	assert.Equal(t, "cidr  192.168.0.0/24\nname  net-1\n", buf.String())
+}
+
+func TestNetworkOutputFormat(t *testing.T) {
+	parse := func(args ...string) (string, error) {
+		set := flag.NewFlagSet("list", flag.ContinueOnError)
+		networkFormatFlag.Apply(set)
+		require.NoError(t, set.Parse(args))
+		return networkOutputFormat(cli.NewContext(nil, set, nil))
+	}
+
+	// JSON stays the default, the table is opt-in
+	format, err := parse()
+	require.NoError(t, err)
+	assert.Equal(t, networkFormatJSON, format)
+
+	format, err = parse("--output", "table")
+	require.NoError(t, err)
+	assert.Equal(t, networkFormatTable, format)
+
+	_, err = parse("--output", "yaml")
+	assert.Error(t, err)
+}
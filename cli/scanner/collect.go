@@ -7,12 +7,13 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	scribble "github.com/nanobox-io/golang-scribble"
 
 	"github.com/CS-SI/SafeScale/lib/server/iaas"
-	"github.com/CS-SI/SafeScale/lib/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
 )
 
 // StoredCPUInfo ...
@@ -21,7 +22,7 @@ type StoredCPUInfo struct {
 	CPUInfo
 }
 
-func collect(tenantName string) error {
+func collect(tenantName string, outputDir string) error {
 	cmd := exec.Command("safescale", "tenant", "set", tenantName)
 	if err := cmd.Run(); err != nil {
 		return err
@@ -42,42 +43,42 @@ func collect(tenantName string) error {
 
 	folder := fmt.Sprintf("images/%s/%s", serviceProvider.GetName(), region)
 
-	err = os.MkdirAll(utils.AbsPathify("$HOME/.safescale/scanner"), 0777)
+	err = os.MkdirAll(outputDir, 0777)
 	if err != nil {
-		log.Fatal(err)
+		return fail.Wrap(err, fmt.Sprintf("failed to create output folder '%s'", outputDir))
 	}
 
-	db, err := scribble.New(utils.AbsPathify("$HOME/.safescale/scanner/db"), nil)
+	db, err := scribble.New(filepath.Join(outputDir, "db"), nil)
 	if err != nil {
-		log.Fatal(err)
+		return fail.Wrap(err, "failed to open scanner database")
 	}
 
-	files, err := ioutil.ReadDir(utils.AbsPathify("$HOME/.safescale/scanner"))
+	files, err := ioutil.ReadDir(outputDir)
 	if err != nil {
-		log.Fatal(err)
+		return fail.Wrap(err, fmt.Sprintf("failed to list output folder '%s'", outputDir))
 	}
 
 	for _, file := range files {
 		acpu := StoredCPUInfo{}
-		theFile := utils.AbsPathify(fmt.Sprintf("$HOME/.safescale/scanner/%s", file.Name()))
+		theFile := filepath.Join(outputDir, file.Name())
 		if strings.Contains(file.Name(), tenantName+"#") {
 			log.Printf("Storing: %s", file.Name())
 
 			byteValue, err := ioutil.ReadFile(theFile)
 			if err != nil {
-				log.Fatal(err)
+				return fail.Wrap(err, fmt.Sprintf("failed to read '%s'", theFile))
 			}
 
 			err = json.Unmarshal(byteValue, &acpu)
 			if err != nil {
-				log.Fatal(err)
+				return fail.Wrap(err, fmt.Sprintf("failed to decode '%s'", theFile))
 			}
 
 			acpu.ID = acpu.ImageID
 
 			err = db.Write(folder, acpu.TemplateName, acpu)
 			if err != nil {
-				log.Fatal(err)
+				return fail.Wrap(err, fmt.Sprintf("failed to store '%s'", theFile))
 			}
 		}
 		if !file.IsDir() {
@@ -20,12 +20,14 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"math"
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -39,13 +41,18 @@ import (
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/ipversion"
 	"github.com/CS-SI/SafeScale/lib/server/metadata"
+	"github.com/CS-SI/SafeScale/lib/system"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
 
 	_ "github.com/CS-SI/SafeScale/lib/server" // Imported to initialise tenants
 )
 
+// defaultOutputDir is the folder where the scanner stores its outputs if not overridden by -output-dir
+const defaultOutputDir string = "$HOME/.safescale/scanner"
+
 const cmdNumberOfCPU string = "lscpu | grep 'CPU(s):' | grep -v 'NUMA' | tr -d '[:space:]' | cut -d: -f2"
 const cmdNumberOfCorePerSocket string = "lscpu | grep 'Core(s) per socket' | tr -d '[:space:]' | cut -d: -f2"
 const cmdNumberOfSocket string = "lscpu | grep 'Socket(s)' | tr -d '[:space:]' | cut -d: -f2"
@@ -58,32 +65,89 @@ const cmdTotalRAM string = "cat /proc/meminfo | grep MemTotal | cut -d: -f2 | se
 const cmdRAMFreq string = "sudo dmidecode -t memory | grep Speed | head -1 | cut -d' ' -f2"
 
 const cmdGPU string = "lspci | egrep -i 'VGA|3D' | grep -i nvidia | cut -d: -f3 | sed 's/.*controller://g' | tr '\n' '%'"
+const cmdGPUMemory string = "nvidia-smi --query-gpu=memory.total --format=csv,noheader,nounits 2>/dev/null | tr '\n' '%'"
 const cmdDiskSize string = "lsblk -b --output SIZE -n -d /dev/sda"
 const cmdEphemeralDiskSize string = "lsblk -o name,type,mountpoint | grep disk | awk {'print $1'} | grep -v sda | xargs -i'{}' lsblk -b --output SIZE -n -d /dev/'{}'"
 const cmdRotational string = "cat /sys/block/sda/queue/rotational"
 const cmdDiskSpeed string = "sudo hdparm -t --direct /dev/sda | grep MB | awk '{print $11}'"
 const cmdNetSpeed string = "URL=\"http://www.google.com\";curl -L --w \"$URL\nDNS %{time_namelookup}s conn %{time_connect}s time %{time_total}s\nSpeed %{speed_download}bps Size %{size_download}bytes\n\" -o/dev/null -s $URL | grep bps | awk '{ print $2}' | cut -d '.' -f 1"
 
-var cmd = fmt.Sprintf(
-	"export LANG=C;echo $(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)î$(%s)",
-	cmdNumberOfCPU,
-	cmdNumberOfCorePerSocket,
-	cmdNumberOfSocket,
-	cmdCPUFreq,
-	cmdArch,
-	cmdHypervisor,
-	cmdCPUModelName,
-	cmdTotalRAM,
-	cmdRAMFreq,
-	cmdGPU,
-	cmdDiskSize,
-	cmdEphemeralDiskSize,
-	cmdDiskSpeed,
-	cmdRotational,
-	cmdNetSpeed,
+// Keys of the fields collected on scanned hosts
+const (
+	fieldNumberOfCPU           = "number_of_cpu"
+	fieldNumberOfCorePerSocket = "number_of_core_per_socket"
+	fieldNumberOfSocket        = "number_of_socket"
+	fieldCPUFreq               = "cpu_freq"
+	fieldArch                  = "arch"
+	fieldHypervisor            = "hypervisor"
+	fieldCPUModelName          = "cpu_model_name"
+	fieldTotalRAM              = "total_ram"
+	fieldRAMFreq               = "ram_freq"
+	fieldGPU                   = "gpu"
+	fieldDiskSize              = "disk_size"
+	fieldEphemeralDiskSize     = "ephemeral_disk_size"
+	fieldDiskSpeed             = "disk_speed"
+	fieldRotational            = "rotational"
+	fieldNetSpeed              = "net_speed"
+	fieldGPUMemory             = "gpu_memory"
 )
 
+// scannerField associates the key of a collected field with the command collecting it
+type scannerField struct {
+	key     string
+	command string
+}
+
+var scannerFields = []scannerField{
+	{fieldNumberOfCPU, cmdNumberOfCPU},
+	{fieldNumberOfCorePerSocket, cmdNumberOfCorePerSocket},
+	{fieldNumberOfSocket, cmdNumberOfSocket},
+	{fieldCPUFreq, cmdCPUFreq},
+	{fieldArch, cmdArch},
+	{fieldHypervisor, cmdHypervisor},
+	{fieldCPUModelName, cmdCPUModelName},
+	{fieldTotalRAM, cmdTotalRAM},
+	{fieldRAMFreq, cmdRAMFreq},
+	{fieldGPU, cmdGPU},
+	{fieldDiskSize, cmdDiskSize},
+	{fieldEphemeralDiskSize, cmdEphemeralDiskSize},
+	{fieldDiskSpeed, cmdDiskSpeed},
+	{fieldRotational, cmdRotational},
+	{fieldNetSpeed, cmdNetSpeed},
+	{fieldGPUMemory, cmdGPUMemory},
+}
+
+var cmd = buildScannerCommand(scannerFields)
+
+// buildScannerCommand builds the command collecting 'fields' on a host, printing one 'key=value' line per field;
+// the output of each command is not quoted, so that its newlines are collapsed and its value stays on one line
+func buildScannerCommand(fields []scannerField) string {
+	lines := make([]string, 0, len(fields)+1)
+	lines = append(lines, "export LANG=C")
+	for _, f := range fields {
+		lines = append(lines, fmt.Sprintf("echo %s=$(%s)", f.key, f.command))
+	}
+	return strings.Join(lines, ";")
+}
+
+// parseScannerOutput parses the 'key=value' lines printed by the command of buildScannerCommand; empty lines and
+// lines without '=' are ignored
+func parseScannerOutput(output string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			continue
+		}
+		fields[line[:idx]] = strings.TrimSpace(line[idx+1:])
+	}
+	return fields
+}
+
 // CPUInfo stores CPU properties
+// The fields whose collection may fail on a host are pointers, serialized as null when not collected,
+// so that a collected zero value (emitted as is) can be told apart from a missing one
 type CPUInfo struct {
 	TenantName   string `json:"tenant_name,omitempty"`
 	TemplateID   string `json:"template_id,omitempty"`
@@ -92,116 +156,192 @@ type CPUInfo struct {
 	ImageName    string `json:"image_name,omitempty"`
 	LastUpdated  string `json:"last_updated,omitempty"`
 
-	NumberOfCPU    int     `json:"number_of_cpu,omitempty"`
-	NumberOfCore   int     `json:"number_of_core,omitempty"`
-	NumberOfSocket int     `json:"number_of_socket,omitempty"`
-	CPUFrequency   float64 `json:"cpu_frequency_Ghz,omitempty"`
-	CPUArch        string  `json:"cpu_arch,omitempty"`
-	Hypervisor     string  `json:"hypervisor,omitempty"`
-	CPUModel       string  `json:"cpu_model,omitempty"`
-	RAMSize        float64 `json:"ram_size_Gb,omitempty"`
-	RAMFreq        float64 `json:"ram_freq,omitempty"`
-	GPU            int     `json:"gpu,omitempty"`
-	GPUModel       string  `json:"gpu_model,omitempty"`
-	DiskSize       int64   `json:"disk_size_Gb,omitempty"`
-	MainDiskType   string  `json:"main_disk_type"`
-	MainDiskSpeed  float64 `json:"main_disk_speed_MBps"`
-	SampleNetSpeed float64 `json:"sample_net_speed_KBps"`
-	EphDiskSize    int64   `json:"eph_disk_size_Gb"`
-	PricePerHour   float64 `json:"price_in_dollars_hour"`
+	NumberOfCPU    int      `json:"number_of_cpu"`
+	NumberOfCore   int      `json:"number_of_core"`
+	NumberOfSocket int      `json:"number_of_socket"`
+	CPUFrequency   float64  `json:"cpu_frequency_Ghz"`
+	CPUArch        string   `json:"cpu_arch,omitempty"`
+	Hypervisor     string   `json:"hypervisor,omitempty"`
+	CPUModel       string   `json:"cpu_model,omitempty"`
+	RAMSize        float64  `json:"ram_size_Gb"`
+	RAMFreq        *float64 `json:"ram_freq"`
+	GPU            int      `json:"gpu"`
+	GPUModel       string   `json:"gpu_model,omitempty"`
+	GPUMemory      *float64 `json:"gpu_memory_Gb"`
+	DiskSize       *int64   `json:"disk_size_Gb"`
+	MainDiskType   *string  `json:"main_disk_type"`
+	MainDiskSpeed  *float64 `json:"main_disk_speed_MBps"`
+	SampleNetSpeed *float64 `json:"sample_net_speed_KBps"`
+	EphDiskSize    *int64   `json:"eph_disk_size_Gb"`
+	PricePerHour   float64  `json:"price_in_dollars_hour"`
 }
 
-func createCPUInfo(output string) (*CPUInfo, error) {
-	str := strings.TrimSpace(output)
+// optionalFloat returns 'value' parsed as a float64, or nil if it cannot be parsed
+func optionalFloat(value string) *float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
+// optionalGigabytes returns 'value', a size in bytes, converted in GB, or nil if it cannot be parsed
+func optionalGigabytes(value string) *int64 {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+	n = n / 1024 / 1024 / 1024
+	return &n
+}
 
-	tokens := strings.Split(str, "î")
-	if len(tokens) < 9 {
-		return nil, fmt.Errorf("parsing error: '%s'", str)
+// createCPUInfo builds the CPUInfo of a host from the output of the scanner command
+func createCPUInfo(output string) (*CPUInfo, error) {
+	fields := parseScannerOutput(output)
+	required := func(key string) (string, error) {
+		value, ok := fields[key]
+		if !ok {
+			return "", fmt.Errorf("parsing error: field '%s' is missing", key)
+		}
+		return value, nil
+	}
+	parseInt := func(key string) (int, error) {
+		value, err := required(key)
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("parsing error: field '%s'='%s' is not an integer", key, value)
+		}
+		return n, nil
+	}
+	parseFloat := func(key string) (float64, error) {
+		value, err := required(key)
+		if err != nil {
+			return 0, err
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parsing error: field '%s'='%s' is not a number", key, value)
+		}
+		return f, nil
 	}
+
 	info := CPUInfo{}
 	var err error
-	info.NumberOfCPU, err = strconv.Atoi(tokens[0])
+	info.NumberOfCPU, err = parseInt(fieldNumberOfCPU)
 	if err != nil {
-		return nil, fmt.Errorf("parsing error: NumberOfCPU='%s' (from '%s')", tokens[0], str)
+		return nil, err
 	}
-	info.NumberOfCore, err = strconv.Atoi(tokens[1])
+	info.NumberOfCore, err = parseInt(fieldNumberOfCorePerSocket)
 	if err != nil {
-		return nil, fmt.Errorf("parsing error: NumberOfCore='%s' (from '%s')", tokens[1], str)
+		return nil, err
 	}
-	info.NumberOfSocket, err = strconv.Atoi(tokens[2])
+	info.NumberOfSocket, err = parseInt(fieldNumberOfSocket)
 	if err != nil {
-		return nil, fmt.Errorf("parsing error: NumberOfSocket='%s' (from '%s')", tokens[2], str)
+		return nil, err
 	}
 	info.NumberOfCore *= info.NumberOfSocket
-	info.CPUFrequency, err = strconv.ParseFloat(tokens[3], 64)
+	info.CPUFrequency, err = parseFloat(fieldCPUFreq)
 	if err != nil {
-		return nil, fmt.Errorf("parsing error: CpuFrequency='%s' (from '%s')", tokens[3], str)
+		return nil, err
 	}
 	info.CPUFrequency = math.Floor(info.CPUFrequency*100) / 100000
 
-	info.CPUArch = tokens[4]
-	info.Hypervisor = tokens[5]
-	info.CPUModel = tokens[6]
-	info.RAMSize, err = strconv.ParseFloat(tokens[7], 64)
+	info.CPUArch = fields[fieldArch]
+	info.Hypervisor = fields[fieldHypervisor]
+	info.CPUModel = fields[fieldCPUModelName]
+	info.RAMSize, err = parseFloat(fieldTotalRAM)
 	if err != nil {
-		return nil, fmt.Errorf("parsing error: RAMSize='%s' (from '%s')", tokens[7], str)
+		return nil, err
 	}
 
 	memInGb := info.RAMSize / 1024 / 1024
 	info.RAMSize = math.Floor(memInGb*100) / 100
-	info.RAMFreq, err = strconv.ParseFloat(tokens[8], 64)
-	if err != nil {
-		info.RAMFreq = 0
-	}
-	gpuTokens := strings.Split(tokens[9], "%")
+	info.RAMFreq = optionalFloat(fields[fieldRAMFreq])
+	gpuTokens := strings.Split(fields[fieldGPU], "%")
 	nb := len(gpuTokens)
 	if nb > 1 {
 		info.GPUModel = strings.TrimSpace(gpuTokens[0])
 		info.GPU = nb - 1
 	}
 
-	info.DiskSize, err = strconv.ParseInt(tokens[10], 10, 64)
-	if err != nil {
-		info.DiskSize = 0
+	info.DiskSize = optionalGigabytes(fields[fieldDiskSize])
+	info.EphDiskSize = optionalGigabytes(fields[fieldEphemeralDiskSize])
+	info.MainDiskSpeed = optionalFloat(fields[fieldDiskSpeed])
+
+	rotational, err := strconv.ParseInt(fields[fieldRotational], 10, 64)
+	if err == nil {
+		diskType := "SSD"
+		if rotational == 1 {
+			diskType = "HDD"
+		}
+		info.MainDiskType = &diskType
 	}
-	info.DiskSize = info.DiskSize / 1024 / 1024 / 1024
 
-	info.EphDiskSize, err = strconv.ParseInt(tokens[11], 10, 64)
-	if err != nil {
-		info.EphDiskSize = 0
+	if nsp := optionalFloat(fields[fieldNetSpeed]); nsp != nil {
+		speed := *nsp / 1000 / 8
+		info.SampleNetSpeed = &speed
 	}
-	info.EphDiskSize = info.EphDiskSize / 1024 / 1024 / 1024
 
-	info.MainDiskSpeed, err = strconv.ParseFloat(tokens[12], 64)
-	if err != nil {
-		info.MainDiskSpeed = 0
+	if info.GPU == 0 {
+		var none float64
+		info.GPUMemory = &none
+	} else if memory, ok := parseGPUMemory(fields[fieldGPUMemory]); ok {
+		info.GPUMemory = &memory
 	}
 
-	rotational, err := strconv.ParseInt(tokens[13], 10, 64)
+	info.PricePerHour = 0
+
+	return &info, nil
+}
+
+// prepareOutputDir resolves the output folder, creates it if needed and checks it's writable
+func prepareOutputDir(dir string) (string, error) {
+	if dir == "" {
+		dir = defaultOutputDir
+	}
+	dir = utils.AbsPathify(dir)
+	err := os.MkdirAll(dir, 0777)
 	if err != nil {
-		info.MainDiskType = ""
-	} else {
-		if rotational == 1 {
-			info.MainDiskType = "HDD"
-		} else {
-			info.MainDiskType = "SSD"
-		}
+		return "", fmt.Errorf("failed to create output directory '%s': %v", dir, err)
 	}
-
-	nsp, err := strconv.ParseFloat(tokens[14], 64)
+	probe, err := ioutil.TempFile(dir, ".scanner-probe-")
 	if err != nil {
-		info.SampleNetSpeed = 0
-	} else {
-		info.SampleNetSpeed = nsp / 1000 / 8
+		return "", fmt.Errorf("output directory '%s' is not writable: %v", dir, err)
 	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	return dir, nil
+}
 
-	info.PricePerHour = 0
-
-	return &info, nil
+// parseGPUMemory returns the total memory (in GB) of the GPUs listed by nvidia-smi, one value in MiB per GPU
+// separated by '%'; returns false if no value has been found (nvidia-smi is not available)
+func parseGPUMemory(output string) (float64, bool) {
+	var (
+		total float64
+		found bool
+	)
+	for _, token := range strings.Split(output, "%") {
+		mem, err := strconv.ParseFloat(strings.TrimSpace(token), 64)
+		if err != nil {
+			continue
+		}
+		total += mem
+		found = true
+	}
+	return math.Floor(total/1024*100) / 100, found
 }
 
-// RunScanner ...
-func RunScanner(targetedTenant string) {
+// RunScanner scans the targeted tenant, or all the scannable tenants if empty
+// If keepOnError is true, the hosts whose scan failed are kept for inspection
+func RunScanner(targetedTenant string, outputDir string, keepOnError bool) error {
+	outputDir, err := prepareOutputDir(outputDir)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
 	var targetedProviders []string
 	theProviders, err := iaas.GetTenants()
 	if err != nil {
@@ -224,7 +364,7 @@ func RunScanner(targetedTenant string) {
 
 	if len(targetedProviders) < 1 {
 		logrus.Warn("No scannable tenant found. Consider marking a tennant as Scannable as stated in documentation")
-		return
+		return nil
 	}
 
 	if targetedTenant != "" {
@@ -257,24 +397,31 @@ func RunScanner(targetedTenant string) {
 
 	if targetedTenant != "" {
 		fmt.Printf("Scanning only tenant %s", targetedTenant)
+		targetedProviders = []string{targetedTenant}
 	}
+	return scanTenants(
+		targetedProviders, outputDir, func(tenantName string, outputDir string) error {
+			return analyzeTenant(nil, tenantName, outputDir, keepOnError)
+		}, collect,
+	)
+}
 
-	for _, tenantName := range targetedProviders {
-		if targetedTenant != "" {
-			if targetedTenant != tenantName {
-				continue
-			}
-		}
-
+// scanTenants analyzes then collects the scanned info of each tenant, going on with the next tenant on failure
+// returns the errors of all the tenants
+func scanTenants(tenants []string, outputDir string, analyze, collector func(string, string) error) error {
+	var errs []error
+	for _, tenantName := range tenants {
 		fmt.Printf("Working with tenant %s\n", tenantName)
-		err := analyzeTenant(nil, tenantName)
-		if err != nil {
-			fmt.Printf("Error working with tenant %s\n", tenantName)
+		if err := analyze(tenantName, outputDir); err != nil {
+			logrus.Warnf("failed to scan tenant %s: %v", tenantName, err)
+			errs = append(errs, fail.Wrap(err, fmt.Sprintf("failed to scan tenant %s", tenantName)))
 		}
-		if err := collect(tenantName); err != nil {
-			logrus.Warn(fmt.Printf("failed to save scanned info from tenant %s", tenantName))
+		if err := collector(tenantName, outputDir); err != nil {
+			logrus.Warnf("failed to save scanned info from tenant %s: %v", tenantName, err)
+			errs = append(errs, fail.Wrap(err, fmt.Sprintf("failed to save scanned info from tenant %s", tenantName)))
 		}
 	}
+	return fail.ErrListError(errs)
 }
 
 // isTenantScannable will return true if a tennant could be used by the scanner and false otherwise
@@ -290,7 +437,115 @@ func isTenantScannable(tenant map[string]interface{}) (isScannable bool, err err
 	return isScannable, nil
 }
 
-func analyzeTenant(group *sync.WaitGroup, theTenant string) (err error) {
+// keptHosts lists the IDs of the scan hosts kept for inspection
+type keptHosts struct {
+	lock sync.Mutex
+	ids  []string
+}
+
+func (k *keptHosts) add(id string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.ids = append(k.ids, id)
+}
+
+func (k *keptHosts) list() []string {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return append([]string{}, k.ids...)
+}
+
+// releaseScanHost deletes the host created to scan a template, unless keepOnError is true and the scan failed with
+// scanErr; a kept host is added to 'kept' and the commands to inspect it are logged
+func releaseScanHost(
+	host *abstract.Host, templateName string, scanErr error, keepOnError bool, kept *keptHosts,
+	deleteHost func(string) error,
+) {
+	if scanErr != nil && keepOnError {
+		kept.add(host.ID)
+		logrus.Warnf(
+			"template [%s]: keeping host '%s' with ID '%s' for inspection; connect with 'safescale ssh connect %s' and reproduce the scan with 'safescale ssh run %s -c \"%s\"'",
+			templateName, host.Name, host.ID, host.Name, host.Name, cmd,
+		)
+		return
+	}
+
+	logrus.Infof("Trying to delete host '%s' with ID '%s'", host.Name, host.ID)
+	if delerr := deleteHost(host.ID); delerr != nil {
+		logrus.Warnf("Error deleting host '%s'", host.ID)
+	}
+}
+
+// teardownScanNetwork deletes the network created for the scan, unless hosts have been kept for inspection in it:
+// the network is then kept too and has to be deleted with the kept hosts
+func teardownScanNetwork(network *abstract.Network, kept *keptHosts, deleteNetwork func(string) error) error {
+	if ids := kept.list(); len(ids) > 0 {
+		logrus.Warnf(
+			"Not deleting network '%s': hosts %s have been kept for inspection; delete them then the network when done",
+			network.Name, strings.Join(ids, ", "),
+		)
+		return nil
+	}
+	delerr := deleteNetwork(network.ID)
+	if delerr != nil {
+		logrus.Warnf("Error deleting network '%s'", network.ID)
+	}
+	return delerr
+}
+
+// staleSSHMarkers are the fragments of the errors telling the SSH configuration of a host may be stale (the host
+// rebooted during its provisioning, changing its host key or its IP)
+var staleSSHMarkers = []string{
+	"error code: 255",
+	"host key",
+	"connection refused",
+	"no route to host",
+	"connection timed out",
+	"connection reset",
+}
+
+// isStaleSSHError tells if err is a connection or host key error, that may be fixed by refreshing the SSH configuration
+func isStaleSSHError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range staleSSHMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanWithSSHRefresh runs 'scan' with the SSH configuration returned by getConfig; if it fails with a connection or
+// host key error, the SSH configuration is fetched again and 'scan' retried once
+func scanWithSSHRefresh(
+	templateName string, getConfig func() (*system.SSHConfig, error), scan func(*system.SSHConfig) (string, error),
+) (string, error) {
+	sshCfg, err := getConfig()
+	if err != nil {
+		logrus.Warnf("template [%s]: error reading SSHConfig: %v", templateName, err)
+		return "", err
+	}
+	out, err := scan(sshCfg)
+	if !isStaleSSHError(err) {
+		return out, err
+	}
+
+	logrus.Warnf(
+		"template [%s]: SSH connection to '%s' failed, refreshing the SSH configuration and retrying once: %v",
+		templateName, sshCfg.Host, err,
+	)
+	sshCfg, rerr := getConfig()
+	if rerr != nil {
+		logrus.Warnf("template [%s]: error refreshing SSHConfig: %v", templateName, rerr)
+		return "", fail.AddConsequence(err, rerr)
+	}
+	return scan(sshCfg)
+}
+
+func analyzeTenant(group *sync.WaitGroup, theTenant string, outputDir string, keepOnError bool) (err error) {
 	// FIXME: Add trace
 	if group != nil {
 		defer group.Done()
@@ -307,12 +562,12 @@ func analyzeTenant(group *sync.WaitGroup, theTenant string) (err error) {
 		return err
 	}
 
-	err = dumpImages(serviceProvider, theTenant)
+	err = dumpImages(serviceProvider, theTenant, outputDir)
 	if err != nil {
 		return err
 	}
 
-	err = dumpTemplates(serviceProvider, theTenant)
+	err = dumpTemplates(serviceProvider, theTenant, outputDir)
 	if err != nil {
 		return err
 	}
@@ -331,6 +586,7 @@ func analyzeTenant(group *sync.WaitGroup, theTenant string) (err error) {
 
 	there := true
 	var network *abstract.Network
+	kept := &keptHosts{}
 
 	netName := "net-safescale" // FIXME: Hardcoded string
 	if network, err = serviceProvider.GetNetwork(netName); network != nil && err == nil {
@@ -355,20 +611,17 @@ func analyzeTenant(group *sync.WaitGroup, theTenant string) (err error) {
 		}
 
 		defer func() {
-			delerr := serviceProvider.DeleteNetwork(network.ID)
-			if delerr != nil {
-				logrus.Warnf("Error deleting network '%s'", network.ID)
-			}
+			delerr := teardownScanNetwork(network, kept, serviceProvider.DeleteNetwork)
 			err = fail.AddConsequence(err, delerr)
 		}()
 
-		_, err = metadata.SaveNetwork(serviceProvider, network)
+		_, err = metadata.SaveOrUpdateNetwork(serviceProvider, network)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = os.MkdirAll(utils.AbsPathify("$HOME/.safescale/scanner"), 0777)
+	err = os.MkdirAll(outputDir, 0777)
 	if err != nil {
 		return err
 	}
@@ -378,7 +631,7 @@ func analyzeTenant(group *sync.WaitGroup, theTenant string) (err error) {
 	concurrency := math.Min(4, float64(len(templates)/2))
 	sem := make(chan bool, int(concurrency))
 
-	hostAnalysis := func(template abstract.HostTemplate) error {
+	hostAnalysis := func(template abstract.HostTemplate) (scanErr error) {
 		defer wg.Done()
 		if network != nil {
 
@@ -396,7 +649,7 @@ func analyzeTenant(group *sync.WaitGroup, theTenant string) (err error) {
 			}
 
 			// TODO: If there is a file with today's date, skip it...
-			fileCandidate := utils.AbsPathify("$HOME/.safescale/scanner/" + theTenant + "#" + template.Name + ".json")
+			fileCandidate := filepath.Join(outputDir, theTenant+"#"+template.Name+".json")
 			if _, err := os.Stat(fileCandidate); !os.IsNotExist(err) {
 				return nil
 			}
@@ -415,34 +668,45 @@ func analyzeTenant(group *sync.WaitGroup, theTenant string) (err error) {
 			}
 
 			defer func() {
-				logrus.Infof("Trying to delete host '%s' with ID '%s'", hostName, host.ID)
-				delerr := hostHandler.Delete(context.Background(), host.ID)
-				if delerr != nil {
-					logrus.Warnf("Error deleting host '%s'", host.ID)
-				}
+				releaseScanHost(
+					host, template.Name, scanErr, keepOnError, kept, func(id string) error {
+						return hostHandler.Delete(context.Background(), id)
+					},
+				)
 			}()
 
 			sshSvc := handlers.NewSSHHandler(serviceProvider)
-			ssh, err := sshSvc.GetConfig(context.Background(), host.ID)
-			if err != nil {
-				logrus.Warnf(
-					"template [%s] host '%s': error reading SSHConfig: %v\n", template.Name, hostName, err.Error(),
-				)
-				return err
+			getConfig := func() (*system.SSHConfig, error) {
+				// bounded, so a provider not answering doesn't block the worker forever
+				ctx, cancel := context.WithTimeout(context.Background(), temporal.GetContextTimeout())
+				defer cancel()
+				return sshSvc.GetConfig(ctx, host.ID)
 			}
-			_, nerr := ssh.WaitServerReady("ready", time.Duration(6+concurrency-1)*time.Minute)
-			if nerr != nil {
-				logrus.Warnf("template [%s]: Error waiting for server ready: %v", template.Name, nerr)
-				return nerr
-			}
-			c, err := ssh.Command(cmd)
-			if err != nil {
-				logrus.Warnf("template [%s]: Problem creating ssh command: %v", template.Name, err)
-				return err
+			scan := func(ssh *system.SSHConfig) (string, error) {
+				_, nerr := ssh.WaitServerReady("ready", time.Duration(6+concurrency-1)*time.Minute)
+				if nerr != nil {
+					logrus.Warnf("template [%s]: Error waiting for server ready: %v", template.Name, nerr)
+					return "", nerr
+				}
+				c, err := ssh.Command(cmd)
+				if err != nil {
+					logrus.Warnf("template [%s]: Problem creating ssh command: %v", template.Name, err)
+					return "", err
+				}
+				retcode, cout, cerr, err := c.RunWithTimeout(nil, outputs.COLLECT, 8*time.Minute) // FIXME: Hardcoded timeout
+				if err != nil {
+					logrus.Warnf("template [%s]: Problem running ssh command: %v", template.Name, err)
+					return "", err
+				}
+				if retcode == 255 {
+					err = fmt.Errorf("ssh connection failed: error code: 255; Error [%s]", cerr)
+					logrus.Warnf("template [%s]: Problem running ssh command: %v", template.Name, err)
+					return "", err
+				}
+				return cout, nil
 			}
-			_, cout, _, err := c.RunWithTimeout(nil, outputs.COLLECT, 8*time.Minute) // FIXME: Hardcoded timeout
+			cout, err := scanWithSSHRefresh(template.Name, getConfig, scan)
 			if err != nil {
-				logrus.Warnf("template [%s]: Problem running ssh command: %v", template.Name, err)
 				return err
 			}
 
@@ -465,17 +729,12 @@ func analyzeTenant(group *sync.WaitGroup, theTenant string) (err error) {
 				return err
 			}
 
-			nerr = ioutil.WriteFile(
-				utils.AbsPathify("$HOME/.safescale/scanner/"+theTenant+"#"+template.Name+".json"), daOut, 0666,
-			)
-			if nerr != nil {
-				logrus.Warnf("template [%s] : Error writing file: %v", template.Name, nerr)
-				return nerr
+			err = ioutil.WriteFile(fileCandidate, daOut, 0666)
+			if err != nil {
+				logrus.Warnf("template [%s] : Error writing file: %v", template.Name, err)
+				return err
 			}
-			logrus.Infof(
-				"template [%s] : Stored in file: %s", template.Name,
-				"$HOME/.safescale/scanner/"+theTenant+"#"+template.Name+".json",
-			)
+			logrus.Infof("template [%s] : Stored in file: %s", template.Name, fileCandidate)
 		} else {
 			return fmt.Errorf("no gateway network")
 		}
@@ -506,8 +765,8 @@ func analyzeTenant(group *sync.WaitGroup, theTenant string) (err error) {
 	return nil
 }
 
-func dumpTemplates(service iaas.Service, tenant string) (err error) {
-	err = os.MkdirAll(utils.AbsPathify("$HOME/.safescale/scanner"), 0777)
+func dumpTemplates(service iaas.Service, tenant string, outputDir string) (err error) {
+	err = os.MkdirAll(outputDir, 0777)
 	if err != nil {
 		return err
 	}
@@ -530,8 +789,7 @@ func dumpTemplates(service iaas.Service, tenant string) (err error) {
 		return err
 	}
 
-	f := fmt.Sprintf("$HOME/.safescale/scanner/%s-templates.json", tenant)
-	f = utils.AbsPathify(f)
+	f := filepath.Join(outputDir, fmt.Sprintf("%s-templates.json", tenant))
 
 	err = ioutil.WriteFile(f, content, 0666)
 	if err != nil {
@@ -541,8 +799,8 @@ func dumpTemplates(service iaas.Service, tenant string) (err error) {
 	return nil
 }
 
-func dumpImages(service iaas.Service, tenant string) (err error) {
-	err = os.MkdirAll(utils.AbsPathify("$HOME/.safescale/scanner"), 0777)
+func dumpImages(service iaas.Service, tenant string, outputDir string) (err error) {
+	err = os.MkdirAll(outputDir, 0777)
 	if err != nil {
 		return err
 	}
@@ -565,8 +823,7 @@ func dumpImages(service iaas.Service, tenant string) (err error) {
 		return err
 	}
 
-	f := fmt.Sprintf("$HOME/.safescale/scanner/%s-images.json", tenant)
-	f = utils.AbsPathify(f)
+	f := filepath.Join(outputDir, fmt.Sprintf("%s-images.json", tenant))
 
 	err = ioutil.WriteFile(f, content, 0666)
 	if err != nil {
@@ -577,6 +834,10 @@ func dumpImages(service iaas.Service, tenant string) (err error) {
 }
 
 func main() {
+	outputDir := flag.String("output-dir", defaultOutputDir, "folder where the scanner stores its outputs")
+	keepOnError := flag.Bool("keep-on-error", false, "keep the hosts whose scan failed for inspection")
+	flag.Parse()
+
 	logrus.Printf(
 		"%s version %s\n", os.Args[0], Version+", build "+Revision+" ("+BuildDate+"), compiled with "+runtime.Version(),
 	)
@@ -601,12 +862,17 @@ func main() {
 		logrus.Fatalf("You must have safescale in your $PATH")
 	}
 
-	if len(os.Args) == 1 {
+	dir, err := prepareOutputDir(*outputDir)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	if flag.NArg() == 0 {
 		fmt.Println("Scanner will create one instance of each available template for ALL your tenants marked as 'Scannable' in your tenants.toml file")
 	} else {
 		fmt.Printf(
 			"Scanner will create one instance of each available template for the tenant '%s' of your tenants.toml file\n",
-			os.Args[1],
+			flag.Arg(0),
 		)
 	}
 
@@ -625,9 +891,7 @@ func main() {
 	time.Sleep(time.Duration(10) * time.Second)
 
 	logrus.Info("Starting scanner...")
-	if len(os.Args) > 1 {
-		RunScanner(os.Args[1])
-	} else {
-		RunScanner("")
+	if err := RunScanner(flag.Arg(0), dir, *keepOnError); err != nil {
+		logrus.Fatal(err)
 	}
 }
@@ -17,29 +17,39 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/system"
 )
 
-func runOnlyInIntegrationTest(key string) {
+// runOnlyInIntegrationTest skips the test if the environment variable 'key' is not set
+func runOnlyInIntegrationTest(t *testing.T, key string) {
 	if tenantOverride := os.Getenv(key); tenantOverride == "" {
-		panic("This only runs as an integration test")
+		t.Skipf("This only runs as an integration test (set %s)", key)
 	}
 }
 
 func TestCmds(t *testing.T) {
-	runOnlyInIntegrationTest("TEST_SCANNER")
+	runOnlyInIntegrationTest(t, "TEST_SCANNER")
 
 	out, err := exec.Command("bash", "-c", "lscpu -p'CPU,CORE,SOCKET,MAXMHZ,MINMHZ' | tail -1").Output()
 	assert.NoError(t, err)
 	lines := strings.Split(strings.TrimSpace(string(out)), ",")
-	fmt.Println(len(lines))
 
 	nbThread, err := strconv.Atoi(lines[0])
 	nbThread++
@@ -65,8 +75,334 @@ func TestCmds(t *testing.T) {
 	assert.Equal(t, 800.0000, fMin)
 }
 
+func TestPrepareOutputDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "scanner")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	dir, err := prepareOutputDir(filepath.Join(tmp, "tenant", "outputs"))
+	require.Nil(t, err)
+	assert.Equal(t, filepath.Join(tmp, "tenant", "outputs"), dir)
+	info, err := os.Stat(dir)
+	require.Nil(t, err)
+	assert.True(t, info.IsDir())
+
+	// Probe file must not be left behind
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	assert.Empty(t, files)
+
+	// A file cannot be used as output directory
+	notADir := filepath.Join(tmp, "file")
+	err = ioutil.WriteFile(notADir, []byte{}, 0666)
+	require.Nil(t, err)
+	_, err = prepareOutputDir(notADir)
+	assert.NotNil(t, err)
+}
+
+// fakeService is an iaas.Service providing only what dumpImages and dumpTemplates need
+type fakeService struct {
+	iaas.Service
+}
+
+func (s *fakeService) ListImages(all bool) ([]abstract.Image, error) {
+	return []abstract.Image{{ID: "img-1", Name: "Ubuntu 18.04"}}, nil
+}
+
+func (s *fakeService) ListTemplates(all bool) ([]abstract.HostTemplate, error) {
+	return []abstract.HostTemplate{{ID: "tpl-1", Name: "s1-2", Cores: 1}}, nil
+}
+
+func TestDumpToOutputDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "scanner")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	err = dumpImages(&fakeService{}, "tenant", tmp)
+	require.Nil(t, err)
+	err = dumpTemplates(&fakeService{}, "tenant", tmp)
+	require.Nil(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(tmp, "tenant-images.json"))
+	require.Nil(t, err)
+	assert.Contains(t, string(content), "img-1")
+	content, err = ioutil.ReadFile(filepath.Join(tmp, "tenant-templates.json"))
+	require.Nil(t, err)
+	assert.Contains(t, string(content), "tpl-1")
+}
+
+func scannerOutput(gpu string, gpuMemory string) string {
+	return strings.Join(
+		[]string{
+			fieldNumberOfCPU + "=4", fieldNumberOfCorePerSocket + "=2", fieldNumberOfSocket + "=1",
+			fieldCPUFreq + "=2400.000", fieldArch + "=x86_64", fieldHypervisor + "=KVM",
+			fieldCPUModelName + "=Intel Xeon", fieldTotalRAM + "=8167148", fieldRAMFreq + "=2400", fieldGPU + "=" + gpu,
+			fieldDiskSize + "=53687091200", fieldEphemeralDiskSize + "=", fieldDiskSpeed + "=120.5",
+			fieldRotational + "=0", fieldNetSpeed + "=12345", fieldGPUMemory + "=" + gpuMemory,
+		}, "\n",
+	)
+}
+
+func TestCreateCPUInfo(t *testing.T) {
+	info, err := createCPUInfo(scannerOutput("", ""))
+	require.Nil(t, err)
+	assert.Equal(t, 4, info.NumberOfCPU)
+	assert.Equal(t, 2, info.NumberOfCore)
+	assert.Equal(t, 1, info.NumberOfSocket)
+	assert.Equal(t, 2.4, info.CPUFrequency)
+	assert.Equal(t, "x86_64", info.CPUArch)
+	assert.Equal(t, "KVM", info.Hypervisor)
+	assert.Equal(t, "Intel Xeon", info.CPUModel)
+	assert.Equal(t, 7.78, info.RAMSize)
+	require.NotNil(t, info.RAMFreq)
+	assert.Equal(t, float64(2400), *info.RAMFreq)
+	require.NotNil(t, info.DiskSize)
+	assert.Equal(t, int64(50), *info.DiskSize)
+	assert.Nil(t, info.EphDiskSize)
+	require.NotNil(t, info.MainDiskSpeed)
+	assert.Equal(t, 120.5, *info.MainDiskSpeed)
+	require.NotNil(t, info.MainDiskType)
+	assert.Equal(t, "SSD", *info.MainDiskType)
+	require.NotNil(t, info.SampleNetSpeed)
+	assert.Equal(t, 1.543125, *info.SampleNetSpeed)
+}
+
+func TestCreateCPUInfo_GPU(t *testing.T) {
+	info, err := createCPUInfo(
+		scannerOutput(" NVIDIA Corporation GV100GL [Tesla V100 SXM2 16GB]% NVIDIA Corporation GV100GL [Tesla V100 SXM2 16GB]%", "16160%16160%"),
+	)
+	require.Nil(t, err)
+	assert.Equal(t, 2, info.GPU)
+	assert.Equal(t, "NVIDIA Corporation GV100GL [Tesla V100 SXM2 16GB]", info.GPUModel)
+	require.NotNil(t, info.GPUMemory)
+	assert.Equal(t, 31.56, *info.GPUMemory)
+
+	// nvidia-smi not available: GPU memory not collected
+	info, err = createCPUInfo(scannerOutput(" NVIDIA Corporation GV100GL [Tesla V100 SXM2 16GB]%", ""))
+	require.Nil(t, err)
+	assert.Equal(t, 1, info.GPU)
+	assert.Nil(t, info.GPUMemory)
+}
+
+func TestCreateCPUInfo_NoGPU(t *testing.T) {
+	info, err := createCPUInfo(scannerOutput("", ""))
+	require.Nil(t, err)
+	assert.Equal(t, 0, info.GPU)
+	assert.Equal(t, "", info.GPUModel)
+	require.NotNil(t, info.GPUMemory)
+	assert.Equal(t, float64(0), *info.GPUMemory)
+
+	// Output of hosts scanned before GPU memory was collected
+	info, err = createCPUInfo(strings.TrimSuffix(scannerOutput("", ""), "\n"+fieldGPUMemory+"="))
+	require.Nil(t, err)
+	require.NotNil(t, info.GPUMemory)
+	assert.Equal(t, float64(0), *info.GPUMemory)
+}
+
+func TestCreateCPUInfo_ExtraNewlines(t *testing.T) {
+	info, err := createCPUInfo("\n" + strings.Replace(scannerOutput("", ""), "\n", "\n\n", 3) + "\n\n")
+	require.Nil(t, err)
+	assert.Equal(t, 4, info.NumberOfCPU)
+	assert.Equal(t, 2, info.NumberOfCore)
+	assert.Equal(t, "Intel Xeon", info.CPUModel)
+	assert.Equal(t, 0, info.GPU)
+}
+
+func TestCreateCPUInfo_ReportsFailingField(t *testing.T) {
+	output := strings.Replace(scannerOutput("", ""), fieldCPUFreq+"=2400.000", fieldCPUFreq+"=", 1)
+	_, err := createCPUInfo(output)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), fieldCPUFreq)
+
+	output = strings.Replace(scannerOutput("", ""), fieldTotalRAM+"=8167148\n", "", 1)
+	_, err = createCPUInfo(output)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), fieldTotalRAM)
+	assert.Contains(t, err.Error(), "missing")
+}
+
+func TestCPUInfo_JSON(t *testing.T) {
+	info, err := createCPUInfo(scannerOutput("", ""))
+	require.Nil(t, err)
+	content, err := json.Marshal(info)
+	require.Nil(t, err)
+	var decoded map[string]interface{}
+	require.Nil(t, json.Unmarshal(content, &decoded))
+	assert.Equal(t, float64(0), decoded["gpu"])
+	assert.Equal(t, float64(0), decoded["gpu_memory_Gb"])
+	assert.Equal(t, 1.543125, decoded["sample_net_speed_KBps"])
+
+	// failed net speed benchmark
+	info, err = createCPUInfo(strings.Replace(scannerOutput("", ""), fieldNetSpeed+"=12345", fieldNetSpeed+"=", 1))
+	require.Nil(t, err)
+	content, err = json.Marshal(info)
+	require.Nil(t, err)
+	decoded = nil
+	require.Nil(t, json.Unmarshal(content, &decoded))
+	value, ok := decoded["sample_net_speed_KBps"]
+	assert.True(t, ok)
+	assert.Nil(t, value)
+
+	// collected zero net speed
+	info, err = createCPUInfo(strings.Replace(scannerOutput("", ""), fieldNetSpeed+"=12345", fieldNetSpeed+"=0", 1))
+	require.Nil(t, err)
+	content, err = json.Marshal(info)
+	require.Nil(t, err)
+	assert.Contains(t, string(content), `"sample_net_speed_KBps":0`)
+}
+
+func TestBuildScannerCommand(t *testing.T) {
+	command := buildScannerCommand([]scannerField{{"a", "echo 1"}, {"b", "echo 2"}})
+	out, err := exec.Command("bash", "-c", command).Output()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, parseScannerOutput(string(out)))
+}
+
 func TestMain(m *testing.M) {
-	runOnlyInIntegrationTest("TEST_SCANNER")
+	if os.Getenv("TEST_SCANNER") != "" {
+		if err := RunScanner("", defaultOutputDir, false); err != nil {
+			logrus.Errorf("failed to run the scanner: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestScanTenants_GoesOnAfterFailure(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	var analyzed, collected []string
+	analyze := func(tenantName string, outputDir string) error {
+		analyzed = append(analyzed, tenantName)
+		return nil
+	}
+	collector := func(tenantName string, outputDir string) error {
+		collected = append(collected, tenantName)
+		if tenantName == "broken" {
+			return fmt.Errorf("region value unset")
+		}
+		return nil
+	}
+
+	err := scanTenants([]string{"first", "broken", "last"}, "/tmp", analyze, collector)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "broken")
+	assert.NotContains(t, err.Error(), "first")
+	assert.Equal(t, []string{"first", "broken", "last"}, analyzed)
+	assert.Equal(t, []string{"first", "broken", "last"}, collected)
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	assert.Equal(t, "failed to save scanned info from tenant broken: region value unset", hook.LastEntry().Message)
+
+	err = scanTenants([]string{"first", "last"}, "/tmp", analyze, collector)
+	assert.Nil(t, err)
+}
+
+func TestReleaseScanHost(t *testing.T) {
+	host := &abstract.Host{ID: "host-id", Name: "scanhost-tiny"}
+	var deleted []string
+	deleteHost := func(id string) error {
+		deleted = append(deleted, id)
+		return nil
+	}
+	scanErr := fmt.Errorf("parsing error: field 'cpu_freq' is missing")
+
+	// without the flag, hosts are deleted whatever the outcome of the scan
+	kept := &keptHosts{}
+	releaseScanHost(host, "tiny", scanErr, false, kept, deleteHost)
+	releaseScanHost(host, "tiny", nil, false, kept, deleteHost)
+	assert.Equal(t, []string{"host-id", "host-id"}, deleted)
+	assert.Empty(t, kept.list())
+
+	// with the flag, only the hosts whose scan failed are kept
+	deleted = nil
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	releaseScanHost(host, "tiny", scanErr, true, kept, deleteHost)
+	assert.Empty(t, deleted)
+	assert.Equal(t, []string{"host-id"}, kept.list())
+	require.NotNil(t, hook.LastEntry())
+	assert.Contains(t, hook.LastEntry().Message, "host-id")
+	assert.Contains(t, hook.LastEntry().Message, "safescale ssh connect scanhost-tiny")
+
+	releaseScanHost(host, "tiny", nil, true, kept, deleteHost)
+	assert.Equal(t, []string{"host-id"}, deleted)
+}
+
+func TestTeardownScanNetwork(t *testing.T) {
+	network := &abstract.Network{ID: "net-id", Name: "net-safescale"}
+	var deleted []string
+	deleteNetwork := func(id string) error {
+		deleted = append(deleted, id)
+		return nil
+	}
+
+	kept := &keptHosts{}
+	kept.add("host-id")
+	assert.Nil(t, teardownScanNetwork(network, kept, deleteNetwork))
+	assert.Empty(t, deleted)
+
+	assert.Nil(t, teardownScanNetwork(network, &keptHosts{}, deleteNetwork))
+	assert.Equal(t, []string{"net-id"}, deleted)
+}
+
+func TestScanWithSSHRefresh(t *testing.T) {
+	configs := []*system.SSHConfig{{Host: "10.0.0.1"}, {Host: "10.0.0.2"}}
+	fetched := 0
+	getConfig := func() (*system.SSHConfig, error) {
+		cfg := configs[fetched]
+		fetched++
+		return cfg, nil
+	}
+	var used []string
+	scan := func(ssh *system.SSHConfig) (string, error) {
+		used = append(used, ssh.Host)
+		if ssh.Host == "10.0.0.1" {
+			return "", fmt.Errorf("remote SSH not ready: error code: 255; Output []; Error [Host key verification failed.]")
+		}
+		return "scanned", nil
+	}
+
+	out, err := scanWithSSHRefresh("tiny", getConfig, scan)
+	require.Nil(t, err)
+	assert.Equal(t, "scanned", out)
+	assert.Equal(t, 2, fetched)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, used)
+}
+
+func TestScanWithSSHRefresh_RetriesOnce(t *testing.T) {
+	fetched := 0
+	getConfig := func() (*system.SSHConfig, error) {
+		fetched++
+		return &system.SSHConfig{Host: "10.0.0.1"}, nil
+	}
+	scans := 0
+	scan := func(ssh *system.SSHConfig) (string, error) {
+		scans++
+		return "", fmt.Errorf("dial tcp 10.0.0.1:22: connect: connection refused")
+	}
+	_, err := scanWithSSHRefresh("tiny", getConfig, scan)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, fetched)
+	assert.Equal(t, 2, scans)
+
+	// other errors are not retried
+	fetched, scans = 0, 0
+	scan = func(ssh *system.SSHConfig) (string, error) {
+		scans++
+		return "", fmt.Errorf("PROVISIONING ERROR: Unknown")
+	}
+	_, err = scanWithSSHRefresh("tiny", getConfig, scan)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, fetched)
+	assert.Equal(t, 1, scans)
+}
 
-	RunScanner("")
+func TestIsStaleSSHError(t *testing.T) {
+	assert.False(t, isStaleSSHError(nil))
+	assert.True(t, isStaleSSHError(fmt.Errorf("ssh connection failed: error code: 255; Error []")))
+	assert.True(t, isStaleSSHError(fmt.Errorf("WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED! Host key verification failed.")))
+	assert.False(t, isStaleSSHError(fmt.Errorf("parsing error: field 'cpu_freq' is missing")))
 }
@@ -20,6 +20,7 @@ import (
 	pb "github.com/CS-SI/SafeScale/lib"
 	propsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
 	propsv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
+	propsv3 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v3"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/clusterstate"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/identity"
 	"github.com/CS-SI/SafeScale/lib/server/iaas"
@@ -37,6 +38,10 @@ type Cluster interface {
 	GetIdentity(task concurrency.Task) identity.Identity
 	// GetNetworkConfig returns network configuration of the cluster
 	GetNetworkConfig(concurrency.Task) (propsv2.Network, error)
+	// ListNetworks returns the IDs of all the networks spanned by the cluster
+	ListNetworks(concurrency.Task) []string
+	// GetSubnets returns the networks spanned by the cluster, with their CIDR
+	GetSubnets(concurrency.Task) []propsv3.Subnet
 	// GetProperties returns the extension of the cluster
 	GetProperties(concurrency.Task) *serialize.JSONProperties
 
@@ -29,6 +29,7 @@ import (
 	"github.com/CS-SI/SafeScale/lib/client"
 	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
 	clusterpropsv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
+	clusterpropsv3 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v3"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/clusterstate"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/identity"
@@ -246,6 +247,52 @@ func (c *Controller) GetNetworkConfig(task concurrency.Task) (_ clusterpropsv2.N
 	return config, nil
 }
 
+// getNetworkConfigV3 returns the network configuration of the cluster, including the other networks it spans;
+// a cluster created before the property NetworkV3 has its configuration upgraded from the older ones
+func (c *Controller) getNetworkConfigV3(task concurrency.Task) (clusterpropsv3.Network, error) {
+	config := clusterpropsv3.Network{}
+	c.RLock(task)
+	found := c.Properties.Lookup(property.NetworkV3)
+	if found {
+		_ = c.Properties.LockForRead(property.NetworkV3).ThenUse(
+			func(clonable data.Clonable) error {
+				config = *(clonable.(*clusterpropsv3.Network))
+				return nil
+			},
+		)
+	}
+	c.RUnlock(task)
+	if found {
+		return config, nil
+	}
+
+	configV2, err := c.GetNetworkConfig(task)
+	if err != nil {
+		return config, err
+	}
+	return *clusterpropsv3.UpgradeNetworkFromV2(&configV2), nil
+}
+
+// ListNetworks returns the IDs of all the networks spanned by the Cluster
+func (c *Controller) ListNetworks(task concurrency.Task) []string {
+	config, err := c.getNetworkConfigV3(task)
+	if err != nil {
+		log.Errorf("failed to get network configuration: %v", err)
+		return nil
+	}
+	return config.NetworkIDs()
+}
+
+// GetSubnets returns the networks spanned by the Cluster, starting with the network of its gateways
+func (c *Controller) GetSubnets(task concurrency.Task) []clusterpropsv3.Subnet {
+	config, err := c.getNetworkConfigV3(task)
+	if err != nil {
+		log.Errorf("failed to get network configuration: %v", err)
+		return nil
+	}
+	return config.AllSubnets()
+}
+
 // CountNodes returns the number of nodes in the cluster
 func (c *Controller) CountNodes(task concurrency.Task) (_ uint, err error) {
 	if c == nil {
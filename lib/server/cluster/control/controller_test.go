@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	clusterpropsv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
+	clusterpropsv3 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v3"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+func TestController_ListNetworks(t *testing.T) {
+	c := &Controller{
+		Properties: serialize.NewJSONProperties("clusters"),
+		TaskedLock: concurrency.NewTaskedLock(),
+	}
+	err := c.Properties.LockForWrite(property.NetworkV3).ThenUse(
+		func(clonable data.Clonable) error {
+			networkV3 := clonable.(*clusterpropsv3.Network)
+			networkV3.NetworkID = "net-1"
+			networkV3.CIDR = "192.168.1.0/24"
+			networkV3.Subnets = []clusterpropsv3.Subnet{{NetworkID: "net-2", CIDR: "192.168.2.0/24"}}
+			return nil
+		},
+	)
+	require.Nil(t, err)
+
+	task := concurrency.RootTask()
+	assert.Equal(t, []string{"net-1", "net-2"}, c.ListNetworks(task))
+	subnets := c.GetSubnets(task)
+	require.Len(t, subnets, 2)
+	assert.Equal(t, "192.168.2.0/24", subnets[1].CIDR)
+}
+
+func TestController_ListNetworks_UpgradesV2(t *testing.T) {
+	c := &Controller{
+		Properties: serialize.NewJSONProperties("clusters"),
+		TaskedLock: concurrency.NewTaskedLock(),
+	}
+	err := c.Properties.LockForWrite(property.NetworkV2).ThenUse(
+		func(clonable data.Clonable) error {
+			networkV2 := clonable.(*clusterpropsv2.Network)
+			networkV2.NetworkID = "net-1"
+			networkV2.CIDR = "192.168.1.0/24"
+			return nil
+		},
+	)
+	require.Nil(t, err)
+
+	task := concurrency.RootTask()
+	assert.Equal(t, []string{"net-1"}, c.ListNetworks(task))
+	assert.Equal(t, []clusterpropsv3.Subnet{{NetworkID: "net-1", CIDR: "192.168.1.0/24"}}, c.GetSubnets(task))
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv3
+
+import (
+	propertiesv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+// Network replaces propertiesv2.Network, adding the other networks spanned by the cluster
+// !!! FROZEN !!!
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type Network struct {
+	NetworkID          string   `json:"network_id"`           // contains the ID of the network
+	CIDR               string   `json:"cidr"`                 // the network CIDR
+	GatewayID          string   `json:"gateway_id"`           // contains the ID of the primary gateway
+	GatewayIP          string   `json:"gateway_ip"`           // contains the private IP address of the primary gateway
+	SecondaryGatewayID string   `json:"secondary_gateway_id"` // contains the ID of the secondary gateway
+	SecondaryGatewayIP string   `json:"secondary_gateway_ip"` // contains the private IP of the secondary gateway
+	DefaultRouteIP     string   `json:"default_route_ip"`     // contains the IP of the default route
+	PrimaryPublicIP    string   `json:"primary_public_ip"`    // contains the public IP of the primary gateway
+	SecondaryPublicIP  string   `json:"secondary_public_ip"`  // contains the public IP of the secondary gateway
+	EndpointIP         string   `json:"endpoint_ip"`          // contains the IP of the external Endpoint
+	Domain             string   `json:"domain,omitempty"`     // contains the domain used to define the host FQDN at creation (taken from the network)
+	Subnets            []Subnet `json:"subnets,omitempty"`    // contains the other networks the cluster spans, if any
+}
+
+// Subnet describes an additional network spanned by the cluster
+type Subnet struct {
+	NetworkID string `json:"network_id"` // contains the ID of the network
+	CIDR      string `json:"cidr"`       // the network CIDR
+}
+
+func newNetwork() *Network {
+	return &Network{}
+}
+
+// UpgradeNetworkFromV2 returns the Network corresponding to the propertiesv2.Network 'v2', spanning no other network
+func UpgradeNetworkFromV2(v2 *propertiesv2.Network) *Network {
+	return &Network{
+		NetworkID:          v2.NetworkID,
+		CIDR:               v2.CIDR,
+		GatewayID:          v2.GatewayID,
+		GatewayIP:          v2.GatewayIP,
+		SecondaryGatewayID: v2.SecondaryGatewayID,
+		SecondaryGatewayIP: v2.SecondaryGatewayIP,
+		DefaultRouteIP:     v2.DefaultRouteIP,
+		PrimaryPublicIP:    v2.PrimaryPublicIP,
+		SecondaryPublicIP:  v2.SecondaryPublicIP,
+		EndpointIP:         v2.EndpointIP,
+		Domain:             v2.Domain,
+	}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (n *Network) Content() data.Clonable {
+	return n
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (n *Network) Clone() data.Clonable {
+	return newNetwork().Replace(n)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (n *Network) Replace(p data.Clonable) data.Clonable {
+	src := p.(*Network)
+	*n = *src
+	if src.Subnets != nil {
+		n.Subnets = make([]Subnet, len(src.Subnets))
+		copy(n.Subnets, src.Subnets)
+	}
+	return n
+}
+
+// AllSubnets returns the network of the cluster followed by the other networks it spans
+func (n *Network) AllSubnets() []Subnet {
+	list := make([]Subnet, 0, len(n.Subnets)+1)
+	if n.NetworkID != "" {
+		list = append(list, Subnet{NetworkID: n.NetworkID, CIDR: n.CIDR})
+	}
+	return append(list, n.Subnets...)
+}
+
+// NetworkIDs returns the IDs of all the networks spanned by the cluster
+func (n *Network) NetworkIDs() []string {
+	subnets := n.AllSubnets()
+	list := make([]string, 0, len(subnets))
+	for _, s := range subnets {
+		list = append(list, s.NetworkID)
+	}
+	return list
+}
+
+func init() {
+	serialize.PropertyTypeRegistry.Register("clusters", property.NetworkV3, &Network{})
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	propertiesv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
+)
+
+func TestNetwork_Clone(t *testing.T) {
+	ct := newNetwork()
+	ct.GatewayID = "None"
+	ct.Subnets = []Subnet{{NetworkID: "net-2", CIDR: "192.168.2.0/24"}}
+
+	clonedCt, ok := ct.Clone().(*Network)
+	if !ok {
+		t.Fail()
+	}
+	assert.Equal(t, ct, clonedCt)
+
+	clonedCt.GatewayID = "Other"
+	clonedCt.Subnets[0].NetworkID = "Other"
+	assert.Equal(t, "None", ct.GatewayID)
+	assert.Equal(t, "net-2", ct.Subnets[0].NetworkID)
+}
+
+func TestNetwork_AllSubnets(t *testing.T) {
+	ct := newNetwork()
+	ct.NetworkID = "net-1"
+	ct.CIDR = "192.168.1.0/24"
+	assert.Equal(t, []string{"net-1"}, ct.NetworkIDs())
+
+	ct.Subnets = []Subnet{{NetworkID: "net-2", CIDR: "192.168.2.0/24"}}
+	assert.Equal(t, []string{"net-1", "net-2"}, ct.NetworkIDs())
+	assert.Equal(
+		t, []Subnet{{NetworkID: "net-1", CIDR: "192.168.1.0/24"}, {NetworkID: "net-2", CIDR: "192.168.2.0/24"}},
+		ct.AllSubnets(),
+	)
+}
+
+func TestUpgradeNetworkFromV2(t *testing.T) {
+	v2 := &propertiesv2.Network{
+		NetworkID:          "net-1",
+		CIDR:               "192.168.1.0/24",
+		GatewayID:          "gw-1",
+		SecondaryGatewayID: "gw-2",
+		DefaultRouteIP:     "192.168.1.254",
+		Domain:             "example.org",
+	}
+	ct := UpgradeNetworkFromV2(v2)
+	assert.Equal(t, "gw-2", ct.SecondaryGatewayID)
+	assert.Equal(t, "192.168.1.254", ct.DefaultRouteIP)
+	assert.Equal(t, "example.org", ct.Domain)
+	assert.Empty(t, ct.Subnets)
+	assert.Equal(t, []string{"net-1"}, ct.NetworkIDs())
+}
@@ -40,4 +40,6 @@ const (
 	NetworkV2 = "10"
 	// ControlPlaneV1 contains optional additional info about Control Plane of the cluster
 	ControlPlaneV1 = "11"
+	// NetworkV3 contains optional additional info about network of the cluster (vip, other networks spanned)
+	NetworkV3 = "12"
 )
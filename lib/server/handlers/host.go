@@ -69,6 +69,7 @@ type HostAPI interface {
 	Resize(ctx context.Context, name string, cpu int, ram float32, disk int, gpuNumber int, freq float32) (*abstract.Host, error)
 	Start(ctx context.Context, ref string) error
 	Stop(ctx context.Context, ref string) error
+	Migrate(ctx context.Context, ref string, fromNetworkRef string, toNetworkRef string) (*abstract.Host, error)
 }
 
 // HostHandler host service
@@ -996,13 +997,15 @@ func (handler *HostHandler) getOrCreateDefaultNetwork() (network *abstract.Netwo
 		}
 	}
 	if network != nil {
+		network.SingleHost = true
 		return network, nil
 	}
 
 	request := abstract.NetworkRequest{
-		Name:      abstract.SingleHostNetworkName,
-		IPVersion: ipversion.IPv4,
-		CIDR:      "10.0.0.0/8",
+		Name:       abstract.SingleHostNetworkName,
+		IPVersion:  ipversion.IPv4,
+		CIDR:       "10.0.0.0/8",
+		SingleHost: true,
 	}
 
 	retryErr = retryOnCommunicationFailure(
@@ -1026,6 +1029,7 @@ func (handler *HostHandler) getOrCreateDefaultNetwork() (network *abstract.Netwo
 		return nil, fail.Errorf(fmt.Sprintf("failure getting or creating default network"), nil)
 	}
 
+	network.SingleHost = true
 	return network, nil
 }
 
@@ -1434,3 +1438,242 @@ func (handler *HostHandler) SSH(ctx context.Context, ref string) (sshConfig *sys
 	}
 	return sshConfig, nil
 }
+
+// Migrate moves the host referenced by 'ref' from the network 'fromNetworkRef' to the network 'toNetworkRef'
+func (handler *HostHandler) Migrate(ctx context.Context, ref string, fromNetworkRef string, toNetworkRef string) (host *abstract.Host, err error) {
+	if handler == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if ref == "" {
+		return nil, fail.InvalidParameterError("ref", "cannot be empty string")
+	}
+	if fromNetworkRef == "" {
+		return nil, fail.InvalidParameterError("fromNetworkRef", "cannot be empty string")
+	}
+	if toNetworkRef == "" {
+		return nil, fail.InvalidParameterError("toNetworkRef", "cannot be empty string")
+	}
+
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s', '%s', '%s')", ref, fromNetworkRef, toNetworkRef), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	mh, err := metadata.LoadHost(handler.service, ref)
+	if err != nil {
+		return nil, err
+	}
+	host, err = mh.Get()
+	if err != nil {
+		return nil, err
+	}
+	from, err := metadata.LoadNetwork(handler.service, fromNetworkRef)
+	if err != nil {
+		return nil, err
+	}
+	to, err := metadata.LoadNetwork(handler.service, toNetworkRef)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCanceled(ctx, "migration of host "+host.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	reconfigure := func(host *abstract.Host, fromNetwork, toNetwork *abstract.Network) (string, error) {
+		return handler.service.MigrateHost(host.ID, fromNetwork.ID, toNetwork.ID)
+	}
+	save := func(host *abstract.Host) error {
+		_, err := metadata.SaveHost(handler.service, host)
+		return err
+	}
+	err = migrateHost(host, from, to, reconfigure, save)
+	if err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+// migrateHost does the real work of Migrate: it moves 'host' from the hosts of 'from' to the hosts of 'to', uses
+// 'reconfigure' to move the interface of the host on provider side and 'save' to write its metadata.
+// The indexes of both networks and the NetworkV1 property of the host are restored if something fails; if the
+// interface was already moved, it is moved back to 'from' with 'reconfigure'.
+func migrateHost(
+	host *abstract.Host, from, to *metadata.Network,
+	reconfigure func(*abstract.Host, *abstract.Network, *abstract.Network) (string, error),
+	save func(*abstract.Host) error,
+) (err error) {
+	fromNetwork, err := from.Get()
+	if err != nil {
+		return err
+	}
+	toNetwork, err := to.Get()
+	if err != nil {
+		return err
+	}
+	if fromNetwork.ID == toNetwork.ID {
+		return fail.InvalidRequestError(fmt.Sprintf("host '%s' is already in network '%s'", host.Name, toNetwork.Name))
+	}
+
+	var previous *propsv1.HostNetwork
+	err = host.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+			if hostNetworkV1.IsGateway {
+				return fail.InvalidRequestError(fmt.Sprintf("host '%s' is a gateway and cannot be migrated", host.Name))
+			}
+			if _, ok := hostNetworkV1.NetworksByID[fromNetwork.ID]; !ok {
+				return fail.InvalidRequestError(
+					fmt.Sprintf("host '%s' is not in network '%s'", host.Name, fromNetwork.Name),
+				)
+			}
+			if _, ok := hostNetworkV1.NetworksByID[toNetwork.ID]; ok {
+				return fail.InvalidRequestError(
+					fmt.Sprintf("host '%s' is already in network '%s'", host.Name, toNetwork.Name),
+				)
+			}
+			previous = clonable.Clone().(*propsv1.HostNetwork)
+			return nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = from.DetachHost(host.ID)
+	if err != nil {
+		return err
+	}
+	moved, written := false, false
+	defer func() {
+		if err != nil {
+			if moved {
+				ip, derr := reconfigure(host, toNetwork, fromNetwork)
+				if derr != nil {
+					err = fail.AddConsequence(
+						err, fail.Wrap(
+							derr, fmt.Sprintf("failed to move back host '%s' to network '%s'", host.Name, fromNetwork.Name),
+						),
+					)
+				} else if ip != "" {
+					previous.IPv4Addresses[fromNetwork.ID] = ip
+				}
+			}
+			derr := rollbackHostMigration(host, from, to, previous, written)
+			if derr != nil {
+				err = fail.AddConsequence(err, derr)
+			}
+		}
+	}()
+	err = to.AttachHost(host, false)
+	if err != nil {
+		return err
+	}
+
+	ip, err := reconfigure(host, fromNetwork, toNetwork)
+	if err != nil {
+		return err
+	}
+	moved = true
+	gatewayIP, err := getNetworkGatewayIP(toNetwork)
+	if err != nil {
+		return err
+	}
+	err = host.Properties.LockForWrite(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+			delete(hostNetworkV1.NetworksByName, fromNetwork.Name)
+			delete(hostNetworkV1.NetworksByID, fromNetwork.ID)
+			delete(hostNetworkV1.IPv4Addresses, fromNetwork.ID)
+			delete(hostNetworkV1.IPv6Addresses, fromNetwork.ID)
+			hostNetworkV1.NetworksByID[toNetwork.ID] = toNetwork.Name
+			hostNetworkV1.NetworksByName[toNetwork.Name] = toNetwork.ID
+			if ip != "" {
+				hostNetworkV1.IPv4Addresses[toNetwork.ID] = ip
+			}
+			if hostNetworkV1.DefaultNetworkID == fromNetwork.ID {
+				hostNetworkV1.DefaultNetworkID = toNetwork.ID
+				hostNetworkV1.DefaultGatewayID = toNetwork.GatewayID
+				hostNetworkV1.DefaultGatewayPrivateIP = gatewayIP
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if ip != "" {
+		err = to.ReserveIP(ip, host.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	written = true
+	err = from.Write()
+	if err != nil {
+		return err
+	}
+	err = to.Write()
+	if err != nil {
+		return err
+	}
+	return save(host)
+}
+
+// rollbackHostMigration restores the NetworkV1 property of 'host' from 'previous' and the host indexes of the
+// networks 'from' and 'to' as they were before the migration; if 'written' is true, the networks are saved again
+func rollbackHostMigration(host *abstract.Host, from, to *metadata.Network, previous *propsv1.HostNetwork, written bool) error {
+	var errs []error
+	err := host.Properties.LockForWrite(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			clonable.(*propsv1.HostNetwork).Replace(previous)
+			return nil
+		},
+	)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	err = to.DetachHost(host.ID)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	err = from.AttachHost(host, false)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if written {
+		err = from.Write()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		err = to.Write()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fail.ErrListError(errs)
+	}
+	return nil
+}
+
+// getNetworkGatewayIP returns the private IP used as default gateway by the hosts of 'network'
+func getNetworkGatewayIP(network *abstract.Network) (string, error) {
+	if network.VIP != nil {
+		return network.VIP.PrivateIP, nil
+	}
+	ip := ""
+	err := network.Properties.LockForRead(networkproperty.IPsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			for k, v := range clonable.(*propsv1.NetworkIPs).ByIP {
+				if v == network.GatewayID {
+					ip = k
+					break
+				}
+			}
+			return nil
+		},
+	)
+	return ip, err
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hostproperty"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/networkproperty"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/metadata"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+// migrationFixture saves the networks 'src' and 'dst' and a host attached to 'src'
+func migrationFixture(t *testing.T, svc *memoryService) (*abstract.Host, *metadata.Network, *metadata.Network) {
+	src := abstract.NewNetwork()
+	src.ID = "src-id"
+	src.Name = "src"
+	src.CIDR = "192.168.1.0/24"
+	from, err := metadata.SaveNetwork(svc, src)
+	require.Nil(t, err)
+
+	dst := abstract.NewNetwork()
+	dst.ID = "dst-id"
+	dst.Name = "dst"
+	dst.CIDR = "192.168.2.0/24"
+	dst.GatewayID = "dst-gw-id"
+	to, err := metadata.SaveNetwork(svc, dst)
+	require.Nil(t, err)
+	require.Nil(t, to.ReserveIP("192.168.2.1", "dst-gw-id"))
+
+	host := abstract.NewHost()
+	host.ID = "host-id"
+	host.Name = "host"
+	err = host.Properties.LockForWrite(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+			hostNetworkV1.DefaultNetworkID = "src-id"
+			hostNetworkV1.NetworksByID["src-id"] = "src"
+			hostNetworkV1.NetworksByName["src"] = "src-id"
+			hostNetworkV1.IPv4Addresses["src-id"] = "192.168.1.10"
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	_, err = metadata.SaveHost(svc, host)
+	require.Nil(t, err)
+	require.Nil(t, from.AttachHost(host, true))
+	return host, from, to
+}
+
+// networkHostIndex returns the hosts indexed by ID and the reserved IPs of the network
+func networkHostIndex(t *testing.T, mn *metadata.Network) (map[string]string, map[string]string) {
+	network, err := mn.Get()
+	require.Nil(t, err)
+	hosts := map[string]string{}
+	ips := map[string]string{}
+	err = network.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			for k, v := range clonable.(*propsv1.NetworkHosts).ByID {
+				hosts[k] = v
+			}
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	err = network.Properties.LockForRead(networkproperty.IPsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			for k, v := range clonable.(*propsv1.NetworkIPs).ByIP {
+				ips[k] = v
+			}
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	return hosts, ips
+}
+
+func TestMigrateHost(t *testing.T) {
+	svc := newMemoryService()
+	host, from, to := migrationFixture(t, svc)
+
+	reconfigure := func(host *abstract.Host, fromNetwork, toNetwork *abstract.Network) (string, error) {
+		assert.Equal(t, "src-id", fromNetwork.ID)
+		assert.Equal(t, "dst-id", toNetwork.ID)
+		return "192.168.2.10", nil
+	}
+	save := func(host *abstract.Host) error {
+		_, err := metadata.SaveHost(svc, host)
+		return err
+	}
+	err := migrateHost(host, from, to, reconfigure, save)
+	require.Nil(t, err)
+
+	from, err = metadata.LoadNetwork(svc, "src")
+	require.Nil(t, err)
+	hosts, ips := networkHostIndex(t, from)
+	assert.Empty(t, hosts)
+	assert.Empty(t, ips)
+
+	to, err = metadata.LoadNetwork(svc, "dst")
+	require.Nil(t, err)
+	hosts, ips = networkHostIndex(t, to)
+	assert.Equal(t, map[string]string{"host-id": "host"}, hosts)
+	assert.Equal(t, map[string]string{"192.168.2.1": "dst-gw-id", "192.168.2.10": "host-id"}, ips)
+
+	mh, err := metadata.LoadHost(svc, "host-id")
+	require.Nil(t, err)
+	host, err = mh.Get()
+	require.Nil(t, err)
+	err = host.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+			assert.Equal(t, map[string]string{"dst-id": "dst"}, hostNetworkV1.NetworksByID)
+			assert.Equal(t, map[string]string{"dst": "dst-id"}, hostNetworkV1.NetworksByName)
+			assert.Equal(t, map[string]string{"dst-id": "192.168.2.10"}, hostNetworkV1.IPv4Addresses)
+			assert.Equal(t, "dst-id", hostNetworkV1.DefaultNetworkID)
+			assert.Equal(t, "dst-gw-id", hostNetworkV1.DefaultGatewayID)
+			assert.Equal(t, "192.168.2.1", hostNetworkV1.DefaultGatewayPrivateIP)
+			return nil
+		},
+	)
+	require.Nil(t, err)
+}
+
+func TestMigrateHost_RollbackOnProviderFailure(t *testing.T) {
+	svc := newMemoryService()
+	host, from, to := migrationFixture(t, svc)
+
+	reconfigure := func(host *abstract.Host, fromNetwork, toNetwork *abstract.Network) (string, error) {
+		return "", fail.Errorf("interface cannot be moved", nil)
+	}
+	save := func(host *abstract.Host) error {
+		t.Fatal("host must not be saved when the provider fails")
+		return nil
+	}
+	err := migrateHost(host, from, to, reconfigure, save)
+	require.NotNil(t, err)
+
+	hosts, ips := networkHostIndex(t, from)
+	assert.Equal(t, map[string]string{"host-id": "host"}, hosts)
+	assert.Equal(t, map[string]string{"192.168.1.10": "host-id"}, ips)
+	hosts, ips = networkHostIndex(t, to)
+	assert.Empty(t, hosts)
+	assert.Equal(t, map[string]string{"192.168.2.1": "dst-gw-id"}, ips)
+
+	err = host.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+			assert.Equal(t, map[string]string{"src-id": "src"}, hostNetworkV1.NetworksByID)
+			assert.Equal(t, "src-id", hostNetworkV1.DefaultNetworkID)
+			return nil
+		},
+	)
+	require.Nil(t, err)
+}
+
+// migrationService is a memoryService moving the interfaces of the hosts between networks like a provider does
+type migrationService struct {
+	*memoryService
+	// unsupported makes the service behave as a provider not able to migrate hosts
+	unsupported bool
+	// interfaces contains the ID of the network the interface of each host is in
+	interfaces map[string]string
+	// ips contains the private IP given to a host moved in each network
+	ips   map[string]string
+	moves []string
+}
+
+func newMigrationService() *migrationService {
+	return &migrationService{
+		memoryService: newMemoryService(),
+		interfaces:    map[string]string{"host-id": "src-id"},
+		ips:           map[string]string{"src-id": "192.168.1.20", "dst-id": "192.168.2.10"},
+	}
+}
+
+func (s *migrationService) MigrateHost(hostID, fromNetworkID, toNetworkID string) (string, error) {
+	if s.unsupported {
+		return "", fail.NotImplementedError("migration of host between networks")
+	}
+	if s.interfaces[hostID] != fromNetworkID {
+		return "", fail.NotFoundError(fmt.Sprintf("host '%s' has no interface in network '%s'", hostID, fromNetworkID))
+	}
+	s.interfaces[hostID] = toNetworkID
+	s.moves = append(s.moves, fromNetworkID+">"+toNetworkID)
+	return s.ips[toNetworkID], nil
+}
+
+func TestHostHandler_Migrate(t *testing.T) {
+	svc := newMigrationService()
+	migrationFixture(t, svc.memoryService)
+
+	host, err := NewHostHandler(svc).Migrate(context.Background(), "host", "src", "dst")
+	require.Nil(t, err)
+	assert.Equal(t, "host-id", host.ID)
+	assert.Equal(t, "dst-id", svc.interfaces["host-id"])
+	assert.Equal(t, []string{"src-id>dst-id"}, svc.moves)
+
+	from, err := metadata.LoadNetwork(svc, "src")
+	require.Nil(t, err)
+	hosts, ips := networkHostIndex(t, from)
+	assert.Empty(t, hosts)
+	assert.Empty(t, ips)
+	to, err := metadata.LoadNetwork(svc, "dst")
+	require.Nil(t, err)
+	hosts, ips = networkHostIndex(t, to)
+	assert.Equal(t, map[string]string{"host-id": "host"}, hosts)
+	assert.Equal(t, map[string]string{"192.168.2.1": "dst-gw-id", "192.168.2.10": "host-id"}, ips)
+}
+
+func TestHostHandler_Migrate_RollbackOnMetadataFailure(t *testing.T) {
+	svc := newMigrationService()
+	migrationFixture(t, svc.memoryService)
+	writeErr := fail.Errorf("write refused", nil)
+	svc.bucket.WriteError = func(name string) error {
+		if strings.Contains(name, "hosts/") {
+			return writeErr
+		}
+		return nil
+	}
+
+	_, err := NewHostHandler(svc).Migrate(context.Background(), "host", "src", "dst")
+	require.NotNil(t, err)
+
+	// the interface is moved back to the origin network, where it gets a new IP
+	assert.Equal(t, "src-id", svc.interfaces["host-id"])
+	assert.Equal(t, []string{"src-id>dst-id", "dst-id>src-id"}, svc.moves)
+
+	from, err := metadata.LoadNetwork(svc, "src")
+	require.Nil(t, err)
+	hosts, ips := networkHostIndex(t, from)
+	assert.Equal(t, map[string]string{"host-id": "host"}, hosts)
+	assert.Equal(t, map[string]string{"192.168.1.20": "host-id"}, ips)
+	to, err := metadata.LoadNetwork(svc, "dst")
+	require.Nil(t, err)
+	hosts, ips = networkHostIndex(t, to)
+	assert.Empty(t, hosts)
+	assert.Equal(t, map[string]string{"192.168.2.1": "dst-gw-id"}, ips)
+}
+
+func TestHostHandler_Migrate_NotSupported(t *testing.T) {
+	svc := newMigrationService()
+	svc.unsupported = true
+	migrationFixture(t, svc.memoryService)
+
+	_, err := NewHostHandler(svc).Migrate(context.Background(), "host", "src", "dst")
+	assert.IsType(t, fail.ErrNotImplemented{}, err)
+	assert.Empty(t, svc.moves)
+
+	from, err := metadata.LoadNetwork(svc, "src")
+	require.Nil(t, err)
+	hosts, _ := networkHostIndex(t, from)
+	assert.Equal(t, map[string]string{"host-id": "host"}, hosts)
+}
@@ -19,9 +19,13 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/CS-SI/SafeScale/lib/utils/debug"
 
@@ -39,12 +43,17 @@ import (
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/networkproperty"
 	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/userdata"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/providers"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks/openstack"
 	"github.com/CS-SI/SafeScale/lib/server/install"
 	"github.com/CS-SI/SafeScale/lib/server/metadata"
 	safescaleutils "github.com/CS-SI/SafeScale/lib/server/utils"
 	"github.com/CS-SI/SafeScale/lib/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/cidr"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/runphase"
+	"github.com/CS-SI/SafeScale/lib/utils/commonlog"
 	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
@@ -58,24 +67,196 @@ import (
 
 // NetworkAPI defines API to manage networks
 type NetworkAPI interface {
-	Create(context.Context, string, string, ipversion.Enum, abstract.SizingRequirements, string, string, bool, string, bool) (*abstract.Network, error)
+	Create(context.Context, string, string, ipversion.Enum, abstract.SizingRequirements, string, string, bool, string, bool, []int, int, int) (*abstract.Network, error)
+	CreateWithOptions(context.Context, string, string, ipversion.Enum, abstract.SizingRequirements, NetworkCreateOptions) (*abstract.Network, error)
+	AttachGateway(context.Context, string, string) (*abstract.Network, error)
 	List(context.Context, bool) ([]*abstract.Network, error)
 	Inspect(context.Context, string) (*abstract.Network, error)
+	WaitGatewaySSHReady(context.Context, string, bool, time.Duration) (*abstract.Host, error)
+	GetActiveGateway(context.Context, string) (*abstract.Host, error)
 	Delete(context.Context, string) error
+	DeleteWithReport(context.Context, string) (*NetworkTeardownReport, error)
 	Destroy(context.Context, string) error
+	RerunGatewayPhase(context.Context, string, string, string) error
 }
 
 // NetworkHandler an implementation of NetworkAPI
 type NetworkHandler struct {
-	service   iaas.Service
-	ipVersion ipversion.Enum
+	service     iaas.Service
+	ipVersion   ipversion.Enum
+	retryPolicy retry.Policy
+	// onCreationMetrics, if set, receives the metrics of each creation of network
+	onCreationMetrics func(NetworkCreationMetrics)
+	// imageMatch is the strategy used to find the image of the gateways
+	imageMatch iaas.ImageMatchStrategy
+	// cidrSupernet and cidrPrefixLen describe the subnets allocated to the networks created without CIDR
+	cidrSupernet  string
+	cidrPrefixLen int
+	// templateSelection chooses the template of the gateways among the ones satisfying the sizing
+	templateSelection TemplateSelectionPolicy
 }
 
+const (
+	// defaultCIDRSupernet is the supernet in which the CIDR of a network created without CIDR is allocated
+	defaultCIDRSupernet = "192.168.0.0/16"
+	// defaultCIDRPrefixLen is the prefix length of the CIDR allocated to a network created without CIDR
+	defaultCIDRPrefixLen = 24
+)
+
 // NewNetworkHandler Creates new Network service
 func NewNetworkHandler(svc iaas.Service) NetworkAPI {
 	return &NetworkHandler{
-		service: svc,
+		service:       svc,
+		retryPolicy:   retry.DefaultPolicy(),
+		cidrSupernet:  defaultCIDRSupernet,
+		cidrPrefixLen: defaultCIDRPrefixLen,
+	}
+}
+
+// OnCreationMetrics sets the function receiving the metrics of each creation of network, successful or not
+func (handler *NetworkHandler) OnCreationMetrics(fn func(NetworkCreationMetrics)) *NetworkHandler {
+	handler.onCreationMetrics = fn
+	return handler
+}
+
+// WithImageMatchStrategy sets the strategy used to find the image of the gateways from its name;
+// iaas.ImageMatchBest (the default) keeps the behavior of SearchImage
+func (handler *NetworkHandler) WithImageMatchStrategy(strategy iaas.ImageMatchStrategy) *NetworkHandler {
+	handler.imageMatch = strategy
+	return handler
+}
+
+// WithCIDRSupernet sets the supernet in which a subnet of length 'prefixLen' is allocated to each network
+// created without CIDR
+func (handler *NetworkHandler) WithCIDRSupernet(supernet string, prefixLen int) *NetworkHandler {
+	handler.cidrSupernet = supernet
+	handler.cidrPrefixLen = prefixLen
+	return handler
+}
+
+// WithTemplateSelection sets the policy choosing the template of the gateways among the ones satisfying the
+// requested sizing (SelectSmallestTemplate by default)
+func (handler *NetworkHandler) WithTemplateSelection(policy TemplateSelectionPolicy) *NetworkHandler {
+	handler.templateSelection = policy
+	return handler
+}
+
+// NetworkCreateOptions contains the options of the creation of a network
+type NetworkCreateOptions struct {
+	// OS is the operating system of the gateways; the default image of the tenant if empty
+	OS string
+	// GatewayName is the name of the gateway; "<PrimaryGatewayPrefix><network name>" if empty.
+	// Cannot be set with Failover
+	GatewayName string
+	// PrimaryGatewayPrefix is prepended to the name of the network to name the primary gateway; "gw-" if empty
+	PrimaryGatewayPrefix string
+	// SecondaryGatewayPrefix is prepended to the name of the network to name the secondary gateway; "gw2-" if empty
+	SecondaryGatewayPrefix string
+	// Failover tells to create 2 gateways sharing a VIP, if the provider supports it
+	Failover bool
+	// GatewayCount is the number of gateways to create; more than 1 implies Failover, all the gateways sharing the
+	// VIP. 0 means 1 gateway, or 2 with Failover (use NoGateway to create none)
+	GatewayCount int
+	// Domain is the domain of the FQDN of the hosts of the network
+	Domain string
+	// KeepOnFailure tells to keep the resources created if the creation fails, for inspection
+	KeepOnFailure bool
+	// SkipFinalization stops the creation once the gateways are created and recorded, without waiting for them
+	// to be reachable nor configuring them
+	SkipFinalization bool
+	// NoGateway tells to create the network without gateway
+	NoGateway bool
+	// AdditionalIngressPorts contains the TCP ports to open on the gateways in addition to SSH
+	AdditionalIngressPorts []int
+	// MTU is the MTU of the network; 0 means the default of the provider
+	MTU int
+	// ExpectedHostCount is the number of hosts expected in the network, gateways excluded; 0 means unknown
+	ExpectedHostCount int
+	// GatewayUserdataTemplate is a custom template of the script configuring the gateways (phase 2), used instead
+	// of the built-in one; it must contain the markers required by userdata.ValidateTemplate
+	GatewayUserdataTemplate string
+	// GatewayUserdataTemplateFile is the path of a file containing the custom template of the script configuring
+	// the gateways; cannot be set with GatewayUserdataTemplate
+	GatewayUserdataTemplateFile string
+	// AllowRoutableCIDR allows a CIDR considered as routable (see utils.IsCIDRRoutable), for deployments in private
+	// ranges not recognized as such (for instance RFC6598 100.64.0.0/10); the CIDR must still be valid
+	AllowRoutableCIDR bool
+}
+
+// gatewayUserdataTemplate returns the custom template of the script configuring the gateways, read from
+// GatewayUserdataTemplateFile if set, after verifying it can be used; returns "" to use the built-in template
+func (o NetworkCreateOptions) gatewayUserdataTemplate() (string, error) {
+	tmpl := o.GatewayUserdataTemplate
+	if o.GatewayUserdataTemplateFile != "" {
+		content, err := ioutil.ReadFile(o.GatewayUserdataTemplateFile)
+		if err != nil {
+			return "", fail.InvalidParameterError(
+				"opts", fmt.Sprintf("failed to read gateway userdata template file: %v", err),
+			)
+		}
+		tmpl = string(content)
+	}
+	if tmpl == "" {
+		return "", nil
+	}
+	err := userdata.ValidateTemplate("phase2", tmpl)
+	if err != nil {
+		return "", fail.InvalidParameterError("opts", err.Error())
+	}
+	return tmpl, nil
+}
+
+// gatewayPrefixes returns the prefixes of the names of the primary and secondary gateways
+func (o NetworkCreateOptions) gatewayPrefixes() (string, string) {
+	primary, secondary := o.PrimaryGatewayPrefix, o.SecondaryGatewayPrefix
+	if primary == "" {
+		primary = "gw-"
+	}
+	if secondary == "" {
+		secondary = "gw2-"
+	}
+	return primary, secondary
+}
+
+// gatewayCount returns the number of gateways to create
+func (o NetworkCreateOptions) gatewayCount() int {
+	switch {
+	case o.NoGateway:
+		return 0
+	case o.GatewayCount > 0:
+		return o.GatewayCount
+	case o.Failover:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// validate checks the options are consistent
+func (o NetworkCreateOptions) validate() error {
+	if o.Failover && o.GatewayName != "" {
+		return fail.InvalidParameterError("gwname", "cannot be set if failover is set")
 	}
+	if o.NoGateway && (o.Failover || o.GatewayName != "" || o.GatewayCount != 0) {
+		return fail.InvalidParameterError("opts", "cannot set gateway name, gateway count nor failover without gateway")
+	}
+	if o.GatewayCount < 0 {
+		return fail.InvalidParameterError("GatewayCount", "cannot be negative")
+	}
+	if o.GatewayCount == 1 && o.Failover {
+		return fail.InvalidParameterError("GatewayCount", "must be at least 2 with failover")
+	}
+	if o.GatewayCount > 1 && o.GatewayName != "" {
+		return fail.InvalidParameterError("gwname", "cannot be set with more than one gateway")
+	}
+	if o.GatewayUserdataTemplate != "" && o.GatewayUserdataTemplateFile != "" {
+		return fail.InvalidParameterError("opts", "cannot set both gateway userdata template and template file")
+	}
+	primary, secondary := o.gatewayPrefixes()
+	if o.Failover && primary == secondary {
+		return fail.InvalidParameterError("opts", "primary and secondary gateway prefixes must differ")
+	}
+	return validateIngressPorts(o.AdditionalIngressPorts)
 }
 
 // Create creates a network
@@ -83,7 +264,26 @@ func (handler *NetworkHandler) Create(
 	ctx context.Context,
 	name string, cidr string, ipVersion ipversion.Enum,
 	sizing abstract.SizingRequirements, theos string, gwname string,
-	failover bool, domain string, keeponfailure bool,
+	failover bool, domain string, keeponfailure bool, additionalIngressPorts []int, mtu int, expectedHostCount int,
+) (*abstract.Network, error) {
+	return handler.CreateWithOptions(
+		ctx, name, cidr, ipVersion, sizing, NetworkCreateOptions{
+			OS:                     theos,
+			GatewayName:            gwname,
+			Failover:               failover,
+			Domain:                 domain,
+			KeepOnFailure:          keeponfailure,
+			AdditionalIngressPorts: additionalIngressPorts,
+			MTU:                    mtu,
+			ExpectedHostCount:      expectedHostCount,
+		},
+	)
+}
+
+// CreateWithOptions creates a network as described by 'opts'
+func (handler *NetworkHandler) CreateWithOptions(
+	ctx context.Context,
+	name string, cidr string, ipVersion ipversion.Enum, sizing abstract.SizingRequirements, opts NetworkCreateOptions,
 ) (network *abstract.Network, err error) {
 	if handler == nil {
 		return nil, fail.InvalidInstanceError()
@@ -91,8 +291,29 @@ func (handler *NetworkHandler) Create(
 	if name == "" {
 		return nil, fail.InvalidParameterError("name", "cannot be nil")
 	}
-	if failover && gwname != "" {
-		return nil, fail.InvalidParameterError("gwname", "cannot be set if failover is set")
+	err = opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	gatewayTemplate, err := opts.gatewayUserdataTemplate()
+	if err != nil {
+		return nil, err
+	}
+	theos, gwname, failover, domain := opts.OS, opts.GatewayName, opts.Failover, opts.Domain
+	keeponfailure, additionalIngressPorts := opts.KeepOnFailure, opts.AdditionalIngressPorts
+	mtu, expectedHostCount := opts.MTU, opts.ExpectedHostCount
+	gatewayCount := opts.gatewayCount()
+	if gatewayCount > 1 {
+		failover = true
+	}
+	err = validateMTU(mtu)
+	if err != nil {
+		return nil, err
+	}
+	domain = normalizeDomain(domain)
+	err = validateDomain(domain)
+	if err != nil {
+		return nil, err
 	}
 
 	tracer := debug.NewTracer(
@@ -100,21 +321,34 @@ func (handler *NetworkHandler) Create(
 		fmt.Sprintf(
 			"('%s', '%s', %s, <sizing>, '%s', '%s', %v)", name, cidr, ipVersion.String(), theos, gwname, failover,
 		),
-		true,
+		debug.ShouldTrace("handlers.network"),
 	).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
-	// Verify that the network doesn't exist first and manage by SafeScale
-	_, err = metadata.LoadNetwork(handler.service, name)
-	if err != nil {
-		switch err.(type) {
-		case fail.ErrNotFound:
-			// so continue
-		default:
-			return nil, err
+	creationLog := newNetworkCreationLog(name, debug.ShouldTrace("handlers.network"))
+	defer func() {
+		creationLog.done(err)
+		if handler.onCreationMetrics != nil {
+			handler.onCreationMetrics(creationLog.metrics())
 		}
-	} else {
+	}()
+	creationLog.transition(networkStateCreation)
+
+	// Fails fast if the provider cannot honor the request
+	caps := handler.service.GetCapabilities()
+	err = checkNetworkCapabilities(caps, ipVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify that the network doesn't exist first and manage by SafeScale; a name known by SafeScale spares the
+	// request to the provider
+	exists, err := metadata.NetworkExists(handler.service, name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
 		return nil, fail.DuplicateError(fmt.Sprintf("network '%s' already exist", name))
 	}
 
@@ -132,23 +366,44 @@ func (handler *NetworkHandler) Create(
 		return nil, fail.DuplicateError(fmt.Sprintf("network '%s' already exists (outside SafeScale scope)", name))
 	}
 
-	// Verify the CIDR is not routable
-	routable, err := utils.IsCIDRRoutable(cidr)
+	if cidr == "" {
+		cidr, err = AllocateCIDR(nil, handler.service, handler.cidrSupernet, handler.cidrPrefixLen)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Debugf("Allocated CIDR '%s' to network '%s'", cidr, name)
+	}
+	err = checkNetworkCIDR(cidr, opts.AllowRoutableCIDR)
 	if err != nil {
-		return nil, fmt.Errorf("failed to determine if CIDR is not routable: %v", err)
+		return nil, err
 	}
-	if routable {
-		return nil, fmt.Errorf("cannot create such a network, CIDR must be not routable; please provide an appropriate CIDR (RFC1918)")
+	err = checkNetworkHostCapacity(cidr, expectedHostCount, failover)
+	if err != nil {
+		return nil, err
+	}
+
+	// Records the effective parameters of the creation, defaults included, to be able to reproduce it
+	spec := newNetworkCreationSpec(cidr, ipVersion, sizing, opts)
+	spec.Domain = domain
+	spec.GatewayTemplate = gatewayTemplate
+
+	networkMTU := mtu
+	if mtu != 0 && !caps.NetworkMTU {
+		logrus.Warnf("provider cannot set the MTU of network '%s', only gateway interfaces will use MTU %d", name, mtu)
+		networkMTU = 0
 	}
 
 	// Create the network
 	logrus.Debugf("Creating network '%s' ...", name)
 	network, err = handler.service.CreateNetwork(
 		abstract.NetworkRequest{
-			Name:      name,
-			IPVersion: ipVersion,
-			CIDR:      cidr,
-			Domain:    domain,
+			Name:                   name,
+			IPVersion:              ipVersion,
+			CIDR:                   cidr,
+			Domain:                 domain,
+			AdditionalIngressPorts: additionalIngressPorts,
+			MTU:                    networkMTU,
+			ExpectedHostCount:      expectedHostCount,
 		},
 	)
 	if err != nil {
@@ -187,13 +442,19 @@ func (handler *NetworkHandler) Create(
 		}
 	}()
 
-	caps := handler.service.GetCapabilities()
+	err = checkCanceled(ctx, "creation of network "+name)
+	if err != nil {
+		return nil, err
+	}
+
 	if failover && caps.PrivateVirtualIP {
 		logrus.Infof("Provider support private Virtual IP, honoring the failover setup for gateways.")
 	} else if failover && !caps.PrivateVirtualIP {
 		logrus.Warningf("Provider doesn't support private Virtual IP, cannot set up high availability of network default route.")
 		failover = false
+		gatewayCount = 1
 	}
+	spec.HA, spec.GatewayCount = failover, gatewayCount
 
 	// Creates VIP for gateways if asked for
 	if failover {
@@ -228,6 +489,29 @@ func (handler *NetworkHandler) Create(
 		}()
 	}
 
+	err = checkCanceled(ctx, "creation of network "+name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = handler.createDefaultSecurityGroup(network)
+	if err != nil {
+		return nil, err
+	}
+	if network.DefaultSecurityGroupID != "" {
+		// Starting from here, delete the default security group if exiting with error
+		defer func() {
+			if err != nil && !keeponfailure {
+				derr := handler.service.DeleteSecurityGroup(network.DefaultSecurityGroupID)
+				if derr != nil {
+					logrus.Errorf("failed to delete default security group: %+v", derr)
+					err = fail.AddConsequence(err, derr)
+				}
+			}
+		}()
+	}
+
+	creationLog.transition(networkStateMetadataUpdate)
 	logrus.Debugf("Saving network metadata '%s' ...", network.Name)
 	mn, err := metadata.SaveNetwork(handler.service, network)
 	if err != nil {
@@ -252,6 +536,16 @@ func (handler *NetworkHandler) Create(
 		}
 	}()
 
+	if opts.NoGateway {
+		err = recordCreationSpec(mn, spec)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Infof("Network '%s' created without gateway", network.Name)
+		return network, nil
+	}
+
+	creationLog.transition(networkStateGatewayCreation)
 	var template *abstract.HostTemplate
 	tpls, err := handler.service.SelectTemplatesBySize(sizing, false)
 	if err != nil {
@@ -264,7 +558,7 @@ func (handler *NetworkHandler) Create(
 		}
 	}
 	if len(tpls) > 0 {
-		template = tpls[0]
+		template = selectTemplate(tpls, handler.templateSelection)
 		msg := fmt.Sprintf(
 			"Selected host template: '%s' (%d core%s", template.Name, template.Cores, utils.Plural(template.Cores),
 		)
@@ -283,7 +577,7 @@ func (handler *NetworkHandler) Create(
 	} else {
 		return nil, fmt.Errorf("error creating network: no host template matching requirements for gateway")
 	}
-	img, err := handler.service.SearchImage(theos)
+	img, err := handler.searchGatewayImage(theos, sizing.Arch, ipVersion)
 	if err != nil {
 		switch err.(type) {
 		case fail.ErrNotFound, fail.ErrTimeout:
@@ -292,21 +586,17 @@ func (handler *NetworkHandler) Create(
 			return nil, err
 		}
 	}
-
-	var primaryGatewayName, secondaryGatewayName string
-	if failover || gwname == "" {
-		primaryGatewayName = "gw-" + network.Name
-	} else {
-		primaryGatewayName = gwname
-	}
-	if failover {
-		secondaryGatewayName = "gw2-" + network.Name
+	err = validateGatewayImage(img, template, sizing.Arch)
+	if err != nil {
+		return nil, err
 	}
+	spec.TemplateID, spec.TemplateName = template.ID, template.Name
+	spec.ImageID, spec.ImageName = img.ID, img.Name
 
-	domain = strings.Trim(domain, ".")
-	if domain != "" {
-		domain = "." + domain
-	}
+	primaryPrefix, secondaryPrefix := opts.gatewayPrefixes()
+	primaryGatewayName, secondaryGatewayName := gatewayFQDNs(
+		network.Name, gwname, primaryPrefix, secondaryPrefix, failover, domain,
+	)
 
 	gwRequest := abstract.GatewayRequest{
 		ImageID: img.ID,
@@ -315,6 +605,7 @@ func (handler *NetworkHandler) Create(
 		OriginalOsRequest: theos,
 		TemplateID:        template.ID,
 		CIDR:              network.CIDR,
+		KeepOnFailure:     keeponfailure,
 	}
 
 	var (
@@ -328,7 +619,7 @@ func (handler *NetworkHandler) Create(
 
 	// Starts primary gateway creation
 	primaryRequest := gwRequest
-	primaryRequest.Name = primaryGatewayName + domain
+	primaryRequest.Name = primaryGatewayName
 	primaryRequest.KeyPair, err = abstract.NewKeyPair(primaryRequest.Name)
 	if err != nil {
 		return nil, err
@@ -342,7 +633,6 @@ func (handler *NetworkHandler) Create(
 			"request": primaryRequest,
 			"sizing":  sizing,
 			"primary": true,
-			"nokeep":  !keeponfailure,
 		},
 	)
 	if err != nil {
@@ -352,7 +642,7 @@ func (handler *NetworkHandler) Create(
 	// Starts secondary gateway creation if asked for
 	if failover {
 		secondaryRequest := gwRequest
-		secondaryRequest.Name = secondaryGatewayName + domain
+		secondaryRequest.Name = secondaryGatewayName
 		secondaryRequest.KeyPair, err = abstract.NewKeyPair(secondaryRequest.Name)
 		if err != nil {
 			return nil, err
@@ -366,7 +656,6 @@ func (handler *NetworkHandler) Create(
 				"request": secondaryRequest,
 				"sizing":  sizing,
 				"primary": false,
-				"nokeep":  !keeponfailure,
 			},
 		)
 		if err != nil {
@@ -378,19 +667,19 @@ func (handler *NetworkHandler) Create(
 	if primaryErr == nil {
 		primaryGateway = primaryResult.(data.Map)["host"].(*abstract.Host)
 		primaryUserdata = primaryResult.(data.Map)["userdata"].(*userdata.Content)
-		if domain != "" {
-			primaryUserdata.HostName = primaryGatewayName + domain
-		}
+		primaryUserdata.HostName = primaryGatewayName
 		primaryMetadata = primaryResult.(data.Map)["metadata"].(*metadata.Gateway)
 
 		// Starting from here, deletes the primary gateway if exiting with error
 		defer func() {
-			if err != nil && !keeponfailure {
-				if keeponfailure {
-					if forensics := os.Getenv("SAFESCALE_FORENSICS"); forensics != "" {
-						return
-					}
-				}
+			if err != nil && keeponfailure {
+				logrus.Warnf(
+					"Keeping primary gateway '%s' (id '%s') and its metadata on failure for inspection",
+					primaryGateway.Name, primaryGateway.ID,
+				)
+				return
+			}
+			if err != nil {
 				derr := handler.deleteGateway(primaryGateway)
 				if derr != nil {
 					switch derr.(type) {
@@ -421,19 +710,19 @@ func (handler *NetworkHandler) Create(
 		if secondaryErr == nil {
 			secondaryGateway = secondaryResult.(data.Map)["host"].(*abstract.Host)
 			secondaryUserdata = secondaryResult.(data.Map)["userdata"].(*userdata.Content)
-			if domain != "" {
-				secondaryUserdata.HostName = secondaryGatewayName + domain
-			}
+			secondaryUserdata.HostName = secondaryGatewayName
 			secondaryMetadata = secondaryResult.(data.Map)["metadata"].(*metadata.Gateway)
 
 			// Starting from here, deletes the secondary gateway if exiting with error
 			defer func() {
-				if err != nil && !keeponfailure {
-					if keeponfailure {
-						if forensics := os.Getenv("SAFESCALE_FORENSICS"); forensics != "" {
-							return
-						}
-					}
+				if err != nil && keeponfailure {
+					logrus.Warnf(
+						"Keeping secondary gateway '%s' (id '%s') and its metadata on failure for inspection",
+						secondaryGateway.Name, secondaryGateway.ID,
+					)
+					return
+				}
+				if err != nil {
 					derr := handler.deleteGateway(secondaryGateway)
 					if derr != nil {
 						switch derr.(type) {
@@ -465,16 +754,88 @@ func (handler *NetworkHandler) Create(
 		return nil, secondaryErr
 	}
 
-	network.GatewayID = primaryGateway.ID
+	// Creates the gateways beyond the primary and the secondary ones, if asked for
+	var extraGateways []*networkGateway
+	if extraNames := extraGatewayNames(network.Name, gatewayCount, domain); len(extraNames) > 0 {
+		extraGateways, err = handler.createExtraGateways(ctx, gwRequest, sizing, extraNames)
+		if err != nil {
+			return nil, err
+		}
+
+		// Starting from here, deletes the additional gateways if exiting with error
+		defer func() {
+			if err != nil && keeponfailure {
+				logrus.Warnf(
+					"Keeping %d additional gateways and their metadata on failure for inspection", len(extraGateways),
+				)
+				return
+			}
+			if err != nil {
+				derr := handler.deleteExtraGateways(newNetwork.VIP, extraGateways)
+				err = fail.AddConsequence(err, derr)
+			}
+		}()
+	}
+
+	gatewayIDs := []string{primaryGateway.ID}
+	if secondaryGateway != nil {
+		gatewayIDs = append(gatewayIDs, secondaryGateway.ID)
+	}
+	for _, gw := range extraGateways {
+		gatewayIDs = append(gatewayIDs, gw.host.ID)
+	}
+	err = mn.SetGatewayIDs(gatewayIDs)
+	if err != nil {
+		return nil, err
+	}
+	err = handler.bindDefaultSecurityGroup(network, gatewayIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Records the private IPs used by gateway(s) and VIP, to detect collisions later
+	creationLog.transition(networkStateMetadataUpdate)
+	reserved := map[string]string{primaryGateway.GetPrivateIP(): primaryGateway.ID}
 	if secondaryGateway != nil {
-		network.SecondaryGatewayID = secondaryGateway.ID
+		reserved[secondaryGateway.GetPrivateIP()] = secondaryGateway.ID
+	}
+	for _, gw := range extraGateways {
+		reserved[gw.host.GetPrivateIP()] = gw.host.ID
+	}
+	if network.VIP != nil {
+		reserved[network.VIP.PrivateIP] = network.VIP.ID
+	}
+	for ip, owner := range reserved {
+		if ip == "" {
+			continue
+		}
+		err = mn.ReserveIP(ip, owner)
+		if err != nil {
+			return nil, err
+		}
 	}
+
 	err = mn.Write()
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.SkipFinalization {
+		err = recordCreationSpec(mn, spec)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Infof("Network '%s' created, configuration of its gateways skipped", network.Name)
+		return network, nil
+	}
+
+	err = checkCanceled(ctx, "creation of network "+name)
+	if err != nil {
+		return nil, err
+	}
+
 	// Starts gateway(s) installation
+	creationLog.transition(networkStateGatewayConfiguration)
 	primaryTask, err = concurrency.NewTaskWithContext(ctx)
 	if err != nil {
 		return nil, err
@@ -517,14 +878,29 @@ func (handler *NetworkHandler) Create(
 			compareOsWithRequestedOs(out2Cast, theos)
 		}
 	}
+	_, err = runInParallel(
+		ctx, len(extraGateways), handler.waitForInstallPhase1OnGateway,
+		func(i int) concurrency.TaskParameters {
+			return extraGateways[i].host
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
 
 	if primaryUserdata == nil {
 		return nil, fmt.Errorf("error creating network: primaryUserdata is nil")
 	}
 
 	// Complement userdata for gateway(s) with allocated IP
-	primaryUserdata.PrimaryGatewayPrivateIP = primaryGateway.GetPrivateIP()
-	primaryUserdata.PrimaryGatewayPublicIP = primaryGateway.GetPublicIP()
+	primaryUserdata.MTU = mtu
+	primaryUserdata.IngressPorts = additionalIngressPorts
+	primaryUserdata.PrimaryGatewayPrivateIP, primaryUserdata.PrimaryGatewayPublicIP, err = gatewayRouteIPs(
+		primaryGateway, ipVersion,
+	)
+	if err != nil {
+		return nil, err
+	}
 	if failover {
 		keepalivedPassword, err := utils.GeneratePassword(16)
 		if err != nil {
@@ -532,18 +908,40 @@ func (handler *NetworkHandler) Create(
 		}
 		primaryUserdata.GatewayHAKeepalivedPassword = keepalivedPassword
 
-		primaryUserdata.SecondaryGatewayPrivateIP = secondaryGateway.GetPrivateIP()
-		primaryUserdata.SecondaryGatewayPublicIP = secondaryGateway.GetPublicIP()
+		primaryUserdata.SecondaryGatewayPrivateIP, primaryUserdata.SecondaryGatewayPublicIP, err = gatewayRouteIPs(
+			secondaryGateway, ipVersion,
+		)
+		if err != nil {
+			return nil, err
+		}
 
 		if secondaryUserdata == nil {
 			return nil, fmt.Errorf("error creating network: secondaryUserdata is nil")
 		}
 
+		secondaryUserdata.MTU = mtu
+		secondaryUserdata.IngressPorts = additionalIngressPorts
 		secondaryUserdata.PrimaryGatewayPrivateIP = primaryUserdata.PrimaryGatewayPrivateIP
 		secondaryUserdata.PrimaryGatewayPublicIP = primaryUserdata.PrimaryGatewayPublicIP
 		secondaryUserdata.SecondaryGatewayPrivateIP = primaryUserdata.SecondaryGatewayPrivateIP
 		secondaryUserdata.SecondaryGatewayPublicIP = primaryUserdata.SecondaryGatewayPublicIP
 		secondaryUserdata.GatewayHAKeepalivedPassword = keepalivedPassword
+
+		// the additional gateways are configured as the secondary one
+		for _, gw := range extraGateways {
+			gw.userdata.MTU = mtu
+			gw.userdata.IngressPorts = additionalIngressPorts
+			gw.userdata.PrimaryGatewayPrivateIP = primaryUserdata.PrimaryGatewayPrivateIP
+			gw.userdata.PrimaryGatewayPublicIP = primaryUserdata.PrimaryGatewayPublicIP
+			gw.userdata.SecondaryGatewayPrivateIP = primaryUserdata.SecondaryGatewayPrivateIP
+			gw.userdata.SecondaryGatewayPublicIP = primaryUserdata.SecondaryGatewayPublicIP
+			gw.userdata.GatewayHAKeepalivedPassword = keepalivedPassword
+		}
+	}
+
+	err = checkCanceled(ctx, "creation of network "+name)
+	if err != nil {
+		return nil, err
 	}
 
 	// Starts gateway(s) installation
@@ -555,6 +953,8 @@ func (handler *NetworkHandler) Create(
 		handler.installPhase2OnGateway, data.Map{
 			"host":     primaryGateway,
 			"userdata": primaryUserdata,
+			"ports":    additionalIngressPorts,
+			"template": gatewayTemplate,
 		},
 	)
 	if err != nil {
@@ -569,6 +969,8 @@ func (handler *NetworkHandler) Create(
 			handler.installPhase2OnGateway, data.Map{
 				"host":     secondaryGateway,
 				"userdata": secondaryUserdata,
+				"ports":    additionalIngressPorts,
+				"template": gatewayTemplate,
 			},
 		)
 		if err != nil {
@@ -585,49 +987,991 @@ func (handler *NetworkHandler) Create(
 			return nil, secondaryErr
 		}
 	}
-
-	select {
-	case <-ctx.Done():
-		logrus.Warnf("Network creation cancelled by user")
-		return nil, fmt.Errorf("network creation cancelled by user")
-	default:
+	_, err = runInParallel(
+		ctx, len(extraGateways), handler.installPhase2OnGateway,
+		func(i int) concurrency.TaskParameters {
+			return data.Map{
+				"host":     extraGateways[i].host,
+				"userdata": extraGateways[i].userdata,
+				"ports":    additionalIngressPorts,
+				"template": gatewayTemplate,
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
 	}
 
+	err = finishNetworkCreation(ctx, name, mn, spec)
+	if err != nil {
+		return nil, err
+	}
 	return network, nil
 }
 
-func compareOsWithRequestedOs(theOs string, requestedOs string) {
-	logrus.Debugf("Analysis of %s vs %s", theOs, requestedOs)
-	frags := strings.Split(theOs, ",")
-	if len(frags) < 3 {
-		return
+// finishNetworkCreation records the creation parameters once the gateways are configured, unless the creation has
+// been canceled meanwhile
+func finishNetworkCreation(ctx context.Context, name string, mn *metadata.Network, spec *propsv1.NetworkCreationSpec) error {
+	err := checkCanceled(ctx, "creation of network "+name)
+	if err != nil {
+		logrus.Warnf("Network creation cancelled by user")
+		return err
 	}
+	return recordCreationSpec(mn, spec)
+}
 
-	os := fmt.Sprintf("%s %s", frags[2], frags[3])
-
-	var err error
+// newNetworkCreationSpec builds the creation parameters of a network from the request
+// The template and the image of the gateway(s) are filled in once resolved
+func newNetworkCreationSpec(
+	cidr string, ipVersion ipversion.Enum, sizing abstract.SizingRequirements, opts NetworkCreateOptions,
+) *propsv1.NetworkCreationSpec {
+	return &propsv1.NetworkCreationSpec{
+		CIDR:                   cidr,
+		IPVersion:              int(ipVersion),
+		Domain:                 opts.Domain,
+		AdditionalIngressPorts: opts.AdditionalIngressPorts,
+		MTU:                    opts.MTU,
+		ExpectedHostCount:      opts.ExpectedHostCount,
+		NoGateway:              opts.NoGateway,
+		GatewayName:            opts.GatewayName,
+		OS:                     opts.OS,
+		Sizing: propsv1.NetworkGatewaySizing{
+			MinCores:    sizing.MinCores,
+			MaxCores:    sizing.MaxCores,
+			MinRAMSize:  sizing.MinRAMSize,
+			MaxRAMSize:  sizing.MaxRAMSize,
+			MinDiskSize: sizing.MinDiskSize,
+			MinGPU:      sizing.MinGPU,
+			GPUType:     sizing.GPUType,
+			MinFreq:     sizing.MinFreq,
+			Arch:        sizing.Arch,
+		},
+		KeepOnFailure:     opts.KeepOnFailure,
+		AllowRoutableCIDR: opts.AllowRoutableCIDR,
+	}
+}
 
-	verRequested := -1
-	ver := -1
+// recordCreationSpec saves the creation parameters 'spec' in the metadata of the network
+func recordCreationSpec(mn *metadata.Network, spec *propsv1.NetworkCreationSpec) error {
+	err := mn.SetCreationSpec(spec)
+	if err != nil {
+		return err
+	}
+	return mn.Write()
+}
 
-	osReqName := ""
-	osName := ""
+// AttachGateway makes the existing host referenced by 'hostRef' the gateway of the network referenced by
+// 'networkRef', instead of a gateway created by SafeScale; the host must have a public IP and an interface on the
+// network, it is configured as a gateway by the phase 2 of userdata
+func (handler *NetworkHandler) AttachGateway(ctx context.Context, networkRef, hostRef string) (network *abstract.Network, err error) {
+	if handler == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if networkRef == "" {
+		return nil, fail.InvalidParameterError("networkRef", "cannot be empty string")
+	}
+	if hostRef == "" {
+		return nil, fail.InvalidParameterError("hostRef", "cannot be empty string")
+	}
 
-	if strings.Contains(requestedOs, " ") {
-		fragments := strings.Split(requestedOs, " ")
-		if len(fragments) >= 2 {
-			osReqName = strings.ToUpper(fragments[0])
-			version := fragments[1]
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s', '%s')", networkRef, hostRef), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
-			verRequested, err = strconv.Atoi(version)
-			if err != nil {
-				return
-			}
-		} else {
-			return
-		}
+	mn, err := metadata.LoadNetwork(handler.service, networkRef)
+	if err != nil {
+		return nil, err
 	}
-
+	mh, err := metadata.LoadHost(handler.service, hostRef)
+	if err != nil {
+		return nil, err
+	}
+	host, err := mh.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	configure := func(network *abstract.Network, host *abstract.Host, ip string) error {
+		err := checkCanceled(ctx, "attachment of gateway to network "+network.Name)
+		if err != nil {
+			return err
+		}
+		userData, err := handler.externalGatewayUserdata(network, host, ip)
+		if err != nil {
+			return err
+		}
+		task, err := concurrency.NewTaskWithContext(ctx)
+		if err != nil {
+			return err
+		}
+		task, err = task.Start(handler.installPhase2OnGateway, data.Map{"host": host, "userdata": userData})
+		if err != nil {
+			return err
+		}
+		_, err = task.Wait()
+		return err
+	}
+	unconfigure := func(network *abstract.Network, host *abstract.Host) error {
+		return handler.unconfigureExternalGateway(ctx, host)
+	}
+	save := func(host *abstract.Host) error {
+		_, err := metadata.SaveHost(handler.service, host)
+		return err
+	}
+	err = attachGateway(mn, host, configure, unconfigure, save)
+	if err != nil {
+		return nil, err
+	}
+	return mn.Get()
+}
+
+// attachGateway does the real work of AttachGateway, using 'configure' to set up the host as gateway and 'save' to
+// write its metadata
+// If the metadata cannot be updated once the host is configured, the host is turned back into a regular host with
+// 'unconfigure', its metadata are restored and the metadata of the network are reloaded
+func attachGateway(
+	mn *metadata.Network, host *abstract.Host, configure func(*abstract.Network, *abstract.Host, string) error,
+	unconfigure func(*abstract.Network, *abstract.Host) error, save func(*abstract.Host) error,
+) (err error) {
+	network, err := mn.Get()
+	if err != nil {
+		return err
+	}
+	ip, err := checkExternalGateway(network, host)
+	if err != nil {
+		return err
+	}
+	err = configure(network, host, ip)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			logrus.Warnf("failed to record host '%s' as gateway of network '%s', reverting: %v", host.Name, network.Name, err)
+			derr := setGatewayFlag(host, false)
+			if derr == nil {
+				derr = save(host)
+			}
+			if derr != nil {
+				logrus.Errorf("failed to restore the metadata of host '%s': %v", host.Name, derr)
+				err = fail.AddConsequence(err, derr)
+			}
+			derr = unconfigure(network, host)
+			if derr != nil {
+				logrus.Errorf("failed to revert the configuration of host '%s' as gateway: %v", host.Name, derr)
+				err = fail.AddConsequence(err, derr)
+			}
+			// Discards the changes not written of the metadata of the network
+			derr = mn.Reload()
+			if derr != nil {
+				err = fail.AddConsequence(err, derr)
+			}
+		}
+	}()
+
+	err = setGatewayFlag(host, true)
+	if err != nil {
+		return err
+	}
+	err = save(host)
+	if err != nil {
+		return err
+	}
+
+	// A gateway is not one of the hosts of the network
+	err = mn.DetachHost(host.ID)
+	if err != nil {
+		return err
+	}
+	err = mn.ReserveIP(ip, host.ID)
+	if err != nil {
+		return err
+	}
+	err = mn.SetGatewayIDs([]string{host.ID})
+	if err != nil {
+		return err
+	}
+	return mn.Write()
+}
+
+// setGatewayFlag sets in the properties of 'host' if it acts as a gateway
+func setGatewayFlag(host *abstract.Host, isGateway bool) error {
+	return host.Properties.LockForWrite(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			clonable.(*propsv1.HostNetwork).IsGateway = isGateway
+			return nil
+		},
+	)
+}
+
+// unconfigureGatewayCommand reverts what the phase 2 of userdata does to make a host a gateway: IP forwarding and
+// masquerading are disabled; the configuration of the network interfaces is left as-is
+const unconfigureGatewayCommand = "sudo bash -c 'rm -f /etc/sysctl.d/21-gateway.conf && sysctl -w net.ipv4.ip_forward=0 && " +
+	"firewall-cmd --permanent --zone=public --remove-masquerade; firewall-cmd --permanent --zone=trusted --remove-masquerade; " +
+	"firewall-cmd --reload'"
+
+// unconfigureExternalGateway turns back the host 'host', configured as gateway by AttachGateway, into a regular host
+func (handler *NetworkHandler) unconfigureExternalGateway(ctx context.Context, host *abstract.Host) error {
+	sshHandler := NewSSHHandler(handler.service)
+	returnCode, _, stderr, err := sshHandler.Run(ctx, host.Name, unconfigureGatewayCommand, outputs.COLLECT)
+	if err != nil {
+		return err
+	}
+	if returnCode != 0 {
+		return fmt.Errorf("failed to revert the gateway configuration of host '%s': errorcode '%d', %s", host.Name, returnCode, stderr)
+	}
+	return nil
+}
+
+// checkExternalGateway verifies 'host' can become the gateway of 'network': the network must not have a gateway yet,
+// the host must have a public IP and an interface on the network; returns the private IP of the host in the network
+func checkExternalGateway(network *abstract.Network, host *abstract.Host) (string, error) {
+	if network.GatewayID != "" {
+		return "", fail.InvalidRequestError(
+			fmt.Sprintf("network '%s' already has a gateway ('%s')", network.Name, network.GatewayID),
+		)
+	}
+	if host.GetPublicIP() == "" {
+		return "", fail.InvalidRequestError(
+			fmt.Sprintf("host '%s' cannot be the gateway of network '%s': it has no public IP", host.Name, network.Name),
+		)
+	}
+	var ip string
+	err := host.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+			ip = hostNetworkV1.IPv4Addresses[network.ID]
+			if ip == "" {
+				ip = hostNetworkV1.IPv6Addresses[network.ID]
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	if ip == "" {
+		return "", fail.InvalidRequestError(
+			fmt.Sprintf(
+				"host '%s' cannot be the gateway of network '%s': it has no interface on the network", host.Name,
+				network.Name,
+			),
+		)
+	}
+	return ip, nil
+}
+
+// externalGatewayUserdata prepares the userdata configuring the existing 'host' as the gateway of 'network', 'ip'
+// being the private IP of the host in the network
+func (handler *NetworkHandler) externalGatewayUserdata(
+	network *abstract.Network, host *abstract.Host, ip string,
+) (*userdata.Content, error) {
+	cfg, err := handler.service.GetConfigurationOptions()
+	if err != nil {
+		return nil, err
+	}
+	options := stacks.ConfigurationOptions{
+		DNSList:          cfg.GetSliceOfStrings("DNSList"),
+		OperatorUsername: cfg.GetString("OperatorUsername"),
+		ProviderName:     cfg.GetString("ProviderName"),
+	}
+	if anon, ok := cfg.Get("UseLayer3Networking"); ok {
+		options.UseLayer3Networking, _ = anon.(bool)
+	}
+
+	userData := userdata.NewContent()
+	err = userData.Prepare(
+		options, abstract.HostRequest{
+			ResourceName: host.Name,
+			Networks:     []*abstract.Network{network},
+			KeyPair:      &abstract.KeyPair{PrivateKey: host.PrivateKey},
+			Password:     host.Password,
+			PublicIP:     true,
+			IsGateway:    true,
+		}, network.CIDR, "",
+	)
+	if err != nil {
+		return nil, err
+	}
+	userData.IsPrimaryGateway = true
+	userData.DefaultRouteIP = ip
+	userData.PrimaryGatewayPrivateIP = ip
+	userData.PrimaryGatewayPublicIP = host.GetPublicIP()
+	return userData, nil
+}
+
+// NetworkCreationMetrics contains the time spent in each phase of the creation of a network
+type NetworkCreationMetrics struct {
+	// Network is the name of the network
+	Network string
+	// Phases contains the time spent in each state of the network (NETWORK_CREATION, METADATA_UPDATE,
+	// GATEWAY_CREATION, GATEWAY_CONFIGURATION); a state entered several times cumulates its durations
+	Phases map[string]time.Duration
+	// Total is the duration of the whole creation
+	Total time.Duration
+	// Failed tells if the creation failed, in the last state recorded
+	Failed bool
+}
+
+// networkCreationLog logs the steps of the creation of a network, with the time spent in each of them
+type networkCreationLog struct {
+	name   string
+	log    func(args ...interface{})
+	state  string
+	begin  time.Time
+	since  time.Time
+	phases map[string]time.Duration
+	total  time.Duration
+	failed bool
+}
+
+// newNetworkCreationLog starts the log of the creation of network 'name'; transitions are logged at info level
+// if 'verbose' is true (tracing enabled), at debug level otherwise
+func newNetworkCreationLog(name string, verbose bool) *networkCreationLog {
+	level := logrus.DebugLevel
+	if verbose {
+		level = logrus.InfoLevel
+	}
+	now := time.Now()
+	return &networkCreationLog{
+		name: name, log: commonlog.LogLevelFnMap[level], begin: now, since: now, phases: map[string]time.Duration{},
+	}
+}
+
+// transition logs the move to 'state'
+func (l *networkCreationLog) transition(state string) {
+	now := time.Now()
+	if l.state == "" {
+		l.log(fmt.Sprintf("network '%s': state %s", l.name, state))
+	} else {
+		l.phases[l.state] += now.Sub(l.since)
+		l.log(
+			fmt.Sprintf(
+				"network '%s': state %s -> %s (after %s)", l.name, l.state, state,
+				temporal.FormatDuration(now.Sub(l.since)),
+			),
+		)
+	}
+	l.state = state
+	l.since = now
+}
+
+// done logs the outcome of the creation, moving to state READY on success
+func (l *networkCreationLog) done(err error) {
+	l.total = time.Since(l.begin)
+	if err != nil {
+		l.failed = true
+		if l.state != "" {
+			l.phases[l.state] += time.Since(l.since)
+		}
+		logrus.Warnf(
+			"network '%s': creation failed in state %s after %s: %v", l.name, l.state,
+			temporal.FormatDuration(time.Since(l.begin)), err,
+		)
+		return
+	}
+	l.transition(networkStateReady)
+	logrus.Infof("network '%s': created in %s", l.name, temporal.FormatDuration(l.total))
+}
+
+// metrics returns the time spent in each state, once the creation is done
+func (l *networkCreationLog) metrics() NetworkCreationMetrics {
+	phases := make(map[string]time.Duration, len(l.phases))
+	for state, d := range l.phases {
+		phases[state] = d
+	}
+	return NetworkCreationMetrics{Network: l.name, Phases: phases, Total: l.total, Failed: l.failed}
+}
+
+// States of a network during its creation, as logged by networkCreationLog
+const (
+	networkStateCreation             = "NETWORK_CREATION"
+	networkStateMetadataUpdate       = "METADATA_UPDATE"
+	networkStateGatewayCreation      = "GATEWAY_CREATION"
+	networkStateGatewayConfiguration = "GATEWAY_CONFIGURATION"
+	networkStateReady                = "READY"
+)
+
+// waitNetworkDeletion waits at most 'timeout' for the network identified by 'id' to disappear, polling the provider
+// with an increasing delay starting from 'delay'
+func waitNetworkDeletion(svc iaas.Service, id string, delay time.Duration, timeout time.Duration) error {
+	return retry.Action(
+		func() error {
+			recNet, recErr := svc.GetNetwork(id)
+			if recNet != nil {
+				return fmt.Errorf("still there")
+			}
+			if _, ok := recErr.(fail.ErrNotFound); ok {
+				return nil
+			}
+			return fmt.Errorf("another kind of error")
+		},
+		retry.PrevailDone(retry.Unsuccessful(), retry.Timeout(timeout)),
+		retry.Fibonacci(delay),
+		nil, nil, nil,
+	)
+}
+
+// validateIngressPorts checks that the additional ingress ports requested for gateways are valid TCP ports
+func validateIngressPorts(ports []int) error {
+	for _, v := range ports {
+		if v < 1 || v > 65535 {
+			return fail.InvalidParameterError("additionalIngressPorts", fmt.Sprintf("port %d is out of range [1-65535]", v))
+		}
+	}
+	return nil
+}
+
+const (
+	// minMTU is the smallest MTU accepted for a network (minimum IPv4 datagram size every host must accept)
+	minMTU = 576
+	// maxMTU is the biggest MTU accepted for a network (jumbo frames)
+	maxMTU = 9000
+)
+
+// validateMTU checks the MTU wanted for a network; 0 means the default MTU
+func validateMTU(mtu int) error {
+	if mtu != 0 && (mtu < minMTU || mtu > maxMTU) {
+		return fail.InvalidParameterError("mtu", fmt.Sprintf("must be between %d and %d (or 0 for default)", minMTU, maxMTU))
+	}
+	return nil
+}
+
+const (
+	// maxDomainLength is the maximum length of a domain name, without the trailing dot (RFC 1035)
+	maxDomainLength = 253
+	// maxDomainLabelLength is the maximum length of each label of a domain name (RFC 1035)
+	maxDomainLabelLength = 63
+)
+
+// validateDomain verifies 'domain' (without leading or trailing dots) is a legal DNS name: at most 253 characters,
+// made of labels of 1 to 63 letters, digits or hyphens, not starting nor ending with a hyphen; an empty domain is valid
+func validateDomain(domain string) error {
+	if domain == "" {
+		return nil
+	}
+	if len(domain) > maxDomainLength {
+		return fail.InvalidRequestError(
+			fmt.Sprintf("domain '%s' is too long (%d characters, maximum is %d)", domain, len(domain), maxDomainLength),
+		)
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > maxDomainLabelLength {
+			return fail.InvalidRequestError(
+				fmt.Sprintf("domain '%s' contains a label not between 1 and %d characters", domain, maxDomainLabelLength),
+			)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fail.InvalidRequestError(
+				fmt.Sprintf("label '%s' of domain '%s' cannot start or end with a hyphen", label, domain),
+			)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' {
+				return fail.InvalidRequestError(
+					fmt.Sprintf("label '%s' of domain '%s' contains the invalid character '%c'", label, domain, c),
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeDomain removes the surrounding spaces and dots of a domain
+func normalizeDomain(domain string) string {
+	return strings.Trim(strings.TrimSpace(domain), ".")
+}
+
+// gatewayFQDNs returns the FQDNs of the primary and secondary (empty if not 'failover') gateways of the network
+// 'networkName', named by their prefix followed by the name of the network unless 'gwname' is set (without failover);
+// both are composed the same way, in the (normalized) domain 'domain'
+func gatewayFQDNs(
+	networkName, gwname, primaryPrefix, secondaryPrefix string, failover bool, domain string,
+) (primary string, secondary string) {
+	if failover || gwname == "" {
+		primary = primaryPrefix + networkName
+	} else {
+		primary = gwname
+	}
+	primary = hostFQDN(primary, domain)
+	if failover {
+		secondary = hostFQDN(secondaryPrefix+networkName, domain)
+	}
+	return primary, secondary
+}
+
+// hostFQDN composes the FQDN of the host 'name' in the (normalized) domain 'domain'; without domain, the name is returned
+func hostFQDN(name, domain string) string {
+	if domain == "" {
+		return name
+	}
+	return name + "." + domain
+}
+
+// checkCanceled returns a fail.ErrAborted if 'ctx' has been canceled or has expired, telling 'what' is aborted
+func checkCanceled(ctx context.Context, what string) error {
+	if ctx == nil {
+		return nil
+	}
+	if cerr := ctx.Err(); cerr != nil {
+		return fail.AbortedError(what, cerr)
+	}
+	return nil
+}
+
+// checkNetworkCapabilities verifies the provider of capabilities 'caps' supports the features requested for a network
+func checkNetworkCapabilities(caps providers.Capabilities, ipVersion ipversion.Enum) error {
+	if ipVersion == ipversion.IPv6 && !caps.SupportsIPv6 {
+		return fail.NotAvailableError("the provider does not support IPv6 networks")
+	}
+	return nil
+}
+
+// checkNetworkCIDR verifies the CIDR of a network is valid and not routable; a routable CIDR is only accepted
+// (with a warning) if 'allowRoutable' is true
+func checkNetworkCIDR(cidr string, allowRoutable bool) error {
+	routable, err := utils.IsCIDRRoutable(cidr)
+	if err != nil {
+		return fail.InvalidCIDRError(cidr, err)
+	}
+	if routable {
+		if !allowRoutable {
+			return fail.RoutableCIDRError(cidr)
+		}
+		logrus.Warnf(
+			"CIDR '%s' is routable and is used as requested: the hosts of the network may conflict with or be reachable from other networks",
+			cidr,
+		)
+	}
+	return nil
+}
+
+// checkNetworkHostCapacity verifies the CIDR of a network provides enough usable addresses for 'expectedHostCount'
+// hosts, in addition to the gateway (or the 2 gateways and the VIP if 'failover' is true); the network address
+// and the broadcast address are not usable
+func checkNetworkHostCapacity(cidr string, expectedHostCount int, failover bool) error {
+	if expectedHostCount < 0 {
+		return fail.InvalidParameterError("expectedHostCount", "cannot be negative")
+	}
+	if expectedHostCount == 0 {
+		return nil
+	}
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fail.InvalidCIDRError(cidr, err)
+	}
+
+	needed, what := expectedHostCount+1, "the gateway"
+	if failover {
+		needed, what = expectedHostCount+3, "the 2 gateways and the VIP"
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones >= 31 {
+		// more than 2^31 addresses, always enough
+		return nil
+	}
+	usable := (1 << uint(bits-ones)) - 2
+	if usable >= needed {
+		return nil
+	}
+
+	prefix := ones
+	for prefix > 0 && (1<<uint(bits-prefix))-2 < needed {
+		prefix--
+	}
+	return fail.InvalidRequestError(
+		fmt.Sprintf(
+			"CIDR '%s' provides %d usable addresses but %d are needed for %d host%s and %s; use a larger CIDR (/%d or less)",
+			cidr, usable, needed, expectedHostCount, utils.Plural(expectedHostCount), what, prefix,
+		),
+	)
+}
+
+// AllocateCIDR returns the first subnet of length 'prefixLen' of 'supernet' not overlapping the CIDR of any network
+// managed by SafeScale; returns a fail.ErrOverflow if the supernet is exhausted
+// 'task' may be nil; if set, the allocation is canceled when the task is aborted
+func AllocateCIDR(task concurrency.Task, svc iaas.Service, supernet string, prefixLen int) (string, error) {
+	if svc == nil {
+		return "", fail.InvalidParameterError("svc", "cannot be nil")
+	}
+
+	mn, err := metadata.NewNetwork(svc)
+	if err != nil {
+		return "", err
+	}
+	var used []string
+	err = mn.Browse(
+		func(network *abstract.Network) error {
+			if task != nil && task.Aborted() {
+				return fail.AbortedError("CIDR allocation aborted", nil)
+			}
+			if network.CIDR != "" {
+				used = append(used, network.CIDR)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	return nextFreeCIDR(supernet, prefixLen, used)
+}
+
+// FindOrphanedGateways returns the gateways recorded in metadata which are not referenced by any network managed
+// by SafeScale anymore (left behind by an interrupted deletion of their network for example), to be deleted
+// 'task' may be nil; if set, the search is canceled when the task is aborted
+func FindOrphanedGateways(task concurrency.Task, svc iaas.Service) ([]*abstract.Host, error) {
+	if svc == nil {
+		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+
+	aborted := func() error {
+		if task != nil && task.Aborted() {
+			return fail.AbortedError("search of orphaned gateways aborted", nil)
+		}
+		return nil
+	}
+
+	mn, err := metadata.NewNetwork(svc)
+	if err != nil {
+		return nil, err
+	}
+	referenced := map[string]bool{}
+	err = mn.Browse(
+		func(network *abstract.Network) error {
+			gatewayIDs, err := metadata.GatewayIDsOf(network)
+			if err != nil {
+				return err
+			}
+			for _, id := range gatewayIDs {
+				referenced[id] = true
+			}
+			return aborted()
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mh, err := metadata.NewHost(svc)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []*abstract.Host
+	err = mh.Browse(
+		func(host *abstract.Host) error {
+			if looksLikeGateway(host) && !referenced[host.ID] {
+				orphans = append(orphans, host)
+			}
+			return aborted()
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return orphans, nil
+}
+
+// FindNetworkByVIP returns the network whose VIP has 'ip' as private or public IP address, to find the HA network
+// owning an observed endpoint; returns fail.ErrNotFound if no network has such a VIP
+// 'task' may be nil; if set, the search is canceled when the task is aborted
+func FindNetworkByVIP(task concurrency.Task, svc iaas.Service, ip string) (*abstract.Network, error) {
+	if svc == nil {
+		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+	if ip == "" {
+		return nil, fail.InvalidParameterError("ip", "cannot be empty string")
+	}
+
+	mn, err := metadata.NewNetwork(svc)
+	if err != nil {
+		return nil, err
+	}
+	var found *abstract.Network
+	err = mn.Browse(
+		func(network *abstract.Network) error {
+			if task != nil && task.Aborted() {
+				return fail.AbortedError("search of network by VIP aborted", nil)
+			}
+			if found == nil && network.VIP != nil && (network.VIP.PrivateIP == ip || network.VIP.PublicIP == ip) {
+				found = network
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fail.NotFoundError(fmt.Sprintf("no network has a VIP with IP address '%s'", ip))
+	}
+	return found, nil
+}
+
+// GatewaySummary identifies a gateway of a network managed by SafeScale
+type GatewaySummary struct {
+	NetworkID   string
+	NetworkName string
+	Index       int // 0 for the primary gateway, 1 for the secondary one, then the additional ones
+	ID          string
+	Name        string
+	PrivateIP   string
+	PublicIP    string
+	Missing     bool // true if the metadata of the gateway is missing; Name and IPs are then empty
+}
+
+// ListAllGateways returns the gateways of all the networks managed by SafeScale, in the order of the networks then
+// of their gateways; a gateway without metadata is listed as missing instead of failing the whole inventory
+// 'task' may be nil; if set, the listing is canceled when the task is aborted
+func ListAllGateways(task concurrency.Task, svc iaas.Service) ([]GatewaySummary, error) {
+	if svc == nil {
+		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+
+	mn, err := metadata.NewNetwork(svc)
+	if err != nil {
+		return nil, err
+	}
+	var list []GatewaySummary
+	err = mn.Browse(
+		func(network *abstract.Network) error {
+			if task != nil && task.Aborted() {
+				return fail.AbortedError("listing of gateways aborted", nil)
+			}
+			gatewayIDs, err := metadata.GatewayIDsOf(network)
+			if err != nil {
+				return err
+			}
+			for i, id := range gatewayIDs {
+				summary, err := summarizeGateway(svc, network, i, id)
+				if err != nil {
+					return err
+				}
+				list = append(list, summary)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// summarizeGateway returns the GatewaySummary of the gateway 'id' of index 'index' of 'network'
+func summarizeGateway(svc iaas.Service, network *abstract.Network, index int, id string) (GatewaySummary, error) {
+	summary := GatewaySummary{
+		NetworkID:   network.ID,
+		NetworkName: network.Name,
+		Index:       index,
+		ID:          id,
+	}
+	mh, err := metadata.LoadHost(svc, id)
+	if err != nil {
+		if _, ok := err.(fail.ErrNotFound); ok {
+			logrus.Warnf("metadata of gateway '%s' of network '%s' not found", id, network.Name)
+			summary.Missing = true
+			return summary, nil
+		}
+		return summary, err
+	}
+	host, err := mh.Get()
+	if err != nil {
+		return summary, err
+	}
+	summary.Name = host.Name
+	summary.PrivateIP = host.GetPrivateIP()
+	summary.PublicIP = host.GetPublicIP()
+	return summary, nil
+}
+
+// looksLikeGateway tells if 'host' is flagged as a gateway or is named as the gateways created by SafeScale
+func looksLikeGateway(host *abstract.Host) bool {
+	if strings.HasPrefix(host.Name, "gw-") || strings.HasPrefix(host.Name, "gw2-") {
+		return true
+	}
+	isGateway := false
+	_ = host.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			isGateway = clonable.(*propsv1.HostNetwork).IsGateway
+			return nil
+		},
+	)
+	return isGateway
+}
+
+// nextFreeCIDR returns the first subnet of length 'prefixLen' of 'supernet' not overlapping any of the CIDRs 'used'
+func nextFreeCIDR(supernet string, prefixLen int, used []string) (string, error) {
+	_, super, err := net.ParseCIDR(supernet)
+	if err != nil {
+		return "", fail.InvalidCIDRError(supernet, err)
+	}
+	superLen, bits := super.Mask.Size()
+	if prefixLen < superLen || prefixLen > bits-2 {
+		return "", fail.InvalidParameterError(
+			"prefixLen", fmt.Sprintf("must be between %d and %d for supernet '%s'", superLen, bits-2, supernet),
+		)
+	}
+	if prefixLen-superLen > 24 {
+		return "", fail.InvalidParameterError("prefixLen", "too many subnets in the supernet")
+	}
+
+	var usedNets []*net.IPNet
+	for _, c := range used {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			logrus.Warnf("ignoring invalid CIDR '%s' of a network", c)
+			continue
+		}
+		usedNets = append(usedNets, n)
+	}
+
+	count := 1 << uint(prefixLen-superLen)
+	for i := 0; i < count; i++ {
+		candidate, err := cidr.Subnet(super, prefixLen-superLen, i)
+		if err != nil {
+			return "", err
+		}
+		free := true
+		for _, n := range usedNets {
+			if n.Contains(candidate.IP) || candidate.Contains(n.IP) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return candidate.String(), nil
+		}
+	}
+	return "", fail.OverflowError(
+		fmt.Sprintf("no free /%d subnet left in supernet '%s'", prefixLen, supernet), uint(count), nil,
+	)
+}
+
+// searchGatewayImage looks for the image to use for a gateway; if theos is empty, the default image configured
+// for the architecture (and IP version) is used, falling back to the tenant DefaultImage
+func (handler *NetworkHandler) searchGatewayImage(theos string, arch string, ipVersion ipversion.Enum) (*abstract.Image, error) {
+	if theos != "" {
+		return handler.searchImage(theos)
+	}
+
+	cfg, err := handler.service.GetConfigurationOptions()
+	if err != nil {
+		return nil, err
+	}
+	imageName, specific := defaultImageFor(cfg, arch, ipVersion)
+	img, err := handler.searchImage(imageName)
+	if err != nil && specific {
+		return nil, fail.NotFoundErrorWithCause(
+			fmt.Sprintf(
+				"failed to find default image '%s' configured for architecture '%s' (%s)", imageName, arch,
+				ipVersion.String(),
+			), err,
+		)
+	}
+	return img, err
+}
+
+// searchImage looks for the image 'name' using the image match strategy of the handler
+func (handler *NetworkHandler) searchImage(name string) (*abstract.Image, error) {
+	if handler.imageMatch == iaas.ImageMatchBest {
+		return handler.service.SearchImage(name)
+	}
+	return handler.service.SearchImageWithStrategy(name, handler.imageMatch)
+}
+
+// defaultImageFor returns the default image configured for the architecture 'arch' and IP version 'ipVersion';
+// the returned bool tells if the image comes from an architecture-specific entry of "DefaultImages"
+// (looked up as "<arch>/<ipversion>" then "<arch>") rather than from the generic "DefaultImage"
+func defaultImageFor(cfg providers.Config, arch string, ipVersion ipversion.Enum) (string, bool) {
+	if arch != "" {
+		images := map[string]string{}
+		for k, v := range cfg.GetMapOfStrings("DefaultImages") {
+			images[strings.ToLower(k)] = v
+		}
+		arch = strings.ToLower(arch)
+		if img, ok := images[arch+"/"+strings.ToLower(ipVersion.String())]; ok && img != "" {
+			return img, true
+		}
+		if img, ok := images[arch]; ok && img != "" {
+			return img, true
+		}
+	}
+	return cfg.GetString("DefaultImage"), false
+}
+
+// imageArch returns the canonical CPU architecture of the image, as told by the stack or else as mentioned in
+// the name or description of the image, or an empty string if unknown
+func imageArch(img *abstract.Image) string {
+	if img.Arch != "" {
+		return stacks.NormalizeArch(img.Arch)
+	}
+	return stacks.GuessImageArch(img.Name, img.Description)
+}
+
+// validateGatewayImage checks the image can boot on the template selected for the gateway
+func validateGatewayImage(img *abstract.Image, template *abstract.HostTemplate, arch string) error {
+	if img == nil {
+		return fail.InvalidParameterError("img", "cannot be nil")
+	}
+	if template == nil {
+		return fail.InvalidParameterError("template", "cannot be nil")
+	}
+
+	if arch != "" {
+		wanted := stacks.NormalizeArch(arch)
+		if found := imageArch(img); found != "" && found != wanted {
+			return fail.InvalidRequestError(
+				fmt.Sprintf(
+					"image '%s' is built for architecture '%s', not for the requested '%s'", img.Name, found, arch,
+				),
+			)
+		}
+	}
+	if img.DiskSize > 0 && template.DiskSize > 0 && int64(template.DiskSize) < img.DiskSize {
+		return fail.InvalidRequestError(
+			fmt.Sprintf(
+				"image '%s' needs a disk of at least %d GB, template '%s' only provides %d GB", img.Name,
+				img.DiskSize, template.Name, template.DiskSize,
+			),
+		)
+	}
+	return nil
+}
+
+func compareOsWithRequestedOs(theOs string, requestedOs string) {
+	logrus.Debugf("Analysis of %s vs %s", theOs, requestedOs)
+	frags := strings.Split(theOs, ",")
+	if len(frags) < 3 {
+		return
+	}
+
+	os := fmt.Sprintf("%s %s", frags[2], frags[3])
+
+	var err error
+
+	verRequested := -1
+	ver := -1
+
+	osReqName := ""
+	osName := ""
+
+	if strings.Contains(requestedOs, " ") {
+		fragments := strings.Split(requestedOs, " ")
+		if len(fragments) >= 2 {
+			osReqName = strings.ToUpper(fragments[0])
+			version := fragments[1]
+
+			verRequested, err = strconv.Atoi(version)
+			if err != nil {
+				return
+			}
+		} else {
+			return
+		}
+	}
+
 	if strings.Contains(os, " ") {
 		fragments := strings.Split(os, " ")
 		if len(fragments) >= 2 {
@@ -666,7 +2010,6 @@ func (handler *NetworkHandler) createGateway(t concurrency.Task, params concurre
 	request := inputs["request"].(abstract.GatewayRequest)
 	sizing := inputs["sizing"].(abstract.SizingRequirements)
 	primary := inputs["primary"].(bool)
-	nokeep := inputs["nokeep"].(bool)
 
 	// Check if gateway already exist in SafeScale scope
 	_, err = metadata.LoadHost(handler.service, request.Name)
@@ -713,14 +2056,13 @@ func (handler *NetworkHandler) createGateway(t concurrency.Task, params concurre
 		}
 	}
 
-	// Starting from here, deletes the primary gateway if exiting with error
+	// Starting from here, deletes the gateway if exiting with error, unless asked to keep it
 	defer func() {
-		if err != nil && !nokeep {
-			if forensics := os.Getenv("SAFESCALE_FORENSICS"); forensics != "" {
-				return
-			}
+		if err != nil && request.KeepOnFailure {
+			logrus.Warnf("Keeping gateway '%s' (id '%s') on failure for inspection", request.Name, gw.ID)
+			return
 		}
-		if err != nil && nokeep {
+		if err != nil {
 			logrus.Warnf("Cleaning up on failure, deleting gateway '%s' host resource...", request.Name)
 			derr := handler.service.DeleteHost(gw.ID)
 			if derr != nil {
@@ -752,55 +2094,513 @@ func (handler *NetworkHandler) createGateway(t concurrency.Task, params concurre
 		}
 	}
 
-	userData.UsesVIP = request.Network.VIP != nil
-
-	// Binds gateway to VIP if primary
-	if primary && request.Network.VIP != nil {
-		err = handler.service.BindHostToVIP(request.Network.VIP, gw.ID)
+	userData.UsesVIP = request.Network.VIP != nil
+
+	routeIP, _, err := gatewayRouteIPs(gw, request.Network.IPVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Binds gateway to VIP; every gateway may hold it
+	if request.Network.VIP != nil {
+		err = handler.service.BindHostToVIP(request.Network.VIP, gw.ID)
+		if err != nil {
+			return nil, err
+		}
+		userData.PrivateVIP = request.Network.VIP.PrivateIP
+		// userData.EndpointIP = request.Network.VIP.PublicIP
+	}
+	userData.DefaultRouteIP = routeIP
+	userData.IsPrimaryGateway = primary
+
+	// Updates requested sizing in gateway property propsv1.HostSizing
+	err = gw.Properties.LockForWrite(hostproperty.SizingV1).ThenUse(
+		func(clonable data.Clonable) error {
+			gwSizingV1 := clonable.(*propsv1.HostSizing)
+			gwSizingV1.RequestedSize = &propsv1.HostSize{
+				Cores:     sizing.MinCores,
+				RAMSize:   sizing.MinRAMSize,
+				DiskSize:  sizing.MinDiskSize,
+				GPUNumber: sizing.MinGPU,
+				CPUFreq:   sizing.MinFreq,
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Writes Gateway metadata
+	m, err := metadata.SaveGateway(handler.service, gw, request.Network.ID)
+	if err != nil {
+		return nil, err
+	}
+	result = data.Map{
+		"host":     gw,
+		"userdata": userData,
+		"metadata": m,
+	}
+	return result, nil
+}
+
+// networkGateway contains what is created for a gateway of a network
+type networkGateway struct {
+	host     *abstract.Host
+	userdata *userdata.Content
+	metadata *metadata.Gateway
+}
+
+// extraGatewayNames returns the FQDNs of the gateways of the network 'networkName' beyond the primary and the
+// secondary ones when 'count' gateways are wanted, in the (normalized) domain 'domain'; the gateway of index i is
+// named "gw<i+1>-<network name>"
+func extraGatewayNames(networkName string, count int, domain string) []string {
+	var names []string
+	for i := 2; i < count; i++ {
+		names = append(names, hostFQDN(fmt.Sprintf("gw%d-%s", i+1, networkName), domain))
+	}
+	return names
+}
+
+// createExtraGateways creates in parallel the gateways named 'names' from 'request', beyond the primary and the
+// secondary ones; if one of the creations fails, the gateways created are deleted (unless request.KeepOnFailure
+// is set) and the error is returned
+func (handler *NetworkHandler) createExtraGateways(
+	ctx context.Context, request abstract.GatewayRequest, sizing abstract.SizingRequirements, names []string,
+) (_ []*networkGateway, err error) {
+	requests := make([]abstract.GatewayRequest, len(names))
+	for i, name := range names {
+		requests[i] = request
+		requests[i].Name = name
+		requests[i].KeyPair, err = abstract.NewKeyPair(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := runInParallel(
+		ctx, len(requests), handler.createGateway,
+		func(i int) concurrency.TaskParameters {
+			return data.Map{
+				"request": requests[i],
+				"sizing":  sizing,
+				"primary": false,
+			}
+		},
+	)
+	var gateways []*networkGateway
+	for i, result := range results {
+		if m, ok := result.(data.Map); ok {
+			gw := &networkGateway{
+				host:     m["host"].(*abstract.Host),
+				userdata: m["userdata"].(*userdata.Content),
+				metadata: m["metadata"].(*metadata.Gateway),
+			}
+			gw.userdata.HostName = names[i]
+			gateways = append(gateways, gw)
+		}
+	}
+	if err != nil {
+		if !request.KeepOnFailure {
+			err = fail.AddConsequence(err, handler.deleteExtraGateways(request.Network.VIP, gateways))
+		}
+		return nil, err
+	}
+	return gateways, nil
+}
+
+// deleteExtraGateways deletes the gateways 'gateways', their metadata and their binding to 'vip' (if not nil)
+func (handler *NetworkHandler) deleteExtraGateways(vip *abstract.VirtualIP, gateways []*networkGateway) error {
+	var errs []error
+	for _, gw := range gateways {
+		if derr := handler.deleteGateway(gw.host); derr != nil {
+			errs = append(errs, derr)
+		}
+		if derr := handler.deleteGatewayMetadata(gw.metadata); derr != nil {
+			errs = append(errs, derr)
+		}
+		if vip != nil {
+			if derr := handler.unbindHostFromVIP(vip, gw.host); derr != nil {
+				errs = append(errs, derr)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fail.ErrListError(errs)
+	}
+	return nil
+}
+
+// runInParallel runs 'count' tasks executing 'action' with the parameters returned by 'params' for their index, and
+// waits for all of them; returns the results indexed as the tasks and the first error met
+func runInParallel(
+	ctx context.Context, count int, action concurrency.TaskAction, params func(int) concurrency.TaskParameters,
+) ([]concurrency.TaskResult, error) {
+	var err error
+	tasks := make([]concurrency.Task, 0, count)
+	for i := 0; i < count; i++ {
+		task, terr := concurrency.NewTaskWithContext(ctx)
+		if terr == nil {
+			task, terr = task.Start(action, params(i))
+		}
+		if terr != nil {
+			err = terr
+			break
+		}
+		tasks = append(tasks, task)
+	}
+
+	results := make([]concurrency.TaskResult, len(tasks))
+	for i, task := range tasks {
+		result, werr := task.Wait()
+		if werr != nil && err == nil {
+			err = werr
+		}
+		results[i] = result
+	}
+	return results, err
+}
+
+// Phases of the configuration of a gateway, recorded in its metadata
+const (
+	gatewayPhaseInit          = "init"
+	gatewayPhaseConfiguration = "configuration"
+	gatewayPhaseIngressPorts  = "ingress-ports"
+	gatewayPhaseReboot        = "reboot"
+	gatewayPhaseReady         = "ready"
+)
+
+// gatewayPhaseRecorder records in the metadata of a gateway the progress of its configuration; failing to record
+// the progress is logged but doesn't fail the configuration
+type gatewayPhaseRecorder struct {
+	gw   *abstract.Host
+	save func(*abstract.Host) error
+}
+
+// newGatewayPhaseRecorder returns a gatewayPhaseRecorder saving the metadata of 'gw'
+func (handler *NetworkHandler) newGatewayPhaseRecorder(gw *abstract.Host) *gatewayPhaseRecorder {
+	return &gatewayPhaseRecorder{
+		gw: gw,
+		save: func(host *abstract.Host) error {
+			_, err := metadata.SaveHost(handler.service, host)
+			return err
+		},
+	}
+}
+
+// enter records the current phase as completed and 'phase' as the current one
+func (r *gatewayPhaseRecorder) enter(phase string) {
+	r.update(
+		func(phases *propsv1.HostGatewayPhases) {
+			if phases.Current != "" && !phases.Failed() {
+				phases.Completed = append(phases.Completed, phases.Current)
+			}
+			phases.Current = phase
+			phases.Error = ""
+		},
+	)
+}
+
+// finish records the current phase as completed if *err is nil, as failed with *err otherwise
+func (r *gatewayPhaseRecorder) finish(err *error) {
+	r.update(
+		func(phases *propsv1.HostGatewayPhases) {
+			if err != nil && *err != nil {
+				phases.Error = (*err).Error()
+				return
+			}
+			if phases.Current != "" && !phases.Failed() {
+				phases.Completed = append(phases.Completed, phases.Current)
+			}
+			phases.Current = ""
+		},
+	)
+}
+
+// restart records 'phase' as the current one and not completed anymore, to run it again
+func (r *gatewayPhaseRecorder) restart(phase string) {
+	r.update(
+		func(phases *propsv1.HostGatewayPhases) {
+			completed := make([]string, 0, len(phases.Completed))
+			for _, v := range phases.Completed {
+				if v != phase {
+					completed = append(completed, v)
+				}
+			}
+			phases.Completed = completed
+			phases.Current = phase
+			phases.Error = ""
+		},
+	)
+}
+
+func (r *gatewayPhaseRecorder) update(fn func(*propsv1.HostGatewayPhases)) {
+	err := r.gw.Properties.LockForWrite(hostproperty.GatewayPhasesV1).ThenUse(
+		func(clonable data.Clonable) error {
+			phases := clonable.(*propsv1.HostGatewayPhases)
+			fn(phases)
+			phases.Updated = time.Now()
+			return nil
+		},
+	)
+	if err == nil {
+		err = r.save(r.gw)
+	}
+	if err != nil {
+		logrus.Warnf("failed to record the configuration phase of gateway '%s': %v", r.gw.Name, err)
+	}
+}
+
+// gatewayPhases contains the phases of the configuration of a gateway, in order
+var gatewayPhases = []string{
+	gatewayPhaseInit, gatewayPhaseConfiguration, gatewayPhaseIngressPorts, gatewayPhaseReboot, gatewayPhaseReady,
+}
+
+// isGatewayPhase tells if 'phase' is a phase of the configuration of a gateway
+func isGatewayPhase(phase string) bool {
+	for _, v := range gatewayPhases {
+		if v == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// RerunGatewayPhase runs again the phase 'phase' of the configuration of the gateway referenced by 'gatewayRef' (ID
+// or name) of the network referenced by 'networkRef', to recover from a transient failure without recreating the
+// network; the progress is recorded in the metadata of the gateway as during the creation.
+// The script of the configuration phase is regenerated from the metadata of the network with the template recorded
+// at its creation. For a network with failover, the configuration phase is run again on every gateway of the
+// network, with a new keepalived password shared by the gateways, and the gateways are bound again to the VIP.
+func (handler *NetworkHandler) RerunGatewayPhase(ctx context.Context, networkRef, gatewayRef, phase string) (err error) {
+	if handler == nil {
+		return fail.InvalidInstanceError()
+	}
+	if networkRef == "" {
+		return fail.InvalidParameterError("networkRef", "cannot be empty string")
+	}
+	if gatewayRef == "" {
+		return fail.InvalidParameterError("gatewayRef", "cannot be empty string")
+	}
+	if !isGatewayPhase(phase) {
+		return fail.InvalidParameterError(
+			"phase", fmt.Sprintf("unknown phase '%s', must be one of %s", phase, strings.Join(gatewayPhases, ", ")),
+		)
+	}
+
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s', '%s', '%s')", networkRef, gatewayRef, phase), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	mn, err := metadata.LoadNetwork(handler.service, networkRef)
+	if err != nil {
+		return err
+	}
+	network, err := mn.Get()
+	if err != nil {
+		return err
+	}
+	gateways, err := networkGateways(mn, network)
+	if err != nil {
+		return err
+	}
+	var gw *abstract.Host
+	for _, v := range gateways {
+		if v.ID == gatewayRef || v.Name == gatewayRef {
+			gw = v
+			break
+		}
+	}
+	if gw == nil {
+		return fail.NotFoundError(fmt.Sprintf("no gateway '%s' in network '%s'", gatewayRef, network.Name))
+	}
+
+	if phase == gatewayPhaseConfiguration && (len(gateways) > 1 || network.VIP != nil) {
+		return handler.rerunFailoverConfiguration(ctx, mn, network, gateways)
+	}
+	step, err := handler.gatewayPhaseStep(ctx, mn, gw, phase)
+	if err != nil {
+		return err
+	}
+	return rerunGatewayPhase(handler.newGatewayPhaseRecorder(gw), phase, step)
+}
+
+// networkGateways returns the gateways of the network 'mn', in order of creation
+func networkGateways(mn *metadata.Network, network *abstract.Network) ([]*abstract.Host, error) {
+	ids, err := metadata.GatewayIDsOf(network)
+	if err != nil {
+		return nil, err
+	}
+	gateways := make([]*abstract.Host, 0, len(ids))
+	for i := range ids {
+		gw, err := mn.GetGatewayByIndex(i)
+		if err != nil {
+			return nil, err
+		}
+		gateways = append(gateways, gw)
+	}
+	return gateways, nil
+}
+
+// rerunFailoverConfiguration runs again the configuration phase on all the gateways of the network 'mn', which is
+// in failover; a gateway failing to be configured doesn't prevent the others to be, the failures are returned together
+func (handler *NetworkHandler) rerunFailoverConfiguration(
+	ctx context.Context, mn *metadata.Network, network *abstract.Network, gateways []*abstract.Host,
+) error {
+	userDatas, customTemplate, err := handler.failoverGatewayConfigurations(mn, network, gateways)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, gw := range gateways {
+		gw, userData := gw, userDatas[i]
+		err := rerunGatewayPhase(
+			handler.newGatewayPhaseRecorder(gw), gatewayPhaseConfiguration, func() error {
+				return handler.runGatewayScript(ctx, gw, userData, customTemplate)
+			},
+		)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fail.ErrListError(errs)
+	}
+	return nil
+}
+
+// failoverGatewayConfigurations returns the userdata configuring each of the gateways of the network 'mn', which is
+// in failover, and the custom template recorded at the creation of the network ("" for the built-in one).
+// As at the creation, the first gateway is the primary one and the others are configured as the secondary one, with
+// a new keepalived password shared by all of them; the gateways are bound again to the VIP of the network, if any.
+func (handler *NetworkHandler) failoverGatewayConfigurations(
+	mn *metadata.Network, network *abstract.Network, gateways []*abstract.Host,
+) ([]*userdata.Content, string, error) {
+	if len(gateways) == 0 {
+		return nil, "", fail.InvalidParameterError("gateways", "cannot be empty slice")
+	}
+
+	keepalivedPassword, err := utils.GeneratePassword(16)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate keepalived password: %v", err)
+	}
+	primaryPrivateIP, primaryPublicIP, err := gatewayRouteIPs(gateways[0], network.IPVersion)
+	if err != nil {
+		return nil, "", err
+	}
+	var secondaryPrivateIP, secondaryPublicIP string
+	if len(gateways) > 1 {
+		secondaryPrivateIP, secondaryPublicIP, err = gatewayRouteIPs(gateways[1], network.IPVersion)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	var customTemplate string
+	userDatas := make([]*userdata.Content, 0, len(gateways))
+	for i, gw := range gateways {
+		var userData *userdata.Content
+		userData, customTemplate, err = handler.gatewayConfiguration(mn, network, gw)
+		if err != nil {
+			return nil, "", err
+		}
+		userData.IsPrimaryGateway = i == 0
+		userData.PrimaryGatewayPrivateIP = primaryPrivateIP
+		userData.PrimaryGatewayPublicIP = primaryPublicIP
+		userData.SecondaryGatewayPrivateIP = secondaryPrivateIP
+		userData.SecondaryGatewayPublicIP = secondaryPublicIP
+		userData.GatewayHAKeepalivedPassword = keepalivedPassword
+
+		userData.UsesVIP = network.VIP != nil
+		if network.VIP != nil {
+			err = handler.service.BindHostToVIP(network.VIP, gw.ID)
+			if err != nil {
+				return nil, "", err
+			}
+			userData.PrivateVIP = network.VIP.PrivateIP
+		}
+		userDatas = append(userDatas, userData)
+	}
+	return userDatas, customTemplate, nil
+}
+
+// gatewayPhaseStep returns the function running the phase 'phase' of the configuration of the gateway 'gw' of the
+// network 'mn'; the configuration phase of a network in failover is run by rerunFailoverConfiguration instead
+func (handler *NetworkHandler) gatewayPhaseStep(
+	ctx context.Context, mn *metadata.Network, gw *abstract.Host, phase string,
+) (func() error, error) {
+	switch phase {
+	case gatewayPhaseInit:
+		return func() error {
+			_, err := handler.waitGatewayInit(ctx, gw)
+			return err
+		}, nil
+	case gatewayPhaseConfiguration:
+		network, err := mn.Get()
 		if err != nil {
 			return nil, err
 		}
-		userData.PrivateVIP = request.Network.VIP.PrivateIP
-		// userData.DefaultRouteIP = request.Network.VIP.PrivateIP
-		userData.DefaultRouteIP = gw.GetPrivateIP()
-		// userData.EndpointIP = request.Network.VIP.PublicIP
-	} else {
-		if request.Network.VIP != nil {
-			userData.PrivateVIP = request.Network.VIP.PrivateIP
+		userData, customTemplate, err := handler.gatewayConfiguration(mn, network, gw)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			return handler.runGatewayScript(ctx, gw, userData, customTemplate)
+		}, nil
+	case gatewayPhaseIngressPorts:
+		ports, err := recordedIngressPorts(mn)
+		if err != nil {
+			return nil, err
 		}
-		userData.DefaultRouteIP = gw.GetPrivateIP()
+		return func() error {
+			if len(ports) == 0 {
+				return nil
+			}
+			return handler.openGatewayIngressPorts(ctx, gw, ports)
+		}, nil
+	case gatewayPhaseReboot:
+		return func() error {
+			return handler.rebootGateway(ctx, gw)
+		}, nil
+	case gatewayPhaseReady:
+		return func() error {
+			return handler.waitGatewayReady(ctx, gw)
+		}, nil
+	default:
+		return nil, fail.InvalidParameterError("phase", fmt.Sprintf("unknown phase '%s'", phase))
 	}
-	userData.IsPrimaryGateway = primary
+}
 
-	// Updates requested sizing in gateway property propsv1.HostSizing
-	err = gw.Properties.LockForWrite(hostproperty.SizingV1).ThenUse(
-		func(clonable data.Clonable) error {
-			gwSizingV1 := clonable.(*propsv1.HostSizing)
-			gwSizingV1.RequestedSize = &propsv1.HostSize{
-				Cores:     sizing.MinCores,
-				RAMSize:   sizing.MinRAMSize,
-				DiskSize:  sizing.MinDiskSize,
-				GPUNumber: sizing.MinGPU,
-				CPUFreq:   sizing.MinFreq,
-			}
-			return nil
-		},
-	)
+// gatewayConfiguration returns the userdata configuring the gateway 'gw' of the network 'mn', regenerated from the
+// metadata of the network, and the custom template recorded at the creation of the network ("" for the built-in one)
+func (handler *NetworkHandler) gatewayConfiguration(
+	mn *metadata.Network, network *abstract.Network, gw *abstract.Host,
+) (*userdata.Content, string, error) {
+	ip, _, err := gatewayRouteIPs(gw, network.IPVersion)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	// Writes Gateway metadata
-	m, err := metadata.SaveGateway(handler.service, gw, request.Network.ID)
+	userData, err := handler.externalGatewayUserdata(network, gw, ip)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	result = data.Map{
-		"host":     gw,
-		"userdata": userData,
-		"metadata": m,
+	spec, err := recordedCreationSpec(mn)
+	if err != nil {
+		return nil, "", err
 	}
-	return result, nil
+	userData.MTU = spec.MTU
+	userData.IngressPorts = spec.AdditionalIngressPorts
+	return userData, spec.GatewayTemplate, nil
+}
+
+// rerunGatewayPhase runs 'step' as the phase 'phase' of the configuration of a gateway, recording its progress
+// with 'phases'
+func rerunGatewayPhase(phases *gatewayPhaseRecorder, phase string, step func() error) (err error) {
+	phases.restart(phase)
+	defer phases.finish(&err)
+
+	return step()
 }
 
 func (handler *NetworkHandler) waitForInstallPhase1OnGateway(
@@ -808,46 +2608,108 @@ func (handler *NetworkHandler) waitForInstallPhase1OnGateway(
 ) (result concurrency.TaskResult, err error) {
 	gw := params.(*abstract.Host)
 
+	phases := handler.newGatewayPhaseRecorder(gw)
+	phases.enter(gatewayPhaseInit)
+	defer phases.finish(&err)
+
+	out, err := handler.waitGatewayInit(task.GetContext(), gw)
+	if err != nil {
+		return nil, err
+	}
+	if out != "" {
+		return out, nil
+	}
+	return nil, nil
+}
+
+// waitGatewayInit waits until the phase 1 of userdata is done on the gateway 'gw' and returns its output
+func (handler *NetworkHandler) waitGatewayInit(ctx context.Context, gw *abstract.Host) (string, error) {
 	// A host claimed ready by a Cloud provider is not necessarily ready
 	// to be used until ssh service is up and running. So we wait for it before
 	// claiming host is created
 	logrus.Infof("Waiting until gateway '%s' is available by SSH ...", gw.Name)
 	sshHandler := NewSSHHandler(handler.service)
-	ssh, err := sshHandler.GetConfig(task.GetContext(), gw.ID)
+	ssh, err := sshHandler.GetConfig(ctx, gw.ID)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	logrus.Debugf("Provisioning gateway '%s', phase 1", gw.Name)
 
-	var out string
-	out, err = ssh.WaitServerReady("phase1", temporal.GetHostCreationTimeout())
+	out, err := ssh.WaitServerReady("phase1", temporal.GetHostCreationTimeout())
 	if err != nil {
 		if client.IsTimeoutError(err) {
-			return nil, err
+			return "", err
 		}
 		if client.IsProvisioningError(err) {
 			host, err := handler.service.GetHostByName(gw.Name)
 			if err != nil {
-				retrieveForensicsData(task.GetContext(), sshHandler, host)
+				retrieveForensicsData(ctx, sshHandler, host)
 			}
 
-			return nil, fmt.Errorf(
+			return "", fmt.Errorf(
 				"error creating network: Failure waiting for gateway '%s' to finish provisioning and being accessible through SSH: [%+v]",
 				gw.Name, err,
 			)
 		}
-		return nil, err
+		return "", err
 	}
 
 	logrus.Infof("SSH service of gateway '%s' started.", gw.Name)
 
 	if out != "" {
 		logrus.Infof("received output from phase 1: %s", out)
-		return out, nil
 	}
+	return out, nil
+}
 
-	return nil, nil
+// gatewayRouteIPs returns the private and public IPs of the gateway 'gw' of the family of the network, used to
+// configure the routes of the network; fails if the gateway has no private IP of this family
+func gatewayRouteIPs(gw *abstract.Host, ipVersion ipversion.Enum) (private string, public string, err error) {
+	if ipVersion != ipversion.IPv6 {
+		private, public = gw.GetPrivateIP(), gw.GetPublicIP()
+		if private != "" && !ipversion.IPv4.Is(private) {
+			return "", "", fail.InvalidRequestError(
+				fmt.Sprintf("gateway '%s' has no IPv4 private address to route the IPv4 network", gw.Name),
+			)
+		}
+		return private, public, nil
+	}
+
+	err = gw.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+			private = hostNetworkV1.IPv6Addresses[hostNetworkV1.DefaultNetworkID]
+			if private == "" {
+				for _, ip := range hostNetworkV1.IPv6Addresses {
+					if ip != "" {
+						private = ip
+						break
+					}
+				}
+			}
+			public = hostNetworkV1.PublicIPv6
+			return nil
+		},
+	)
+	if err != nil {
+		return "", "", err
+	}
+	if private == "" {
+		return "", "", fail.InvalidRequestError(
+			fmt.Sprintf("gateway '%s' has no IPv6 private address to route the IPv6 network", gw.Name),
+		)
+	}
+	return private, public, nil
+}
+
+// generateGatewayScript generates the script configuring a gateway from the custom template 'customTemplate',
+// or from the built-in template if empty
+func generateGatewayScript(userData *userdata.Content, customTemplate string) ([]byte, error) {
+	if customTemplate != "" {
+		return userData.GenerateFromTemplate("phase2", customTemplate)
+	}
+	return userData.Generate("phase2")
 }
 
 func (handler *NetworkHandler) installPhase2OnGateway(task concurrency.Task, params concurrency.TaskParameters) (result concurrency.TaskResult, err error) {
@@ -864,7 +2726,7 @@ func (handler *NetworkHandler) installPhase2OnGateway(task concurrency.Task, par
 	}
 
 	// Executes userdata phase2 script to finalize host installation
-	tracer := debug.NewTracer(nil, fmt.Sprintf("(%s)", gw.Name), true).WithStopwatch().GoingIn()
+	tracer := debug.NewTracer(nil, fmt.Sprintf("(%s)", gw.Name), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 	defer temporal.NewStopwatch().OnExitLogInfo(
@@ -872,76 +2734,235 @@ func (handler *NetworkHandler) installPhase2OnGateway(task concurrency.Task, par
 		fmt.Sprintf("Ending configuration phase 2 on the gateway '%s'", gw.Name),
 	)()
 
-	content, err := userData.Generate("phase2")
+	phases := handler.newGatewayPhaseRecorder(gw)
+	phases.enter(gatewayPhaseConfiguration)
+	defer phases.finish(&err)
+
+	ctx := task.GetContext()
+	customTemplate, _ := params.(data.Map)["template"].(string)
+	err = handler.runGatewayScript(ctx, gw, userData, customTemplate)
 	if err != nil {
 		return nil, err
 	}
-	pbHost, err := safescaleutils.ToPBHost(gw)
+
+	// Opens additional ingress ports in the security rules of the provider
+	if ports, ok := params.(data.Map)["ports"].([]int); ok && len(ports) > 0 {
+		phases.enter(gatewayPhaseIngressPorts)
+		err = handler.openGatewayIngressPorts(ctx, gw, ports)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	phases.enter(gatewayPhaseReboot)
+	err = handler.rebootGateway(ctx, gw)
 	if err != nil {
 		return nil, err
 	}
-	err = install.UploadStringToRemoteFile(string(content), pbHost, utils.TempFolder+"/user_data.phase2.sh", "", "", "")
+
+	phases.enter(gatewayPhaseReady)
+	err = handler.waitGatewayReady(ctx, gw)
 	if err != nil {
 		return nil, err
 	}
+	return nil, nil
+}
+
+// runGatewayScript uploads and executes on the gateway 'gw' the script configuring it (phase 2 of userdata)
+func (handler *NetworkHandler) runGatewayScript(
+	ctx context.Context, gw *abstract.Host, userData *userdata.Content, customTemplate string,
+) error {
+	content, err := generateGatewayScript(userData, customTemplate)
+	if err != nil {
+		return err
+	}
+	pbHost, err := safescaleutils.ToPBHost(gw)
+	if err != nil {
+		return err
+	}
+	err = install.UploadStringToRemoteFile(string(content), pbHost, utils.TempFolder+"/user_data.phase2.sh", "", "", "")
+	if err != nil {
+		return err
+	}
 	command := fmt.Sprintf("sudo bash %s/%s; exit $?", utils.TempFolder, "user_data.phase2.sh")
 	sshHandler := NewSSHHandler(handler.service)
 
 	// logrus.Debugf("Configuring gateway '%s', phase 2", gw.Name)
-	returnCode, _, _, err := sshHandler.Run(task.GetContext(), gw.Name, command, outputs.COLLECT)
+	returnCode, _, _, err := sshHandler.Run(ctx, gw.Name, command, outputs.COLLECT)
 	if err != nil {
-		retrieveForensicsData(task.GetContext(), sshHandler, gw)
+		retrieveForensicsData(ctx, sshHandler, gw)
 
-		return nil, err
+		return err
 	}
 	if returnCode != 0 {
-		retrieveForensicsData(task.GetContext(), sshHandler, gw)
+		retrieveForensicsData(ctx, sshHandler, gw)
 
-		warnings, errs := getPhaseWarningsAndErrors(task.GetContext(), sshHandler, gw)
+		warnings, errs := getPhaseWarningsAndErrors(ctx, sshHandler, gw)
 
-		return nil, fmt.Errorf(
+		return fmt.Errorf(
 			"failed to finalize gateway '%s' installation: errorcode '%d', warnings '%s', errors '%s'", gw.Name,
 			returnCode, warnings, errs,
 		)
 	}
 
 	// retrieve data anyway
-	retrieveForensicsData(task.GetContext(), sshHandler, gw)
+	retrieveForensicsData(ctx, sshHandler, gw)
 
 	logrus.Infof("Gateway '%s' successfully configured.", gw.Name)
+	return nil
+}
 
-	// Reboot gateway
-	logrus.Debugf("Rebooting gateway '%s'", gw.Name)
-	command = "sudo systemctl reboot"
-	returnCode, _, _, err = sshHandler.Run(task.GetContext(), gw.Name, command, outputs.COLLECT)
+// defaultSecurityGroupName returns the name of the default security group of the network named 'networkName'
+func defaultSecurityGroupName(networkName string) string {
+	return stacks.DefaultSecurityGroupName + "." + networkName
+}
+
+// createDefaultSecurityGroup creates the default security group of 'network', built from the default rules (see
+// stacks.DefaultSecurityGroupRules), and records its ID in 'network'.
+// A provider not managing security groups is not an error: the network has no default security group then
+func (handler *NetworkHandler) createDefaultSecurityGroup(network *abstract.Network) error {
+	name := defaultSecurityGroupName(network.Name)
+	logrus.Debugf("Creating default security group '%s' ...", name)
+	sg, err := handler.service.CreateSecurityGroup(
+		name, fmt.Sprintf("Default security group of network %s", network.Name), stacks.DefaultSecurityGroupRules(),
+	)
+	if err != nil {
+		if _, ok := err.(fail.ErrNotImplemented); ok {
+			logrus.Debugf("provider doesn't manage security groups, network '%s' has no default security group", network.Name)
+			return nil
+		}
+		return fail.Wrap(err, fmt.Sprintf("failed to create default security group of network '%s'", network.Name))
+	}
+	network.DefaultSecurityGroupID = sg.ID
+	return nil
+}
+
+// bindDefaultSecurityGroup applies the default security group of 'network', if any, to the gateways 'gatewayIDs'
+func (handler *NetworkHandler) bindDefaultSecurityGroup(network *abstract.Network, gatewayIDs []string) error {
+	sgID := network.GetDefaultSecurityGroup()
+	if sgID == "" {
+		return nil
+	}
+	for _, id := range gatewayIDs {
+		err := handler.service.BindSecurityGroupToHost(sgID, id)
+		if err != nil {
+			return fail.Wrap(err, fmt.Sprintf("failed to bind default security group to gateway '%s'", id))
+		}
+	}
+	return nil
+}
+
+// deleteDefaultSecurityGroup deletes the default security group of 'network', if any
+func (handler *NetworkHandler) deleteDefaultSecurityGroup(network *abstract.Network) error {
+	sgID := network.GetDefaultSecurityGroup()
+	if sgID == "" {
+		return nil
+	}
+	err := handler.service.DeleteSecurityGroup(sgID)
+	if err != nil {
+		return fail.Wrap(err, fmt.Sprintf("failed to delete default security group of network '%s'", network.Name))
+	}
+	return nil
+}
+
+// openGatewayIngressPorts opens the TCP ports 'ports' of the gateway 'gw' with security rules of the provider;
+// the firewall of the gateway itself opens them when the gateway is configured (see userdata.Content.IngressPorts)
+func (handler *NetworkHandler) openGatewayIngressPorts(ctx context.Context, gw *abstract.Host, ports []int) error {
+	err := checkCanceled(ctx, "opening of the ingress ports of gateway "+gw.Name)
+	if err != nil {
+		return err
+	}
+
+	logrus.Debugf("Opening ingress ports %v on gateway '%s'", ports, gw.Name)
+	err = handler.service.ApplySecurityRules(gw.ID, ingressPortsRules(ports))
+	if err != nil {
+		if _, ok := err.(fail.ErrNotImplemented); ok {
+			// The default security group of the provider already lets all the traffic in
+			logrus.Debugf("provider cannot filter the traffic of gateway '%s', no security rule to apply", gw.Name)
+			return nil
+		}
+		return fail.Wrap(err, fmt.Sprintf("failed to open ingress ports on gateway '%s'", gw.Name))
+	}
+	return nil
+}
+
+// ingressPortsRules builds the security rules opening the TCP ports 'ports' to anyone
+func ingressPortsRules(ports []int) []abstract.SecurityGroupRule {
+	rules := make([]abstract.SecurityGroupRule, 0, len(ports))
+	for _, v := range ports {
+		rules = append(rules, abstract.SecurityGroupRule{
+			Description: fmt.Sprintf("ingress port %d", v),
+			Direction:   abstract.SecurityGroupRuleIngress,
+			Protocol:    "tcp",
+			PortFrom:    v,
+			Targets:     []string{"0.0.0.0/0"},
+		})
+	}
+	return rules
+}
+
+// recordedCreationSpec returns the creation parameters recorded for the network 'mn'; empty ones if the creation
+// parameters are not recorded
+func recordedCreationSpec(mn *metadata.Network) (*propsv1.NetworkCreationSpec, error) {
+	spec, err := mn.GetCreationSpec()
+	if err != nil {
+		if _, ok := err.(fail.ErrNotFound); ok {
+			return propsv1.NewNetworkCreationSpec(), nil
+		}
+		return nil, err
+	}
+	return spec, nil
+}
+
+// recordedIngressPorts returns the additional ingress ports recorded in the creation parameters of the network
+// 'mn'; none if the creation parameters are not recorded
+func recordedIngressPorts(mn *metadata.Network) ([]int, error) {
+	spec, err := recordedCreationSpec(mn)
 	if err != nil {
 		return nil, err
 	}
+	return spec.AdditionalIngressPorts, nil
+}
+
+// rebootGateway reboots the gateway 'gw', without waiting for it to be back
+func (handler *NetworkHandler) rebootGateway(ctx context.Context, gw *abstract.Host) error {
+	logrus.Debugf("Rebooting gateway '%s'", gw.Name)
+	sshHandler := NewSSHHandler(handler.service)
+	command := "sudo systemctl reboot"
+	returnCode, _, _, err := sshHandler.Run(ctx, gw.Name, command, outputs.COLLECT)
+	if err != nil {
+		return err
+	}
 	if returnCode != 0 && returnCode != 255 {
 		logrus.Warnf("Unexpected problem rebooting (retcode=%d)", returnCode)
 	}
+	return nil
+}
 
-	ssh, err := sshHandler.GetConfig(task.GetContext(), gw.ID)
+// waitGatewayReady waits until the gateway 'gw' is reachable by SSH and reports its configuration done
+func (handler *NetworkHandler) waitGatewayReady(ctx context.Context, gw *abstract.Host) error {
+	sshHandler := NewSSHHandler(handler.service)
+	ssh, err := sshHandler.GetConfig(ctx, gw.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	sshDefaultTimeout := temporal.GetHostTimeout()
 	_, err = ssh.WaitServerReady("ready", sshDefaultTimeout)
 	if err != nil {
 		if client.IsTimeoutError(err) {
-			return nil, err
+			return err
 		}
 		if client.IsProvisioningError(err) {
 			logrus.Errorf("%+v", err)
-			return nil, fmt.Errorf(
+			return fmt.Errorf(
 				"error creating network: Failure waiting for gateway '%s' to finish provisioning and being accessible through SSH",
 				gw.Name,
 			)
 		}
-		return nil, err
+		return err
 	}
-	return nil, nil
+	return nil
 }
 
 func (handler *NetworkHandler) deleteGateway(gw *abstract.Host) (err error) {
@@ -992,7 +3013,7 @@ func (handler *NetworkHandler) unbindHostFromVIP(vip *abstract.VirtualIP, host *
 
 // List returns the network list
 func (handler *NetworkHandler) List(ctx context.Context, all bool) (netList []*abstract.Network, err error) {
-	tracer := debug.NewTracer(nil, fmt.Sprintf("(%v)", all), true).WithStopwatch().GoingIn()
+	tracer := debug.NewTracer(nil, fmt.Sprintf("(%v)", all), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -1020,7 +3041,7 @@ func (handler *NetworkHandler) List(ctx context.Context, all bool) (netList []*a
 
 // Inspect returns the network identified by ref, ref can be the name or the id
 func (handler *NetworkHandler) Inspect(ctx context.Context, ref string) (network *abstract.Network, err error) {
-	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s')", ref), true).WithStopwatch().GoingIn()
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s')", ref), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -1032,35 +3053,223 @@ func (handler *NetworkHandler) Inspect(ctx context.Context, ref string) (network
 	return mn.Get()
 }
 
+// WaitGatewaySSHReady waits until the SSH server of a gateway of the network is reachable, starting with the primary
+// gateway if 'primary' is true; on HA networks the other gateway is tried if the first one is not ready.
+// Returns the gateway that responded, or a fail.ErrNotAvailable if none did.
+func (handler *NetworkHandler) WaitGatewaySSHReady(
+	ctx context.Context, ref string, primary bool, timeout time.Duration,
+) (gw *abstract.Host, err error) {
+	if handler == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if ctx == nil {
+		return nil, fail.InvalidParameterError("ctx", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s', %v, %v)", ref, primary, timeout), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	mn, err := metadata.LoadNetwork(handler.service, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	sshHandler := NewSSHHandler(handler.service)
+	return waitGatewaySSHReady(
+		primary, mn.GetGatewayE, func(host *abstract.Host) error {
+			return sshHandler.WaitServerReady(ctx, host, timeout)
+		},
+	)
+}
+
+// waitGatewaySSHReady does the real work of WaitGatewaySSHReady, using 'load' to read the gateways and 'wait' to
+// wait for their SSH server
+func waitGatewaySSHReady(
+	primary bool, load func(bool) (*abstract.Host, bool, error), wait func(*abstract.Host) error,
+) (*abstract.Host, error) {
+	var errs []error
+	for _, p := range []bool{primary, !primary} {
+		host, exists, err := load(p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !exists {
+			continue
+		}
+		err = wait(host)
+		if err == nil {
+			return host, nil
+		}
+		logrus.Warnf("SSH of gateway '%s' is not ready: %v", host.Name, err)
+		errs = append(errs, err)
+	}
+	return nil, fail.AddConsequence(fail.NotAvailableError("no gateway available"), fail.ErrListError(errs))
+}
+
+// GetActiveGateway returns the gateway of the network currently holding the VIP for HA networks,
+// or the primary gateway otherwise. Returns a fail.ErrNotAvailable if no gateway can be reached.
+func (handler *NetworkHandler) GetActiveGateway(ctx context.Context, ref string) (gw *abstract.Host, err error) {
+	if handler == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if ctx == nil {
+		return nil, fail.InvalidParameterError("ctx", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s')", ref), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	mn, err := metadata.LoadNetwork(handler.service, ref)
+	if err != nil {
+		return nil, err
+	}
+	network, err := mn.Get()
+	if err != nil {
+		return nil, err
+	}
+	if network.VIP == nil || network.SecondaryGatewayID == "" {
+		return mn.GetGateway(true)
+	}
+
+	var gateways []*abstract.Host
+	for _, primary := range []bool{true, false} {
+		host, err := mn.GetGateway(primary)
+		if err != nil {
+			logrus.Warnf("failed to load gateway of network '%s': %v", network.Name, err)
+			continue
+		}
+		gateways = append(gateways, host)
+	}
+
+	sshHandler := NewSSHHandler(handler.service)
+	return activeGateway(
+		network.VIP.PrivateIP, gateways, func(host *abstract.Host, vip string) (bool, error) {
+			result, err := sshHandler.run(
+				ctx, host.Name, holdsVIPCommand(vip), outputs.COLLECT, temporal.GetConnectionTimeout(),
+				temporal.GetConnectionTimeout(),
+			)
+			if err != nil {
+				return false, err
+			}
+			if result.Phase != runphase.COMPLETED {
+				return false, fail.NotAvailableError(fmt.Sprintf("cannot reach gateway '%s' by SSH", host.Name))
+			}
+			return result.ExitCode == 0, nil
+		},
+	)
+}
+
+// holdsVIPCommand builds the command succeeding only if the host has the IP 'vip' configured on an interface
+func holdsVIPCommand(vip string) string {
+	return fmt.Sprintf("ip -o addr show | grep -qF ' %s/'", vip)
+}
+
+// activeGateway returns the first gateway holding 'vip' according to 'holdsVIP'; if none holds it, returns the
+// first gateway reachable, or a fail.ErrNotAvailable if none is
+func activeGateway(
+	vip string, gateways []*abstract.Host, holdsVIP func(*abstract.Host, string) (bool, error),
+) (*abstract.Host, error) {
+	var (
+		reachable *abstract.Host
+		errs      []error
+	)
+	for _, gw := range gateways {
+		holds, err := holdsVIP(gw, vip)
+		if err != nil {
+			logrus.Warnf("cannot check if gateway '%s' holds VIP %s: %v", gw.Name, vip, err)
+			errs = append(errs, err)
+			continue
+		}
+		if holds {
+			return gw, nil
+		}
+		if reachable == nil {
+			reachable = gw
+		}
+	}
+	if reachable != nil {
+		logrus.Warnf("no gateway holds VIP %s, using gateway '%s'", vip, reachable.Name)
+		return reachable, nil
+	}
+	return nil, fail.AddConsequence(fail.NotAvailableError("no gateway available"), fail.ErrListError(errs))
+}
+
 // Delete deletes network referenced by ref
-func (handler *NetworkHandler) Delete(ctx context.Context, ref string) (err error) {
-	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s')", ref), true).WithStopwatch().GoingIn()
+func (handler *NetworkHandler) Delete(ctx context.Context, ref string) error {
+	_, err := handler.DeleteWithReport(ctx, ref)
+	return err
+}
+
+// DeleteWithReport deletes network referenced by ref and reports what happened to its gateways and to each step of
+// the deletion, even if the deletion fails; a gateway already gone or failing to be deleted on provider side doesn't
+// stop the deletion
+func (handler *NetworkHandler) DeleteWithReport(ctx context.Context, ref string) (report *NetworkTeardownReport, err error) {
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s')", ref), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
+	report = &NetworkTeardownReport{Network: ref}
+
 	mn, err := metadata.LoadNetwork(handler.service, ref)
 	if err != nil {
-		if _, ok := err.(fail.ErrNotFound); !ok {
-			cleanErr := handler.service.DeleteNetwork(ref)
-			if cleanErr != nil {
-				switch cleanErr.(type) {
-				case fail.ErrNotFound, fail.ErrTimeout:
-					logrus.Warnf(
-						"error deleting network on cleanup after failure to load metadata '%s': %v", ref, cleanErr,
-					)
-				default:
-					logrus.Warnf(
-						"error deleting network on cleanup after failure to load metadata '%s': %v", ref, cleanErr,
-					)
-				}
+		if _, ok := err.(fail.ErrNotFound); ok {
+			// Already deleted (by a previous call, for example): the deletion is a success
+			logrus.Debugf("network '%s' not found, considering it as already deleted", ref)
+			report.record(TeardownMetadata, TeardownAlreadyGone, nil)
+			return report, nil
+		}
+		cleanErr := handler.service.DeleteNetwork(ref)
+		if cleanErr != nil {
+			switch cleanErr.(type) {
+			case fail.ErrNotFound, fail.ErrTimeout:
+				logrus.Warnf(
+					"error deleting network on cleanup after failure to load metadata '%s': %v", ref, cleanErr,
+				)
+			default:
+				logrus.Warnf(
+					"error deleting network on cleanup after failure to load metadata '%s': %v", ref, cleanErr,
+				)
 			}
-			err = fail.AddConsequence(err, cleanErr)
 		}
-		return err
+		err = fail.AddConsequence(err, cleanErr)
+		return report, err
 	}
 	network, err := mn.Get()
 	if err != nil {
-		return err
+		return report, err
+	}
+
+	// A deletion of the same network already in progress (requested by name or by ID) is waited for instead of
+	// being done twice
+	deletion, owner := networkDeletions.start(tenantOf(handler.service), network.ID)
+	if !owner {
+		return report, deletion.wait(ctx)
+	}
+	defer func() {
+		networkDeletions.finish(deletion, err)
+	}()
+
+	// A previous deletion may have ended between the read of the metadata and the start of this one
+	mn, err = metadata.LoadNetwork(handler.service, network.ID)
+	if err != nil {
+		if _, ok := err.(fail.ErrNotFound); ok {
+			logrus.Debugf("network '%s' deleted meanwhile", ref)
+			report.record(TeardownMetadata, TeardownAlreadyGone, nil)
+			return report, nil
+		}
+		return report, err
+	}
+	// Forget hosts deleted out-of-band, they cannot prevent the deletion of the network
+	_, err = mn.ReconcileHosts()
+	if err != nil {
+		return report, err
+	}
+	network, err = mn.Get()
+	if err != nil {
+		return report, err
 	}
 
 	// Check if hosts are still attached to network according to metadata
@@ -1097,89 +3306,49 @@ func (handler *NetworkHandler) Delete(ctx context.Context, ref string) (err erro
 	)
 	if err != nil {
 		if _, ok := err.(fail.ErrNotAvailable); ok {
-			return fmt.Errorf(errorMsg)
+			return report, fmt.Errorf(errorMsg)
 		}
-		return err
+		return report, err
 	}
 
 	// Delete gateway(s)
 	if network.GatewayID != "" {
-		mh, err := metadata.LoadHost(handler.service, network.GatewayID)
+		result, err := handler.deleteNetworkGateway(network, network.GatewayID, true)
+		report.addGateway(result)
 		if err != nil {
-			logrus.Error(err)
-		} else {
-			if network.VIP != nil {
-				mhm, merr := mh.Get()
-				if merr != nil {
-					return merr
-				}
-				err = handler.service.UnbindHostFromVIP(network.VIP, mhm.ID)
-				if err != nil {
-					logrus.Errorf("failed to unbind primary gateway from VIP: %v", err)
-				}
-			}
-
-			err = handler.service.DeleteGateway(network.GatewayID) // allow no gateway, but log it
-			if err != nil {
-				switch err.(type) {
-				case fail.ErrNotFound:
-					logrus.Errorf(
-						"failed to delete primary gateway, resource not found: %s", openstack.ProviderErrorToString(err),
-					)
-				case fail.ErrTimeout:
-					logrus.Errorf("failed to delete primary gateway, timeout: %s", openstack.ProviderErrorToString(err))
-				default:
-					logrus.Errorf("failed to delete primary gateway: %s", openstack.ProviderErrorToString(err))
-				}
-			}
-
-			err = mh.Delete()
-			if err != nil {
-				return err
-			}
+			return report, err
 		}
 	}
 	if network.SecondaryGatewayID != "" {
-		mh, err := metadata.LoadHost(handler.service, network.SecondaryGatewayID)
+		result, err := handler.deleteNetworkGateway(network, network.SecondaryGatewayID, false)
+		report.addGateway(result)
 		if err != nil {
-			logrus.Error(err)
-		} else {
-			if network.VIP != nil {
-				err = handler.service.UnbindHostFromVIP(network.VIP, network.SecondaryGatewayID)
-				if err != nil {
-					logrus.Errorf("failed to unbind secondary gateway from VIP: %v", err)
-				}
-			}
-
-			err = handler.service.DeleteGateway(network.SecondaryGatewayID) // allow no gateway, but log it
-			if err != nil {
-				switch err.(type) {
-				case fail.ErrNotFound:
-					logrus.Errorf(
-						"failed to delete secondary gateway, resource not found: %s",
-						openstack.ProviderErrorToString(err),
-					)
-				case fail.ErrTimeout:
-					logrus.Errorf(
-						"failed to delete secondary gateway, timeout: %s", openstack.ProviderErrorToString(err),
-					)
-				default:
-					logrus.Errorf("failed to delete secondary gateway: %s", openstack.ProviderErrorToString(err))
-				}
-			}
-
-			err = mh.Delete()
-			if err != nil {
-				return err
-			}
+			return report, err
+		}
+	}
+	gatewayIDs, err := metadata.GatewayIDsOf(network)
+	if err != nil {
+		return report, err
+	}
+	for i := 2; i < len(gatewayIDs); i++ {
+		result, err := handler.deleteNetworkGateway(network, gatewayIDs[i], false)
+		report.addGateway(result)
+		if err != nil {
+			return report, err
 		}
 	}
 
 	// Delete VIP if needed
 	if network.VIP != nil {
 		err = handler.service.DeleteVIP(network.VIP)
-		if err != nil {
+		switch err.(type) {
+		case nil:
+			report.record(TeardownVIP, TeardownDeleted, nil)
+		case fail.ErrNotFound:
+			report.record(TeardownVIP, TeardownAlreadyGone, nil)
+		default:
 			logrus.Errorf("failed to delete VIP: %v", err)
+			report.record(TeardownVIP, TeardownFailed, err)
 		}
 	}
 
@@ -1189,12 +3358,16 @@ func (handler *NetworkHandler) Delete(ctx context.Context, ref string) (err erro
 			mnm, nerr := mn.Get()
 			if nerr != nil {
 				err = fail.AddConsequence(err, nerr)
+				report.record(TeardownMetadata, TeardownFailed, nerr)
 			}
 			if nerr == nil {
 				if mnm != nil {
 					derr := mn.Delete()
 					if derr != nil {
 						err = fail.AddConsequence(err, derr)
+						report.record(TeardownMetadata, TeardownFailed, derr)
+					} else {
+						report.record(TeardownMetadata, TeardownDeleted, nil)
 					}
 				}
 			}
@@ -1202,6 +3375,7 @@ func (handler *NetworkHandler) Delete(ctx context.Context, ref string) (err erro
 	}()
 
 	waitMore := false
+	networkOutcome := TeardownDeleted
 	// delete network, with tolerance
 	err = handler.service.DeleteNetwork(network.ID)
 	if err != nil {
@@ -1209,7 +3383,8 @@ func (handler *NetworkHandler) Delete(ctx context.Context, ref string) (err erro
 		case fail.ErrNotFound:
 			// If network doesn't exist anymore on the provider infrastructure, don't fail to cleanup the metadata
 			logrus.Warnf("network not found on provider side, cleaning up metadata.")
-			return err
+			networkOutcome = TeardownAlreadyGone
+			err = nil
 		case fail.ErrTimeout:
 			logrus.Error("cannot delete network due to a timeout")
 			waitMore = true
@@ -1218,17 +3393,8 @@ func (handler *NetworkHandler) Delete(ctx context.Context, ref string) (err erro
 		}
 	}
 	if waitMore {
-		errWaitMore := retry.WhileUnsuccessfulDelay1Second(
-			func() error {
-				recNet, recErr := handler.service.GetNetwork(network.ID)
-				if recNet != nil {
-					return fmt.Errorf("still there")
-				}
-				if _, ok := recErr.(fail.ErrNotFound); ok {
-					return nil
-				}
-				return fmt.Errorf("another kind of error")
-			}, temporal.GetContextTimeout(),
+		errWaitMore := waitNetworkDeletion(
+			handler.service, network.ID, temporal.GetMinDelay(), temporal.GetNetworkCleanupTimeout(),
 		)
 		if errWaitMore != nil {
 			err = fail.AddConsequence(err, errWaitMore)
@@ -1236,28 +3402,274 @@ func (handler *NetworkHandler) Delete(ctx context.Context, ref string) (err erro
 	}
 
 	if err != nil {
-		return err
+		report.record(TeardownProviderNetwork, TeardownFailed, err)
+		return report, err
+	}
+	report.record(TeardownProviderNetwork, networkOutcome, nil)
+
+	// Delete the default security group, now that the gateways bound to it are gone
+	if network.GetDefaultSecurityGroup() != "" {
+		err = handler.deleteDefaultSecurityGroup(network)
+		if err != nil {
+			report.record(TeardownDefaultSecurityGroup, TeardownFailed, err)
+			return report, err
+		}
+		report.record(TeardownDefaultSecurityGroup, TeardownDeleted, nil)
 	}
 
 	// Delete network metadata if there
 	mnm, err := mn.Get()
 	if err != nil {
-		return err
+		report.record(TeardownMetadata, TeardownFailed, err)
+		return report, err
 	}
 
 	if mnm != nil {
 		err = mn.Delete()
 		if err != nil {
-			return err
+			report.record(TeardownMetadata, TeardownFailed, err)
+			return report, err
 		}
+		report.record(TeardownMetadata, TeardownDeleted, nil)
 	}
 
-	return nil
+	return report, nil
+}
+
+// networkDeletion is a deletion of network in progress, that other deletions of the same network wait for
+type networkDeletion struct {
+	done chan struct{}
+	err  error
+	key  networkDeletionKey
+}
+
+// wait waits for the end of the deletion and returns its error, or an AbortedError if 'ctx' is canceled before
+func (d *networkDeletion) wait(ctx context.Context) error {
+	select {
+	case <-d.done:
+		return d.err
+	case <-ctx.Done():
+		return fail.AbortedError("canceled while waiting for the deletion of the network in progress", ctx.Err())
+	}
+}
+
+// networkDeletionKey identifies a network by the tenant it belongs to and its ID
+type networkDeletionKey struct {
+	tenant    string
+	networkID string
+}
+
+// networkDeletionRegistry records the deletions of networks in progress; a deletion is removed from the registry
+// as soon as it ends, successfully or not
+type networkDeletionRegistry struct {
+	lock     sync.Mutex
+	inFlight map[networkDeletionKey]*networkDeletion
+}
+
+var networkDeletions = &networkDeletionRegistry{inFlight: map[networkDeletionKey]*networkDeletion{}}
+
+// start returns the deletion in progress of the network 'networkID' of 'tenant' with false, or registers a new
+// deletion of this network and returns it with true
+func (r *networkDeletionRegistry) start(tenant, networkID string) (*networkDeletion, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := networkDeletionKey{tenant: tenant, networkID: networkID}
+	if d, ok := r.inFlight[key]; ok {
+		return d, false
+	}
+	d := &networkDeletion{done: make(chan struct{}), key: key}
+	r.inFlight[key] = d
+	return d, true
+}
+
+// finish ends the deletion 'd' with the error 'err', removing it from the registry and waking up the deletions
+// waiting for it
+func (r *networkDeletionRegistry) finish(d *networkDeletion, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.inFlight, d.key)
+	d.err = err
+	close(d.done)
+}
+
+// inProgress returns the number of deletions in progress
+func (r *networkDeletionRegistry) inProgress() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return len(r.inFlight)
+}
+
+// tenantOf returns the name identifying the tenant of 'svc': the name of its metadata bucket, specific to the tenant
+func tenantOf(svc iaas.Service) string {
+	bucket := svc.GetMetadataBucket()
+	if bucket == nil {
+		return ""
+	}
+	name, err := bucket.GetName()
+	if err != nil {
+		logrus.Warnf("failed to get the name of the metadata bucket: %v", err)
+		return ""
+	}
+	return name
+}
+
+// GatewayDeletionOutcome tells what happened to a gateway in the deletion of its network
+type GatewayDeletionOutcome int
+
+const (
+	// GatewayDeleted means the gateway has been deleted
+	GatewayDeleted GatewayDeletionOutcome = iota
+	// GatewayAlreadyGone means the gateway did not exist anymore on provider side; its metadata have been deleted
+	GatewayAlreadyGone
+	// GatewayDeletionFailed means the deletion of the gateway failed (see Err)
+	GatewayDeletionFailed
+)
+
+// GatewayDeletionResult is the outcome of the deletion of a gateway of a network
+type GatewayDeletionResult struct {
+	ID      string
+	Primary bool
+	Outcome GatewayDeletionOutcome
+	Err     error
+}
+
+// TeardownStep identifies a step of the deletion of a network
+type TeardownStep string
+
+const (
+	// TeardownPrimaryGateway is the deletion of the primary gateway
+	TeardownPrimaryGateway TeardownStep = "primary gateway"
+	// TeardownSecondaryGateway is the deletion of the secondary gateway
+	TeardownSecondaryGateway TeardownStep = "secondary gateway"
+	// TeardownVIP is the deletion of the VIP of the gateways
+	TeardownVIP TeardownStep = "VIP"
+	// TeardownProviderNetwork is the deletion of the network on provider side
+	TeardownProviderNetwork TeardownStep = "provider network"
+	// TeardownDefaultSecurityGroup is the deletion of the default security group of the network, if it has one
+	TeardownDefaultSecurityGroup TeardownStep = "default security group"
+	// TeardownMetadata is the deletion of the metadata of the network
+	TeardownMetadata TeardownStep = "metadata"
+)
+
+// TeardownOutcome tells what happened in a step of the deletion of a network
+type TeardownOutcome int
+
+const (
+	// TeardownDeleted means the resource has been deleted
+	TeardownDeleted TeardownOutcome = iota
+	// TeardownAlreadyGone means the resource did not exist anymore
+	TeardownAlreadyGone
+	// TeardownFailed means the deletion of the resource failed (see Err)
+	TeardownFailed
+)
+
+// TeardownStepResult is the outcome of a step of the deletion of a network
+type TeardownStepResult struct {
+	Step    TeardownStep
+	Outcome TeardownOutcome
+	Err     error
+}
+
+// NetworkTeardownReport tells what happened to the gateways of a network deleted by DeleteWithReport, and the
+// outcome of each step of the deletion run; a step not listed has not been run
+type NetworkTeardownReport struct {
+	// Network is the reference of the network
+	Network  string
+	Gateways []GatewayDeletionResult
+	Steps    []TeardownStepResult
+}
+
+// Step returns the result of 'step', and false if the step has not been run
+func (r *NetworkTeardownReport) Step(step TeardownStep) (TeardownStepResult, bool) {
+	for _, s := range r.Steps {
+		if s.Step == step {
+			return s, true
+		}
+	}
+	return TeardownStepResult{}, false
+}
+
+// record records the outcome of 'step', replacing the one previously recorded
+func (r *NetworkTeardownReport) record(step TeardownStep, outcome TeardownOutcome, err error) {
+	result := TeardownStepResult{Step: step, Outcome: outcome, Err: err}
+	for i, s := range r.Steps {
+		if s.Step == step {
+			r.Steps[i] = result
+			return
+		}
+	}
+	r.Steps = append(r.Steps, result)
+}
+
+// addGateway records the result of the deletion of a gateway, also as a step
+func (r *NetworkTeardownReport) addGateway(result GatewayDeletionResult) {
+	r.Gateways = append(r.Gateways, result)
+	step := TeardownPrimaryGateway
+	if !result.Primary {
+		step = TeardownSecondaryGateway
+	}
+	outcome := TeardownDeleted
+	switch result.Outcome {
+	case GatewayAlreadyGone:
+		outcome = TeardownAlreadyGone
+	case GatewayDeletionFailed:
+		outcome = TeardownFailed
+	}
+	r.record(step, outcome, result.Err)
+}
+
+// deleteNetworkGateway deletes the gateway identified by 'id' of 'network', then its metadata; the failure to delete
+// the gateway is reported in the result, only the failure to delete its metadata is returned
+func (handler *NetworkHandler) deleteNetworkGateway(
+	network *abstract.Network, id string, primary bool,
+) (GatewayDeletionResult, error) {
+	kind := "primary"
+	if !primary {
+		kind = "secondary"
+	}
+	result := GatewayDeletionResult{ID: id, Primary: primary}
+
+	mh, err := metadata.LoadHost(handler.service, id)
+	if err != nil {
+		logrus.Error(err)
+		result.Outcome, result.Err = GatewayDeletionFailed, err
+		return result, nil
+	}
+	if network.VIP != nil {
+		err = handler.service.UnbindHostFromVIP(network.VIP, id)
+		if err != nil {
+			logrus.Errorf("failed to unbind %s gateway from VIP: %v", kind, err)
+		}
+	}
+
+	err = handler.service.DeleteGateway(id)
+	switch err.(type) {
+	case nil:
+		result.Outcome = GatewayDeleted
+	case fail.ErrNotFound:
+		logrus.Warnf("%s gateway '%s' appears to be already deleted", kind, id)
+		result.Outcome = GatewayAlreadyGone
+	case fail.ErrTimeout:
+		logrus.Errorf("failed to delete %s gateway, timeout: %s", kind, openstack.ProviderErrorToString(err))
+		result.Outcome, result.Err = GatewayDeletionFailed, err
+	default:
+		logrus.Errorf("failed to delete %s gateway: %s", kind, openstack.ProviderErrorToString(err))
+		result.Outcome, result.Err = GatewayDeletionFailed, err
+	}
+
+	err = mh.Delete()
+	if err != nil {
+		return result, err
+	}
+	return result, nil
 }
 
 // Destroy destroys network referenced by ref
 func (handler *NetworkHandler) Destroy(ctx context.Context, ref string) (err error) {
-	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s')", ref), true).WithStopwatch().GoingIn()
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s')", ref), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -1359,6 +3771,17 @@ func (handler *NetworkHandler) Destroy(ctx context.Context, ref string) (err err
 		}
 	}
 
+	gatewayIDs, err := metadata.GatewayIDsOf(network)
+	if err != nil {
+		return err
+	}
+	for i := 2; i < len(gatewayIDs); i++ {
+		_, err = handler.deleteNetworkGateway(network, gatewayIDs[i], false)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Delete VIP if needed
 	if network.VIP != nil {
 		err = handler.service.DeleteVIP(network.VIP)
@@ -1402,7 +3825,7 @@ func (handler *NetworkHandler) Destroy(ctx context.Context, ref string) (err err
 		}
 	}
 	if waitMore {
-		errWaitMore := retry.WhileUnsuccessfulDelay1Second(
+		errWaitMore := handler.retryPolicy.WhileUnsuccessful(
 			func() error {
 				recNet, recErr := handler.service.GetNetwork(network.ID)
 				if recNet != nil {
@@ -1423,6 +3846,12 @@ func (handler *NetworkHandler) Destroy(ctx context.Context, ref string) (err err
 		return err
 	}
 
+	// Delete the default security group, now that the gateways bound to it are gone
+	err = handler.deleteDefaultSecurityGroup(network)
+	if err != nil {
+		return err
+	}
+
 	// Delete network metadata if there
 	mnm, err := mn.Get()
 	if err != nil {
@@ -1438,3 +3867,188 @@ func (handler *NetworkHandler) Destroy(ctx context.Context, ref string) (err err
 
 	return nil
 }
+
+// NetworkDeletionOutcome tells what happened to a network in DeleteNetworks
+type NetworkDeletionOutcome int
+
+const (
+	// NetworkDeleted means the network has been deleted
+	NetworkDeleted NetworkDeletionOutcome = iota
+	// NetworkSkippedHasHosts means the network has not been deleted because hosts are still attached to it
+	NetworkSkippedHasHosts
+	// NetworkDeletionFailed means the deletion of the network failed (see Err)
+	NetworkDeletionFailed
+)
+
+// DeleteNetworksOptions tunes DeleteNetworks
+type DeleteNetworksOptions struct {
+	// Force deletes networks even if hosts are still attached to them
+	Force bool
+	// MaxConcurrency is the maximum number of networks deleted at the same time (no limit if <= 0)
+	MaxConcurrency int
+}
+
+// NetworkDeletionResult is the outcome of the deletion of one network by DeleteNetworks
+type NetworkDeletionResult struct {
+	Ref     string
+	Outcome NetworkDeletionOutcome
+	Err     error
+}
+
+// DeleteNetworks deletes the networks referenced by 'refs', subnetworks before their parent network, and returns
+// the outcome for each of them in the order of 'refs'; the failure of a deletion doesn't stop the others
+func DeleteNetworks(ctx context.Context, svc iaas.Service, refs []string, opts DeleteNetworksOptions) (_ []NetworkDeletionResult, err error) {
+	if svc == nil {
+		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+	if ctx == nil {
+		return nil, fail.InvalidParameterError("ctx", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, fmt.Sprintf("(%v, %v)", refs, opts.Force), debug.ShouldTrace("handlers.network")).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	handler := &NetworkHandler{service: svc, retryPolicy: retry.DefaultPolicy()}
+	load := func(ref string) (*abstract.Network, error) {
+		mn, err := metadata.LoadNetwork(svc, ref)
+		if err != nil {
+			return nil, err
+		}
+		// Hosts deleted out-of-band must not prevent the deletion
+		_, err = mn.ReconcileHosts()
+		if err != nil {
+			return nil, err
+		}
+		return mn.Get()
+	}
+	remove := func(ref string, force bool) error {
+		if force {
+			return handler.Destroy(ctx, ref)
+		}
+		return handler.Delete(ctx, ref)
+	}
+	return deleteNetworks(refs, opts, load, remove), nil
+}
+
+// deleteNetworks does the real work of DeleteNetworks, using 'load' to read the networks and 'remove' to delete them
+func deleteNetworks(
+	refs []string, opts DeleteNetworksOptions, load func(string) (*abstract.Network, error),
+	remove func(string, bool) error,
+) []NetworkDeletionResult {
+	results := make([]NetworkDeletionResult, len(refs))
+	networks := make([]*abstract.Network, len(refs))
+	indexByID := map[string]int{}
+	for i, ref := range refs {
+		results[i].Ref = ref
+		network, err := load(ref)
+		if err != nil {
+			results[i].Outcome, results[i].Err = NetworkDeletionFailed, err
+			continue
+		}
+		networks[i] = network
+		indexByID[network.ID] = i
+	}
+
+	// The level of a network is 0 if none of its subnetworks is to be deleted, 1 + the highest level of
+	// its subnetworks otherwise; networks are deleted by increasing level
+	children := map[int][]int{}
+	for i, network := range networks {
+		if network == nil || network.Parent == "" {
+			continue
+		}
+		if parent, ok := indexByID[network.Parent]; ok && parent != i {
+			children[parent] = append(children[parent], i)
+		}
+	}
+	levels := make([]int, len(refs))
+	var levelOf func(int, int) int
+	levelOf = func(i int, depth int) int {
+		if depth > len(refs) { // cycle in the parents, should not happen
+			return 0
+		}
+		level := 0
+		for _, c := range children[i] {
+			if l := levelOf(c, depth+1) + 1; l > level {
+				level = l
+			}
+		}
+		return level
+	}
+	maxLevel := 0
+	for i := range networks {
+		levels[i] = levelOf(i, 0)
+		if levels[i] > maxLevel {
+			maxLevel = levels[i]
+		}
+	}
+
+	maxConcurrency := opts.MaxConcurrency
+	if maxConcurrency <= 0 || maxConcurrency > len(refs) {
+		maxConcurrency = len(refs)
+	}
+	for level := 0; level <= maxLevel; level++ {
+		slots := make(chan struct{}, maxConcurrency)
+		var wg sync.WaitGroup
+		for i, network := range networks {
+			if network == nil || levels[i] != level {
+				continue
+			}
+			if notDeleted := undeletedChildren(results, children[i]); len(notDeleted) > 0 {
+				results[i].Outcome = NetworkDeletionFailed
+				results[i].Err = fail.NotAvailableError(
+					fmt.Sprintf("subnetworks %s of network '%s' have not been deleted", strings.Join(notDeleted, ", "), network.Name),
+				)
+				continue
+			}
+			if !opts.Force && networkHasHosts(network) {
+				results[i].Outcome = NetworkSkippedHasHosts
+				continue
+			}
+
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(i int) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+
+				if err := remove(refs[i], opts.Force); err != nil {
+					results[i].Outcome, results[i].Err = NetworkDeletionFailed, err
+					return
+				}
+				results[i].Outcome = NetworkDeleted
+			}(i)
+		}
+		wg.Wait()
+	}
+	return results
+}
+
+// undeletedChildren returns the references of the networks at indexes 'children' that have not been deleted
+func undeletedChildren(results []NetworkDeletionResult, children []int) []string {
+	var list []string
+	for _, c := range children {
+		if results[c].Outcome != NetworkDeleted {
+			list = append(list, results[c].Ref)
+		}
+	}
+	return list
+}
+
+// networkHasHosts tells if hosts are attached to the network according to its metadata
+func networkHasHosts(network *abstract.Network) bool {
+	hasHosts := false
+	err := network.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hasHosts = len(clonable.(*propsv1.NetworkHosts).ByID) > 0
+			return nil
+		},
+	)
+	if err != nil {
+		logrus.Warnf("failed to read the hosts of network '%s', considering it has some: %v", network.Name, err)
+		return true
+	}
+	return hasHosts
+}
@@ -16,6 +16,75 @@
 
 package handlers
 
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hostproperty"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/ipversion"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/networkproperty"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/userdata"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/providers"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
+	"github.com/CS-SI/SafeScale/lib/server/metadata"
+	"github.com/CS-SI/SafeScale/lib/utils/cidr"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+func TestDefaultImageFor(t *testing.T) {
+	cfg := providers.ConfigMap{
+		"DefaultImage": "Ubuntu 18.04",
+		"DefaultImages": map[string]string{
+			"arm64":       "Ubuntu 18.04 arm64",
+			"arm64/ipv6":  "Ubuntu 20.04 arm64",
+			"X86_64":      "Ubuntu 18.04 amd64",
+			"ppc64le":     "",
+			"unused/ipv4": "CentOS 7",
+		},
+	}
+
+	img, specific := defaultImageFor(cfg, "arm64", ipversion.IPv4)
+	assert.True(t, specific)
+	assert.Equal(t, "Ubuntu 18.04 arm64", img)
+
+	img, specific = defaultImageFor(cfg, "arm64", ipversion.IPv6)
+	assert.True(t, specific)
+	assert.Equal(t, "Ubuntu 20.04 arm64", img)
+
+	img, specific = defaultImageFor(cfg, "x86_64", ipversion.IPv4)
+	assert.True(t, specific)
+	assert.Equal(t, "Ubuntu 18.04 amd64", img)
+
+	img, specific = defaultImageFor(cfg, "ppc64le", ipversion.IPv4)
+	assert.False(t, specific)
+	assert.Equal(t, "Ubuntu 18.04", img)
+
+	img, specific = defaultImageFor(cfg, "", ipversion.IPv4)
+	assert.False(t, specific)
+	assert.Equal(t, "Ubuntu 18.04", img)
+
+	img, specific = defaultImageFor(providers.ConfigMap{"DefaultImage": "Debian 10"}, "arm64", ipversion.IPv4)
+	assert.False(t, specific)
+	assert.Equal(t, "Debian 10", img)
+}
+
 // FIXME: iaas.Service became an interface, so cannot be used as before.
 //       Need to write a service struct satisfying iaas.Service interface
 //       and then initializes an instance of this service struct
@@ -71,3 +140,2089 @@ package handlers
 
 // 	assert.Nil(t, result)
 // }
+
+func TestValidateIngressPorts(t *testing.T) {
+	assert.Nil(t, validateIngressPorts(nil))
+	assert.Nil(t, validateIngressPorts([]int{1, 8444, 65535}))
+
+	for _, v := range []int{0, -1, 65536} {
+		err := validateIngressPorts([]int{8444, v})
+		if assert.NotNil(t, err) {
+			_, ok := err.(fail.ErrInvalidParameter)
+			assert.True(t, ok)
+		}
+	}
+}
+
+func TestIngressPortsRules(t *testing.T) {
+	rules := ingressPortsRules([]int{8444, 443})
+	require.Len(t, rules, 2)
+	for i, port := range []int{8444, 443} {
+		assert.Nil(t, rules[i].Validate())
+		assert.Equal(t, abstract.SecurityGroupRuleIngress, rules[i].Direction)
+		assert.Equal(t, "tcp", rules[i].Protocol)
+		assert.Equal(t, port, rules[i].PortFrom)
+		assert.Equal(t, []string{"0.0.0.0/0"}, rules[i].Targets)
+	}
+}
+
+// vanishingNetworkService is an iaas.Service where the network disappears after a few calls to GetNetwork
+type vanishingNetworkService struct {
+	iaas.Service
+	calls    int
+	vanishAt int
+}
+
+func (s *vanishingNetworkService) GetNetwork(id string) (*abstract.Network, error) {
+	s.calls++
+	if s.calls >= s.vanishAt {
+		return nil, fail.NotFoundError("network '" + id + "' not found")
+	}
+	return &abstract.Network{ID: id}, nil
+}
+
+func TestWaitNetworkDeletion(t *testing.T) {
+	svc := &vanishingNetworkService{vanishAt: 3}
+
+	start := time.Now()
+	err := waitNetworkDeletion(svc, "net-id", 10*time.Millisecond, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, svc.calls)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestWaitNetworkDeletion_Timeout(t *testing.T) {
+	svc := &vanishingNetworkService{vanishAt: 1000}
+
+	start := time.Now()
+	err := waitNetworkDeletion(svc, "net-id", 10*time.Millisecond, 100*time.Millisecond)
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+// goneNetworkService is an iaas.Service where the network has already been deleted on provider side
+type goneNetworkService struct {
+	*memoryService
+}
+
+func (s *goneNetworkService) DeleteNetwork(id string) error {
+	return fail.NotFoundError("network '" + id + "' not found")
+}
+
+func TestDelete_NetworkAlreadyGoneOnProvider(t *testing.T) {
+	svc := &goneNetworkService{memoryService: newMemoryService()}
+
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	_, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+	require.NotEmpty(t, svc.bucket.Objects)
+
+	handler := NewNetworkHandler(svc)
+	err = handler.Delete(context.Background(), "net")
+	assert.Nil(t, err)
+	assert.Empty(t, svc.bucket.Objects)
+}
+
+func TestValidateGatewayImage(t *testing.T) {
+	template := &abstract.HostTemplate{Name: "s1-4", DiskSize: 20}
+
+	img := &abstract.Image{Name: "Ubuntu 18.04 arm64"}
+	assert.Nil(t, validateGatewayImage(img, template, "arm64"))
+	assert.Nil(t, validateGatewayImage(img, template, "aarch64"))
+	assert.Nil(t, validateGatewayImage(img, template, ""))
+
+	// architecture mismatch
+	err := validateGatewayImage(img, template, "x86_64")
+	if assert.NotNil(t, err) {
+		_, ok := err.(fail.ErrInvalidRequest)
+		assert.True(t, ok)
+	}
+
+	// image without architecture hint
+	assert.Nil(t, validateGatewayImage(&abstract.Image{Name: "Ubuntu 18.04"}, template, "x86_64"))
+	assert.Nil(t, validateGatewayImage(&abstract.Image{Name: "debian-10-buster-amd64"}, template, "x86_64"))
+
+	// the architecture told by the stack prevails over the name of the image
+	err = validateGatewayImage(&abstract.Image{Name: "Ubuntu 18.04 amd64", Arch: "aarch64"}, template, "x86_64")
+	assert.IsType(t, fail.ErrInvalidRequest{}, err)
+	assert.Nil(t, validateGatewayImage(&abstract.Image{Name: "Ubuntu 18.04", Arch: "AArch64"}, template, "arm64"))
+
+	// disk too small
+	err = validateGatewayImage(&abstract.Image{Name: "Ubuntu 18.04", DiskSize: 30}, template, "")
+	if assert.NotNil(t, err) {
+		_, ok := err.(fail.ErrInvalidRequest)
+		assert.True(t, ok)
+	}
+	assert.Nil(t, validateGatewayImage(&abstract.Image{Name: "Ubuntu 18.04", DiskSize: 10}, template, ""))
+}
+
+func TestCheckNetworkCIDR(t *testing.T) {
+	assert.Nil(t, checkNetworkCIDR("192.168.1.0/24", false))
+
+	err := checkNetworkCIDR("192.168.1.0/33", false)
+	if assert.NotNil(t, err) {
+		cidrErr, ok := err.(fail.ErrInvalidCIDR)
+		if assert.True(t, ok) {
+			assert.Equal(t, "192.168.1.0/33", cidrErr.CIDR())
+		}
+	}
+
+	err = checkNetworkCIDR("8.8.8.0/24", false)
+	if assert.NotNil(t, err) {
+		cidrErr, ok := err.(fail.ErrRoutableCIDR)
+		if assert.True(t, ok) {
+			assert.Equal(t, "8.8.8.0/24", cidrErr.CIDR())
+		}
+	}
+}
+
+func TestCheckNetworkCIDR_AllowRoutable(t *testing.T) {
+	// RFC6598 shared address space is not recognized as private
+	assert.IsType(t, fail.ErrRoutableCIDR{}, checkNetworkCIDR("100.64.0.0/24", false))
+	assert.Nil(t, checkNetworkCIDR("100.64.0.0/24", true))
+
+	// the CIDR must still be valid
+	assert.IsType(t, fail.ErrInvalidCIDR{}, checkNetworkCIDR("100.64.0.0/33", true))
+}
+
+func TestValidateMTU(t *testing.T) {
+	for _, v := range []int{0, 576, 1400, 1500, 9000} {
+		assert.Nil(t, validateMTU(v), v)
+	}
+	for _, v := range []int{-1, 575, 9001} {
+		err := validateMTU(v)
+		if assert.NotNil(t, err, v) {
+			_, ok := err.(fail.ErrInvalidParameter)
+			assert.True(t, ok)
+		}
+	}
+}
+
+func TestValidateDomain(t *testing.T) {
+	valid := []string{
+		"", "example.com", "my-domain.example.com", "a.b.c", "xn--bcher-kva.example", strings.Repeat("a", 63) + ".com",
+	}
+	for _, v := range valid {
+		assert.Nil(t, validateDomain(v), v)
+	}
+	tooLong := strings.Repeat(strings.Repeat("a", 60)+".", 4) + "com"
+	invalid := []string{
+		"-example.com", "example-.com", "exa_mple.com", "example..com", "exam ple.com", strings.Repeat("a", 64) + ".com",
+		tooLong,
+	}
+	for _, v := range invalid {
+		err := validateDomain(v)
+		if assert.NotNil(t, err, v) {
+			assert.IsType(t, fail.ErrInvalidRequest{}, err, v)
+		}
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	assert.Equal(t, "example.com", normalizeDomain(" .example.com. "))
+	assert.Equal(t, "", normalizeDomain("."))
+}
+
+func TestGatewayFQDNs(t *testing.T) {
+	primary, secondary := gatewayFQDNs("net", "", "gw-", "gw2-", true, "example.com")
+	assert.Equal(t, "gw-net.example.com", primary)
+	assert.Equal(t, "gw2-net.example.com", secondary)
+
+	primary, secondary = gatewayFQDNs("net", "", "gw-", "gw2-", true, "")
+	assert.Equal(t, "gw-net", primary)
+	assert.Equal(t, "gw2-net", secondary)
+
+	primary, secondary = gatewayFQDNs("net", "my-gw", "gw-", "gw2-", false, normalizeDomain("example.com."))
+	assert.Equal(t, "my-gw.example.com", primary)
+	assert.Equal(t, "", secondary)
+}
+
+func fakeGateways(primary, secondary *abstract.Host) func(bool) (*abstract.Host, bool, error) {
+	return func(p bool) (*abstract.Host, bool, error) {
+		host := primary
+		if !p {
+			host = secondary
+		}
+		return host, host != nil, nil
+	}
+}
+
+func TestWaitGatewaySSHReady_FallsBackToSecondary(t *testing.T) {
+	gw1 := &abstract.Host{ID: "gw1", Name: "gw-net"}
+	gw2 := &abstract.Host{ID: "gw2", Name: "gw2-net"}
+	var waited []string
+	wait := func(host *abstract.Host) error {
+		waited = append(waited, host.ID)
+		if host == gw1 {
+			return fail.TimeoutError("ssh not ready", time.Second, nil)
+		}
+		return nil
+	}
+
+	gw, err := waitGatewaySSHReady(true, fakeGateways(gw1, gw2), wait)
+	require.NoError(t, err)
+	assert.Equal(t, gw2, gw)
+	assert.Equal(t, []string{"gw1", "gw2"}, waited)
+}
+
+func TestWaitGatewaySSHReady_StartsWithRequested(t *testing.T) {
+	gw1 := &abstract.Host{ID: "gw1"}
+	gw2 := &abstract.Host{ID: "gw2"}
+	var waited []string
+	wait := func(host *abstract.Host) error {
+		waited = append(waited, host.ID)
+		return nil
+	}
+
+	gw, err := waitGatewaySSHReady(false, fakeGateways(gw1, gw2), wait)
+	require.NoError(t, err)
+	assert.Equal(t, gw2, gw)
+	assert.Equal(t, []string{"gw2"}, waited)
+}
+
+func TestWaitGatewaySSHReady_NoneAvailable(t *testing.T) {
+	wait := func(host *abstract.Host) error {
+		return fail.TimeoutError("ssh not ready", time.Second, nil)
+	}
+
+	_, err := waitGatewaySSHReady(true, fakeGateways(&abstract.Host{ID: "gw1"}, nil), wait)
+	require.Error(t, err)
+	assert.IsType(t, fail.ErrNotAvailable{}, err)
+	assert.Contains(t, err.Error(), "no gateway available")
+}
+
+func TestNetworkCreationLog(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	defer logrus.SetLevel(logrus.GetLevel())
+	logrus.SetLevel(logrus.DebugLevel)
+
+	creationLog := newNetworkCreationLog("net", false)
+	creationLog.transition(networkStateCreation)
+	creationLog.transition(networkStateGatewayCreation)
+	creationLog.transition(networkStateGatewayConfiguration)
+	creationLog.done(nil)
+
+	var messages []string
+	for _, e := range hook.AllEntries() {
+		messages = append(messages, e.Message)
+	}
+	require.Len(t, messages, 5)
+	assert.Equal(t, "network 'net': state NETWORK_CREATION", messages[0])
+	assert.True(t, strings.HasPrefix(messages[1], "network 'net': state NETWORK_CREATION -> GATEWAY_CREATION (after "))
+	assert.True(t, strings.HasPrefix(messages[2], "network 'net': state GATEWAY_CREATION -> GATEWAY_CONFIGURATION (after "))
+	assert.True(t, strings.HasPrefix(messages[3], "network 'net': state GATEWAY_CONFIGURATION -> READY (after "))
+	assert.True(t, strings.HasPrefix(messages[4], "network 'net': created in "))
+	for _, e := range hook.AllEntries()[:4] {
+		assert.Equal(t, logrus.DebugLevel, e.Level)
+	}
+	assert.Equal(t, logrus.InfoLevel, hook.LastEntry().Level)
+}
+
+func TestNetworkCreationLog_Failure(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	creationLog := newNetworkCreationLog("net", true)
+	creationLog.transition(networkStateCreation)
+	creationLog.transition(networkStateGatewayCreation)
+	creationLog.done(fail.TimeoutError("gateway not ready", time.Minute, nil))
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, logrus.InfoLevel, entries[0].Level)
+	assert.Equal(t, logrus.WarnLevel, entries[2].Level)
+	assert.Contains(t, entries[2].Message, "network 'net': creation failed in state GATEWAY_CREATION after ")
+}
+
+func TestNetworkCreationLog_Metrics(t *testing.T) {
+	creationLog := newNetworkCreationLog("net", false)
+	states := []string{
+		networkStateCreation, networkStateMetadataUpdate, networkStateGatewayCreation, networkStateMetadataUpdate,
+		networkStateGatewayConfiguration,
+	}
+	for _, state := range states {
+		creationLog.transition(state)
+		time.Sleep(20 * time.Millisecond)
+	}
+	creationLog.done(nil)
+
+	metrics := creationLog.metrics()
+	assert.Equal(t, "net", metrics.Network)
+	assert.False(t, metrics.Failed)
+	require.Len(t, metrics.Phases, 4)
+	var sum time.Duration
+	for state, d := range metrics.Phases {
+		assert.True(t, d >= 20*time.Millisecond, state)
+		sum += d
+	}
+	assert.True(t, metrics.Phases[networkStateMetadataUpdate] >= 40*time.Millisecond)
+	assert.True(t, sum <= metrics.Total)
+	assert.True(t, metrics.Total-sum < 10*time.Millisecond)
+}
+
+func TestCreate_MetricsOnFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	svc := &slowNetworkService{memoryService: newMemoryService(), cancel: cancel}
+
+	var metrics []NetworkCreationMetrics
+	handler := NewNetworkHandler(svc).(*NetworkHandler).OnCreationMetrics(
+		func(m NetworkCreationMetrics) { metrics = append(metrics, m) },
+	)
+	_, err := handler.Create(
+		ctx, "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{}, "", "", false, "", false, nil, 0,
+		0,
+	)
+	require.NotNil(t, err)
+	require.Len(t, metrics, 1)
+	assert.True(t, metrics[0].Failed)
+	assert.Contains(t, metrics[0].Phases, networkStateCreation)
+	assert.NotContains(t, metrics[0].Phases, networkStateGatewayCreation)
+	assert.True(t, metrics[0].Phases[networkStateCreation] <= metrics[0].Total)
+}
+
+func TestActiveGateway_VIPOnSecondary(t *testing.T) {
+	gw1 := &abstract.Host{ID: "gw1", Name: "gw-net"}
+	gw2 := &abstract.Host{ID: "gw2", Name: "gw2-net"}
+	holdsVIP := func(host *abstract.Host, vip string) (bool, error) {
+		assert.Equal(t, "192.168.0.254", vip)
+		return host == gw2, nil
+	}
+
+	gw, err := activeGateway("192.168.0.254", []*abstract.Host{gw1, gw2}, holdsVIP)
+	require.NoError(t, err)
+	assert.Equal(t, gw2, gw)
+}
+
+func TestActiveGateway_NoHolder(t *testing.T) {
+	gw1 := &abstract.Host{ID: "gw1", Name: "gw-net"}
+	gw2 := &abstract.Host{ID: "gw2", Name: "gw2-net"}
+
+	// primary unreachable, secondary reachable but not holding the VIP
+	gw, err := activeGateway(
+		"192.168.0.254", []*abstract.Host{gw1, gw2}, func(host *abstract.Host, vip string) (bool, error) {
+			if host == gw1 {
+				return false, fail.NotAvailableError("unreachable")
+			}
+			return false, nil
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, gw2, gw)
+
+	// none reachable
+	_, err = activeGateway(
+		"192.168.0.254", []*abstract.Host{gw1, gw2}, func(host *abstract.Host, vip string) (bool, error) {
+			return false, fail.NotAvailableError("unreachable")
+		},
+	)
+	assert.IsType(t, fail.ErrNotAvailable{}, err)
+	assert.Contains(t, err.Error(), "no gateway available")
+}
+
+func TestHoldsVIPCommand(t *testing.T) {
+	assert.Equal(t, "ip -o addr show | grep -qF ' 192.168.0.254/'", holdsVIPCommand("192.168.0.254"))
+}
+
+// bulkNetwork builds a network for the tests of deleteNetworks, with 'hosts' attached
+func bulkNetwork(t *testing.T, id, parent string, hosts ...string) *abstract.Network {
+	network := abstract.NewNetwork()
+	network.ID = id
+	network.Name = id
+	network.Parent = parent
+	err := network.Properties.LockForWrite(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
+			for _, h := range hosts {
+				networkHostsV1.ByID[h+"-id"] = h
+				networkHostsV1.ByName[h] = h + "-id"
+			}
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	return network
+}
+
+func TestDeleteNetworks(t *testing.T) {
+	networks := map[string]*abstract.Network{
+		"vpc":    bulkNetwork(t, "vpc", ""),
+		"sub":    bulkNetwork(t, "sub", "vpc"),
+		"busy":   bulkNetwork(t, "busy", "", "host"),
+		"broken": bulkNetwork(t, "broken", ""),
+	}
+	load := func(ref string) (*abstract.Network, error) {
+		if n, ok := networks[ref]; ok {
+			return n, nil
+		}
+		return nil, fail.NotFoundError("network '" + ref + "' not found")
+	}
+
+	for _, force := range []bool{false, true} {
+		var (
+			lock    sync.Mutex
+			removed []string
+		)
+		remove := func(ref string, f bool) error {
+			assert.Equal(t, force, f)
+			lock.Lock()
+			defer lock.Unlock()
+			if ref == "broken" {
+				return fail.TimeoutError("deletion timed out", time.Minute, nil)
+			}
+			removed = append(removed, ref)
+			return nil
+		}
+
+		refs := []string{"vpc", "busy", "missing", "sub", "broken"}
+		results := deleteNetworks(refs, DeleteNetworksOptions{Force: force, MaxConcurrency: 2}, load, remove)
+		require.Len(t, results, len(refs))
+		outcomes := map[string]NetworkDeletionOutcome{}
+		for i, r := range results {
+			assert.Equal(t, refs[i], r.Ref)
+			outcomes[r.Ref] = r.Outcome
+		}
+
+		assert.Equal(t, NetworkDeleted, outcomes["vpc"])
+		assert.Equal(t, NetworkDeleted, outcomes["sub"])
+		assert.Equal(t, NetworkDeletionFailed, outcomes["missing"])
+		assert.IsType(t, fail.ErrNotFound{}, results[2].Err)
+		assert.Equal(t, NetworkDeletionFailed, outcomes["broken"])
+		if force {
+			assert.Equal(t, NetworkDeleted, outcomes["busy"])
+			assert.Contains(t, removed, "busy")
+		} else {
+			assert.Equal(t, NetworkSkippedHasHosts, outcomes["busy"])
+			assert.NotContains(t, removed, "busy")
+		}
+
+		// the subnetwork is deleted before its parent
+		var subIndex, vpcIndex int
+		for i, r := range removed {
+			switch r {
+			case "sub":
+				subIndex = i
+			case "vpc":
+				vpcIndex = i
+			}
+		}
+		assert.True(t, subIndex < vpcIndex)
+	}
+}
+
+func TestDeleteNetworks_ParentKeptIfSubnetworkNotDeleted(t *testing.T) {
+	networks := map[string]*abstract.Network{
+		"vpc": bulkNetwork(t, "vpc", ""),
+		"sub": bulkNetwork(t, "sub", "vpc", "host"),
+	}
+	load := func(ref string) (*abstract.Network, error) { return networks[ref], nil }
+	remove := func(ref string, force bool) error {
+		t.Errorf("network '%s' should not be deleted", ref)
+		return nil
+	}
+
+	results := deleteNetworks([]string{"vpc", "sub"}, DeleteNetworksOptions{}, load, remove)
+	assert.Equal(t, NetworkDeletionFailed, results[0].Outcome)
+	assert.IsType(t, fail.ErrNotAvailable{}, results[0].Err)
+	assert.Equal(t, NetworkSkippedHasHosts, results[1].Outcome)
+}
+
+// capabilitiesService is an iaas.Service only able to tell its capabilities; any other call panics
+type capabilitiesService struct {
+	iaas.Service
+	caps providers.Capabilities
+}
+
+func (s *capabilitiesService) GetCapabilities() providers.Capabilities {
+	return s.caps
+}
+
+func TestCheckNetworkCapabilities(t *testing.T) {
+	assert.Nil(t, checkNetworkCapabilities(providers.Capabilities{}, ipversion.IPv4))
+	assert.Nil(t, checkNetworkCapabilities(providers.Capabilities{SupportsIPv6: true}, ipversion.IPv6))
+
+	err := checkNetworkCapabilities(providers.Capabilities{}, ipversion.IPv6)
+	require.NotNil(t, err)
+	assert.IsType(t, fail.ErrNotAvailable{}, err)
+	assert.Contains(t, err.Error(), "IPv6")
+}
+
+func TestCreate_IPv6OnProviderWithoutIPv6(t *testing.T) {
+	handler := &NetworkHandler{service: &capabilitiesService{}}
+
+	network, err := handler.Create(
+		context.Background(), "net", "fd00::/64", ipversion.IPv6, abstract.SizingRequirements{}, "", "", false, "",
+		false, nil, 0, 0,
+	)
+	assert.Nil(t, network)
+	assert.IsType(t, fail.ErrNotAvailable{}, err)
+}
+
+func TestCheckNetworkHostCapacity(t *testing.T) {
+	err := checkNetworkHostCapacity("192.168.1.0/30", 10, false)
+	require.NotNil(t, err)
+	assert.IsType(t, fail.ErrInvalidRequest{}, err)
+	assert.Contains(t, err.Error(), "/28")
+
+	assert.Nil(t, checkNetworkHostCapacity("192.168.1.0/24", 10, false))
+	assert.Nil(t, checkNetworkHostCapacity("192.168.1.0/24", 10, true))
+	assert.Nil(t, checkNetworkHostCapacity("192.168.1.0/30", 0, false))
+	assert.Nil(t, checkNetworkHostCapacity("fd00::/64", 1000, true))
+
+	// 14 usable addresses: enough for 13 hosts and the gateway, not for 11 hosts, 2 gateways and the VIP
+	assert.Nil(t, checkNetworkHostCapacity("192.168.1.0/28", 13, false))
+	assert.Nil(t, checkNetworkHostCapacity("192.168.1.0/28", 11, true))
+	err = checkNetworkHostCapacity("192.168.1.0/28", 12, true)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "/27")
+
+	assert.IsType(t, fail.ErrInvalidParameter{}, checkNetworkHostCapacity("192.168.1.0/24", -1, false))
+}
+
+func TestCreate_CIDRTooSmallForExpectedHosts(t *testing.T) {
+	svc := &slowNetworkService{memoryService: newMemoryService(), cancel: func() {}}
+
+	network, err := NewNetworkHandler(svc).Create(
+		context.Background(), "net", "192.168.1.0/30", ipversion.IPv4, abstract.SizingRequirements{}, "", "", false,
+		"", false, nil, 0, 10,
+	)
+	assert.Nil(t, network)
+	assert.IsType(t, fail.ErrInvalidRequest{}, err)
+	assert.Empty(t, svc.deleted)
+}
+
+// slowNetworkService is an iaas.Service where the client cancels the request while the network is being created
+type slowNetworkService struct {
+	*memoryService
+	cancel  context.CancelFunc
+	deleted []string
+}
+
+func (s *slowNetworkService) GetCapabilities() providers.Capabilities {
+	return providers.Capabilities{}
+}
+
+func (s *slowNetworkService) GetNetworkByName(name string) (*abstract.Network, error) {
+	return nil, fail.NotFoundError("network '" + name + "' not found")
+}
+
+func (s *slowNetworkService) CreateNetwork(req abstract.NetworkRequest) (*abstract.Network, error) {
+	s.cancel()
+	return &abstract.Network{ID: "net-id", Name: req.Name, CIDR: req.CIDR}, nil
+}
+
+func (s *slowNetworkService) DeleteNetwork(id string) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func TestCreate_CanceledDuringCreation(t *testing.T) {
+	for _, keepOnFailure := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		svc := &slowNetworkService{memoryService: newMemoryService(), cancel: cancel}
+
+		network, err := NewNetworkHandler(svc).Create(
+			ctx, "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{}, "", "", false, "",
+			keepOnFailure, nil, 0, 0,
+		)
+		assert.Nil(t, network)
+		require.NotNil(t, err)
+		assert.IsType(t, fail.ErrAborted{}, err)
+		assert.Equal(t, context.Canceled, fail.Cause(err))
+		if keepOnFailure {
+			assert.Empty(t, svc.deleted)
+		} else {
+			assert.Equal(t, []string{"net-id"}, svc.deleted)
+		}
+		assert.Empty(t, svc.bucket.Objects)
+	}
+}
+
+func TestFinishNetworkCreation_Canceled(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	mn, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+	spec := &propsv1.NetworkCreationSpec{CIDR: "192.168.1.0/24"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = finishNetworkCreation(ctx, "net", mn, spec)
+	assert.IsType(t, fail.ErrAborted{}, err)
+	assert.Equal(t, context.Canceled, fail.Cause(err))
+	_, err = mn.GetCreationSpec()
+	assert.IsType(t, fail.ErrNotFound{}, err)
+
+	err = finishNetworkCreation(context.Background(), "net", mn, spec)
+	require.Nil(t, err)
+	recorded, err := mn.GetCreationSpec()
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.0/24", recorded.CIDR)
+}
+
+// brokenGatewayService is an iaas.Service where the gateway host is created but cannot be inspected afterwards
+type brokenGatewayService struct {
+	*memoryService
+	deleted []string
+}
+
+func (s *brokenGatewayService) GetHostByName(name string) (*abstract.Host, error) {
+	return nil, fail.NotFoundError("host '" + name + "' not found")
+}
+
+func (s *brokenGatewayService) CreateGateway(
+	req abstract.GatewayRequest, sizing *abstract.SizingRequirements,
+) (*abstract.Host, *userdata.Content, error) {
+	return &abstract.Host{ID: "gw-id", Name: req.Name}, &userdata.Content{}, nil
+}
+
+func (s *brokenGatewayService) InspectHost(something interface{}) (*abstract.Host, error) {
+	return nil, fail.TimeoutError("timeout inspecting host", time.Minute, nil)
+}
+
+func (s *brokenGatewayService) DeleteHost(id string) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func TestCreateGateway_KeepOnFailure(t *testing.T) {
+	for _, keepOnFailure := range []bool{false, true} {
+		svc := &brokenGatewayService{memoryService: newMemoryService()}
+		handler := &NetworkHandler{service: svc}
+
+		_, err := handler.createGateway(
+			nil, data.Map{
+				"request": abstract.GatewayRequest{
+					Name:          "gw-net",
+					Network:       &abstract.Network{ID: "net-id", Name: "net"},
+					KeepOnFailure: keepOnFailure,
+				},
+				"sizing":  abstract.SizingRequirements{},
+				"primary": true,
+			},
+		)
+		require.NotNil(t, err)
+		if keepOnFailure {
+			assert.Empty(t, svc.deleted)
+		} else {
+			assert.Equal(t, []string{"gw-id"}, svc.deleted)
+		}
+	}
+}
+
+// teardownService is an iaas.Service where DeleteGateway fails with gatewayErr
+type teardownService struct {
+	*memoryService
+	gatewayErr            error
+	vipErr                error
+	networkErr            error
+	deletedSecurityGroups []string
+}
+
+func (s *teardownService) DeleteSecurityGroup(id string) error {
+	s.deletedSecurityGroups = append(s.deletedSecurityGroups, id)
+	return nil
+}
+
+func (s *teardownService) DeleteGateway(id string) error {
+	return s.gatewayErr
+}
+
+func (s *teardownService) UnbindHostFromVIP(vip *abstract.VirtualIP, hostID string) error {
+	return nil
+}
+
+func (s *teardownService) DeleteVIP(vip *abstract.VirtualIP) error {
+	return s.vipErr
+}
+
+func (s *teardownService) DeleteNetwork(id string) error {
+	return s.networkErr
+}
+
+func TestDeleteWithReport_GatewayOutcomes(t *testing.T) {
+	cases := []struct {
+		gatewayErr error
+		outcome    GatewayDeletionOutcome
+	}{
+		{nil, GatewayDeleted},
+		{fail.NotFoundError("gateway 'gw-id' not found"), GatewayAlreadyGone},
+		{fail.TimeoutError("timeout deleting gateway", time.Minute, nil), GatewayDeletionFailed},
+	}
+	for _, tc := range cases {
+		svc := &teardownService{memoryService: newMemoryService(), gatewayErr: tc.gatewayErr}
+		network := abstract.NewNetwork()
+		network.ID = "net-id"
+		network.Name = "net"
+		network.GatewayID = "gw-id"
+		_, err := metadata.SaveNetwork(svc, network)
+		require.Nil(t, err)
+		gw := abstract.NewHost()
+		gw.ID = "gw-id"
+		gw.Name = "gw-net"
+		_, err = metadata.SaveHost(svc, gw)
+		require.Nil(t, err)
+
+		report, err := NewNetworkHandler(svc).DeleteWithReport(context.Background(), "net")
+		require.Nil(t, err)
+		require.NotNil(t, report)
+		assert.Equal(t, "net", report.Network)
+		require.Len(t, report.Gateways, 1)
+		assert.Equal(t, "gw-id", report.Gateways[0].ID)
+		assert.True(t, report.Gateways[0].Primary)
+		assert.Equal(t, tc.outcome, report.Gateways[0].Outcome)
+		if tc.outcome == GatewayDeletionFailed {
+			assert.Equal(t, tc.gatewayErr, report.Gateways[0].Err)
+		} else {
+			assert.Nil(t, report.Gateways[0].Err)
+		}
+		assert.Empty(t, svc.bucket.Objects)
+	}
+}
+
+// bastionHost returns a host having an interface with IP 'ip' on the network 'networkID' and the public IP 'publicIP'
+func bastionHost(t *testing.T, networkID, ip, publicIP string) *abstract.Host {
+	host := abstract.NewHost()
+	host.ID = "bastion-id"
+	host.Name = "bastion"
+	err := host.Properties.LockForWrite(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+			hostNetworkV1.PublicIPv4 = publicIP
+			hostNetworkV1.IPv4Addresses[networkID] = ip
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	return host
+}
+
+func TestAttachGateway(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	mn, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+	host := bastionHost(t, "net-id", "192.168.1.10", "203.0.113.10")
+	_, err = metadata.SaveHost(svc, host)
+	require.Nil(t, err)
+	require.Nil(t, mn.AttachHost(host, false))
+
+	var configured []string
+	configure := func(network *abstract.Network, host *abstract.Host, ip string) error {
+		configured = append(configured, host.ID+"@"+ip)
+		return nil
+	}
+	save := func(host *abstract.Host) error {
+		_, err := metadata.SaveHost(svc, host)
+		return err
+	}
+	unconfigure := func(network *abstract.Network, host *abstract.Host) error {
+		t.Fatal("host attached successfully must not be unconfigured")
+		return nil
+	}
+	err = attachGateway(mn, host, configure, unconfigure, save)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"bastion-id@192.168.1.10"}, configured)
+
+	mn, err = metadata.LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	network, err = mn.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "bastion-id", network.GatewayID)
+	err = network.Properties.LockForRead(networkproperty.IPsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			assert.Equal(t, "bastion-id", clonable.(*propsv1.NetworkIPs).ByIP["192.168.1.10"])
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	assert.False(t, networkHasHosts(network))
+
+	mh, err := metadata.LoadHost(svc, "bastion-id")
+	require.Nil(t, err)
+	gw, err := mh.Get()
+	require.Nil(t, err)
+	err = gw.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			assert.True(t, clonable.(*propsv1.HostNetwork).IsGateway)
+			return nil
+		},
+	)
+	require.Nil(t, err)
+}
+
+func TestAttachGateway_RollbackOnMetadataFailure(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	mn, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+	host := bastionHost(t, "net-id", "192.168.1.10", "203.0.113.10")
+	_, err = metadata.SaveHost(svc, host)
+	require.Nil(t, err)
+	require.Nil(t, mn.AttachHost(host, false))
+	require.Nil(t, mn.Write())
+
+	// The metadata of the network cannot be written anymore
+	writeErr := fmt.Errorf("object storage unavailable")
+	svc.bucket.WriteError = func(name string) error {
+		if strings.Contains(name, "networks/") {
+			return writeErr
+		}
+		return nil
+	}
+	var configured, unconfigured []string
+	configure := func(network *abstract.Network, host *abstract.Host, ip string) error {
+		configured = append(configured, host.ID)
+		return nil
+	}
+	unconfigure := func(network *abstract.Network, host *abstract.Host) error {
+		unconfigured = append(unconfigured, host.ID)
+		return nil
+	}
+	save := func(host *abstract.Host) error {
+		_, err := metadata.SaveHost(svc, host)
+		return err
+	}
+	err = attachGateway(mn, host, configure, unconfigure, save)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), writeErr.Error())
+	assert.Equal(t, []string{"bastion-id"}, configured)
+	assert.Equal(t, []string{"bastion-id"}, unconfigured)
+
+	// The host is a regular host again
+	mh, err := metadata.LoadHost(svc, "bastion-id")
+	require.Nil(t, err)
+	restored, err := mh.Get()
+	require.Nil(t, err)
+	err = restored.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			assert.False(t, clonable.(*propsv1.HostNetwork).IsGateway)
+			return nil
+		},
+	)
+	require.Nil(t, err)
+
+	// The changes of the network not written are discarded
+	network, err = mn.Get()
+	require.Nil(t, err)
+	assert.Empty(t, network.GatewayID)
+	assert.True(t, networkHasHosts(network))
+}
+
+func TestAttachGateway_NoPublicIP(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	mn, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	configure := func(network *abstract.Network, host *abstract.Host, ip string) error {
+		t.Fatal("host without public IP must not be configured")
+		return nil
+	}
+	save := func(host *abstract.Host) error {
+		t.Fatal("host without public IP must not be saved")
+		return nil
+	}
+	unconfigure := func(network *abstract.Network, host *abstract.Host) error {
+		t.Fatal("host without public IP must not be unconfigured")
+		return nil
+	}
+	err = attachGateway(mn, bastionHost(t, "net-id", "192.168.1.10", ""), configure, unconfigure, save)
+	assert.IsType(t, fail.ErrInvalidRequest{}, err)
+	assert.Contains(t, err.Error(), "no public IP")
+
+	network, err = mn.Get()
+	require.Nil(t, err)
+	assert.Empty(t, network.GatewayID)
+
+	// No interface on the network
+	_, err = checkExternalGateway(network, bastionHost(t, "other-net-id", "10.0.0.10", "203.0.113.10"))
+	assert.IsType(t, fail.ErrInvalidRequest{}, err)
+	assert.Contains(t, err.Error(), "no interface on the network")
+}
+
+func TestNetworkCreateOptions_Validate(t *testing.T) {
+	assert.Nil(t, NetworkCreateOptions{}.validate())
+	assert.Nil(t, NetworkCreateOptions{Failover: true, PrimaryGatewayPrefix: "edge-"}.validate())
+	assert.Nil(t, NetworkCreateOptions{GatewayCount: 3, Failover: true}.validate())
+
+	invalid := []NetworkCreateOptions{
+		{Failover: true, GatewayName: "my-gw"},
+		{NoGateway: true, Failover: true},
+		{NoGateway: true, GatewayName: "my-gw"},
+		{NoGateway: true, GatewayCount: 2},
+		{GatewayCount: -1},
+		{GatewayCount: 1, Failover: true},
+		{GatewayCount: 3, GatewayName: "my-gw"},
+		{Failover: true, PrimaryGatewayPrefix: "gw2-"},
+		{AdditionalIngressPorts: []int{0}},
+	}
+	for _, opts := range invalid {
+		assert.IsType(t, fail.ErrInvalidParameter{}, opts.validate(), "%+v", opts)
+	}
+}
+
+func TestNetworkCreateOptions_GatewayCount(t *testing.T) {
+	assert.Equal(t, 1, NetworkCreateOptions{}.gatewayCount())
+	assert.Equal(t, 2, NetworkCreateOptions{Failover: true}.gatewayCount())
+	assert.Equal(t, 0, NetworkCreateOptions{NoGateway: true}.gatewayCount())
+	assert.Equal(t, 4, NetworkCreateOptions{GatewayCount: 4}.gatewayCount())
+}
+
+func TestExtraGatewayNames(t *testing.T) {
+	assert.Empty(t, extraGatewayNames("net", 2, ""))
+	assert.Equal(t, []string{"gw3-net.example.com", "gw4-net.example.com"}, extraGatewayNames("net", 4, "example.com"))
+}
+
+// gatewayNetworkService is an iaas.Service able to create a network and its gateways, recording the requests
+type gatewayNetworkService struct {
+	*memoryService
+	lock            sync.Mutex
+	networkRequests []abstract.NetworkRequest
+	gatewayRequests []abstract.GatewayRequest
+	boundToVIP      []string
+	getByNameCalls  int
+	// noSecurityGroups tells the provider doesn't manage security groups
+	noSecurityGroups bool
+	securityGroups   []*abstract.SecurityGroup
+	boundToSG        []string
+}
+
+func (s *gatewayNetworkService) GetCapabilities() providers.Capabilities {
+	return providers.Capabilities{PrivateVirtualIP: true}
+}
+
+func (s *gatewayNetworkService) GetNetworkByName(name string) (*abstract.Network, error) {
+	s.getByNameCalls++
+	return nil, fail.NotFoundError("network '" + name + "' not found")
+}
+
+func (s *gatewayNetworkService) CreateNetwork(req abstract.NetworkRequest) (*abstract.Network, error) {
+	s.networkRequests = append(s.networkRequests, req)
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = req.Name
+	network.CIDR = req.CIDR
+	return network, nil
+}
+
+func (s *gatewayNetworkService) CreateVIP(networkID, name string) (*abstract.VirtualIP, error) {
+	return &abstract.VirtualIP{ID: "vip-id", NetworkID: networkID, PrivateIP: "192.168.1.254"}, nil
+}
+
+func (s *gatewayNetworkService) BindHostToVIP(vip *abstract.VirtualIP, hostID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.boundToVIP = append(s.boundToVIP, hostID)
+	return nil
+}
+
+func (s *gatewayNetworkService) CreateSecurityGroup(
+	name, description string, rules []abstract.SecurityGroupRule,
+) (*abstract.SecurityGroup, error) {
+	if s.noSecurityGroups {
+		return nil, fail.NotImplementedError("security groups")
+	}
+	sg := &abstract.SecurityGroup{ID: "sg-id", Name: name, Description: description, Rules: rules}
+	s.securityGroups = append(s.securityGroups, sg)
+	return sg, nil
+}
+
+func (s *gatewayNetworkService) BindSecurityGroupToHost(sgID, hostID string) error {
+	s.boundToSG = append(s.boundToSG, sgID+"/"+hostID)
+	return nil
+}
+
+func (s *gatewayNetworkService) SelectTemplatesBySize(
+	sizing abstract.SizingRequirements, force bool,
+) ([]*abstract.HostTemplate, error) {
+	return []*abstract.HostTemplate{{ID: "tpl-id", Name: "tpl"}}, nil
+}
+
+func (s *gatewayNetworkService) SearchImage(name string) (*abstract.Image, error) {
+	return &abstract.Image{ID: "img-id", Name: name}, nil
+}
+
+func (s *gatewayNetworkService) GetHostByName(name string) (*abstract.Host, error) {
+	return nil, fail.NotFoundError("host '" + name + "' not found")
+}
+
+func (s *gatewayNetworkService) CreateGateway(
+	req abstract.GatewayRequest, sizing *abstract.SizingRequirements,
+) (*abstract.Host, *userdata.Content, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.gatewayRequests = append(s.gatewayRequests, req)
+	host := abstract.NewHost()
+	host.ID = "id-" + req.Name
+	host.Name = req.Name
+	return host, &userdata.Content{}, nil
+}
+
+func (s *gatewayNetworkService) InspectHost(something interface{}) (*abstract.Host, error) {
+	return something.(*abstract.Host), nil
+}
+
+// gatewayNames returns the sorted names of the gateways requested
+func (s *gatewayNetworkService) gatewayNames() []string {
+	var names []string
+	for _, req := range s.gatewayRequests {
+		names = append(names, req.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCreateWithOptions_NoGateway(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+
+	network, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{NoGateway: true},
+	)
+	require.Nil(t, err)
+	assert.Empty(t, network.GatewayID)
+	assert.Empty(t, svc.gatewayRequests)
+	_, err = metadata.LoadNetwork(svc, "net")
+	assert.Nil(t, err)
+}
+
+func TestCreateWithOptions_Gateways(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+
+	network, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{
+			OS:                     "Ubuntu 18.04",
+			Failover:               true,
+			PrimaryGatewayPrefix:   "edge-",
+			SecondaryGatewayPrefix: "edge2-",
+			Domain:                 "example.com",
+			KeepOnFailure:          true,
+			SkipFinalization:       true,
+			AdditionalIngressPorts: []int{443},
+		},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"edge-net.example.com", "edge2-net.example.com"}, svc.gatewayNames())
+	for _, req := range svc.gatewayRequests {
+		assert.True(t, req.KeepOnFailure)
+		assert.Equal(t, "img-id", req.ImageID)
+	}
+	require.Len(t, svc.networkRequests, 1)
+	assert.Equal(t, []int{443}, svc.networkRequests[0].AdditionalIngressPorts)
+	assert.Equal(t, "id-edge-net.example.com", network.GatewayID)
+	assert.Equal(t, "id-edge2-net.example.com", network.SecondaryGatewayID)
+	assert.NotNil(t, network.VIP)
+}
+
+func TestCreateWithOptions_DefaultSecurityGroup(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+
+	network, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{OS: "Ubuntu 18.04", Failover: true, SkipFinalization: true},
+	)
+	require.Nil(t, err)
+	require.Len(t, svc.securityGroups, 1)
+	sg := svc.securityGroups[0]
+	assert.Equal(t, "sg-safescale.net", sg.Name)
+	assert.Equal(t, stacks.DefaultSecurityGroupRules(), sg.Rules)
+	assert.Equal(t, "sg-id", network.GetDefaultSecurityGroup())
+	assert.ElementsMatch(t, []string{"sg-id/" + network.GatewayID, "sg-id/" + network.SecondaryGatewayID}, svc.boundToSG)
+
+	// the ID of the default security group is recorded in the metadata of the network
+	mn, err := metadata.LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	recorded, err := mn.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "sg-id", recorded.GetDefaultSecurityGroup())
+}
+
+func TestCreateWithOptions_NoSecurityGroups(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService(), noSecurityGroups: true}
+
+	network, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{OS: "Ubuntu 18.04", SkipFinalization: true},
+	)
+	require.Nil(t, err)
+	assert.Empty(t, network.GetDefaultSecurityGroup())
+	assert.Empty(t, svc.boundToSG)
+}
+
+func TestCreateWithOptions_GatewayName(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+
+	network, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{OS: "Ubuntu 18.04", GatewayName: "my-gw", SkipFinalization: true},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"my-gw"}, svc.gatewayNames())
+	assert.False(t, svc.gatewayRequests[0].KeepOnFailure)
+	assert.Equal(t, "id-my-gw", network.GatewayID)
+	assert.Empty(t, network.SecondaryGatewayID)
+}
+
+// gatewayPhasesOf returns the configuration phases recorded in the metadata of the host 'ref'
+func gatewayPhasesOf(t *testing.T, svc *memoryService, ref string) *propsv1.HostGatewayPhases {
+	mh, err := metadata.LoadHost(svc, ref)
+	require.Nil(t, err)
+	host, err := mh.Get()
+	require.Nil(t, err)
+	var phases *propsv1.HostGatewayPhases
+	err = host.Properties.LockForRead(hostproperty.GatewayPhasesV1).ThenUse(
+		func(clonable data.Clonable) error {
+			phases = clonable.Clone().(*propsv1.HostGatewayPhases)
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	return phases
+}
+
+func TestGatewayPhaseRecorder(t *testing.T) {
+	svc := newMemoryService()
+	gw := abstract.NewHost()
+	gw.ID = "gw-id"
+	gw.Name = "gw"
+	rec := NewNetworkHandler(svc).(*NetworkHandler).newGatewayPhaseRecorder(gw)
+
+	rec.enter(gatewayPhaseConfiguration)
+	phases := gatewayPhasesOf(t, svc, "gw-id")
+	assert.Empty(t, phases.Completed)
+	assert.Equal(t, gatewayPhaseConfiguration, phases.Current)
+	assert.False(t, phases.Updated.IsZero())
+
+	rec.enter(gatewayPhaseReboot)
+	rec.enter(gatewayPhaseReady)
+	var err error
+	rec.finish(&err)
+	phases = gatewayPhasesOf(t, svc, "gw-id")
+	assert.Equal(t, []string{gatewayPhaseConfiguration, gatewayPhaseReboot, gatewayPhaseReady}, phases.Completed)
+	assert.Empty(t, phases.Current)
+	assert.False(t, phases.Failed())
+}
+
+func TestGatewayPhaseRecorder_Failure(t *testing.T) {
+	svc := newMemoryService()
+	gw := abstract.NewHost()
+	gw.ID = "gw-id"
+	gw.Name = "gw"
+	rec := NewNetworkHandler(svc).(*NetworkHandler).newGatewayPhaseRecorder(gw)
+
+	func() (err error) {
+		rec.enter(gatewayPhaseConfiguration)
+		defer rec.finish(&err)
+		rec.enter(gatewayPhaseReboot)
+		return fail.Errorf("failed to reboot", nil)
+	}()
+	phases := gatewayPhasesOf(t, svc, "gw-id")
+	assert.Equal(t, []string{gatewayPhaseConfiguration}, phases.Completed)
+	assert.Equal(t, gatewayPhaseReboot, phases.Current)
+	assert.True(t, phases.Failed())
+	assert.Contains(t, phases.Error, "failed to reboot")
+
+	// a new run of the configuration starts from the failed phase
+	rec.enter(gatewayPhaseReboot)
+	phases = gatewayPhasesOf(t, svc, "gw-id")
+	assert.Equal(t, []string{gatewayPhaseConfiguration}, phases.Completed)
+	assert.False(t, phases.Failed())
+}
+
+func TestRerunGatewayPhase(t *testing.T) {
+	svc := newMemoryService()
+	gw := abstract.NewHost()
+	gw.ID = "gw-id"
+	gw.Name = "gw"
+	rec := NewNetworkHandler(svc).(*NetworkHandler).newGatewayPhaseRecorder(gw)
+
+	// the creation failed during the reboot
+	func() (err error) {
+		rec.enter(gatewayPhaseConfiguration)
+		defer rec.finish(&err)
+		rec.enter(gatewayPhaseReboot)
+		return fail.Errorf("connection reset", nil)
+	}()
+
+	runs := 0
+	err := rerunGatewayPhase(rec, gatewayPhaseReboot, func() error {
+		runs++
+		phases := gatewayPhasesOf(t, svc, "gw-id")
+		assert.Equal(t, gatewayPhaseReboot, phases.Current)
+		assert.False(t, phases.Failed())
+		return nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 1, runs)
+	phases := gatewayPhasesOf(t, svc, "gw-id")
+	assert.Equal(t, []string{gatewayPhaseConfiguration, gatewayPhaseReboot}, phases.Completed)
+	assert.Empty(t, phases.Current)
+	assert.False(t, phases.Failed())
+
+	// a phase already completed is not recorded twice, and its failure is recorded
+	err = rerunGatewayPhase(rec, gatewayPhaseConfiguration, func() error {
+		return fail.Errorf("script failed", nil)
+	})
+	require.NotNil(t, err)
+	phases = gatewayPhasesOf(t, svc, "gw-id")
+	assert.Equal(t, []string{gatewayPhaseReboot}, phases.Completed)
+	assert.Equal(t, gatewayPhaseConfiguration, phases.Current)
+	assert.Contains(t, phases.Error, "script failed")
+}
+
+func TestNetworkHandler_RerunGatewayPhase_UnknownPhase(t *testing.T) {
+	err := NewNetworkHandler(newMemoryService()).RerunGatewayPhase(context.Background(), "net", "bastion-id", "sysfix")
+	assert.IsType(t, fail.ErrInvalidParameter{}, err)
+}
+
+// securityRulesService is a memoryService recording the security rules applied to the hosts
+type securityRulesService struct {
+	*memoryService
+	// unsupported makes the service behave as a provider not filtering the traffic of each host
+	unsupported bool
+	applied     map[string][]abstract.SecurityGroupRule
+}
+
+func (s *securityRulesService) ApplySecurityRules(hostID string, rules []abstract.SecurityGroupRule) error {
+	if s.unsupported {
+		return fail.NotImplementedError("security rules of host")
+	}
+	if s.applied == nil {
+		s.applied = map[string][]abstract.SecurityGroupRule{}
+	}
+	s.applied[hostID] = rules
+	return nil
+}
+
+func TestNetworkHandler_RerunGatewayPhase_IngressPorts(t *testing.T) {
+	for _, unsupported := range []bool{false, true} {
+		svc := &securityRulesService{memoryService: newMemoryService(), unsupported: unsupported}
+		network := abstract.NewNetwork()
+		network.ID = "net-id"
+		network.Name = "net"
+		network.CIDR = "192.168.1.0/24"
+		network.GatewayID = "bastion-id"
+		mn, err := metadata.SaveNetwork(svc, network)
+		require.Nil(t, err)
+		require.Nil(t, recordCreationSpec(mn, &propsv1.NetworkCreationSpec{
+			CIDR: "192.168.1.0/24", AdditionalIngressPorts: []int{443, 8444},
+		}))
+		_, err = metadata.SaveHost(svc, bastionHost(t, "net-id", "192.168.1.10", "203.0.113.10"))
+		require.Nil(t, err)
+
+		err = NewNetworkHandler(svc).RerunGatewayPhase(context.Background(), "net", "bastion", gatewayPhaseIngressPorts)
+		require.Nil(t, err)
+		if unsupported {
+			assert.Empty(t, svc.applied)
+			continue
+		}
+		require.Len(t, svc.applied["bastion-id"], 2)
+		assert.Equal(t, 443, svc.applied["bastion-id"][0].PortFrom)
+		assert.Equal(t, 8444, svc.applied["bastion-id"][1].PortFrom)
+		phases := gatewayPhasesOf(t, svc.memoryService, "bastion-id")
+		assert.Contains(t, phases.Completed, gatewayPhaseIngressPorts)
+	}
+}
+
+func TestNetworkHandler_GatewayConfiguration(t *testing.T) {
+	svc := &defaultImageService{&gatewayNetworkService{memoryService: newMemoryService()}}
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	network.GatewayID = "bastion-id"
+	mn, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+	gw := bastionHost(t, "net-id", "192.168.1.10", "203.0.113.10")
+
+	// without recorded creation parameters, the built-in template is used
+	userData, tmpl, err := NewNetworkHandler(svc).(*NetworkHandler).gatewayConfiguration(mn, network, gw)
+	require.Nil(t, err)
+	assert.Empty(t, tmpl)
+	assert.Empty(t, userData.IngressPorts)
+
+	// the custom template recorded at the creation of the network is used again
+	require.Nil(t, recordCreationSpec(mn, &propsv1.NetworkCreationSpec{
+		CIDR: "192.168.1.0/24", MTU: 1400, AdditionalIngressPorts: []int{443}, GatewayTemplate: customGatewayTemplate,
+	}))
+	userData, tmpl, err = NewNetworkHandler(svc).(*NetworkHandler).gatewayConfiguration(mn, network, gw)
+	require.Nil(t, err)
+	assert.Equal(t, customGatewayTemplate, tmpl)
+	assert.Equal(t, []int{443}, userData.IngressPorts)
+	assert.Equal(t, 1400, userData.MTU)
+	script, err := generateGatewayScript(userData, tmpl)
+	require.Nil(t, err)
+	assert.Contains(t, string(script), "custom configuration of")
+}
+
+func TestNetworkHandler_RerunGatewayPhase_UnknownGateway(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	network.GatewayID = "bastion-id"
+	_, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+	_, err = metadata.SaveHost(svc, bastionHost(t, "net-id", "192.168.1.10", "203.0.113.10"))
+	require.Nil(t, err)
+
+	err = NewNetworkHandler(svc).RerunGatewayPhase(context.Background(), "net", "gw2", gatewayPhaseIngressPorts)
+	assert.IsType(t, fail.ErrNotFound{}, err)
+	assert.Empty(t, gatewayPhasesOf(t, svc, "bastion-id").Current)
+}
+
+func TestNetworkHandler_FailoverGatewayConfigurations(t *testing.T) {
+	svc := &defaultImageService{&gatewayNetworkService{memoryService: newMemoryService()}}
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	network.VIP = &abstract.VirtualIP{ID: "vip-id", PrivateIP: "192.168.1.254"}
+	mn, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+	require.Nil(t, mn.SetGatewayIDs([]string{"bastion-id", "gw2-id"}))
+	require.Nil(t, mn.Write())
+	require.Nil(t, recordCreationSpec(mn, &propsv1.NetworkCreationSpec{
+		CIDR: "192.168.1.0/24", AdditionalIngressPorts: []int{443},
+	}))
+	primary := bastionHost(t, "net-id", "192.168.1.10", "203.0.113.10")
+	_, err = metadata.SaveHost(svc, primary)
+	require.Nil(t, err)
+	secondary := bastionHost(t, "net-id", "192.168.1.11", "203.0.113.11")
+	secondary.ID, secondary.Name = "gw2-id", "gw2"
+	_, err = metadata.SaveHost(svc, secondary)
+	require.Nil(t, err)
+
+	network, err = mn.Get()
+	require.Nil(t, err)
+	gateways, err := networkGateways(mn, network)
+	require.Nil(t, err)
+	require.Len(t, gateways, 2)
+	userDatas, tmpl, err := NewNetworkHandler(svc).(*NetworkHandler).failoverGatewayConfigurations(mn, network, gateways)
+	require.Nil(t, err)
+	assert.Empty(t, tmpl)
+	require.Len(t, userDatas, 2)
+
+	assert.True(t, userDatas[0].IsPrimaryGateway)
+	assert.False(t, userDatas[1].IsPrimaryGateway)
+	assert.NotEmpty(t, userDatas[0].GatewayHAKeepalivedPassword)
+	for _, userData := range userDatas {
+		assert.Equal(t, userDatas[0].GatewayHAKeepalivedPassword, userData.GatewayHAKeepalivedPassword)
+		assert.Equal(t, "192.168.1.10", userData.PrimaryGatewayPrivateIP)
+		assert.Equal(t, "203.0.113.10", userData.PrimaryGatewayPublicIP)
+		assert.Equal(t, "192.168.1.11", userData.SecondaryGatewayPrivateIP)
+		assert.Equal(t, "203.0.113.11", userData.SecondaryGatewayPublicIP)
+		assert.True(t, userData.UsesVIP)
+		assert.Equal(t, "192.168.1.254", userData.PrivateVIP)
+		assert.Equal(t, []int{443}, userData.IngressPorts)
+	}
+	// the gateways are bound again to the VIP
+	assert.Equal(t, []string{"bastion-id", "gw2-id"}, svc.boundToVIP)
+
+	// a new keepalived password is generated each time the configuration is run again
+	again, _, err := NewNetworkHandler(svc).(*NetworkHandler).failoverGatewayConfigurations(mn, network, gateways)
+	require.Nil(t, err)
+	assert.NotEqual(t, userDatas[0].GatewayHAKeepalivedPassword, again[0].GatewayHAKeepalivedPassword)
+}
+
+func TestAllocateCIDR(t *testing.T) {
+	svc := newMemoryService()
+
+	var allocated []*net.IPNet
+	for i := 0; i < 4; i++ {
+		c, err := AllocateCIDR(nil, svc, "10.1.0.0/16", 18)
+		require.Nil(t, err)
+		_, ipnet, err := net.ParseCIDR(c)
+		require.Nil(t, err)
+		allocated = append(allocated, ipnet)
+
+		network := abstract.NewNetwork()
+		network.ID = fmt.Sprintf("net%d-id", i)
+		network.Name = fmt.Sprintf("net%d", i)
+		network.CIDR = c
+		_, err = metadata.SaveNetwork(svc, network)
+		require.Nil(t, err)
+	}
+	_, super, _ := net.ParseCIDR("10.1.0.0/16")
+	assert.Nil(t, cidr.VerifyNoOverlap(allocated, super))
+
+	_, err := AllocateCIDR(nil, svc, "10.1.0.0/16", 18)
+	require.NotNil(t, err)
+	_, ok := err.(fail.ErrOverflow)
+	assert.True(t, ok)
+
+	// a smaller subnet doesn't fit either
+	_, err = AllocateCIDR(nil, svc, "10.1.0.0/16", 24)
+	_, ok = err.(fail.ErrOverflow)
+	assert.True(t, ok)
+}
+
+func TestNextFreeCIDR(t *testing.T) {
+	c, err := nextFreeCIDR("192.168.0.0/16", 24, []string{"192.168.0.0/24", "192.168.2.0/23", "10.0.0.0/8"})
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.0/24", c)
+
+	// a used network larger than the requested size covers several candidates
+	c, err = nextFreeCIDR("192.168.0.0/16", 24, []string{"192.168.0.0/22"})
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.4.0/24", c)
+
+	_, err = nextFreeCIDR("192.168.0.0/16", 8, nil)
+	assert.NotNil(t, err)
+	_, err = nextFreeCIDR("not a cidr", 24, nil)
+	_, ok := err.(fail.ErrInvalidCIDR)
+	assert.True(t, ok)
+}
+
+func TestCreateWithOptions_AllocatesCIDR(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+	other := abstract.NewNetwork()
+	other.ID = "other-id"
+	other.Name = "other"
+	other.CIDR = "192.168.0.0/24"
+	_, err := metadata.SaveNetwork(svc, other)
+	require.Nil(t, err)
+
+	network, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{NoGateway: true},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.0/24", network.CIDR)
+	require.Len(t, svc.networkRequests, 1)
+	assert.Equal(t, "192.168.1.0/24", svc.networkRequests[0].CIDR)
+}
+
+func TestCreateWithOptions_GatewayCount(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+
+	network, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{GatewayCount: 3, SkipFinalization: true},
+	)
+	require.Nil(t, err)
+	names := []string{"gw-net", "gw2-net", "gw3-net"}
+	assert.Equal(t, names, svc.gatewayNames())
+	require.NotNil(t, network.VIP)
+	sort.Strings(svc.boundToVIP)
+	assert.Equal(t, []string{"id-gw-net", "id-gw2-net", "id-gw3-net"}, svc.boundToVIP)
+
+	// the first two gateways are still referenced by the fields of the network
+	assert.Equal(t, "id-gw-net", network.GatewayID)
+	assert.Equal(t, "id-gw2-net", network.SecondaryGatewayID)
+
+	mn, err := metadata.LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	for i, name := range names {
+		gw, err := mn.GetGatewayByIndex(i)
+		require.Nil(t, err)
+		assert.Equal(t, name, gw.Name)
+	}
+	_, err = mn.GetGatewayByIndex(3)
+	assert.IsType(t, fail.ErrNotFound{}, err)
+
+	spec, err := mn.GetCreationSpec()
+	require.Nil(t, err)
+	assert.Equal(t, 3, spec.GatewayCount)
+	assert.True(t, spec.HA)
+}
+
+func TestCreateWithOptions_DuplicateInMetadata(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+	existing := abstract.NewNetwork()
+	existing.ID = "existing-id"
+	existing.Name = "net"
+	existing.CIDR = "192.168.1.0/24"
+	_, err := metadata.SaveNetwork(svc, existing)
+	require.Nil(t, err)
+
+	_, err = NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "192.168.2.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{NoGateway: true},
+	)
+	assert.IsType(t, fail.ErrDuplicate{}, err)
+	assert.Equal(t, 0, svc.getByNameCalls)
+	assert.Empty(t, svc.networkRequests)
+
+	// a free name is still checked on provider side
+	_, err = NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "other", "192.168.2.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{NoGateway: true},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, 1, svc.getByNameCalls)
+}
+
+// defaultImageService is a gatewayNetworkService configured with a default image
+type defaultImageService struct {
+	*gatewayNetworkService
+}
+
+func (s *defaultImageService) GetConfigurationOptions() (providers.Config, error) {
+	return providers.ConfigMap{"DefaultImage": "Ubuntu 18.04"}, nil
+}
+
+func TestCreateWithOptions_RecordsCreationSpec(t *testing.T) {
+	svc := &defaultImageService{&gatewayNetworkService{memoryService: newMemoryService()}}
+	sizing := abstract.SizingRequirements{MinCores: 2, MinRAMSize: 4, Arch: "x86_64"}
+
+	_, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "", ipversion.IPv4, sizing,
+		NetworkCreateOptions{
+			Failover:                true,
+			Domain:                  "example.com.",
+			SkipFinalization:        true,
+			AdditionalIngressPorts:  []int{443},
+			MTU:                     1400,
+			GatewayUserdataTemplate: customGatewayTemplate,
+		},
+	)
+	require.Nil(t, err)
+
+	mn, err := metadata.LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	spec, err := mn.GetCreationSpec()
+	require.Nil(t, err)
+	assert.Equal(t, svc.networkRequests[0].CIDR, spec.CIDR)
+	assert.Equal(t, int(ipversion.IPv4), spec.IPVersion)
+	assert.Equal(t, "example.com", spec.Domain)
+	assert.True(t, spec.HA)
+	assert.Equal(t, []int{443}, spec.AdditionalIngressPorts)
+	assert.Equal(t, 1400, spec.MTU)
+	assert.Equal(t, customGatewayTemplate, spec.GatewayTemplate)
+	assert.Empty(t, spec.GatewayName)
+	assert.Equal(t, 2, spec.Sizing.MinCores)
+	assert.Equal(t, float32(4), spec.Sizing.MinRAMSize)
+	assert.Equal(t, "x86_64", spec.Sizing.Arch)
+	assert.False(t, spec.NoGateway)
+
+	// the defaults filled in during the creation are recorded
+	assert.Empty(t, spec.OS)
+	assert.Equal(t, "img-id", spec.ImageID)
+	assert.Equal(t, "Ubuntu 18.04", spec.ImageName)
+	assert.Equal(t, "tpl-id", spec.TemplateID)
+	assert.Equal(t, "tpl", spec.TemplateName)
+}
+
+func TestCreateWithOptions_RecordsCreationSpec_NoGateway(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+
+	_, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{NoGateway: true, ExpectedHostCount: 10},
+	)
+	require.Nil(t, err)
+
+	mn, err := metadata.LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	spec, err := mn.GetCreationSpec()
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.0/24", spec.CIDR)
+	assert.Equal(t, 10, spec.ExpectedHostCount)
+	assert.True(t, spec.NoGateway)
+	assert.Empty(t, spec.ImageID)
+	assert.Empty(t, spec.TemplateID)
+}
+
+func TestCreateWithOptions_AllowRoutableCIDR(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+	handler := NewNetworkHandler(svc)
+
+	_, err := handler.CreateWithOptions(
+		context.Background(), "net", "100.64.0.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{NoGateway: true},
+	)
+	assert.IsType(t, fail.ErrRoutableCIDR{}, err)
+
+	_, err = handler.CreateWithOptions(
+		context.Background(), "net", "100.64.0.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{NoGateway: true, AllowRoutableCIDR: true},
+	)
+	require.Nil(t, err)
+
+	mn, err := metadata.LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	spec, err := mn.GetCreationSpec()
+	require.Nil(t, err)
+	assert.Equal(t, "100.64.0.0/24", spec.CIDR)
+	assert.True(t, spec.AllowRoutableCIDR)
+}
+
+const customGatewayTemplate = `#!/bin/bash
+echo "custom configuration of {{ .HostName }}"
+echo -n "0,custom" >/opt/safescale/var/state/user_data.phase2.done
+#insert_tag
+`
+
+func TestNetworkCreateOptions_GatewayUserdataTemplate(t *testing.T) {
+	tmpl, err := NetworkCreateOptions{}.gatewayUserdataTemplate()
+	require.Nil(t, err)
+	assert.Empty(t, tmpl)
+
+	tmpl, err = NetworkCreateOptions{GatewayUserdataTemplate: customGatewayTemplate}.gatewayUserdataTemplate()
+	require.Nil(t, err)
+	assert.Equal(t, customGatewayTemplate, tmpl)
+
+	f, err := ioutil.TempFile("", "gateway-template-")
+	require.Nil(t, err)
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, err = f.WriteString(customGatewayTemplate)
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+	tmpl, err = NetworkCreateOptions{GatewayUserdataTemplateFile: f.Name()}.gatewayUserdataTemplate()
+	require.Nil(t, err)
+	assert.Equal(t, customGatewayTemplate, tmpl)
+
+	// a template without the tag where content is inserted is rejected
+	_, err = NetworkCreateOptions{
+		GatewayUserdataTemplate: strings.Replace(customGatewayTemplate, "#insert_tag", "", 1),
+	}.gatewayUserdataTemplate()
+	require.NotNil(t, err)
+	assert.IsType(t, fail.ErrInvalidParameter{}, err)
+
+	err = NetworkCreateOptions{
+		GatewayUserdataTemplate: customGatewayTemplate, GatewayUserdataTemplateFile: f.Name(),
+	}.validate()
+	assert.NotNil(t, err)
+}
+
+func TestGenerateGatewayScript(t *testing.T) {
+	ud := userdata.NewContent()
+	ud.HostName = "gw-net"
+
+	script, err := generateGatewayScript(ud, customGatewayTemplate)
+	require.Nil(t, err)
+	assert.Contains(t, string(script), `echo "custom configuration of gw-net"`)
+
+	script, err = generateGatewayScript(ud, "")
+	require.Nil(t, err)
+	assert.NotContains(t, string(script), "custom configuration")
+}
+
+func TestCreateWithOptions_InvalidGatewayTemplate(t *testing.T) {
+	svc := &gatewayNetworkService{memoryService: newMemoryService()}
+
+	_, err := NewNetworkHandler(svc).CreateWithOptions(
+		context.Background(), "net", "192.168.1.0/24", ipversion.IPv4, abstract.SizingRequirements{},
+		NetworkCreateOptions{GatewayUserdataTemplate: "#!/bin/bash\nexit 0\n"},
+	)
+	require.NotNil(t, err)
+	assert.Empty(t, svc.networkRequests)
+}
+
+func TestDeleteWithReport_Steps(t *testing.T) {
+	vipErr := fail.Errorf("VIP port is busy", nil)
+	networkErr := fail.Errorf("network has ports", nil)
+	cases := []struct {
+		name       string
+		gatewayErr error
+		vipErr     error
+		networkErr error
+		expected   map[TeardownStep]TeardownOutcome
+	}{
+		{
+			"all deleted", nil, nil, nil,
+			map[TeardownStep]TeardownOutcome{
+				TeardownPrimaryGateway: TeardownDeleted, TeardownSecondaryGateway: TeardownDeleted,
+				TeardownVIP: TeardownDeleted, TeardownProviderNetwork: TeardownDeleted, TeardownMetadata: TeardownDeleted,
+			},
+		},
+		{
+			"already gone", fail.NotFoundError("gateway not found"), fail.NotFoundError("VIP not found"),
+			fail.NotFoundError("network not found"),
+			map[TeardownStep]TeardownOutcome{
+				TeardownPrimaryGateway: TeardownAlreadyGone, TeardownSecondaryGateway: TeardownAlreadyGone,
+				TeardownVIP: TeardownAlreadyGone, TeardownProviderNetwork: TeardownAlreadyGone,
+				TeardownMetadata: TeardownDeleted,
+			},
+		},
+		{
+			"VIP failed", nil, vipErr, nil,
+			map[TeardownStep]TeardownOutcome{
+				TeardownPrimaryGateway: TeardownDeleted, TeardownSecondaryGateway: TeardownDeleted,
+				TeardownVIP: TeardownFailed, TeardownProviderNetwork: TeardownDeleted, TeardownMetadata: TeardownDeleted,
+			},
+		},
+		{
+			"provider network failed", nil, nil, networkErr,
+			map[TeardownStep]TeardownOutcome{
+				TeardownPrimaryGateway: TeardownDeleted, TeardownSecondaryGateway: TeardownDeleted,
+				TeardownVIP: TeardownDeleted, TeardownProviderNetwork: TeardownFailed, TeardownMetadata: TeardownDeleted,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(
+			tc.name, func(t *testing.T) {
+				svc := &teardownService{
+					memoryService: newMemoryService(), gatewayErr: tc.gatewayErr, vipErr: tc.vipErr,
+					networkErr: tc.networkErr,
+				}
+				network := abstract.NewNetwork()
+				network.ID = "net-id"
+				network.Name = "net"
+				network.GatewayID = "gw-id"
+				network.SecondaryGatewayID = "gw2-id"
+				network.VIP = &abstract.VirtualIP{ID: "vip-id", PrivateIP: "192.168.1.254"}
+				_, err := metadata.SaveNetwork(svc, network)
+				require.Nil(t, err)
+				for _, id := range []string{"gw-id", "gw2-id"} {
+					gw := abstract.NewHost()
+					gw.ID = id
+					gw.Name = id
+					_, err = metadata.SaveHost(svc, gw)
+					require.Nil(t, err)
+				}
+
+				report, err := NewNetworkHandler(svc).DeleteWithReport(context.Background(), "net")
+				if tc.expected[TeardownProviderNetwork] == TeardownFailed {
+					require.NotNil(t, err)
+				} else {
+					require.Nil(t, err)
+				}
+				require.Len(t, report.Steps, len(tc.expected))
+				for step, outcome := range tc.expected {
+					result, ok := report.Step(step)
+					require.True(t, ok, string(step))
+					assert.Equal(t, outcome, result.Outcome, string(step))
+					assert.Equal(t, outcome == TeardownFailed, result.Err != nil, string(step))
+				}
+				if result, _ := report.Step(TeardownVIP); result.Outcome == TeardownFailed {
+					assert.Equal(t, vipErr, result.Err)
+				}
+				// the deletion is forgotten once ended, even on failure
+				assert.Equal(t, 0, networkDeletions.inProgress())
+			},
+		)
+	}
+}
+
+func TestDeleteWithReport_DefaultSecurityGroup(t *testing.T) {
+	svc := &teardownService{memoryService: newMemoryService()}
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.DefaultSecurityGroupID = "sg-id"
+	_, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	report, err := NewNetworkHandler(svc).DeleteWithReport(context.Background(), "net")
+	require.Nil(t, err)
+	result, ok := report.Step(TeardownDefaultSecurityGroup)
+	require.True(t, ok)
+	assert.Equal(t, TeardownDeleted, result.Outcome)
+	assert.Equal(t, []string{"sg-id"}, svc.deletedSecurityGroups)
+}
+
+func TestNetworkTeardownReport_Record(t *testing.T) {
+	report := &NetworkTeardownReport{}
+	_, ok := report.Step(TeardownMetadata)
+	assert.False(t, ok)
+
+	report.record(TeardownMetadata, TeardownFailed, fail.Errorf("failed", nil))
+	report.record(TeardownMetadata, TeardownDeleted, nil)
+	require.Len(t, report.Steps, 1)
+	result, ok := report.Step(TeardownMetadata)
+	require.True(t, ok)
+	assert.Equal(t, TeardownDeleted, result.Outcome)
+	assert.Nil(t, result.Err)
+}
+
+// dualStackGateway returns a gateway with the given addresses on the network 'net-id'
+func dualStackGateway(t *testing.T, ipv4, publicIPv4, ipv6, publicIPv6 string) *abstract.Host {
+	gw := abstract.NewHost()
+	gw.ID = "gw-id"
+	gw.Name = "gw-net"
+	err := gw.Properties.LockForWrite(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+			hostNetworkV1.DefaultNetworkID = "net-id"
+			if ipv4 != "" {
+				hostNetworkV1.IPv4Addresses["net-id"] = ipv4
+			}
+			if ipv6 != "" {
+				hostNetworkV1.IPv6Addresses["net-id"] = ipv6
+			}
+			hostNetworkV1.PublicIPv4 = publicIPv4
+			hostNetworkV1.PublicIPv6 = publicIPv6
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	return gw
+}
+
+func TestGatewayRouteIPs_IPv4(t *testing.T) {
+	gw := dualStackGateway(t, "192.168.1.1", "203.0.113.1", "fd00::1", "2001:db8::1")
+
+	private, public, err := gatewayRouteIPs(gw, ipversion.IPv4)
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.1", private)
+	assert.Equal(t, "203.0.113.1", public)
+
+	// IPv6 only gateway
+	gw = dualStackGateway(t, "", "", "fd00::1", "2001:db8::1")
+	_, _, err = gatewayRouteIPs(gw, ipversion.IPv4)
+	require.NotNil(t, err)
+	assert.IsType(t, fail.ErrInvalidRequest{}, err)
+}
+
+func TestGatewayRouteIPs_IPv6(t *testing.T) {
+	gw := dualStackGateway(t, "192.168.1.1", "203.0.113.1", "fd00::1", "2001:db8::1")
+
+	private, public, err := gatewayRouteIPs(gw, ipversion.IPv6)
+	require.Nil(t, err)
+	assert.Equal(t, "fd00::1", private)
+	assert.Equal(t, "2001:db8::1", public)
+
+	// IPv4 only gateway
+	gw = dualStackGateway(t, "192.168.1.1", "203.0.113.1", "", "")
+	_, _, err = gatewayRouteIPs(gw, ipversion.IPv6)
+	require.NotNil(t, err)
+	assert.IsType(t, fail.ErrInvalidRequest{}, err)
+	assert.Contains(t, err.Error(), "IPv6")
+}
+
+func TestFindOrphanedGateways(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.GatewayID = "gw-id"
+	network.SecondaryGatewayID = "gw2-id"
+	_, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	hosts := []*abstract.Host{
+		{ID: "gw-id", Name: "gw-net"},
+		{ID: "gw2-id", Name: "gw2-net"},
+		{ID: "orphan-id", Name: "gw-deleted"},
+		{ID: "host-id", Name: "host"},
+	}
+	for _, h := range hosts {
+		host := abstract.NewHost()
+		host.ID, host.Name = h.ID, h.Name
+		_, err = metadata.SaveHost(svc, host)
+		require.Nil(t, err)
+	}
+	// a gateway with a custom name is recognized by its property
+	custom := abstract.NewHost()
+	custom.ID = "custom-id"
+	custom.Name = "edge"
+	err = custom.Properties.LockForWrite(hostproperty.NetworkV1).ThenUse(
+		func(clonable data.Clonable) error {
+			clonable.(*propsv1.HostNetwork).IsGateway = true
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	_, err = metadata.SaveHost(svc, custom)
+	require.Nil(t, err)
+
+	orphans, err := FindOrphanedGateways(nil, svc)
+	require.Nil(t, err)
+	var ids []string
+	for _, o := range orphans {
+		ids = append(ids, o.ID)
+	}
+	sort.Strings(ids)
+	assert.Equal(t, []string{"custom-id", "orphan-id"}, ids)
+}
+
+func TestFindNetworkByVIP(t *testing.T) {
+	svc := newMemoryService()
+	for i, cidr := range []string{"192.168.1.0/24", "192.168.2.0/24", "192.168.3.0/24"} {
+		network := abstract.NewNetwork()
+		network.ID = fmt.Sprintf("net-%d-id", i)
+		network.Name = fmt.Sprintf("net-%d", i)
+		network.CIDR = cidr
+		if i > 0 {
+			network.VIP = &abstract.VirtualIP{
+				ID:        fmt.Sprintf("vip-%d-id", i),
+				NetworkID: network.ID,
+				PrivateIP: fmt.Sprintf("192.168.%d.254", i+1),
+				PublicIP:  fmt.Sprintf("35.1.2.%d", i),
+			}
+		}
+		_, err := metadata.SaveNetwork(svc, network)
+		require.Nil(t, err)
+	}
+
+	network, err := FindNetworkByVIP(nil, svc, "192.168.3.254")
+	require.Nil(t, err)
+	assert.Equal(t, "net-2-id", network.ID)
+
+	network, err = FindNetworkByVIP(nil, svc, "35.1.2.1")
+	require.Nil(t, err)
+	assert.Equal(t, "net-1-id", network.ID)
+
+	_, err = FindNetworkByVIP(nil, svc, "192.168.1.254")
+	assert.IsType(t, fail.ErrNotFound{}, err)
+
+	_, err = FindNetworkByVIP(nil, svc, "")
+	assert.IsType(t, fail.ErrInvalidParameter{}, err)
+}
+
+func TestListAllGateways(t *testing.T) {
+	svc := newMemoryService()
+	saveNetwork := func(id, name, primary, secondary string) {
+		network := abstract.NewNetwork()
+		network.ID = id
+		network.Name = name
+		network.GatewayID = primary
+		network.SecondaryGatewayID = secondary
+		_, err := metadata.SaveNetwork(svc, network)
+		require.Nil(t, err)
+	}
+	saveGateway := func(networkID, id, ip, publicIP string) {
+		gw := bastionHost(t, networkID, ip, publicIP)
+		gw.ID = id
+		gw.Name = strings.TrimSuffix(id, "-id")
+		_, err := metadata.SaveHost(svc, gw)
+		require.Nil(t, err)
+	}
+	saveNetwork("ha-id", "ha", "gw-ha-id", "gw2-ha-id")
+	saveGateway("ha-id", "gw-ha-id", "192.168.1.1", "203.0.113.1")
+	saveGateway("ha-id", "gw2-ha-id", "192.168.1.2", "203.0.113.2")
+	saveNetwork("single-id", "single", "gw-single-id", "")
+	saveGateway("single-id", "gw-single-id", "192.168.2.1", "203.0.113.3")
+	saveNetwork("none-id", "none", "", "")
+	saveNetwork("broken-id", "broken", "gw-broken-id", "")
+
+	list, err := ListAllGateways(nil, svc)
+	require.Nil(t, err)
+	byID := map[string]GatewaySummary{}
+	for _, gw := range list {
+		byID[gw.ID] = gw
+	}
+	assert.Equal(
+		t, map[string]GatewaySummary{
+			"gw-ha-id": {
+				NetworkID: "ha-id", NetworkName: "ha", Index: 0, ID: "gw-ha-id", Name: "gw-ha",
+				PrivateIP: "192.168.1.1", PublicIP: "203.0.113.1",
+			},
+			"gw2-ha-id": {
+				NetworkID: "ha-id", NetworkName: "ha", Index: 1, ID: "gw2-ha-id", Name: "gw2-ha",
+				PrivateIP: "192.168.1.2", PublicIP: "203.0.113.2",
+			},
+			"gw-single-id": {
+				NetworkID: "single-id", NetworkName: "single", Index: 0, ID: "gw-single-id", Name: "gw-single",
+				PrivateIP: "192.168.2.1", PublicIP: "203.0.113.3",
+			},
+			"gw-broken-id": {
+				NetworkID: "broken-id", NetworkName: "broken", Index: 0, ID: "gw-broken-id", Missing: true,
+			},
+		}, byID,
+	)
+
+	_, err = ListAllGateways(nil, nil)
+	assert.IsType(t, fail.ErrInvalidParameter{}, err)
+}
+
+// blockingTeardownService is a teardownService whose deletion of network waits for 'release' once started
+type blockingTeardownService struct {
+	*teardownService
+	started  chan struct{}
+	release  chan struct{}
+	lock     sync.Mutex
+	deletion int
+}
+
+func (s *blockingTeardownService) DeleteNetwork(id string) error {
+	s.lock.Lock()
+	s.deletion++
+	first := s.deletion == 1
+	s.lock.Unlock()
+	if first {
+		close(s.started)
+		<-s.release
+	}
+	return s.teardownService.DeleteNetwork(id)
+}
+
+func TestDelete_Concurrent(t *testing.T) {
+	svc := &blockingTeardownService{
+		teardownService: &teardownService{memoryService: newMemoryService()},
+		started:         make(chan struct{}),
+		release:         make(chan struct{}),
+	}
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	_, err := metadata.SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	errs := make(chan error, 3)
+	go func() {
+		errs <- NewNetworkHandler(svc).Delete(context.Background(), "net")
+	}()
+	<-svc.started
+	// the same network, by name and by ID, while the first deletion is in progress
+	for _, ref := range []string{"net", "net-id"} {
+		go func(ref string) {
+			errs <- NewNetworkHandler(svc).Delete(context.Background(), ref)
+		}(ref)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(svc.release)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, <-errs)
+	}
+	assert.Equal(t, 1, svc.deletion)
+	assert.Empty(t, svc.bucket.Objects)
+	assert.Equal(t, 0, networkDeletions.inProgress())
+
+	// deleting a network already deleted succeeds
+	report, err := NewNetworkHandler(svc).DeleteWithReport(context.Background(), "net")
+	require.Nil(t, err)
+	result, ok := report.Step(TeardownMetadata)
+	require.True(t, ok)
+	assert.Equal(t, TeardownAlreadyGone, result.Outcome)
+	assert.Equal(t, 1, svc.deletion)
+}
+
+func TestNetworkDeletionRegistry(t *testing.T) {
+	registry := &networkDeletionRegistry{inFlight: map[networkDeletionKey]*networkDeletion{}}
+
+	d, owner := registry.start("tenant-a", "net-id")
+	require.True(t, owner)
+	same, owner := registry.start("tenant-a", "net-id")
+	assert.False(t, owner)
+	assert.Equal(t, d, same)
+
+	// the same network ID in another tenant is another network
+	other, owner := registry.start("tenant-b", "net-id")
+	assert.True(t, owner)
+	assert.Equal(t, 2, registry.inProgress())
+
+	deletionErr := fail.NotAvailableError("network has ports")
+	registry.finish(d, deletionErr)
+	assert.Equal(t, deletionErr, same.wait(context.Background()))
+	assert.Equal(t, 1, registry.inProgress())
+	registry.finish(other, nil)
+	assert.Equal(t, 0, registry.inProgress())
+
+	// a new deletion can start once the previous one ended
+	_, owner = registry.start("tenant-a", "net-id")
+	assert.True(t, owner)
+}
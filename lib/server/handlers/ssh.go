@@ -19,6 +19,8 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"os"
+	"regexp"
 	"strings"
 	"time"
 
@@ -32,7 +34,9 @@ import (
 	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/server/metadata"
 	"github.com/CS-SI/SafeScale/lib/system"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/copypolicy"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/runphase"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
 	"github.com/CS-SI/SafeScale/lib/utils/retry"
@@ -42,6 +46,9 @@ import (
 
 const protocolSeparator = ":"
 
+// ownerRegexp matches the owners allowed by CopyOptions ("user" or "user:group")
+var ownerRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_-]*(:[a-z_][a-z0-9_-]*)?$`)
+
 //go:generate mockgen -destination=../mocks/mock_sshapi.go -package=mocks github.com/CS-SI/SafeScale/lib/server/handlers SSHAPI
 
 // TODO: At service level, ve need to log before returning, because it's the last chance to track the real issue in server side
@@ -66,12 +73,45 @@ func NewSSHHandler(svc iaas.Service) *SSHHandler {
 	}
 }
 
-// GetConfig creates SSHConfig to connect to an host
-func (handler *SSHHandler) GetConfig(ctx context.Context, hostParam interface{}) (sshConfig *system.SSHConfig, err error) {
+// GetConfig creates SSHConfig to connect to an host; returns an AbortedError as soon as 'ctx' is done, without
+// waiting for the metadata or the provider to answer
+func (handler *SSHHandler) GetConfig(ctx context.Context, hostParam interface{}) (*system.SSHConfig, error) {
 	if handler == nil {
 		return nil, fail.InvalidInstanceError()
 	}
+	if ctx == nil {
+		return nil, fail.InvalidParameterError("ctx", "cannot be nil")
+	}
+	if cerr := ctx.Err(); cerr != nil {
+		return nil, fail.AbortedError("canceled before getting the SSH configuration", cerr)
+	}
+
+	type result struct {
+		sshConfig *system.SSHConfig
+		err       error
+	}
+	// buffered, so the goroutine ends when the answer finally comes even if nobody waits for it anymore
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		defer func() {
+			done <- r
+		}()
+		defer fail.OnPanic(&r.err)()
+
+		r.sshConfig, r.err = handler.getConfig(ctx, hostParam)
+	}()
 
+	select {
+	case r := <-done:
+		return r.sshConfig, r.err
+	case <-ctx.Done():
+		return nil, fail.AbortedError("canceled while getting the SSH configuration", ctx.Err())
+	}
+}
+
+// getConfig does the real work of GetConfig
+func (handler *SSHHandler) getConfig(ctx context.Context, hostParam interface{}) (sshConfig *system.SSHConfig, err error) {
 	var hostRef string
 	host := abstract.NewHost()
 	switch hostParam := hostParam.(type) {
@@ -96,9 +136,6 @@ func (handler *SSHHandler) GetConfig(ctx context.Context, hostParam interface{})
 	if host == nil {
 		return nil, fail.InvalidParameterError("hostParam", "must be a not-empty string or a *abstract.Host")
 	}
-	if ctx == nil {
-		return nil, fail.InvalidParameterError("ctx", "cannot be nil")
-	}
 
 	tracer := debug.NewTracer(nil, fmt.Sprintf("(%s)", hostRef), true).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
@@ -176,10 +213,28 @@ func (handler *SSHHandler) WaitServerReady(ctx context.Context, hostParam interf
 	return waitErr
 }
 
+// SSHRunResult contains the outcome of the execution of a command on a host
+type SSHRunResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// Phase tells at which step the execution stopped; ExitCode is meaningful only if Phase is runphase.COMPLETED
+	Phase runphase.Enum
+}
+
+// sshConnectionFailureCode is the exit code used by ssh when it cannot connect to the remote host
+const sshConnectionFailureCode = 255
+
 // Run tries to execute command 'cmd' on the host
 func (handler *SSHHandler) Run(ctx context.Context, hostName, cmd string, outs outputs.Enum) (retCode int, stdOut string, stdErr string, err error) {
+	result, err := handler.RunWithResult(ctx, hostName, cmd, outs)
+	return legacyRunResult(result, err)
+}
+
+// RunWithResult tries to execute command 'cmd' on the host, telling in the result where the execution stopped
+func (handler *SSHHandler) RunWithResult(ctx context.Context, hostName, cmd string, outs outputs.Enum) (result *SSHRunResult, err error) {
 	if handler == nil {
-		return -1, "", "", fail.InvalidInstanceError()
+		return nil, fail.InvalidInstanceError()
 	}
 
 	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s', <command>)", hostName), true).WithStopwatch().GoingIn()
@@ -187,48 +242,10 @@ func (handler *SSHHandler) Run(ctx context.Context, hostName, cmd string, outs o
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 	tracer.Trace(fmt.Sprintf("<command>=[%s]", cmd))
 
-	if ctx == nil {
-		return 1, "", "", fail.InvalidParameterError("ctx", "cannot be nil")
-	}
-	if hostName == "" {
-		return 1, "", "", fail.InvalidParameterError("hostName", "cannot be empty")
-	}
-	if cmd == "" {
-		return 1, "", "", fail.InvalidParameterError("cmd", "cannot be empty")
-	}
-
-	hostSvc := NewHostHandler(handler.service)
-	host, err := hostSvc.ForceInspect(ctx, hostName)
-	if err != nil {
-		return 0, "", "", err
-	}
-
-	// retrieve ssh config to perform some commands
-	ssh, err := handler.GetConfig(ctx, host)
-	if err != nil {
-		return 0, "", "", err
-	}
-
-	retryErr := retry.WhileUnsuccessfulDelay1SecondWithNotify(
-		func() error {
-			retCode, stdOut, stdErr, err = handler.runWithTimeout(ssh, cmd, outs, temporal.GetHostTimeout())
-			return err
-		},
-		temporal.GetHostTimeout(),
-		func(t retry.Try, v verdict.Enum) {
-			if v == verdict.Retry {
-				logrus.Debugf("Remote SSH service on host '%s' isn't ready, retrying...\n", hostName)
-			}
-		},
-	)
-	if retryErr != nil {
-		return retCode, stdOut, stdErr, retryErr
-	}
-
-	return retCode, stdOut, stdErr, err
+	return handler.run(ctx, hostName, cmd, outs, temporal.GetHostTimeout(), temporal.GetHostTimeout())
 }
 
-// Run tries to execute command 'cmd' on the host
+// RunWithTimeout tries to execute command 'cmd' on the host
 func (handler *SSHHandler) RunWithTimeout(ctx context.Context, hostName, cmd string, outs outputs.Enum, timeout time.Duration) (retCode int, stdOut string, stdErr string, err error) {
 	if handler == nil {
 		return -1, "", "", fail.InvalidInstanceError()
@@ -239,34 +256,40 @@ func (handler *SSHHandler) RunWithTimeout(ctx context.Context, hostName, cmd str
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 	tracer.Trace(fmt.Sprintf("<command>=[%s]", cmd))
 
+	return legacyRunResult(handler.run(ctx, hostName, cmd, outs, timeout, 2*timeout))
+}
+
+// run executes command on the host, retrying during 'retryTimeout' while the execution fails
+func (handler *SSHHandler) run(ctx context.Context, hostName, cmd string, outs outputs.Enum, timeout, retryTimeout time.Duration) (*SSHRunResult, error) {
 	if ctx == nil {
-		return 1, "", "", fail.InvalidParameterError("ctx", "cannot be nil")
+		return nil, fail.InvalidParameterError("ctx", "cannot be nil")
 	}
 	if hostName == "" {
-		return 1, "", "", fail.InvalidParameterError("hostName", "cannot be empty")
+		return nil, fail.InvalidParameterError("hostName", "cannot be empty")
 	}
 	if cmd == "" {
-		return 1, "", "", fail.InvalidParameterError("cmd", "cannot be empty")
+		return nil, fail.InvalidParameterError("cmd", "cannot be empty")
 	}
 
 	hostSvc := NewHostHandler(handler.service)
 	host, err := hostSvc.ForceInspect(ctx, hostName)
 	if err != nil {
-		return 0, "", "", err
+		return &SSHRunResult{Phase: runphase.CONNECT}, err
 	}
 
 	// retrieve ssh config to perform some commands
 	ssh, err := handler.GetConfig(ctx, host)
 	if err != nil {
-		return 0, "", "", err
+		return &SSHRunResult{Phase: runphase.CONNECT}, err
 	}
 
+	var result *SSHRunResult
 	retryErr := retry.WhileUnsuccessfulDelay1SecondWithNotify(
 		func() error {
-			retCode, stdOut, stdErr, err = handler.runWithTimeout(ssh, cmd, outs, timeout)
+			result, err = handler.runWithTimeout(ssh, cmd, outs, timeout)
 			return err
 		},
-		2*timeout,
+		retryTimeout,
 		func(t retry.Try, v verdict.Enum) {
 			if v == verdict.Retry {
 				logrus.Debugf("Remote SSH service on host '%s' isn't ready, retrying...\n", hostName)
@@ -274,20 +297,48 @@ func (handler *SSHHandler) RunWithTimeout(ctx context.Context, hostName, cmd str
 		},
 	)
 	if retryErr != nil {
-		return retCode, stdOut, stdErr, retryErr
+		return result, retryErr
 	}
 
-	return retCode, stdOut, stdErr, err
+	return result, err
 }
 
-// run executes command on the host
-func (handler *SSHHandler) runWithTimeout(ssh *system.SSHConfig, cmd string, outs outputs.Enum, duration time.Duration) (int, string, string, error) {
+// runWithTimeout executes command on the host
+func (handler *SSHHandler) runWithTimeout(ssh *system.SSHConfig, cmd string, outs outputs.Enum, duration time.Duration) (*SSHRunResult, error) {
 	// Create the command
 	sshCmd, err := ssh.Command(cmd)
 	if err != nil {
+		return &SSHRunResult{Phase: runphase.START}, err
+	}
+	return newSSHRunResult(sshCmd.RunWithTimeout(nil, outs, duration))
+}
+
+// newSSHRunResult builds the result of a launched ssh command from its outputs
+func newSSHRunResult(retCode int, stdOut, stdErr string, err error) (*SSHRunResult, error) {
+	result := &SSHRunResult{
+		Stdout:   stdOut,
+		Stderr:   stdErr,
+		ExitCode: retCode,
+		Phase:    runphase.COMPLETED,
+	}
+	switch {
+	case err != nil:
+		result.Phase = runphase.WAIT
+	case retCode == sshConnectionFailureCode:
+		result.Phase = runphase.CONNECT
+	}
+	return result, err
+}
+
+// legacyRunResult converts the result of RunWithResult to the values returned by Run
+func legacyRunResult(result *SSHRunResult, err error) (int, string, string, error) {
+	if result == nil {
+		return -1, "", "", err
+	}
+	if result.Phase == runphase.CONNECT || result.Phase == runphase.START {
 		return 0, "", "", err
 	}
-	return sshCmd.RunWithTimeout(nil, outs, duration)
+	return result.ExitCode, result.Stdout, result.Stderr, err
 }
 
 func extracthostName(in string) (string, error) {
@@ -324,13 +375,37 @@ func extractPath(in string) (string, error) {
 	return strings.TrimSpace(parts[1]), nil
 }
 
-// Copy copy file/directory
+// Copy copy file/directory, overwriting the destination if it exists
 func (handler *SSHHandler) Copy(ctx context.Context, from, to string) (retCode int, stdOut string, stdErr string, err error) {
+	return handler.CopyWithPolicy(ctx, from, to, copypolicy.OVERWRITE)
+}
+
+// CopyOptions tunes the behavior of CopyWithOptions
+type CopyOptions struct {
+	// Policy tells what to do if the destination already exists
+	Policy copypolicy.Enum
+	// Mode is the permissions to give to the copied file; if 0, the permissions of the source file are kept on upload
+	Mode os.FileMode
+	// Owner, if not empty, is the owner ("user" or "user:group") to give to the uploaded file (needs sudo on remote host)
+	Owner string
+}
+
+// CopyWithPolicy copy file/directory, applying policy if the destination already exists
+func (handler *SSHHandler) CopyWithPolicy(ctx context.Context, from, to string, policy copypolicy.Enum) (retCode int, stdOut string, stdErr string, err error) {
+	return handler.CopyWithOptions(ctx, from, to, CopyOptions{Policy: policy})
+}
+
+// CopyWithOptions copy file/directory, applying options
+func (handler *SSHHandler) CopyWithOptions(ctx context.Context, from, to string, options CopyOptions) (retCode int, stdOut string, stdErr string, err error) {
 	if handler == nil {
 		return -1, "", "", fail.InvalidInstanceError()
 	}
+	if options.Owner != "" && !ownerRegexp.MatchString(options.Owner) {
+		return -1, "", "", fail.InvalidParameterError("options.Owner", fmt.Sprintf("'%s' is not a valid 'user' or 'user:group'", options.Owner))
+	}
 
-	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s', '%s')", from, to), true).WithStopwatch().GoingIn()
+	policy := options.Policy
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s', '%s', %d)", from, to, policy), true).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -398,6 +473,122 @@ func (handler *SSHHandler) Copy(ctx context.Context, from, to string) (retCode i
 		return 0, "", "", err
 	}
 
+	var destination string
+	var exists func() (bool, error)
+	if upload {
+		destination = to
+		exists = func() (bool, error) {
+			cmd := fmt.Sprintf("test -e %s", shellQuote(remotePath))
+			result, err := handler.runWithTimeout(ssh, cmd, outputs.COLLECT, temporal.GetHostTimeout())
+			if err != nil {
+				return false, err
+			}
+			if result.Phase != runphase.COMPLETED {
+				return false, fail.NotAvailableError(fmt.Sprintf("failed to reach host to check '%s'", destination))
+			}
+			return result.ExitCode == 0, nil
+		}
+	} else {
+		destination = localPath
+		exists = func() (bool, error) {
+			_, err := os.Stat(localPath)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return false, nil
+				}
+				return false, err
+			}
+			return true, nil
+		}
+	}
+	proceed, err := applyCopyPolicy(policy, destination, exists)
+	if err != nil || !proceed {
+		return 0, "", "", err
+	}
+
+	var mode os.FileMode
+	if upload {
+		mode, err = uploadMode(localPath, options.Mode)
+		if err != nil {
+			return 0, "", "", err
+		}
+	}
+
 	cRc, cStcOut, cStdErr, cErr := ssh.Copy(remotePath, localPath, upload)
+	if cErr != nil || cRc != 0 {
+		return cRc, cStcOut, cStdErr, cErr
+	}
+
+	if !upload {
+		if options.Mode != 0 {
+			err = os.Chmod(localPath, options.Mode.Perm())
+			if err != nil {
+				return 0, "", "", fail.Wrap(err, fmt.Sprintf("failed to change permissions of '%s'", localPath))
+			}
+		}
+		return cRc, cStcOut, cStdErr, cErr
+	}
+
+	cmd := postUploadCommand(remotePath, mode, options.Owner)
+	result, err := handler.runWithTimeout(ssh, cmd, outputs.COLLECT, temporal.GetHostTimeout())
+	if err != nil {
+		return 0, "", "", err
+	}
+	if result.ExitCode != 0 {
+		return result.ExitCode, result.Stdout, result.Stderr, fail.Errorf(fmt.Sprintf("failed to set permissions of '%s': %s", to, result.Stderr), nil)
+	}
 	return cRc, cStcOut, cStdErr, cErr
 }
+
+// uploadMode returns the permissions to give to the uploaded file: 'requested' if not 0, the permissions of
+// the local file otherwise
+func uploadMode(localPath string, requested os.FileMode) (os.FileMode, error) {
+	if requested != 0 {
+		return requested.Perm(), nil
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return 0, fail.Wrap(err, fmt.Sprintf("failed to read permissions of '%s'", localPath))
+	}
+	return info.Mode().Perm(), nil
+}
+
+// postUploadCommand returns the command to run on remote host to apply 'mode' and 'owner' (if not empty)
+// to the uploaded file; 'owner' is expected to have been validated against ownerRegexp
+func postUploadCommand(remotePath string, mode os.FileMode, owner string) string {
+	quotedPath := shellQuote(remotePath)
+	cmd := fmt.Sprintf("chmod %04o %s", mode.Perm(), quotedPath)
+	if owner != "" {
+		cmd += fmt.Sprintf(" && sudo chown %s %s", shellQuote(owner), quotedPath)
+	}
+	return cmd
+}
+
+// shellQuote returns 'value' single-quoted for the shell, the single quotes it contains being escaped
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// applyCopyPolicy tells if the copy to destination has to be done according to policy
+// exists is called only when policy needs to know if destination is already there
+func applyCopyPolicy(policy copypolicy.Enum, destination string, exists func() (bool, error)) (bool, error) {
+	switch policy {
+	case copypolicy.OVERWRITE:
+		return true, nil
+	case copypolicy.SKIPIFEXISTS, copypolicy.FAILIFEXISTS:
+		found, err := exists()
+		if err != nil {
+			return false, fail.Wrap(err, fmt.Sprintf("failed to check if '%s' exists", destination))
+		}
+		if !found {
+			return true, nil
+		}
+		if policy == copypolicy.FAILIFEXISTS {
+			return false, fail.DuplicateError(fmt.Sprintf("'%s' already exists", destination))
+		}
+		logrus.Debugf("'%s' already exists, copy skipped", destination)
+		return false, nil
+	default:
+		return false, fail.InvalidParameterError("policy", fmt.Sprintf("unknown copy policy %d", policy))
+	}
+}
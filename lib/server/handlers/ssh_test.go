@@ -0,0 +1,216 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/providers"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/copypolicy"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/runphase"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+func destinationExists(called *bool) func() (bool, error) {
+	return func() (bool, error) {
+		*called = true
+		return true, nil
+	}
+}
+
+func TestApplyCopyPolicy_Overwrite(t *testing.T) {
+	called := false
+	proceed, err := applyCopyPolicy(copypolicy.OVERWRITE, "/tmp/dest", destinationExists(&called))
+	assert.Nil(t, err)
+	assert.True(t, proceed)
+	assert.False(t, called)
+}
+
+func TestApplyCopyPolicy_SkipIfExists(t *testing.T) {
+	called := false
+	proceed, err := applyCopyPolicy(copypolicy.SKIPIFEXISTS, "/tmp/dest", destinationExists(&called))
+	assert.Nil(t, err)
+	assert.False(t, proceed)
+	assert.True(t, called)
+
+	proceed, err = applyCopyPolicy(copypolicy.SKIPIFEXISTS, "/tmp/dest", func() (bool, error) { return false, nil })
+	assert.Nil(t, err)
+	assert.True(t, proceed)
+}
+
+func TestApplyCopyPolicy_FailIfExists(t *testing.T) {
+	called := false
+	proceed, err := applyCopyPolicy(copypolicy.FAILIFEXISTS, "/tmp/dest", destinationExists(&called))
+	assert.False(t, proceed)
+	assert.True(t, called)
+	if assert.NotNil(t, err) {
+		_, ok := err.(fail.ErrDuplicate)
+		assert.True(t, ok)
+	}
+
+	proceed, err = applyCopyPolicy(copypolicy.FAILIFEXISTS, "/tmp/dest", func() (bool, error) { return false, nil })
+	assert.Nil(t, err)
+	assert.True(t, proceed)
+}
+
+func TestApplyCopyPolicy_CheckFailure(t *testing.T) {
+	proceed, err := applyCopyPolicy(copypolicy.SKIPIFEXISTS, "/tmp/dest", func() (bool, error) { return false, fmt.Errorf("connection lost") })
+	assert.NotNil(t, err)
+	assert.False(t, proceed)
+}
+
+func TestNewSSHRunResult_ConnectionFailure(t *testing.T) {
+	result, err := newSSHRunResult(255, "", "ssh: connect to host 10.0.0.2 port 22: Connection refused", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, runphase.CONNECT, result.Phase)
+
+	retCode, stdOut, stdErr, err := legacyRunResult(result, err)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, retCode)
+	assert.Empty(t, stdOut)
+	assert.Empty(t, stdErr)
+}
+
+func TestNewSSHRunResult_CompletedWithFailure(t *testing.T) {
+	result, err := newSSHRunResult(2, "", "ls: cannot access '/nowhere'", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, runphase.COMPLETED, result.Phase)
+	assert.Equal(t, 2, result.ExitCode)
+	assert.Equal(t, "ls: cannot access '/nowhere'", result.Stderr)
+
+	retCode, _, stdErr, err := legacyRunResult(result, err)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, retCode)
+	assert.Equal(t, "ls: cannot access '/nowhere'", stdErr)
+}
+
+func TestNewSSHRunResult_WaitFailure(t *testing.T) {
+	result, err := newSSHRunResult(-1, "", "", fail.TimeoutError("command took too long", 0, nil))
+	assert.NotNil(t, err)
+	assert.Equal(t, runphase.WAIT, result.Phase)
+}
+
+func TestUploadMode_PreservesExecutable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safescale-copy")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	script := filepath.Join(dir, "script.sh")
+	require.Nil(t, ioutil.WriteFile(script, []byte("#!/bin/sh\necho ok\n"), 0755))
+	require.Nil(t, os.Chmod(script, 0755))
+
+	mode, err := uploadMode(script, 0)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0755), mode)
+	assert.NotZero(t, mode&0100)
+	assert.Equal(t, "chmod 0755 '/opt/script.sh'", postUploadCommand("/opt/script.sh", mode, ""))
+
+	// requested mode wins over the source one
+	mode, err = uploadMode(script, 0600)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), mode)
+
+	_, err = uploadMode(filepath.Join(dir, "missing"), 0)
+	assert.NotNil(t, err)
+}
+
+func TestPostUploadCommand_Owner(t *testing.T) {
+	cmd := postUploadCommand("/opt/script.sh", 0750, "safescale:safescale")
+	assert.Equal(t, "chmod 0750 '/opt/script.sh' && sudo chown 'safescale:safescale' '/opt/script.sh'", cmd)
+}
+
+func TestPostUploadCommand_QuotesPath(t *testing.T) {
+	cmd := postUploadCommand("/opt/it's.sh", 0644, "")
+	assert.Equal(t, `chmod 0644 '/opt/it'\''s.sh'`, cmd)
+}
+
+func TestCopyWithOptions_InvalidOwner(t *testing.T) {
+	handler := NewSSHHandler(newMemoryService())
+	for _, owner := range []string{"root; rm -rf /", "user:", "User", "$(id)", "a b"} {
+		_, _, _, err := handler.CopyWithOptions(context.Background(), "/tmp/file", "host:/tmp/file", CopyOptions{Owner: owner})
+		assert.IsType(t, fail.ErrInvalidParameter{}, err, owner)
+	}
+	assert.True(t, ownerRegexp.MatchString("safescale"))
+	assert.True(t, ownerRegexp.MatchString("_apt:nogroup"))
+}
+
+// blockingConfigService is a memoryService whose configuration options are only returned once 'release' is closed
+type blockingConfigService struct {
+	*memoryService
+	release chan struct{}
+}
+
+func (s *blockingConfigService) GetConfigurationOptions() (providers.Config, error) {
+	<-s.release
+	return providers.ConfigMap{"OperatorUsername": "operator"}, nil
+}
+
+func sshTestHost() *abstract.Host {
+	host := abstract.NewHost()
+	host.ID = "host-id"
+	host.Name = "host"
+	host.PrivateKey = "private key"
+	return host
+}
+
+func TestSSHHandler_GetConfig(t *testing.T) {
+	svc := &blockingConfigService{memoryService: newMemoryService(), release: make(chan struct{})}
+	close(svc.release)
+
+	cfg, err := NewSSHHandler(svc).GetConfig(context.Background(), sshTestHost())
+	require.Nil(t, err)
+	assert.Equal(t, "operator", cfg.User)
+	assert.Equal(t, "private key", cfg.PrivateKey)
+	assert.Nil(t, cfg.GatewayConfig)
+}
+
+func TestSSHHandler_GetConfig_BoundedContext(t *testing.T) {
+	svc := &blockingConfigService{memoryService: newMemoryService(), release: make(chan struct{})}
+	defer close(svc.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	_, err := NewSSHHandler(svc).GetConfig(ctx, sshTestHost())
+	assert.True(t, time.Since(begin) < 5*time.Second)
+	require.NotNil(t, err)
+	aborted, ok := err.(fail.ErrAborted)
+	require.True(t, ok)
+	assert.Equal(t, context.DeadlineExceeded, aborted.Cause())
+}
+
+func TestSSHHandler_GetConfig_Canceled(t *testing.T) {
+	svc := &blockingConfigService{memoryService: newMemoryService(), release: make(chan struct{})}
+	defer close(svc.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewSSHHandler(svc).GetConfig(ctx, sshTestHost())
+	assert.IsType(t, fail.ErrAborted{}, err)
+}
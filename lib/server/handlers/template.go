@@ -19,6 +19,10 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
 
 	"github.com/CS-SI/SafeScale/lib/utils/debug"
 
@@ -57,3 +61,95 @@ func (handler *TemplateHandler) List(ctx context.Context, all bool) (tlist []abs
 	tlist, err = handler.service.ListTemplates(all)
 	return tlist, err
 }
+
+// TemplateSelectionPolicy chooses a template among the candidates satisfying the sizing requirements, which are
+// never empty; the choice must be deterministic whatever the order of the candidates
+type TemplateSelectionPolicy func(candidates []*abstract.HostTemplate) *abstract.HostTemplate
+
+// SelectLowestName is a TemplateSelectionPolicy choosing the template with the lowest name (then ID)
+func SelectLowestName(candidates []*abstract.HostTemplate) *abstract.HostTemplate {
+	var selected *abstract.HostTemplate
+	for _, t := range candidates {
+		if selected == nil || t.Name < selected.Name || (t.Name == selected.Name && t.ID < selected.ID) {
+			selected = t
+		}
+	}
+	return selected
+}
+
+// SelectSmallestTemplate is a TemplateSelectionPolicy choosing the template over-provisioning the least, that is
+// the one with the smallest rank according to the Dominant Resource Fairness (see iaas.RankDRF), then the one with
+// the lowest name
+func SelectSmallestTemplate(candidates []*abstract.HostTemplate) *abstract.HostTemplate {
+	return selectByCost(candidates, func(t *abstract.HostTemplate) (float64, bool) {
+		return float64(iaas.RankDRF(t)), true
+	})
+}
+
+// SelectCheapestTemplate returns a TemplateSelectionPolicy choosing the cheapest template according to 'prices',
+// indexed by template name; templates without price are considered only if none has a price, and the smallest one
+// is then chosen (see SelectSmallestTemplate)
+func SelectCheapestTemplate(prices map[string]float64) TemplateSelectionPolicy {
+	return func(candidates []*abstract.HostTemplate) *abstract.HostTemplate {
+		selected := selectByCost(candidates, func(t *abstract.HostTemplate) (float64, bool) {
+			price, ok := prices[t.Name]
+			return price, ok
+		})
+		if selected == nil {
+			return SelectSmallestTemplate(candidates)
+		}
+		return selected
+	}
+}
+
+// selectByCost returns the template of lowest cost, then of lowest name among the ones of equal cost;
+// templates whose cost is unknown are ignored
+func selectByCost(
+	candidates []*abstract.HostTemplate, cost func(*abstract.HostTemplate) (float64, bool),
+) *abstract.HostTemplate {
+	var cheapest []*abstract.HostTemplate
+	var lowest float64
+	for _, t := range candidates {
+		c, ok := cost(t)
+		if !ok {
+			continue
+		}
+		switch {
+		case len(cheapest) == 0 || c < lowest:
+			cheapest = []*abstract.HostTemplate{t}
+			lowest = c
+		case c == lowest:
+			cheapest = append(cheapest, t)
+		}
+	}
+	return SelectLowestName(cheapest)
+}
+
+// selectTemplate chooses a template among 'candidates' with 'policy' (SelectSmallestTemplate if nil), logging the
+// alternatives considered; returns nil if there is no candidate
+func selectTemplate(candidates []*abstract.HostTemplate, policy TemplateSelectionPolicy) *abstract.HostTemplate {
+	if len(candidates) == 0 {
+		return nil
+	}
+	if policy == nil {
+		policy = SelectSmallestTemplate
+	}
+	selected := policy(candidates)
+	if selected == nil {
+		selected = SelectSmallestTemplate(candidates)
+	}
+	if len(candidates) > 1 {
+		var alternatives []string
+		for _, t := range candidates {
+			if t != selected {
+				alternatives = append(alternatives, t.Name)
+			}
+		}
+		sort.Strings(alternatives)
+		logrus.Debugf(
+			"Selected host template '%s' among %d candidates; alternatives were: %s", selected.Name, len(candidates),
+			strings.Join(alternatives, ", "),
+		)
+	}
+	return selected
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+)
+
+// templateCandidates returns templates satisfying a sizing of 2 cores and 4 GB of RAM, in an arbitrary order
+func templateCandidates() []*abstract.HostTemplate {
+	return []*abstract.HostTemplate{
+		{ID: "4", Name: "n2-standard-2", Cores: 2, RAMSize: 8},
+		{ID: "2", Name: "n1-standard-2", Cores: 2, RAMSize: 7.5},
+		{ID: "5", Name: "n1-gpu-2", Cores: 2, RAMSize: 7.5, GPUNumber: 1},
+		{ID: "3", Name: "e2-medium", Cores: 2, RAMSize: 4},
+		{ID: "1", Name: "e2-custom", Cores: 2, RAMSize: 4},
+	}
+}
+
+// reversed returns the templates in reverse order
+func reversed(tpls []*abstract.HostTemplate) []*abstract.HostTemplate {
+	out := make([]*abstract.HostTemplate, 0, len(tpls))
+	for i := len(tpls) - 1; i >= 0; i-- {
+		out = append(out, tpls[i])
+	}
+	return out
+}
+
+func TestTemplateSelectionPolicies(t *testing.T) {
+	cases := []struct {
+		name     string
+		policy   TemplateSelectionPolicy
+		expected string
+	}{
+		{"lowest name", SelectLowestName, "e2-custom"},
+		// e2-custom and e2-medium are the smallest, the name breaks the tie
+		{"smallest", SelectSmallestTemplate, "e2-custom"},
+		{
+			"cheapest",
+			SelectCheapestTemplate(map[string]float64{"n1-standard-2": 0.09, "n2-standard-2": 0.1, "e2-medium": 0.09}),
+			"e2-medium",
+		},
+		// without prices, the smallest template is chosen
+		{"cheapest without prices", SelectCheapestTemplate(nil), "e2-custom"},
+		// the default policy is the smallest template
+		{"default", nil, "e2-custom"},
+	}
+	for _, c := range cases {
+		selected := selectTemplate(templateCandidates(), c.policy)
+		assert.Equal(t, c.expected, selected.Name, c.name)
+		// the choice doesn't depend on the order of the candidates
+		selected = selectTemplate(reversed(templateCandidates()), c.policy)
+		assert.Equal(t, c.expected, selected.Name, c.name)
+	}
+
+	assert.Nil(t, selectTemplate(nil, SelectLowestName))
+}
+
+func TestSelectSmallestTemplate_AvoidsGPU(t *testing.T) {
+	candidates := []*abstract.HostTemplate{
+		{ID: "5", Name: "a-gpu-2", Cores: 2, RAMSize: 7.5, GPUNumber: 1},
+		{ID: "2", Name: "n1-standard-2", Cores: 2, RAMSize: 7.5},
+	}
+	assert.Equal(t, "n1-standard-2", SelectSmallestTemplate(candidates).Name)
+	assert.Equal(t, "a-gpu-2", SelectLowestName(candidates).Name)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/utils/debug"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+// TenantAPI defines API to manipulate tenants
+type TenantAPI interface {
+	Health(ctx context.Context) (*abstract.ProviderHealth, error)
+}
+
+// TenantHandler tenant service
+type TenantHandler struct {
+	service iaas.Service
+}
+
+// NewTenantHandler creates a tenant service
+func NewTenantHandler(svc iaas.Service) TenantAPI {
+	return &TenantHandler{
+		service: svc,
+	}
+}
+
+// Health checks the API of the provider of the tenant is reachable and its credentials are valid
+func (handler *TenantHandler) Health(ctx context.Context) (health *abstract.ProviderHealth, err error) {
+	if handler == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+
+	tracer := debug.NewTracer(nil, "", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	health, err = handler.service.CheckProviderHealth()
+	if err != nil {
+		return nil, err
+	}
+	if !health.OK() {
+		logrus.Warnf(
+			"provider '%s' is unhealthy (reachable: %v, authenticated: %v): %s", handler.service.GetName(),
+			health.Reachable, health.Authenticated, health.Error,
+		)
+	}
+	return health, nil
+}
+
+// CheckTenantsHealth checks the providers of the tenants 'services' (indexed by tenant name); the error of a tenant
+// whose health cannot be checked is reported in its ProviderHealth
+func CheckTenantsHealth(ctx context.Context, services map[string]iaas.Service) map[string]*abstract.ProviderHealth {
+	report := make(map[string]*abstract.ProviderHealth, len(services))
+	for name, svc := range services {
+		health, err := NewTenantHandler(svc).Health(ctx)
+		if err != nil {
+			health = &abstract.ProviderHealth{Error: err.Error()}
+		}
+		report[name] = health
+	}
+	return report
+}
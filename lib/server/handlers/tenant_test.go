@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+// healthService is an iaas.Service whose provider health is 'health', or 'err' if set
+type healthService struct {
+	*memoryService
+	health *abstract.ProviderHealth
+	err    error
+}
+
+func (s *healthService) GetName() string {
+	return "fake"
+}
+
+func (s *healthService) CheckProviderHealth() (*abstract.ProviderHealth, error) {
+	return s.health, s.err
+}
+
+func TestTenantHandler_Health(t *testing.T) {
+	svc := &healthService{
+		memoryService: newMemoryService(),
+		health:        &abstract.ProviderHealth{Reachable: true, Authenticated: true, Latency: 20 * time.Millisecond},
+	}
+	health, err := NewTenantHandler(svc).Health(context.Background())
+	require.Nil(t, err)
+	assert.True(t, health.OK())
+	assert.Equal(t, 20*time.Millisecond, health.Latency)
+}
+
+func TestTenantHandler_Health_AuthFailure(t *testing.T) {
+	svc := &healthService{
+		memoryService: newMemoryService(),
+		health:        &abstract.ProviderHealth{Reachable: true, Error: "invalid credentials"},
+	}
+	health, err := NewTenantHandler(svc).Health(context.Background())
+	require.Nil(t, err)
+	assert.False(t, health.OK())
+	assert.True(t, health.Reachable)
+	assert.False(t, health.Authenticated)
+	assert.Equal(t, "invalid credentials", health.Error)
+}
+
+func TestCheckTenantsHealth(t *testing.T) {
+	services := map[string]iaas.Service{
+		"ok": &healthService{
+			memoryService: newMemoryService(),
+			health:        &abstract.ProviderHealth{Reachable: true, Authenticated: true},
+		},
+		"denied": &healthService{
+			memoryService: newMemoryService(),
+			health:        &abstract.ProviderHealth{Reachable: true, Error: "invalid credentials"},
+		},
+		"unsupported": &healthService{
+			memoryService: newMemoryService(),
+			err:           fail.NotImplementedError("health check"),
+		},
+	}
+	report := CheckTenantsHealth(context.Background(), services)
+	require.Len(t, report, 3)
+	assert.True(t, report["ok"].OK())
+	assert.True(t, report["denied"].Reachable)
+	assert.False(t, report["denied"].OK())
+	assert.False(t, report["unsupported"].OK())
+	assert.NotEmpty(t, report["unsupported"].Error)
+}
@@ -45,6 +45,11 @@ func (kp *KeyPair) IsNull() bool {
 
 // NewKeyPair creates a *abstract.KeyPair
 func NewKeyPair(prefix string) (*KeyPair, error) {
+	return NewKeyPairWithType(prefix, crypt.KeyTypeRSA, crypt.DefaultRSAKeyBits)
+}
+
+// NewKeyPairWithType creates a *abstract.KeyPair with keys of type 'keyType' and size 'bits' (see crypt.GenerateKeyPair)
+func NewKeyPairWithType(prefix string, keyType string, bits int) (*KeyPair, error) {
 	id, err := uuid.NewV4()
 	if err != nil {
 		msg := fmt.Sprintf("failed to create host UUID: %+v", err)
@@ -56,7 +61,7 @@ func NewKeyPair(prefix string) (*KeyPair, error) {
 	}
 	name := fmt.Sprintf("%s_%s", prefix, id)
 
-	privKey, pubKey, err := crypt.GenerateRSAKeyPair(name)
+	privKey, pubKey, err := crypt.GenerateKeyPair(name, keyType, bits)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +81,9 @@ type SizingRequirements struct {
 	MaxRAMSize  float32 `json:"max_ram_size,omitempty"`
 	MinDiskSize int     `json:"min_disk_size,omitempty"`
 	MinGPU      int     `json:"min_gpu,omitempty"`
+	GPUType     string  `json:"gpu_type,omitempty"` // Model of GPU wanted (nvidia-tesla-t4, ...); empty means no preference
 	MinFreq     float32 `json:"min_freq,omitempty"`
+	Arch        string  `json:"arch,omitempty"`        // CPU architecture wanted (arm64, x86_64, ...); empty means no preference
 	Replaceable bool    `json:"replaceable,omitempty"` // Tells if we accept server that could be removed without notice (AWS proposes such kind of server with SPOT
 }
 
@@ -101,6 +108,7 @@ type StoredCPUInfo struct {
 	RAMFreq        float64 `json:"ram_freq,omitempty"`
 	GPU            int     `json:"gpu,omitempty"`
 	GPUModel       string  `json:"gpu_model,omitempty"`
+	GPUMemory      float64 `json:"gpu_memory_Gb,omitempty"`
 	DiskSize       int64   `json:"disk_size_Gb,omitempty"`
 	MainDiskType   string  `json:"main_disk_type"`
 	MainDiskSpeed  float64 `json:"main_disk_speed_MBps"`
@@ -117,6 +125,7 @@ type Image struct {
 	Description string `json:"description,omitempty"`
 	StorageType string `json:"storagetype,omitempty"`
 	DiskSize    int64  `json:"disk_size_Gb,omitempty"`
+	Arch        string `json:"arch,omitempty"` // CPU architecture of the image (x86_64, arm64, ...); empty if unknown
 }
 
 // HostRequest represents requirements to create host
@@ -133,6 +142,8 @@ type HostRequest struct {
 	DefaultGateway *Host
 	// PublicIP a flag telling if the host must have a public IP
 	PublicIP bool
+	// IsGateway tells if the host acts as the gateway of its network (PublicIP alone doesn't make a gateway)
+	IsGateway bool
 	// TemplateID is the UUID of the template used to size the host (see SelectTemplates)
 	TemplateID string
 	// ImageID is the UUID of the image that contains the server's OS and initial state.
@@ -145,6 +156,8 @@ type HostRequest struct {
 	DiskSize int
 	// Use spot instance
 	Spot bool
+	// KeepOnFailure tells to not delete the host resource if its creation fails
+	KeepOnFailure bool
 }
 
 // HostDefinition ...
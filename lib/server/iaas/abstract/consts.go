@@ -33,5 +33,7 @@ const (
 	DefaultShareMountPath = "/shared"
 
 	// SingleHostNetworkName is the name to use to create the network owning single hosts (not attached to a named network)
+	// Deprecated: use Network.IsSingleHost() to recognize such a network; the name is only a fallback for networks
+	// created without the flag SingleHost
 	SingleHostNetworkName = "net-safescale"
 )
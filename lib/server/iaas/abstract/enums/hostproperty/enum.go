@@ -31,4 +31,6 @@ const (
 	SharesV1 = "6"
 	// MountsV1 contains optional additional info about mounted devices (locally attached or remote filesystem)
 	MountsV1 = "7"
+	// GatewayPhasesV1 contains the progress of the configuration of a gateway
+	GatewayPhasesV1 = "8"
 )
@@ -21,4 +21,10 @@ const (
 	DescriptionV1 = "1"
 	// HostsV1 contains list of hosts attached to the network
 	HostsV1 = "2"
+	// IPsV1 contains the private IPs of the network reserved by SafeScale (gateways, VIP, hosts)
+	IPsV1 = "3"
+	// CreationSpecV1 contains the effective parameters used to create the network
+	CreationSpecV1 = "4"
+	// GatewaysV1 contains the IDs of all the gateways of the network
+	GatewaysV1 = "5"
 )
@@ -17,9 +17,14 @@
 package abstract
 
 import (
+	"bytes"
+	"math/big"
+	"net"
+
 	"github.com/sirupsen/logrus"
 
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/ipversion"
+	"github.com/CS-SI/SafeScale/lib/utils/cidr"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/serialize"
 )
@@ -38,6 +43,8 @@ type GatewayRequest struct {
 
 	// OriginalOsRequest is the original os requested
 	OriginalOsRequest string
+	// KeepOnFailure tells to not delete the gateway resources if its creation fails
+	KeepOnFailure bool
 }
 
 // NetworkRequest represents network requirements to create a subnet where Mask is defined in CIDR notation
@@ -54,6 +61,14 @@ type NetworkRequest struct {
 	Domain string
 	// HA tells if 2 gateways and a VIP needs to be created; the VIP IP address will be used as gateway
 	HA bool
+	// AdditionalIngressPorts contains the TCP ports to open on the gateway(s) in addition to SSH
+	AdditionalIngressPorts []int
+	// MTU is the MTU of the network; 0 means the default of the provider
+	MTU int
+	// SingleHost tells the network owns single hosts, each host acting as its own gateway
+	SingleHost bool
+	// ExpectedHostCount is the number of hosts expected in the network, gateways excluded; 0 means unknown
+	ExpectedHostCount int
 }
 
 type SubNetwork struct {
@@ -77,6 +92,10 @@ type Network struct {
 
 	Subnet bool   // FIXME: comment!
 	Parent string // FIXME: comment!
+
+	SingleHost bool `json:"single_host,omitempty"` // tells the network owns single hosts, each host acting as its own gateway
+
+	DefaultSecurityGroupID string `json:"default_security_group_id,omitempty"` // contains the ID of the security group bound to the gateways, if the provider manages security groups
 }
 
 // NewNetwork ...
@@ -114,6 +133,98 @@ func (n *Network) OK() bool {
 	return result
 }
 
+// GetDefaultSecurityGroup returns the ID of the default security group of the network, bound to its gateways;
+// empty if the provider doesn't manage security groups
+func (n *Network) GetDefaultSecurityGroup() string {
+	if n == nil {
+		return ""
+	}
+	return n.DefaultSecurityGroupID
+}
+
+// IsSingleHost tells if the network owns single hosts (not attached to a named network).
+// The flag SingleHost is authoritative; the name SingleHostNetworkName is still recognized for networks
+// created before the flag existed, but this fallback is deprecated
+func (n *Network) IsSingleHost() bool {
+	if n == nil {
+		return false
+	}
+	return n.SingleHost || n.Name == SingleHostNetworkName
+}
+
+// UsableAddressRange returns the first and last addresses of the network usable by hosts; the network address
+// (subnet-router anycast address in IPv6) and the IPv4 broadcast address are excluded, except for point-to-point
+// networks (/31 and /32 in IPv4, /127 and /128 in IPv6) where every address is usable.
+// Returns empty strings if the CIDR of the network is invalid
+func (n *Network) UsableAddressRange() (first, last string) {
+	firstIP, lastIP, _ := n.usableAddresses()
+	if firstIP == nil {
+		return "", ""
+	}
+	return firstIP.String(), lastIP.String()
+}
+
+// Capacity returns the number of addresses of the network usable by hosts (see UsableAddressRange), the VIP
+// excluded if known; the result is capped to the maximum value of an int for large IPv6 networks.
+// Returns 0 if the CIDR of the network is invalid
+func (n *Network) Capacity() int {
+	firstIP, lastIP, count := n.usableAddresses()
+	if firstIP == nil {
+		return 0
+	}
+	if n.VIP != nil && n.VIP.PrivateIP != "" {
+		if vip := net.ParseIP(n.VIP.PrivateIP); vip != nil && containsIP(firstIP, lastIP, vip) {
+			count.Sub(count, big.NewInt(1))
+		}
+	}
+	if !count.IsInt64() || count.Int64() > int64(maxInt) {
+		return maxInt
+	}
+	return int(count.Int64())
+}
+
+// maxInt is the maximum value of an int
+const maxInt = int(^uint(0) >> 1)
+
+// usableAddresses parses the CIDR of the network and returns the first and last usable addresses, and their count
+// Returns nil addresses if the CIDR is invalid
+func (n *Network) usableAddresses() (net.IP, net.IP, *big.Int) {
+	if n == nil || n.CIDR == "" {
+		return nil, nil, nil
+	}
+	_, ipnet, err := net.ParseCIDR(n.CIDR)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	first, last := cidr.AddressRange(ipnet)
+	ones, bits := ipnet.Mask.Size()
+	count := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	if bits-ones <= 1 {
+		// point-to-point networks: every address is usable
+		return first, last, count
+	}
+
+	first = cidr.Inc(first)
+	count.Sub(count, big.NewInt(1))
+	if bits == 8*net.IPv4len {
+		last = cidr.Dec(last)
+		count.Sub(count, big.NewInt(1))
+	}
+	return first, last, count
+}
+
+// containsIP tells if 'ip' is between 'first' and 'last' included
+func containsIP(first, last, ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil && len(first) == net.IPv4len {
+		ip = v4
+	}
+	if len(ip) != len(first) {
+		return false
+	}
+	return bytes.Compare(ip, first) >= 0 && bytes.Compare(ip, last) <= 0
+}
+
 // Serialize serializes Host instance into bytes (output json code)
 func (n *Network) Serialize() ([]byte, error) {
 	return serialize.ToJSON(n)
@@ -29,3 +29,94 @@ func TestVirtualIP_Clone(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestNetwork_IsSingleHost(t *testing.T) {
+	network := NewNetwork()
+	network.Name = "my-single-hosts"
+	assert.Equal(t, network.IsSingleHost(), false)
+
+	network.SingleHost = true
+	assert.Equal(t, network.IsSingleHost(), true)
+
+	legacy := NewNetwork()
+	legacy.Name = SingleHostNetworkName
+	assert.Equal(t, legacy.IsSingleHost(), true)
+
+	var none *Network
+	assert.Equal(t, none.IsSingleHost(), false)
+}
+
+func TestNetwork_SingleHostSerialization(t *testing.T) {
+	network := NewNetwork()
+	network.Name = "my-single-hosts"
+	network.SingleHost = true
+
+	buf, err := network.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewNetwork()
+	if err = restored.Deserialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, restored.IsSingleHost(), true)
+}
+
+func TestNetwork_GetDefaultSecurityGroup(t *testing.T) {
+	network := NewNetwork()
+	assert.Equal(t, network.GetDefaultSecurityGroup(), "")
+
+	network.DefaultSecurityGroupID = "sg-id"
+	buf, err := network.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewNetwork()
+	if err = restored.Deserialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, restored.GetDefaultSecurityGroup(), "sg-id")
+
+	var none *Network
+	assert.Equal(t, none.GetDefaultSecurityGroup(), "")
+}
+
+func TestNetwork_UsableAddressRange(t *testing.T) {
+	cases := []struct {
+		cidr        string
+		first, last string
+		capacity    int
+	}{
+		{"192.168.1.0/24", "192.168.1.1", "192.168.1.254", 254},
+		{"10.0.0.4/30", "10.0.0.5", "10.0.0.6", 2},
+		{"10.0.0.4/31", "10.0.0.4", "10.0.0.5", 2},
+		{"10.0.0.4/32", "10.0.0.4", "10.0.0.4", 1},
+		{"fd00:1::/120", "fd00:1::1", "fd00:1::ff", 255},
+		{"fd00:1::/64", "fd00:1::1", "fd00:1::ffff:ffff:ffff:ffff", maxInt},
+	}
+	for _, c := range cases {
+		network := NewNetwork()
+		network.CIDR = c.cidr
+		first, last := network.UsableAddressRange()
+		assert.Equal(t, first, c.first, c.cidr)
+		assert.Equal(t, last, c.last, c.cidr)
+		assert.Equal(t, network.Capacity(), c.capacity, c.cidr)
+	}
+
+	invalid := NewNetwork()
+	invalid.CIDR = "192.168.1.0"
+	first, last := invalid.UsableAddressRange()
+	assert.Equal(t, first, "")
+	assert.Equal(t, last, "")
+	assert.Equal(t, invalid.Capacity(), 0)
+}
+
+func TestNetwork_Capacity_VIP(t *testing.T) {
+	network := NewNetwork()
+	network.CIDR = "192.168.1.0/24"
+	network.VIP = &VirtualIP{PrivateIP: "192.168.1.254"}
+	assert.Equal(t, network.Capacity(), 253)
+
+	network.VIP.PrivateIP = "192.168.2.254"
+	assert.Equal(t, network.Capacity(), 254)
+}
@@ -710,6 +710,58 @@ func (hf *HostFeatures) Replace(p data.Clonable) data.Clonable {
 	return hf
 }
 
+// HostGatewayPhases contains the progress of the configuration of a gateway
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental/overriding fields
+type HostGatewayPhases struct {
+	Completed []string  `json:"completed,omitempty"` // contains the phases completed, in order
+	Current   string    `json:"current,omitempty"`   // contains the phase running, or the phase failed if Error is set
+	Error     string    `json:"error,omitempty"`     // contains the error of the failed phase
+	Updated   time.Time `json:"updated,omitempty"`   // contains the date of the last update
+}
+
+// NewHostGatewayPhases ...
+func NewHostGatewayPhases() *HostGatewayPhases {
+	return &HostGatewayPhases{
+		Completed: []string{},
+	}
+}
+
+// Reset resets the content of the property
+func (hgp *HostGatewayPhases) Reset() {
+	*hgp = HostGatewayPhases{
+		Completed: []string{},
+	}
+}
+
+// Failed tells if a phase failed
+func (hgp *HostGatewayPhases) Failed() bool {
+	return hgp.Error != ""
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (hgp *HostGatewayPhases) Content() data.Clonable {
+	return hgp
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (hgp *HostGatewayPhases) Clone() data.Clonable {
+	return NewHostGatewayPhases().Replace(hgp)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (hgp *HostGatewayPhases) Replace(p data.Clonable) data.Clonable {
+	src := p.(*HostGatewayPhases)
+	*hgp = *src
+	hgp.Completed = make([]string, len(src.Completed))
+	copy(hgp.Completed, src.Completed)
+	return hgp
+}
+
 func init() {
 	serialize.PropertyTypeRegistry.Register("abstract.host", hostproperty.DescriptionV1, NewHostDescription())
 	serialize.PropertyTypeRegistry.Register("abstract.host", hostproperty.NetworkV1, NewHostNetwork())
@@ -718,4 +770,5 @@ func init() {
 	serialize.PropertyTypeRegistry.Register("abstract.host", hostproperty.VolumesV1, NewHostVolumes())
 	serialize.PropertyTypeRegistry.Register("abstract.host", hostproperty.MountsV1, NewHostMounts())
 	serialize.PropertyTypeRegistry.Register("abstract.host", hostproperty.FeaturesV1, NewHostFeatures())
+	serialize.PropertyTypeRegistry.Register("abstract.host", hostproperty.GatewayPhasesV1, NewHostGatewayPhases())
 }
@@ -111,7 +111,165 @@ func (nh *NetworkHosts) Replace(p data.Clonable) data.Clonable {
 	return nh
 }
 
+// NetworkIPs contains the private IPs of the network in use by SafeScale resources
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental/overriding fields
+type NetworkIPs struct {
+	ByIP map[string]string `json:"by_ip"` // ID of the resource using the IP, indexed by IP
+}
+
+// NewNetworkIPs ...
+func NewNetworkIPs() *NetworkIPs {
+	return &NetworkIPs{
+		ByIP: map[string]string{},
+	}
+}
+
+// Reset resets the content of the property
+func (ni *NetworkIPs) Reset() {
+	*ni = NetworkIPs{
+		ByIP: map[string]string{},
+	}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (ni *NetworkIPs) Content() data.Clonable {
+	return ni
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (ni *NetworkIPs) Clone() data.Clonable {
+	return NewNetworkIPs().Replace(ni)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (ni *NetworkIPs) Replace(p data.Clonable) data.Clonable {
+	src := p.(*NetworkIPs)
+	ni.ByIP = make(map[string]string, len(src.ByIP))
+	for k, v := range src.ByIP {
+		ni.ByIP[k] = v
+	}
+	return ni
+}
+
+// NetworkGatewaySizing contains the sizing requirements used to select the template of the gateway(s)
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental/overriding fields
+type NetworkGatewaySizing struct {
+	MinCores    int     `json:"min_cores,omitempty"`
+	MaxCores    int     `json:"max_cores,omitempty"`
+	MinRAMSize  float32 `json:"min_ram_size,omitempty"`
+	MaxRAMSize  float32 `json:"max_ram_size,omitempty"`
+	MinDiskSize int     `json:"min_disk_size,omitempty"`
+	MinGPU      int     `json:"min_gpu,omitempty"`
+	GPUType     string  `json:"gpu_type,omitempty"`
+	MinFreq     float32 `json:"min_freq,omitempty"`
+	Arch        string  `json:"arch,omitempty"`
+}
+
+// NetworkCreationSpec contains the effective parameters used to create the network, defaults included,
+// allowing to reproduce it
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental/overriding fields
+type NetworkCreationSpec struct {
+	CIDR                   string               `json:"cidr,omitempty"`                     // CIDR requested or allocated
+	IPVersion              int                  `json:"ip_version,omitempty"`               // IP version of the network
+	Domain                 string               `json:"domain,omitempty"`                   // normalized domain of the network
+	HA                     bool                 `json:"ha,omitempty"`                       // true if the gateways are in failover
+	AdditionalIngressPorts []int                `json:"additional_ingress_ports,omitempty"` // TCP ports opened on the gateway(s)
+	MTU                    int                  `json:"mtu,omitempty"`                      // MTU requested
+	ExpectedHostCount      int                  `json:"expected_host_count,omitempty"`      // number of hosts expected in the network
+	NoGateway              bool                 `json:"no_gateway,omitempty"`               // true if the network has been created without gateway
+	GatewayName            string               `json:"gateway_name,omitempty"`             // name requested for the gateway
+	OS                     string               `json:"os,omitempty"`                       // OS requested for the gateway(s)
+	Sizing                 NetworkGatewaySizing `json:"sizing,omitempty"`                   // sizing requested for the gateway(s)
+	GatewayCount           int                  `json:"gateway_count,omitempty"`            // number of gateways created
+	ImageID                string               `json:"image_id,omitempty"`                 // ID of the image resolved from OS
+	ImageName              string               `json:"image_name,omitempty"`               // name of the image resolved from OS
+	TemplateID             string               `json:"template_id,omitempty"`              // ID of the template selected from Sizing
+	TemplateName           string               `json:"template_name,omitempty"`            // name of the template selected from Sizing
+	KeepOnFailure          bool                 `json:"keep_on_failure,omitempty"`          // true if the resources were to be kept on failure
+	AllowRoutableCIDR      bool                 `json:"allow_routable_cidr,omitempty"`      // true if a routable CIDR was allowed
+	GatewayTemplate        string               `json:"gateway_template,omitempty"`         // custom template of the script configuring the gateway(s)
+}
+
+// NewNetworkCreationSpec ...
+func NewNetworkCreationSpec() *NetworkCreationSpec {
+	return &NetworkCreationSpec{}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (ncs *NetworkCreationSpec) Content() data.Clonable {
+	return ncs
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (ncs *NetworkCreationSpec) Clone() data.Clonable {
+	return NewNetworkCreationSpec().Replace(ncs)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (ncs *NetworkCreationSpec) Replace(p data.Clonable) data.Clonable {
+	src := p.(*NetworkCreationSpec)
+	*ncs = *src
+	if src.AdditionalIngressPorts != nil {
+		ncs.AdditionalIngressPorts = make([]int, len(src.AdditionalIngressPorts))
+		copy(ncs.AdditionalIngressPorts, src.AdditionalIngressPorts)
+	}
+	return ncs
+}
+
+// NetworkGateways contains the IDs of all the gateways of the network, the primary first then the secondary;
+// GatewayID and SecondaryGatewayID of the network still reference the first two
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental/overriding fields
+type NetworkGateways struct {
+	IDs []string `json:"ids,omitempty"` // IDs of the gateways, in order of creation
+}
+
+// NewNetworkGateways ...
+func NewNetworkGateways() *NetworkGateways {
+	return &NetworkGateways{}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (ng *NetworkGateways) Content() data.Clonable {
+	return ng
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (ng *NetworkGateways) Clone() data.Clonable {
+	return NewNetworkGateways().Replace(ng)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (ng *NetworkGateways) Replace(p data.Clonable) data.Clonable {
+	src := p.(*NetworkGateways)
+	ng.IDs = nil
+	if src.IDs != nil {
+		ng.IDs = make([]string, len(src.IDs))
+		copy(ng.IDs, src.IDs)
+	}
+	return ng
+}
+
 func init() {
 	serialize.PropertyTypeRegistry.Register("abstract.network", networkproperty.HostsV1, NewNetworkHosts())
 	serialize.PropertyTypeRegistry.Register("abstract.network", networkproperty.DescriptionV1, NewNetworkDescription())
+	serialize.PropertyTypeRegistry.Register("abstract.network", networkproperty.IPsV1, NewNetworkIPs())
+	serialize.PropertyTypeRegistry.Register("abstract.network", networkproperty.CreationSpecV1, NewNetworkCreationSpec())
+	serialize.PropertyTypeRegistry.Register("abstract.network", networkproperty.GatewaysV1, NewNetworkGateways())
 }
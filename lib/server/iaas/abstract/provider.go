@@ -0,0 +1,38 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package abstract
+
+import (
+	"time"
+)
+
+// ProviderHealth is the result of a check of the API of a provider
+type ProviderHealth struct {
+	// Reachable tells if the API of the provider answered
+	Reachable bool `json:"reachable"`
+	// Authenticated tells if the credentials of the tenant have been accepted
+	Authenticated bool `json:"authenticated"`
+	// Latency is the time taken by the check
+	Latency time.Duration `json:"latency"`
+	// Error contains the message of the error returned by the check, if any
+	Error string `json:"error,omitempty"`
+}
+
+// OK tells if the provider is reachable with valid credentials
+func (h *ProviderHealth) OK() bool {
+	return h != nil && h.Reachable && h.Authenticated && h.Error == ""
+}
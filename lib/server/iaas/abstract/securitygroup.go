@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package abstract
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+// SecurityGroupRuleDirection tells which traffic a security group rule applies to
+type SecurityGroupRuleDirection string
+
+const (
+	// SecurityGroupRuleIngress applies the rule to the incoming traffic
+	SecurityGroupRuleIngress SecurityGroupRuleDirection = "ingress"
+	// SecurityGroupRuleEgress applies the rule to the outgoing traffic
+	SecurityGroupRuleEgress SecurityGroupRuleDirection = "egress"
+	// SecurityGroupRuleBoth applies the rule to the incoming and the outgoing traffic
+	SecurityGroupRuleBoth SecurityGroupRuleDirection = "both"
+)
+
+// SecurityGroupRule represents a rule allowing traffic to or from a host
+type SecurityGroupRule struct {
+	Description string
+	Direction   SecurityGroupRuleDirection
+	// Protocol is "tcp", "udp", "icmp" or "all"; empty means "all". ICMP applies to IPv4 or IPv6 depending on the
+	// targets, "icmpv6" and "ipv6-icmp" being accepted as well
+	Protocol string
+	// PortFrom and PortTo define the range of ports allowed; 0 for both means all the ports
+	PortFrom int
+	PortTo   int
+	// Targets contains the CIDRs the traffic comes from (ingress) or goes to (egress), at least one;
+	// "0.0.0.0/0" and "::/0" mean anywhere
+	Targets []string
+}
+
+// SecurityGroup represents a named set of security rules, applied to the hosts bound to it
+type SecurityGroup struct {
+	ID          string
+	Name        string
+	Description string
+	Rules       []SecurityGroupRule
+}
+
+// Validate checks the rule is consistent
+func (r SecurityGroupRule) Validate() fail.Error {
+	switch r.Direction {
+	case SecurityGroupRuleIngress, SecurityGroupRuleEgress, SecurityGroupRuleBoth:
+	default:
+		return fail.InvalidParameterError("Direction", fmt.Sprintf("unknown direction '%s'", r.Direction))
+	}
+
+	switch strings.ToLower(r.Protocol) {
+	case "", "all", "icmp", "icmpv6", "ipv6-icmp":
+		if r.PortFrom != 0 || r.PortTo != 0 {
+			return fail.InvalidParameterError("PortFrom", "ports can only be set for tcp and udp")
+		}
+	case "tcp", "udp":
+		if r.PortFrom < 0 || r.PortFrom > 65535 || r.PortTo < 0 || r.PortTo > 65535 {
+			return fail.InvalidParameterError("PortFrom", "ports must be between 0 and 65535")
+		}
+		if r.PortTo != 0 && r.PortTo < r.PortFrom {
+			return fail.InvalidParameterError("PortTo", "cannot be lower than PortFrom")
+		}
+		if r.PortFrom == 0 && r.PortTo != 0 {
+			return fail.InvalidParameterError("PortFrom", "must be set when PortTo is set")
+		}
+	default:
+		return fail.InvalidParameterError("Protocol", fmt.Sprintf("unsupported protocol '%s'", r.Protocol))
+	}
+
+	if len(r.Targets) == 0 {
+		return fail.InvalidParameterError("Targets", "cannot be empty")
+	}
+	for _, target := range r.Targets {
+		if _, _, err := net.ParseCIDR(target); err != nil {
+			return fail.InvalidParameterError("Targets", fmt.Sprintf("'%s' is not a valid CIDR", target))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package abstract
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSecurityGroupRule_Validate(t *testing.T) {
+	valid := []SecurityGroupRule{
+		{Direction: SecurityGroupRuleIngress, Targets: []string{"0.0.0.0/0"}},
+		{Direction: SecurityGroupRuleEgress, Protocol: "icmp", Targets: []string{"10.0.0.0/8"}},
+		{Direction: SecurityGroupRuleIngress, Protocol: "icmpv6", Targets: []string{"::/0"}},
+		{Direction: SecurityGroupRuleBoth, Protocol: "TCP", PortFrom: 22, Targets: []string{"192.168.1.0/24", "fd00::/64"}},
+		{Direction: SecurityGroupRuleIngress, Protocol: "udp", PortFrom: 1000, PortTo: 2000, Targets: []string{"0.0.0.0/0"}},
+	}
+	for _, r := range valid {
+		assert.Equal(t, r.Validate(), nil)
+	}
+
+	invalid := []SecurityGroupRule{
+		{},
+		{Direction: SecurityGroupRuleIngress, Protocol: "gre"},
+		{Direction: SecurityGroupRuleIngress, Protocol: "icmp", PortFrom: 22},
+		{Direction: SecurityGroupRuleIngress, Protocol: "tcp", PortFrom: 2000, PortTo: 1000},
+		{Direction: SecurityGroupRuleIngress, Protocol: "tcp", PortTo: 1000},
+		{Direction: SecurityGroupRuleIngress, Protocol: "tcp", PortFrom: 70000},
+		{Direction: SecurityGroupRuleIngress, Protocol: "tcp", PortFrom: 22},
+		{Direction: SecurityGroupRuleIngress, Protocol: "tcp", PortFrom: 22, Targets: []string{"10.0.0.1"}},
+		{Direction: SecurityGroupRuleIngress, Protocol: "tcp", PortFrom: 22, Targets: []string{"0.0.0.0/0", "anywhere"}},
+	}
+	for _, r := range invalid {
+		assert.Equal(t, r.Validate() != nil, true)
+	}
+}
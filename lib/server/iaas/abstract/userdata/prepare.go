@@ -81,6 +81,10 @@ type Content struct {
 	SecondaryGatewayPrivateIP string `valid:"-"`
 	// SecondaryGatewayPublicIP is the public IP of the secondary gateway
 	SecondaryGatewayPublicIP string `valid:"-"`
+	// MTU is the MTU to set on the private interfaces of a gateway (0 to keep the default)
+	MTU int `valid:"-"`
+	// IngressPorts contains the TCP ports opened in the public zone of the firewall of a gateway, in addition to SSH
+	IngressPorts []int `valid:"-"`
 	// EmulatedPublicNet is a private network which is used to emulate a public one
 	EmulatedPublicNet string `valid:"-"`
 	// HostName contains the name wanted as host name (default == name of the Cloud resource)
@@ -192,7 +196,7 @@ func (ud *Content) Prepare(
 	ud.PublicKey = strings.Trim(request.KeyPair.PublicKey, "\n")
 	ud.PrivateKey = strings.Trim(request.KeyPair.PrivateKey, "\n")
 	// ud.ConfIF = !autoHostNetworkInterfaces
-	ud.IsGateway = request.DefaultRouteIP == "" && len(request.Networks) != 0 && request.Networks[0].Name != abstract.SingleHostNetworkName && !useLayer3Networking
+	ud.IsGateway = request.DefaultRouteIP == "" && len(request.Networks) != 0 && !request.Networks[0].IsSingleHost() && !useLayer3Networking
 	ud.AddGateway = !request.PublicIP && !useLayer3Networking && ip != "" && !useNATService
 	ud.DNSServers = dnsList
 	ud.CIDR = cidr
@@ -329,6 +333,57 @@ func (ud *Content) Generate(phase string) ([]byte, error) {
 	return result, nil
 }
 
+// requiredTemplateMarkers contains, by phase, the markers a custom template must contain: the file telling the phase
+// is done (waited for by SafeScale) and the tag where content is inserted
+var requiredTemplateMarkers = map[string][]string{
+	"phase2": {"user_data.phase2.done", "#insert_tag"},
+}
+
+// ValidateTemplate verifies 'tmplString' can be used as custom template of the script of 'phase': it must be a valid
+// template containing the markers required by the phase
+func ValidateTemplate(phase string, tmplString string) error {
+	markers, ok := requiredTemplateMarkers[phase]
+	if !ok {
+		return fmt.Errorf("phase '%s' cannot use a custom template", phase)
+	}
+	var missing []string
+	for _, m := range markers {
+		if !strings.Contains(tmplString, m) {
+			missing = append(missing, "'"+m+"'")
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"custom template of %s is missing required marker%s %s", phase, utils.Plural(len(missing)),
+			strings.Join(missing, ", "),
+		)
+	}
+	_, err := template.New("userdata.custom." + phase).Parse(tmplString)
+	if err != nil {
+		return fmt.Errorf("error parsing custom template of %s: %s", phase, err.Error())
+	}
+	return nil
+}
+
+// GenerateFromTemplate generates the script file corresponding to the phase from the custom template 'tmplString'
+// instead of the built-in one
+func (ud *Content) GenerateFromTemplate(phase string, tmplString string) ([]byte, error) {
+	err := ValidateTemplate(phase, tmplString)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("userdata.custom." + phase).Parse(tmplString)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBufferString("")
+	err = tmpl.Execute(buf, ud)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // AddInTag adds some useful code on the end of userdata.phase2.sh just before the end (on the label #insert_tag)
 func (ud Content) AddInTag(phase string, tagname string, content string) {
 	if _, ok := ud.Tags[phase]; !ok {
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package userdata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate_GatewayMTU(t *testing.T) {
+	ud := NewContent()
+	ud.HostName = "gw-net"
+	ud.IsGateway = true
+	ud.MTU = 1400
+
+	script, err := ud.Generate("phase2")
+	require.Nil(t, err)
+	assert.Contains(t, string(script), "configure_gateway_mtu 1400 ||")
+
+	ud.MTU = 0
+	script, err = ud.Generate("phase2")
+	require.Nil(t, err)
+	assert.NotContains(t, string(script), "configure_gateway_mtu 0")
+	assert.NotContains(t, string(script), "configure_gateway_mtu 1400")
+}
+
+func TestGenerate_GatewayIngressPorts(t *testing.T) {
+	ud := NewContent()
+	ud.HostName = "gw-net"
+	ud.IsGateway = true
+	ud.IngressPorts = []int{443, 8444}
+
+	script, err := ud.Generate("phase2")
+	require.Nil(t, err)
+	assert.Contains(t, string(script), "--zone=public --add-port=443/tcp || return 1")
+	assert.Contains(t, string(script), "--zone=public --add-port=8444/tcp || return 1")
+
+	ud.IngressPorts = nil
+	script, err = ud.Generate("phase2")
+	require.Nil(t, err)
+	assert.NotContains(t, string(script), "--add-port=")
+}
+
+const customPhase2 = `#!/bin/bash
+echo "configuring {{ .HostName }}"
+echo -n "0,custom" >/opt/safescale/var/state/user_data.phase2.done
+#insert_tag
+exit 0
+`
+
+func TestValidateTemplate(t *testing.T) {
+	assert.Nil(t, ValidateTemplate("phase2", customPhase2))
+
+	err := ValidateTemplate("phase2", strings.Replace(customPhase2, "#insert_tag", "", 1))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "#insert_tag")
+
+	err = ValidateTemplate("phase2", customPhase2+"{{ .HostName")
+	assert.NotNil(t, err)
+
+	err = ValidateTemplate("phase1", customPhase2)
+	assert.NotNil(t, err)
+}
+
+func TestGenerateFromTemplate(t *testing.T) {
+	ud := NewContent()
+	ud.HostName = "gw-net"
+
+	script, err := ud.GenerateFromTemplate("phase2", customPhase2)
+	require.Nil(t, err)
+	assert.Contains(t, string(script), `echo "configuring gw-net"`)
+
+	_, err = ud.GenerateFromTemplate("phase2", "#!/bin/bash\nexit 0\n")
+	assert.NotNil(t, err)
+}
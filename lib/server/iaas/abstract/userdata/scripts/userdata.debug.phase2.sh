@@ -127,6 +127,11 @@ reset_fw() {
 
     [[ $op -ne 0 ]] && return 1
 
+    # Open the additional ingress ports of a gateway on public zone
+    {{- range .IngressPorts }}
+    $FWCMD --zone=public --add-port={{ . }}/tcp || return 1
+    {{- end }}
+
     # Save current fw settings as permanent
     $FWRELOAD
     return 0
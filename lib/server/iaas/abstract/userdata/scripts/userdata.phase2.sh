@@ -131,6 +131,11 @@ function lkw_reset_fw() {
         return 1
     fi
 
+    # Open the additional ingress ports of a gateway on public zone
+    {{- range .IngressPorts }}
+    firewall-offline-cmd --zone=public --add-port={{ . }}/tcp || return 1
+    {{- end }}
+
     sfService enable firewalld &>/dev/null || return 1
     sfService start firewalld &>/dev/null || return 1
 
@@ -269,6 +274,11 @@ function reset_fw() {
         return 1
     fi
 
+    # Open the additional ingress ports of a gateway on public zone
+    {{- range .IngressPorts }}
+    $FWCMD --zone=public --add-port={{ . }}/tcp || return 1
+    {{- end }}
+
     sfService enable firewalld &>/dev/null || return 1
     sfService start firewalld &>/dev/null || return 1
 
@@ -1153,9 +1163,26 @@ EOF
     sed -i '/^.*PasswordAuthentication / s/^.*$/PasswordAuthentication no/' /etc/ssh/sshd_config || sfFail 197
     systemctl restart sshd || sfFail 197
 
+    {{- if .MTU }}
+    configure_gateway_mtu {{ .MTU }} || sfFail 198
+    {{- end }}
+
     echo done
 }
 
+# Sets the MTU of the private interfaces of the gateway, now and at boot
+configure_gateway_mtu() {
+    local mtu=$1
+    for i in $PR_IFs; do
+        ip link set dev $i mtu $mtu || return 1
+        if [[ -f /etc/sysconfig/network-scripts/ifcfg-$i ]]; then
+            sed -i '/^MTU=/d' /etc/sysconfig/network-scripts/ifcfg-$i
+            echo "MTU=$mtu" >>/etc/sysconfig/network-scripts/ifcfg-$i
+        fi
+    done
+    return 0
+}
+
 install_keepalived() {
     # Try installing network-scripts if available
     case $LINUX_KIND in
@@ -143,6 +143,11 @@ reset_fw() {
         return 1
     fi
 
+    # Open the additional ingress ports of a gateway on public zone
+    {{- range .IngressPorts }}
+    firewall-offline-cmd --zone=public --add-port={{ . }}/tcp || return 1
+    {{- end }}
+
     # Save current fw settings as permanent
     # sfFirewallReload
     sfService enable firewalld
@@ -0,0 +1,16 @@
+package objectstorage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBuildFullPath checks the layout of the keys stays the one of the metadata already stored:
+// path and prefix are concatenated without separator
+func TestBuildFullPath(t *testing.T) {
+	assert.Equal(t, "safescale/networks/byID", buildFullPath("safescale/networks/byID", NoPrefix))
+	assert.Equal(t, "safescale/networks/byID", buildFullPath("safescale/networks/byID/", NoPrefix))
+	assert.Equal(t, "safescale/networks/byIDnet", buildFullPath("safescale/networks/byID", "net"))
+	assert.Equal(t, "net", buildFullPath("", "net"))
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package objectstoragetest provides an in-memory objectstorage.Bucket for tests
+package objectstoragetest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/objectstorage"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+// Bucket is an in-memory objectstorage.Bucket used to store metadata in tests; the methods not implemented
+// panic when called
+type Bucket struct {
+	objectstorage.Bucket
+	lock sync.Mutex
+
+	// Objects contains the content of the objects, by name
+	Objects map[string][]byte
+	// Reads lists the names of the objects read, in order
+	Reads []string
+	// ReadErrors is the number of next reads failing with a transient error
+	ReadErrors int
+	// ReadError, if set, is returned by all the reads
+	ReadError error
+	// Hide, if set, reports the objects not yet visible to reads
+	Hide func(name string) bool
+	// WriteError, if set, returns the error the write of the object 'name' fails with, if any
+	WriteError func(name string) error
+}
+
+// NewBucket returns an empty Bucket
+func NewBucket() *Bucket {
+	return &Bucket{Objects: map[string][]byte{}}
+}
+
+// List lists the objects like the Object Storage does, path and prefix being concatenated without separator
+func (b *Bucket) List(path, prefix string) ([]string, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	fullPath := strings.TrimRight(path, "/") + prefix
+	var list []string
+	for k := range b.Objects {
+		if strings.HasPrefix(k, fullPath) {
+			list = append(list, k)
+		}
+	}
+	return list, nil
+}
+
+// ReadObject writes the content of the object 'name' into 'target'
+func (b *Bucket) ReadObject(name string, target io.Writer, from int64, to int64) (objectstorage.Object, error) {
+	b.lock.Lock()
+	b.Reads = append(b.Reads, name)
+	if b.ReadError != nil {
+		b.lock.Unlock()
+		return nil, b.ReadError
+	}
+	if b.ReadErrors > 0 {
+		b.ReadErrors--
+		b.lock.Unlock()
+		return nil, fmt.Errorf("transient failure reading '%s'", name)
+	}
+	content, ok := b.Objects[name]
+	if ok && b.Hide != nil && b.Hide(name) {
+		ok = false
+	}
+	b.lock.Unlock()
+
+	if !ok {
+		return nil, fail.NotFoundError("object '" + name + "' not found")
+	}
+	_, err := io.Copy(target, bytes.NewReader(content))
+	return nil, err
+}
+
+// WriteObject stores the content read from 'source' as the object 'name'
+func (b *Bucket) WriteObject(name string, source io.Reader, size int64, metadata objectstorage.ObjectMetadata) (objectstorage.Object, error) {
+	content, err := ioutil.ReadAll(source)
+	if err != nil {
+		return nil, err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.WriteError != nil {
+		if err = b.WriteError(name); err != nil {
+			return nil, err
+		}
+	}
+	b.Objects[name] = content
+	return nil, nil
+}
+
+// DeleteObject removes the object 'name'; removing a missing object is not an error
+func (b *Bucket) DeleteObject(name string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.Objects, name)
+	return nil
+}
+
+// GetName returns the name of the bucket
+func (b *Bucket) GetName() (string, error) {
+	return "safescale-metadata", nil
+}
@@ -54,3 +54,30 @@ type Provider interface {
 	// GetTenantParameters returns the tenant parameters as read
 	GetTenantParameters() map[string]interface{}
 }
+
+// HostSecurityRulesProvider is implemented by the providers able to filter the traffic of each host with its own
+// security rules
+type HostSecurityRulesProvider interface {
+	// ApplySecurityRules makes the security rules of the host identified by hostID match the rules
+	ApplySecurityRules(hostID string, rules []abstract.SecurityGroupRule) fail.Error
+	// RemoveSecurityRules removes the security rules of the host identified by hostID
+	RemoveSecurityRules(hostID string) fail.Error
+}
+
+// SecurityGroupsProvider is implemented by the providers able to manage security groups, sets of security rules
+// shared by the hosts bound to them
+type SecurityGroupsProvider interface {
+	// CreateSecurityGroup creates a security group containing the rules
+	CreateSecurityGroup(name, description string, rules []abstract.SecurityGroupRule) (*abstract.SecurityGroup, fail.Error)
+	// DeleteSecurityGroup deletes the security group identified by id
+	DeleteSecurityGroup(id string) fail.Error
+	// BindSecurityGroupToHost applies the security group identified by sgID to the host identified by hostID
+	BindSecurityGroupToHost(sgID, hostID string) fail.Error
+}
+
+// HostMigrationProvider is implemented by the providers able to move the interface of a host from a network to another
+type HostMigrationProvider interface {
+	// MigrateHost moves the interface of the host identified by hostID from the network fromNetworkID to the network
+	// toNetworkID and returns the private IP of the host in the destination network
+	MigrateHost(hostID, fromNetworkID, toNetworkID string) (string, fail.Error)
+}
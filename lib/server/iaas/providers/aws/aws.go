@@ -148,6 +148,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 	projectName, _ := computeCfg["ProjectName"].(string)
 	projectID, _ := computeCfg["ProjectID"].(string)
 	defaultImage, _ := computeCfg["DefaultImage"].(string)
+	defaultImages := providers.ToMapOfStrings(computeCfg["DefaultImages"])
 
 	operatorUsername := abstract.DefaultUser
 	if operatorUsernameIf, ok := computeCfg["OperatorUsername"]; ok {
@@ -189,6 +190,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 		},
 		MetadataBucket:   metadataBucketName,
 		DefaultImage:     defaultImage,
+		DefaultImages:    defaultImages,
 		OperatorUsername: operatorUsername,
 		UseNATService:    false,
 		ProviderName:     providerName,
@@ -229,6 +231,7 @@ func (p *provider) GetConfigurationOptions() (providers.Config, error) {
 	cfg.Set("AutoHostNetworkInterfaces", opts.AutoHostNetworkInterfaces)
 	cfg.Set("UseLayer3Networking", opts.UseLayer3Networking)
 	cfg.Set("DefaultImage", opts.DefaultImage)
+	cfg.Set("DefaultImages", opts.DefaultImages)
 	cfg.Set("MetadataBucketName", opts.MetadataBucket)
 	cfg.Set("OperatorUsername", opts.OperatorUsername)
 	cfg.Set("ProviderName", p.GetName())
@@ -253,7 +256,9 @@ func (p *provider) ListTemplates(all bool) ([]abstract.HostTemplate, error) {
 // GetCapabilities returns the capabilities of the provider
 func (p *provider) GetCapabilities() providers.Capabilities {
 	return providers.Capabilities{
-		PrivateVirtualIP: false,
+		PrivateVirtualIP:       false,
+		SupportsSecurityGroups: true,
+		SupportsCustomDisks:    true,
 	}
 }
 
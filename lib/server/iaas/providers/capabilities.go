@@ -24,4 +24,12 @@ type Capabilities struct {
 	PrivateVirtualIP bool
 	// Layer3Networking indicates if the provider uses Layer3 networking
 	Layer3Networking bool
+	// NetworkMTU indicates if the provider can set the MTU of a network
+	NetworkMTU bool
+	// SupportsIPv6 indicates if the provider can create IPv6 networks
+	SupportsIPv6 bool
+	// SupportsSecurityGroups indicates if the provider filters the traffic of hosts with security groups
+	SupportsSecurityGroups bool
+	// SupportsCustomDisks indicates if the provider honors the type (speed) requested for volumes
+	SupportsCustomDisks bool
 }
@@ -69,6 +69,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 	projectName, _ := compute["ProjectName"].(string)
 	// projectID, _ := compute["ProjectID"].(string)
 	defaultImage, _ := compute["DefaultImage"].(string)
+	defaultImages := providers.ToMapOfStrings(compute["DefaultImages"])
 	if defaultImage == "" {
 		defaultImage = cloudferroDefaultImage
 	}
@@ -131,6 +132,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 		MetadataBucket:   metadataBucketName,
 		DNSList:          cloudferroDNSServers,
 		DefaultImage:     defaultImage,
+		DefaultImages:    defaultImages,
 		OperatorUsername: operatorUsername,
 		ProviderName:     providerName,
 	}
@@ -219,6 +221,7 @@ func (p *provider) GetConfigurationOptions() (providers.Config, error) {
 	cfg.Set("AutoHostNetworkInterfaces", opts.AutoHostNetworkInterfaces)
 	cfg.Set("UseLayer3Networking", opts.UseLayer3Networking)
 	cfg.Set("DefaultImage", opts.DefaultImage)
+	cfg.Set("DefaultImages", opts.DefaultImages)
 	cfg.Set("MetadataBucketName", opts.MetadataBucket)
 	cfg.Set("OperatorUsername", opts.OperatorUsername)
 	cfg.Set("ProviderName", p.GetName())
@@ -258,7 +261,10 @@ func (p *provider) GetTenantParameters() map[string]interface{} {
 // GetCapabilities returns the capabilities of the provider
 func (p *provider) GetCapabilities() providers.Capabilities {
 	return providers.Capabilities{
-		PrivateVirtualIP: true,
+		PrivateVirtualIP:       true,
+		SupportsIPv6:           true,
+		SupportsSecurityGroups: true,
+		SupportsCustomDisks:    true,
 	}
 }
 
@@ -93,3 +93,53 @@ func (c ConfigMap) GetInteger(name string) int {
 func (c ConfigMap) Set(name string, value interface{}) {
 	c[name] = value
 }
+
+// ToMapOfStrings converts a value read from tenant parameters (usually map[string]interface{}) to a string map of strings;
+// entries whose value is not a string are ignored
+func ToMapOfStrings(in interface{}) map[string]string {
+	out := map[string]string{}
+	switch casted := in.(type) {
+	case map[string]string:
+		for k, v := range casted {
+			out[k] = v
+		}
+	case map[string]interface{}:
+		for k, v := range casted {
+			if s, ok := v.(string); ok {
+				out[k] = s
+			}
+		}
+	}
+	return out
+}
+
+// ToInt converts a value read from tenant parameters (int64 from TOML, float64 from JSON, ...) to an int;
+// returns 0 if the value is not a number
+func ToInt(in interface{}) int {
+	switch casted := in.(type) {
+	case int:
+		return casted
+	case int64:
+		return int(casted)
+	case float64:
+		return int(casted)
+	}
+	return 0
+}
+
+// ToSliceOfStrings converts a value read from tenant parameters (usually []interface{}) to a slice of strings;
+// items that are not strings are ignored
+func ToSliceOfStrings(in interface{}) []string {
+	var out []string
+	switch casted := in.(type) {
+	case []string:
+		out = append(out, casted...)
+	case []interface{}:
+		for _, v := range casted {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
@@ -46,6 +46,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 	insecure, _ := identity["Insecure"].(string)
 	region, _ := compute["Region"].(string)
 	vdc, _ := compute["Vdc"].(string)
+	defaultImages := providers.ToMapOfStrings(compute["DefaultImages"])
 	org, _ := identity["Org"].(string)
 	identityEndpoint, _ := identity["EntryPoint"].(string)
 
@@ -83,6 +84,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 			"performant": volumespeed.HDD,
 		},
 		MetadataBucket: metadataBucketName,
+		DefaultImages:  defaultImages,
 	}
 
 	notsafe := false
@@ -134,6 +136,7 @@ func (p *provider) GetConfigurationOptions() (providers.Config, error) {
 	cfg.Set("AutoHostNetworkInterfaces", opts.AutoHostNetworkInterfaces)
 	cfg.Set("UseLayer3Networking", opts.UseLayer3Networking)
 	cfg.Set("DefaultImage", opts.DefaultImage)
+	cfg.Set("DefaultImages", opts.DefaultImages)
 	cfg.Set("ProviderNetwork", opts.ProviderNetwork)
 	cfg.Set("MetadataBucketName", opts.MetadataBucket)
 
@@ -92,6 +92,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 	vpcCIDR, _ := network["VPCCIDR"].(string)
 	region, _ := compute["Region"].(string)
 	zone, _ := compute["AvailabilityZone"].(string)
+	defaultImages := providers.ToMapOfStrings(compute["DefaultImages"])
 	operatorUsername := abstract.DefaultUser
 	if operatorUsernameIf, ok := compute["OperatorUsername"]; ok {
 		operatorUsername = operatorUsernameIf.(string)
@@ -148,6 +149,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 			"SSD":  volumespeed.SSD,
 		},
 		MetadataBucket:   metadataBucketName,
+		DefaultImages:    defaultImages,
 		OperatorUsername: operatorUsername,
 		ProviderName:     providerName,
 		// WhitelistTemplateRegexp: whitelistTemplatePattern,
@@ -347,6 +349,7 @@ func (p *provider) GetConfigurationOptions() (providers.Config, error) {
 	cfg.Set("AutoHostNetworkInterfaces", opts.AutoHostNetworkInterfaces)
 	cfg.Set("UseLayer3Networking", opts.UseLayer3Networking)
 	cfg.Set("DefaultImage", opts.DefaultImage)
+	cfg.Set("DefaultImages", opts.DefaultImages)
 	cfg.Set("MetadataBucketName", opts.MetadataBucket)
 	cfg.Set("OperatorUsername", opts.OperatorUsername)
 	cfg.Set("ProviderName", p.GetName())
@@ -368,7 +371,9 @@ func (p *provider) GetTenantParameters() map[string]interface{} {
 // GetCapabilities returns the capabilities of the provider
 func (p *provider) GetCapabilities() providers.Capabilities {
 	return providers.Capabilities{
-		PrivateVirtualIP: true,
+		PrivateVirtualIP:       true,
+		SupportsSecurityGroups: true,
+		SupportsCustomDisks:    true,
 	}
 }
 
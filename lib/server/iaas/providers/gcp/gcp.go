@@ -78,21 +78,30 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 	clientCertURL, _ := identityCfg["client_x509_cert_url"].(string)
 	region, _ := computeCfg["Region"].(string)
 	zone, _ := computeCfg["Zone"].(string)
+	keyType, _ := computeCfg["KeyType"].(string)
+	serviceAccount, _ := computeCfg["ServiceAccount"].(string)
+	requireKeyPair, _ := computeCfg["RequireKeyPair"].(bool)
 
 	gcpConf := stacks.GCPConfiguration{
-		Type:         "service_account",
-		ProjectID:    gcpprojectID,
-		PrivateKeyID: privateKeyID,
-		PrivateKey:   privateKey,
-		ClientEmail:  clientEmail,
-		ClientID:     clientID,
-		AuthURI:      authURI,
-		TokenURI:     tokenURI,
-		AuthProvider: authProvider,
-		ClientCert:   clientCertURL,
-		Region:       region,
-		Zone:         zone,
-		NetworkName:  networkName,
+		Type:           "service_account",
+		ProjectID:      gcpprojectID,
+		PrivateKeyID:   privateKeyID,
+		PrivateKey:     privateKey,
+		ClientEmail:    clientEmail,
+		ClientID:       clientID,
+		AuthURI:        authURI,
+		TokenURI:       tokenURI,
+		AuthProvider:   authProvider,
+		ClientCert:     clientCertURL,
+		Region:         region,
+		Zone:           zone,
+		NetworkName:    networkName,
+		PasswordLength: providers.ToInt(computeCfg["PasswordLength"]),
+		KeyType:        keyType,
+		KeyBits:        providers.ToInt(computeCfg["KeyBits"]),
+		ServiceAccount: serviceAccount,
+		Scopes:         providers.ToSliceOfStrings(computeCfg["Scopes"]),
+		RequireKeyPair: requireKeyPair,
 	}
 
 	username, _ := identityCfg["Username"].(string)
@@ -102,6 +111,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 	projectName, _ := computeCfg["ProjectName"].(string)
 	projectID, _ := computeCfg["ProjectID"].(string)
 	defaultImage, _ := computeCfg["DefaultImage"].(string)
+	defaultImages := providers.ToMapOfStrings(computeCfg["DefaultImages"])
 
 	operatorUsername := abstract.DefaultUser
 	if operatorUsernameIf, ok := computeCfg["OperatorUsername"]; ok {
@@ -141,6 +151,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 		},
 		MetadataBucket:   metadataBucketName,
 		DefaultImage:     defaultImage,
+		DefaultImages:    defaultImages,
 		OperatorUsername: operatorUsername,
 		UseNATService:    true,
 		ProviderName:     providerName,
@@ -183,6 +194,7 @@ func (p *provider) GetConfigurationOptions() (providers.Config, error) {
 	cfg.Set("AutoHostNetworkInterfaces", opts.AutoHostNetworkInterfaces)
 	cfg.Set("UseLayer3Networking", opts.UseLayer3Networking)
 	cfg.Set("DefaultImage", opts.DefaultImage)
+	cfg.Set("DefaultImages", opts.DefaultImages)
 	cfg.Set("MetadataBucketName", opts.MetadataBucket)
 	cfg.Set("OperatorUsername", opts.OperatorUsername)
 	cfg.Set("ProviderName", p.GetName())
@@ -206,7 +218,10 @@ func (p *provider) GetTenantParameters() map[string]interface{} {
 
 // GetCapabilities returns the capabilities of the provider
 func (p *provider) GetCapabilities() providers.Capabilities {
-	return providers.Capabilities{}
+	return providers.Capabilities{
+		NetworkMTU:          true,
+		SupportsCustomDisks: true,
+	}
 }
 
 func init() {
@@ -72,6 +72,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 		providerNetwork = "public"
 	}
 	defaultImage, _ := compute["DefaultImage"].(string)
+	defaultImages := providers.ToMapOfStrings(compute["DefaultImages"])
 	dnsServers, _ := network["DNSServers"].([]string)
 	if len(dnsServers) == 0 {
 		dnsServers = []string{"8.8.8.8", "1.1.1.1"}
@@ -120,6 +121,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 		},
 		DNSList:          dnsServers,
 		DefaultImage:     defaultImage,
+		DefaultImages:    defaultImages,
 		MetadataBucket:   metadataBucketName,
 		OperatorUsername: operatorUsername,
 		ProviderName:     providerName,
@@ -212,6 +214,7 @@ func (p *provider) GetConfigurationOptions() (providers.Config, error) {
 	cfg.Set("AutoHostNetworkInterfaces", opts.AutoHostNetworkInterfaces)
 	cfg.Set("UseLayer3Networking", opts.UseLayer3Networking)
 	cfg.Set("DefaultImage", opts.DefaultImage)
+	cfg.Set("DefaultImages", opts.DefaultImages)
 	cfg.Set("ProviderNetwork", opts.ProviderNetwork)
 	cfg.Set("MetadataBucketName", opts.MetadataBucket)
 	cfg.Set("OperatorUsername", opts.OperatorUsername)
@@ -253,7 +256,10 @@ func (p *provider) GetTenantParameters() map[string]interface{} {
 // GetCapabilities returns the capabilities of the provider
 func (p *provider) GetCapabilities() providers.Capabilities {
 	return providers.Capabilities{
-		PrivateVirtualIP: true,
+		PrivateVirtualIP:       true,
+		SupportsIPv6:           true,
+		SupportsSecurityGroups: true,
+		SupportsCustomDisks:    true,
 	}
 }
 
@@ -64,6 +64,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 	zone, _ := compute["AvailabilityZone"].(string)
 	vpcName, _ := network["VPCName"].(string)
 	vpcCIDR, _ := network["VPCCIDR"].(string)
+	defaultImages := providers.ToMapOfStrings(compute["DefaultImages"])
 
 	identityEndpoint, _ := identity["IdentityEndpoint"].(string)
 	if identityEndpoint == "" {
@@ -127,6 +128,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 			"SSD":  volumespeed.SSD,
 		},
 		MetadataBucket:   metadataBucketName,
+		DefaultImages:    defaultImages,
 		OperatorUsername: operatorUsername,
 		ProviderName:     providerName,
 	}
@@ -235,6 +237,7 @@ func (p *provider) GetConfigurationOptions() (providers.Config, error) {
 	cfg.Set("AutoHostNetworkInterfaces", opts.AutoHostNetworkInterfaces)
 	cfg.Set("UseLayer3Networking", opts.UseLayer3Networking)
 	cfg.Set("DefaultImage", opts.DefaultImage)
+	cfg.Set("DefaultImages", opts.DefaultImages)
 	cfg.Set("MetadataBucketName", opts.MetadataBucket)
 	cfg.Set("OperatorUsername", opts.OperatorUsername)
 	cfg.Set("ProviderName", p.GetName())
@@ -253,7 +256,9 @@ func (p *provider) GetTenantParameters() map[string]interface{} {
 // GetCapabilities returns the capabilities of the provider
 func (p *provider) GetCapabilities() providers.Capabilities {
 	return providers.Capabilities{
-		PrivateVirtualIP: true,
+		PrivateVirtualIP:       true,
+		SupportsSecurityGroups: true,
+		SupportsCustomDisks:    true,
 	}
 }
 
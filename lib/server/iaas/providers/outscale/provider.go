@@ -128,6 +128,7 @@ func (p *provider) Build(opt map[string]interface{}) (apiprovider.Provider, erro
 			DNSList:                 getList(compute, "DNSList"),
 			DefaultTenancy:          get(compute, "DefaultTenancy", "default"),
 			DefaultImage:            get(compute, "DefaultImage"),
+			DefaultImages:           providers.ToMapOfStrings(compute["DefaultImages"]),
 			DefaultVolumeSpeed:      volumeSpeed(get(compute, "DefaultVolumeSpeed", "HDD")),
 			OperatorUsername:        get(compute, "OperatorUsername", "safescale"),
 			BlacklistImageRegexp:    regexp.MustCompile(get(compute, "BlacklistImageRegexp")),
@@ -200,6 +201,7 @@ func (p *provider) GetConfigurationOptions() (providers.Config, error) {
 	cfg.Set("AutoHostNetworkInterfaces", true)
 	cfg.Set("UseLayer3Networking", false)
 	cfg.Set("DefaultImage", p.Options.Compute.DefaultImage)
+	cfg.Set("DefaultImages", p.Options.Compute.DefaultImages)
 	cfg.Set("MetadataBucketName", p.Options.Metadata.Bucket)
 	cfg.Set("OperatorUsername", p.Options.Compute.OperatorUsername)
 	cfg.Set("ProviderName", p.GetName())
@@ -220,9 +222,11 @@ func (p *provider) GetTenantParameters() map[string]interface{} {
 // GetCapabilities returns the capabilities of the provider
 func (p *provider) GetCapabilities() providers.Capabilities {
 	return providers.Capabilities{
-		PublicVirtualIP:  false,
-		PrivateVirtualIP: true,
-		Layer3Networking: false,
+		PublicVirtualIP:        false,
+		PrivateVirtualIP:       true,
+		Layer3Networking:       false,
+		SupportsSecurityGroups: true,
+		SupportsCustomDisks:    true,
 	}
 }
 
@@ -105,6 +105,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 	}
 
 	projectName, _ := compute["ProjectName"].(string)
+	defaultImages := providers.ToMapOfStrings(compute["DefaultImages"])
 
 	val1, ok1 := identityParams["AlternateApiConsumerKey"]
 	val2, ok2 := identityParams["AlternateApiApplicationSecret"]
@@ -168,6 +169,7 @@ func (p *provider) Build(params map[string]interface{}) (apiprovider.Provider, e
 			"high-speed": volumespeed.HDD,
 		},
 		MetadataBucket:   metadataBucketName,
+		DefaultImages:    defaultImages,
 		OperatorUsername: operatorUsername,
 		ProviderName:     providerName,
 	}
@@ -263,6 +265,7 @@ func (p *provider) GetConfigurationOptions() (providers.Config, error) {
 	cfg.Set("AutoHostNetworkInterfaces", opts.AutoHostNetworkInterfaces)
 	cfg.Set("UseLayer3Networking", opts.UseLayer3Networking)
 	cfg.Set("DefaultImage", opts.DefaultImage)
+	cfg.Set("DefaultImages", opts.DefaultImages)
 	cfg.Set("MetadataBucketName", opts.MetadataBucket)
 	cfg.Set("OperatorUsername", opts.OperatorUsername)
 	cfg.Set("ProviderName", p.GetName())
@@ -373,7 +376,10 @@ func (p *provider) GetTenantParameters() map[string]interface{} {
 // GetCapabilities returns the capabilities of the provider
 func (p *provider) GetCapabilities() providers.Capabilities {
 	return providers.Capabilities{
-		PrivateVirtualIP: true,
+		PrivateVirtualIP:       true,
+		SupportsIPv6:           true,
+		SupportsSecurityGroups: true,
+		SupportsCustomDisks:    true,
 	}
 }
 
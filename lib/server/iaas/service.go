@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -45,7 +46,8 @@ import (
 	templatefilters "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/filters/templates"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/userdata"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/objectstorage"
-	providers "github.com/CS-SI/SafeScale/lib/server/iaas/providers/api"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/providers"
+	apiprovider "github.com/CS-SI/SafeScale/lib/server/iaas/providers/api"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/crypt"
 )
@@ -57,19 +59,29 @@ import (
 type Service interface {
 	// --- from service ---
 
+	ApplySecurityRules(string, []abstract.SecurityGroupRule) error
+	BindSecurityGroupToHost(string, string) error
+	CheckProviderHealth() (*abstract.ProviderHealth, error)
+	CreateSecurityGroup(string, string, []abstract.SecurityGroupRule) (*abstract.SecurityGroup, error)
+	DeleteSecurityGroup(string) error
 	CreateHostWithKeyPair(abstract.HostRequest) (*abstract.Host, *userdata.Content, *abstract.KeyPair, error)
 	FilterImages(string) ([]abstract.Image, error)
 	GetMetadataKey() *crypt.Key
 	GetMetadataBucket() objectstorage.Bucket
+	HostExists(string) (bool, error)
+	NetworkExists(string) (bool, error)
 	ListHostsByName() (map[string]*abstract.Host, error)
+	MigrateHost(string, string, string) (string, error)
+	RefreshCapabilities() providers.Capabilities
 	SearchImage(string) (*abstract.Image, error)
+	SearchImageWithStrategy(string, ImageMatchStrategy) (*abstract.Image, error)
 	SelectTemplatesBySize(abstract.SizingRequirements, bool) ([]*abstract.HostTemplate, error)
 	SelectTemplateByName(string) (*abstract.HostTemplate, error)
 	WaitHostState(string, hoststate.Enum, time.Duration) error
 	WaitVolumeState(string, volumestate.Enum, time.Duration) (*abstract.Volume, error)
 
 	// --- from interface iaas.Providers ---
-	providers.Provider
+	apiprovider.Provider
 
 	// --- from interface ObjectStorage ---
 
@@ -78,11 +90,15 @@ type Service interface {
 
 // Service ...
 type service struct {
-	providers.Provider
+	apiprovider.Provider
 	objectstorage.Location
 	metadataBucket objectstorage.Bucket
 	metadataKey    *crypt.Key
 
+	// capabilities caches the capabilities of the provider, which don't change during the lifetime of the daemon
+	capabilitiesLock sync.Mutex
+	capabilities     *providers.Capabilities
+
 	whitelistTemplateRE *regexp.Regexp
 	blacklistTemplateRE *regexp.Regexp
 	whitelistImageRE    *regexp.Regexp
@@ -133,6 +149,77 @@ func (a ByRankDRF) Less(i, j int) bool { return RankDRF(a[i]) < RankDRF(a[j]) }
 // 	return access.Host.GetAccessIP()
 // }
 
+// GetCapabilities returns the capabilities of the provider, asked to the provider the first time only
+func (svc *service) GetCapabilities() providers.Capabilities {
+	svc.capabilitiesLock.Lock()
+	defer svc.capabilitiesLock.Unlock()
+
+	if svc.capabilities == nil {
+		caps := svc.Provider.GetCapabilities()
+		svc.capabilities = &caps
+	}
+	return *svc.capabilities
+}
+
+// RefreshCapabilities asks again the capabilities to the provider and caches them
+func (svc *service) RefreshCapabilities() providers.Capabilities {
+	svc.capabilitiesLock.Lock()
+	defer svc.capabilitiesLock.Unlock()
+
+	caps := svc.Provider.GetCapabilities()
+	svc.capabilities = &caps
+	return caps
+}
+
+// ApplySecurityRules makes the security rules of the host identified by 'hostID' match 'rules', if the provider
+// filters the traffic of each host (see apiprovider.HostSecurityRulesProvider); fail.ErrNotImplemented otherwise
+func (svc *service) ApplySecurityRules(hostID string, rules []abstract.SecurityGroupRule) error {
+	provider, ok := svc.Provider.(apiprovider.HostSecurityRulesProvider)
+	if !ok {
+		return fail.NotImplementedError(fmt.Sprintf("security rules of host on provider '%s'", svc.GetName()))
+	}
+	return provider.ApplySecurityRules(hostID, rules)
+}
+
+// CreateSecurityGroup creates a security group named 'name' containing 'rules', if the provider manages security
+// groups (see apiprovider.SecurityGroupsProvider); fail.ErrNotImplemented otherwise
+func (svc *service) CreateSecurityGroup(name, description string, rules []abstract.SecurityGroupRule) (*abstract.SecurityGroup, error) {
+	provider, ok := svc.Provider.(apiprovider.SecurityGroupsProvider)
+	if !ok {
+		return nil, fail.NotImplementedError(fmt.Sprintf("security groups on provider '%s'", svc.GetName()))
+	}
+	return provider.CreateSecurityGroup(name, description, rules)
+}
+
+// DeleteSecurityGroup deletes the security group identified by 'id' (see CreateSecurityGroup)
+func (svc *service) DeleteSecurityGroup(id string) error {
+	provider, ok := svc.Provider.(apiprovider.SecurityGroupsProvider)
+	if !ok {
+		return fail.NotImplementedError(fmt.Sprintf("security groups on provider '%s'", svc.GetName()))
+	}
+	return provider.DeleteSecurityGroup(id)
+}
+
+// BindSecurityGroupToHost applies the security group identified by 'sgID' to the host identified by 'hostID'
+// (see CreateSecurityGroup)
+func (svc *service) BindSecurityGroupToHost(sgID, hostID string) error {
+	provider, ok := svc.Provider.(apiprovider.SecurityGroupsProvider)
+	if !ok {
+		return fail.NotImplementedError(fmt.Sprintf("security groups on provider '%s'", svc.GetName()))
+	}
+	return provider.BindSecurityGroupToHost(sgID, hostID)
+}
+
+// MigrateHost moves the interface of the host identified by 'hostID' from the network 'fromNetworkID' to the network
+// 'toNetworkID', if the provider supports it (see apiprovider.HostMigrationProvider); fail.ErrNotImplemented otherwise
+func (svc *service) MigrateHost(hostID, fromNetworkID, toNetworkID string) (string, error) {
+	provider, ok := svc.Provider.(apiprovider.HostMigrationProvider)
+	if !ok {
+		return "", fail.NotImplementedError(fmt.Sprintf("migration of host between networks on provider '%s'", svc.GetName()))
+	}
+	return provider.MigrateHost(hostID, fromNetworkID, toNetworkID)
+}
+
 func (svc *service) GetMetadataBucket() objectstorage.Bucket {
 	return svc.metadataBucket
 }
@@ -142,7 +229,7 @@ func (svc *service) GetMetadataKey() *crypt.Key {
 }
 
 // SetProvider allows to change provider interface of service object (mainly for test purposes)
-func (svc *service) SetProvider(provider providers.Provider) {
+func (svc *service) SetProvider(provider apiprovider.Provider) {
 	svc.Provider = provider
 }
 
@@ -484,10 +571,60 @@ func (svc *service) SelectTemplatesBySize(sizing abstract.SizingRequirements, fo
 		}
 	}
 
+	selectedTpls, err = filterTemplatesByAccelerator(selectedTpls, sizing)
+	if err != nil {
+		return nil, err
+	}
+
 	sort.Sort(ByRankDRF(selectedTpls))
 	return selectedTpls, nil
 }
 
+// filterTemplatesByAccelerator keeps the templates having at least sizing.MinGPU GPUs of type sizing.GPUType (if set)
+// If none satisfies the requirement, returns a NotFoundError enumerating the shortfall of each template
+func filterTemplatesByAccelerator(
+	tpls []*abstract.HostTemplate, sizing abstract.SizingRequirements,
+) ([]*abstract.HostTemplate, error) {
+	if sizing.MinGPU <= 0 && sizing.GPUType == "" {
+		return tpls, nil
+	}
+	minGPU := sizing.MinGPU
+	if minGPU <= 0 {
+		minGPU = 1
+	}
+
+	var (
+		selected  []*abstract.HostTemplate
+		shortfall []string
+	)
+	for _, t := range tpls {
+		switch {
+		case t.GPUNumber < minGPU:
+			shortfall = append(
+				shortfall, fmt.Sprintf("'%s' has %d GPU(s), %d missing", t.Name, t.GPUNumber, minGPU-t.GPUNumber),
+			)
+		case sizing.GPUType != "" && !strings.EqualFold(t.GPUType, sizing.GPUType):
+			shortfall = append(shortfall, fmt.Sprintf("'%s' has GPU(s) of type '%s'", t.Name, t.GPUType))
+		default:
+			selected = append(selected, t)
+		}
+	}
+	if len(selected) > 0 {
+		return selected, nil
+	}
+
+	wanted := fmt.Sprintf("at least %d GPU(s)", minGPU)
+	if sizing.GPUType != "" {
+		wanted += fmt.Sprintf(" of type '%s'", sizing.GPUType)
+	}
+	if len(shortfall) == 0 {
+		return nil, fail.NotFoundError(
+			fmt.Sprintf("no template with %s: no template satisfies the other sizing requirements", wanted),
+		)
+	}
+	return nil, fail.NotFoundError(fmt.Sprintf("no template with %s: %s", wanted, strings.Join(shortfall, "; ")))
+}
+
 type scoredImage struct {
 	abstract.Image
 	score float64
@@ -688,6 +825,133 @@ func (svc *service) SearchImage(osname string) (*abstract.Image, error) {
 	return &imgs[maxi], nil
 }
 
+// ProviderHealthChecker is implemented by the providers able to check cheaply that their API is reachable and
+// the credentials of the tenant are valid
+type ProviderHealthChecker interface {
+	CheckProviderHealth() *abstract.ProviderHealth
+}
+
+// CheckProviderHealth checks the API of the provider is reachable and the credentials of the tenant are valid
+func (svc *service) CheckProviderHealth() (*abstract.ProviderHealth, error) {
+	if svc == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+
+	checker, ok := svc.Provider.(ProviderHealthChecker)
+	if !ok {
+		return nil, fail.NotImplementedError(fmt.Sprintf("health check of provider '%s'", svc.GetName()))
+	}
+	return checker.CheckProviderHealth(), nil
+}
+
+// ImageMatchStrategy tells how SearchImageWithStrategy selects an image among the available ones
+type ImageMatchStrategy int
+
+const (
+	// ImageMatchBest selects the image whose name is the most similar to the one requested (behavior of SearchImage)
+	ImageMatchBest ImageMatchStrategy = iota
+	// ImageMatchExact selects the image whose ID or name is exactly the one requested
+	ImageMatchExact
+	// ImageMatchPrefix selects the image whose name starts with the one requested
+	ImageMatchPrefix
+	// ImageMatchLatestByFamily selects, among the images whose name starts with the one requested, the last one
+	// in name order (image names of a family usually end with a version or a date)
+	ImageMatchLatestByFamily
+)
+
+// String returns the name of the strategy
+func (s ImageMatchStrategy) String() string {
+	switch s {
+	case ImageMatchBest:
+		return "best"
+	case ImageMatchExact:
+		return "exact"
+	case ImageMatchPrefix:
+		return "prefix"
+	case ImageMatchLatestByFamily:
+		return "latest"
+	}
+	return fmt.Sprintf("ImageMatchStrategy(%d)", int(s))
+}
+
+// ParseImageMatchStrategy returns the strategy named 's' ("best", "exact", "prefix" or "latest");
+// an empty string means ImageMatchBest
+func ParseImageMatchStrategy(s string) (ImageMatchStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "best":
+		return ImageMatchBest, nil
+	case "exact":
+		return ImageMatchExact, nil
+	case "prefix":
+		return ImageMatchPrefix, nil
+	case "latest":
+		return ImageMatchLatestByFamily, nil
+	}
+	return ImageMatchBest, fail.InvalidParameterError("s", fmt.Sprintf("unknown image match strategy '%s'", s))
+}
+
+// SearchImageWithStrategy searches an image corresponding to 'name' using 'strategy'
+func (svc *service) SearchImageWithStrategy(name string, strategy ImageMatchStrategy) (*abstract.Image, error) {
+	if svc == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if strategy == ImageMatchBest {
+		return svc.SearchImage(name)
+	}
+
+	imgs, err := svc.ListImages(false)
+	if err != nil {
+		return nil, err
+	}
+	img, err := matchImage(imgs, name, strategy)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Selected image: '%s' (ID='%s') using strategy '%s'", img.Name, img.ID, strategy.String())
+	return img, nil
+}
+
+// matchImage selects in 'imgs' the image corresponding to 'name' according to 'strategy' (other than ImageMatchBest);
+// returns fail.ErrInvalidRequest listing the candidates if several images match with exact or prefix strategies
+func matchImage(imgs []abstract.Image, name string, strategy ImageMatchStrategy) (*abstract.Image, error) {
+	var candidates []abstract.Image
+	upperName := strings.ToUpper(name)
+	for _, img := range imgs {
+		upperImgName := strings.ToUpper(img.Name)
+		switch strategy {
+		case ImageMatchExact:
+			if img.ID == name || upperImgName == upperName {
+				candidates = append(candidates, img)
+			}
+		case ImageMatchPrefix, ImageMatchLatestByFamily:
+			if img.ID == name || strings.HasPrefix(upperImgName, upperName) {
+				candidates = append(candidates, img)
+			}
+		default:
+			return nil, fail.InvalidParameterError("strategy", fmt.Sprintf("unsupported image match strategy '%s'", strategy.String()))
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fail.NotFoundError(fmt.Sprintf("unable to find an image matching '%s' (strategy '%s')", name, strategy.String()))
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Name < candidates[j].Name
+	})
+	if len(candidates) == 1 || strategy == ImageMatchLatestByFamily {
+		return &candidates[len(candidates)-1], nil
+	}
+
+	names := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		names = append(names, fmt.Sprintf("'%s' (ID='%s')", c.Name, c.ID))
+	}
+	return nil, fail.InvalidRequestError(
+		fmt.Sprintf(
+			"several images match '%s' (strategy '%s'): %s", name, strategy.String(), strings.Join(names, ", "),
+		),
+	)
+}
+
 // CreateHostWithKeyPair creates an host
 func (svc *service) CreateHostWithKeyPair(request abstract.HostRequest) (_ *abstract.Host, _ *userdata.Content, _ *abstract.KeyPair, errx error) {
 	if svc == nil {
@@ -730,6 +994,7 @@ func (svc *service) CreateHostWithKeyPair(request abstract.HostRequest) (_ *abst
 		ImageID:        request.ImageID,
 		KeyPair:        kp,
 		PublicIP:       request.PublicIP,
+		IsGateway:      request.IsGateway,
 		Networks:       request.Networks,
 		DefaultRouteIP: request.DefaultRouteIP,
 		DefaultGateway: request.DefaultGateway,
@@ -879,3 +1144,44 @@ func InitializeBucket(svc *service, location objectstorage.Location) error {
 	}
 	return nil
 }
+
+// existenceChecker is implemented by the providers able to tell cheaply if a resource exists
+type existenceChecker interface {
+	HostExists(id string) (bool, fail.Error)
+	NetworkExists(id string) (bool, fail.Error)
+}
+
+// HostExists tells if the host identified by 'id' exists on provider side
+func (svc *service) HostExists(id string) (bool, error) {
+	if id == "" {
+		return false, fail.InvalidParameterError("id", "cannot be empty string")
+	}
+	if checker, ok := svc.Provider.(existenceChecker); ok {
+		return checker.HostExists(id)
+	}
+	_, err := svc.GetHostState(id)
+	return existsFromError(err)
+}
+
+// NetworkExists tells if the network identified by 'id' exists on provider side
+func (svc *service) NetworkExists(id string) (bool, error) {
+	if id == "" {
+		return false, fail.InvalidParameterError("id", "cannot be empty string")
+	}
+	if checker, ok := svc.Provider.(existenceChecker); ok {
+		return checker.NetworkExists(id)
+	}
+	_, err := svc.GetNetwork(id)
+	return existsFromError(err)
+}
+
+// existsFromError converts the error of a lookup to the existence of the resource looked for
+func existsFromError(err error) (bool, error) {
+	if err != nil {
+		if _, ok := err.(fail.ErrNotFound); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package iaas
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/providers"
+	apiprovider "github.com/CS-SI/SafeScale/lib/server/iaas/providers/api"
+)
+
+// capabilitiesProvider is an apiprovider.Provider only able to tell its capabilities, counting the requests
+type capabilitiesProvider struct {
+	apiprovider.Provider
+	caps  providers.Capabilities
+	calls int
+}
+
+func (p *capabilitiesProvider) GetCapabilities() providers.Capabilities {
+	p.calls++
+	return p.caps
+}
+
+func TestService_GetCapabilities(t *testing.T) {
+	provider := &capabilitiesProvider{caps: providers.Capabilities{PrivateVirtualIP: true}}
+	svc := &service{Provider: provider}
+
+	assert.True(t, svc.GetCapabilities().PrivateVirtualIP)
+	assert.True(t, svc.GetCapabilities().PrivateVirtualIP)
+	assert.Equal(t, 1, provider.calls)
+
+	provider.caps.PrivateVirtualIP = false
+	assert.True(t, svc.GetCapabilities().PrivateVirtualIP)
+	assert.False(t, svc.RefreshCapabilities().PrivateVirtualIP)
+	assert.Equal(t, 2, provider.calls)
+	assert.False(t, svc.GetCapabilities().PrivateVirtualIP)
+	assert.Equal(t, 2, provider.calls)
+
+	// the cache belongs to the service
+	other := &service{Provider: provider}
+	assert.False(t, other.GetCapabilities().PrivateVirtualIP)
+	assert.Equal(t, 3, provider.calls)
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package iaas
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	providers "github.com/CS-SI/SafeScale/lib/server/iaas/providers/api"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+var ambiguousImages = []abstract.Image{
+	{ID: "img-2004", Name: "ubuntu-2004-focal-v20200701"},
+	{ID: "img-1804-old", Name: "ubuntu-1804-bionic-v20200317"},
+	{ID: "img-1804", Name: "ubuntu-1804-bionic-v20200610"},
+	{ID: "img-centos", Name: "centos-7-v20200618"},
+	{ID: "img-ubuntu-a", Name: "ubuntu"},
+	{ID: "img-ubuntu-b", Name: "Ubuntu"},
+}
+
+// imagesProvider is a providers.Provider only able to list images
+type imagesProvider struct {
+	providers.Provider
+	images []abstract.Image
+}
+
+func (p *imagesProvider) GetName() string {
+	return "images"
+}
+
+func (p *imagesProvider) ListImages(bool) ([]abstract.Image, error) {
+	return p.images, nil
+}
+
+func TestSearchImageWithStrategy(t *testing.T) {
+	svc := &service{Provider: &imagesProvider{images: ambiguousImages}}
+
+	// the default strategy keeps the behavior of SearchImage and picks one of the ambiguous images
+	best, err := svc.SearchImageWithStrategy("ubuntu", ImageMatchBest)
+	require.Nil(t, err)
+	img, err := svc.SearchImage("ubuntu")
+	require.Nil(t, err)
+	assert.Equal(t, img.ID, best.ID)
+
+	_, err = svc.SearchImageWithStrategy("ubuntu", ImageMatchExact)
+	assert.NotNil(t, err)
+
+	img, err = svc.SearchImageWithStrategy("ubuntu-1804", ImageMatchLatestByFamily)
+	require.Nil(t, err)
+	assert.Equal(t, "img-1804", img.ID)
+}
+
+func TestParseImageMatchStrategy(t *testing.T) {
+	for _, s := range []ImageMatchStrategy{ImageMatchBest, ImageMatchExact, ImageMatchPrefix, ImageMatchLatestByFamily} {
+		parsed, err := ParseImageMatchStrategy(s.String())
+		require.Nil(t, err)
+		assert.Equal(t, s, parsed)
+	}
+	parsed, err := ParseImageMatchStrategy("")
+	require.Nil(t, err)
+	assert.Equal(t, ImageMatchBest, parsed)
+	_, err = ParseImageMatchStrategy("fuzzy")
+	assert.NotNil(t, err)
+}
+
+func TestMatchImage_Exact(t *testing.T) {
+	_, err := matchImage(ambiguousImages, "ubuntu", ImageMatchExact)
+	require.NotNil(t, err)
+	_, ok := err.(fail.ErrInvalidRequest)
+	assert.True(t, ok)
+	assert.True(t, strings.Contains(err.Error(), "img-ubuntu-a"))
+	assert.True(t, strings.Contains(err.Error(), "img-ubuntu-b"))
+
+	img, err := matchImage(ambiguousImages, "ubuntu-1804-bionic-v20200317", ImageMatchExact)
+	require.Nil(t, err)
+	assert.Equal(t, "img-1804-old", img.ID)
+
+	img, err = matchImage(ambiguousImages, "img-centos", ImageMatchExact)
+	require.Nil(t, err)
+	assert.Equal(t, "img-centos", img.ID)
+
+	_, err = matchImage(ambiguousImages, "ubuntu-1804", ImageMatchExact)
+	_, ok = err.(fail.ErrNotFound)
+	assert.True(t, ok)
+}
+
+func TestMatchImage_Prefix(t *testing.T) {
+	_, err := matchImage(ambiguousImages, "ubuntu-1804", ImageMatchPrefix)
+	require.NotNil(t, err)
+	_, ok := err.(fail.ErrInvalidRequest)
+	assert.True(t, ok)
+
+	img, err := matchImage(ambiguousImages, "ubuntu-2004", ImageMatchPrefix)
+	require.Nil(t, err)
+	assert.Equal(t, "img-2004", img.ID)
+}
+
+func TestMatchImage_LatestByFamily(t *testing.T) {
+	img, err := matchImage(ambiguousImages, "ubuntu-1804", ImageMatchLatestByFamily)
+	require.Nil(t, err)
+	assert.Equal(t, "img-1804", img.ID)
+
+	img, err = matchImage(ambiguousImages, "ubuntu-", ImageMatchLatestByFamily)
+	require.Nil(t, err)
+	assert.Equal(t, "img-2004", img.ID)
+
+	_, err = matchImage(ambiguousImages, "debian", ImageMatchLatestByFamily)
+	_, ok := err.(fail.ErrNotFound)
+	assert.True(t, ok)
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package iaas
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+func TestFilterTemplatesByAccelerator_NoGPUTemplate(t *testing.T) {
+	tpls := []*abstract.HostTemplate{
+		{ID: "1", Name: "n1-standard-4", Cores: 4},
+		{ID: "2", Name: "n1-standard-8", Cores: 8},
+	}
+	_, err := filterTemplatesByAccelerator(tpls, abstract.SizingRequirements{MinCores: 4, MinGPU: 1})
+	require.NotNil(t, err)
+	_, ok := err.(fail.ErrNotFound)
+	assert.True(t, ok)
+	assert.True(t, strings.Contains(err.Error(), "'n1-standard-4' has 0 GPU(s), 1 missing"))
+	assert.True(t, strings.Contains(err.Error(), "'n1-standard-8' has 0 GPU(s), 1 missing"))
+
+	// without accelerator requirement, templates are kept as is
+	selected, err := filterTemplatesByAccelerator(tpls, abstract.SizingRequirements{MinCores: 4})
+	require.Nil(t, err)
+	assert.Len(t, selected, 2)
+}
+
+func TestFilterTemplatesByAccelerator_Match(t *testing.T) {
+	tpls := []*abstract.HostTemplate{
+		{ID: "1", Name: "n1-standard-4", Cores: 4},
+		{ID: "2", Name: "a2-highgpu-1g", Cores: 12, GPUNumber: 1, GPUType: "nvidia-tesla-a100"},
+		{ID: "3", Name: "a2-highgpu-2g", Cores: 24, GPUNumber: 2, GPUType: "nvidia-tesla-a100"},
+	}
+	selected, err := filterTemplatesByAccelerator(tpls, abstract.SizingRequirements{MinGPU: 2})
+	require.Nil(t, err)
+	require.Len(t, selected, 1)
+	assert.Equal(t, "a2-highgpu-2g", selected[0].Name)
+
+	selected, err = filterTemplatesByAccelerator(
+		tpls, abstract.SizingRequirements{MinGPU: 1, GPUType: "NVIDIA-Tesla-A100"},
+	)
+	require.Nil(t, err)
+	assert.Len(t, selected, 2)
+
+	_, err = filterTemplatesByAccelerator(tpls, abstract.SizingRequirements{GPUType: "nvidia-tesla-t4"})
+	require.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "'a2-highgpu-1g' has GPU(s) of type 'nvidia-tesla-a100'"))
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stacks
+
+import "strings"
+
+// archAliases maps the names of CPU architectures to their canonical form
+var archAliases = map[string]string{
+	"x86_64":  "x86_64",
+	"amd64":   "x86_64",
+	"x64":     "x86_64",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+	"ppc64le": "ppc64le",
+}
+
+// NormalizeArch returns the canonical name of the CPU architecture 'arch' ("x86_64", "arm64", ...);
+// an unknown architecture is returned lower-cased
+func NormalizeArch(arch string) string {
+	a := strings.ToLower(strings.TrimSpace(arch))
+	if canonical, ok := archAliases[a]; ok {
+		return canonical
+	}
+	return a
+}
+
+// GuessImageArch returns the canonical CPU architecture mentioned in the name or the description of an image,
+// or an empty string if none is found; it is used by the stacks whose provider doesn't tell the architecture
+// of its images
+func GuessImageArch(name, description string) string {
+	fields := strings.FieldsFunc(
+		strings.ToLower(name+" "+description), func(r rune) bool {
+			return r == ' ' || r == '-' || r == '.' || r == '(' || r == ')' || r == ',' || r == '/'
+		},
+	)
+	for _, f := range fields {
+		if arch, ok := archAliases[f]; ok {
+			return arch
+		}
+	}
+	return ""
+}
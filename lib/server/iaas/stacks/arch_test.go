@@ -0,0 +1,41 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stacks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeArch(t *testing.T) {
+	for _, arch := range []string{"x86_64", "AMD64", "x64"} {
+		assert.Equal(t, "x86_64", NormalizeArch(arch), arch)
+	}
+	for _, arch := range []string{"arm64", "aarch64", " AArch64 "} {
+		assert.Equal(t, "arm64", NormalizeArch(arch), arch)
+	}
+	assert.Equal(t, "i386", NormalizeArch("I386"))
+	assert.Equal(t, "", NormalizeArch(""))
+}
+
+func TestGuessImageArch(t *testing.T) {
+	assert.Equal(t, "arm64", GuessImageArch("ubuntu-2004-focal-arm64-v20201028", ""))
+	assert.Equal(t, "x86_64", GuessImageArch("debian-10-buster-amd64", ""))
+	assert.Equal(t, "x86_64", GuessImageArch("Ubuntu 18.04", "Ubuntu server (x86_64)"))
+	assert.Equal(t, "", GuessImageArch("Ubuntu 18.04", ""))
+}
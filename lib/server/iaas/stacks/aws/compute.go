@@ -37,6 +37,7 @@ import (
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties"
 	propertiesv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/userdata"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
@@ -328,6 +329,7 @@ func (s *Stack) ListImages() ([]abstract.Image, fail.Error) {
 					Description: aws.StringValue(image.Description),
 					StorageType: aws.StringValue(image.RootDeviceType),
 					DiskSize:    0,
+					Arch:        stacks.NormalizeArch(aws.StringValue(image.Architecture)),
 				}
 
 				if len(image.BlockDeviceMappings) > 0 {
@@ -517,7 +519,7 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 	defaultNetwork := request.Networks[0]
 	defaultNetworkID := defaultNetwork.ID
 	defaultGateway := request.DefaultGateway
-	isGateway := defaultGateway == nil && defaultNetwork.Name != abstract.SingleHostNetworkName
+	isGateway := defaultGateway == nil && !defaultNetwork.IsSingleHost()
 	defaultGatewayID := ""
 	defaultGatewayPrivateIP := ""
 	if defaultGateway != nil {
@@ -720,13 +720,14 @@ func (s *Stack) CreateGateway(req abstract.GatewayRequest, sizing *abstract.Sizi
 	}
 
 	hostReq := abstract.HostRequest{
-		ImageID:      req.ImageID,
-		KeyPair:      req.KeyPair,
-		HostName:     req.Name,
-		ResourceName: gwname,
-		TemplateID:   req.TemplateID,
-		Networks:     []*abstract.Network{req.Network},
-		PublicIP:     true,
+		ImageID:       req.ImageID,
+		KeyPair:       req.KeyPair,
+		HostName:      req.Name,
+		ResourceName:  gwname,
+		TemplateID:    req.TemplateID,
+		Networks:      []*abstract.Network{req.Network},
+		PublicIP:      true,
+		KeepOnFailure: req.KeepOnFailure,
 	}
 	if sizing != nil && sizing.MinDiskSize > 0 {
 		hostReq.DiskSize = sizing.MinDiskSize
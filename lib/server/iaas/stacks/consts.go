@@ -16,5 +16,6 @@
 
 package stacks
 
-// DefaultSecurityGroupName is the name of the default security group used by SafeScale
+// DefaultSecurityGroupName is the name of the default security group used by SafeScale; the default security
+// group of a network is named after it (see DefaultSecurityGroupRules for its rules)
 const DefaultSecurityGroupName = "sg-safescale"
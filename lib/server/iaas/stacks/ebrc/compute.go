@@ -34,6 +34,7 @@ import (
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hostproperty"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hoststate"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/userdata"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
@@ -75,7 +76,13 @@ func (s *StackEbrc) ListImages(all bool) ([]abstract.Image, fail.Error) {
 			}
 			for _, item := range cat.Catalog.CatalogItems {
 				for _, deepItem := range item.CatalogItem {
-					empty = append(empty, abstract.Image{ID: deepItem.ID, Name: deepItem.Name})
+					empty = append(
+						empty, abstract.Image{
+							ID:   deepItem.ID,
+							Name: deepItem.Name,
+							Arch: stacks.GuessImageArch(deepItem.Name, ""),
+						},
+					)
 				}
 			}
 		}
@@ -530,7 +537,7 @@ func (s *StackEbrc) CreateHost(request abstract.HostRequest) (host *abstract.Hos
 			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
 			hostNetworkV1.DefaultNetworkID = defaultNetwork.ID
 
-			hostNetworkV1.IsGateway = request.DefaultGateway == nil && request.Networks[0].Name != abstract.SingleHostNetworkName
+			hostNetworkV1.IsGateway = request.DefaultGateway == nil && !request.Networks[0].IsSingleHost()
 			if request.DefaultGateway != nil {
 				hostNetworkV1.DefaultGatewayID = request.DefaultGateway.ID
 
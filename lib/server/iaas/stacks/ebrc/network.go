@@ -737,13 +737,14 @@ func (s *StackEbrc) CreateGateway(req abstract.GatewayRequest, sizing *abstract.
 	}
 
 	hostReq := abstract.HostRequest{
-		ImageID:      req.ImageID,
-		KeyPair:      req.KeyPair,
-		HostName:     req.Name,
-		ResourceName: gwname,
-		TemplateID:   req.TemplateID,
-		Networks:     []*abstract.Network{req.Network},
-		PublicIP:     true,
+		ImageID:       req.ImageID,
+		KeyPair:       req.KeyPair,
+		HostName:      req.Name,
+		ResourceName:  gwname,
+		TemplateID:    req.TemplateID,
+		Networks:      []*abstract.Network{req.Network},
+		PublicIP:      true,
+		KeepOnFailure: req.KeepOnFailure,
 	}
 	if sizing != nil && sizing.MinDiskSize > 0 {
 		hostReq.DiskSize = sizing.MinDiskSize
@@ -18,7 +18,12 @@ package gcp
 
 import (
 	"fmt"
+	"math"
+	"net"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/CS-SI/SafeScale/lib/utils/debug"
@@ -34,6 +39,7 @@ import (
 	converters "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties"
 	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/userdata"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
@@ -65,7 +71,14 @@ func (s *Stack) ListImages() (images []abstract.Image, xerr fail.Error) {
 
 			for _, image := range resp.Items {
 				images = append(
-					images, abstract.Image{Name: image.Name, URL: image.SelfLink, ID: strconv.FormatUint(image.Id, 10), DiskSize: image.DiskSizeGb},
+					images, abstract.Image{
+						Name:     image.Name,
+						URL:      image.SelfLink,
+						ID:       strconv.FormatUint(image.Id, 10),
+						DiskSize: image.DiskSizeGb,
+						// GCP tells the architecture in the name of the image ("...-arm64-...")
+						Arch: stacks.GuessImageArch(image.Name, image.Description),
+					},
 				)
 			}
 			token := resp.NextPageToken
@@ -120,6 +133,7 @@ func (s *Stack) ListTemplates(all bool) (templates []abstract.HostTemplate, xerr
 					ID:       strconv.FormatUint(matype.Id, 10),
 					Name:     matype.Name,
 				}
+				ht.GPUNumber, ht.GPUType = machineTypeAccelerators(matype)
 				templates = append(templates, ht)
 			}
 		}
@@ -134,6 +148,22 @@ func (s *Stack) ListTemplates(all bool) (templates []abstract.HostTemplate, xerr
 	return templates, nil
 }
 
+// machineTypeAccelerators returns the number and the type of the GPUs bundled with the machine type
+func machineTypeAccelerators(matype *compute.MachineType) (int, string) {
+	count := 0
+	gpuType := ""
+	for _, acc := range matype.Accelerators {
+		if acc == nil {
+			continue
+		}
+		count += int(acc.GuestAcceleratorCount)
+		if gpuType == "" {
+			gpuType = acc.GuestAcceleratorType
+		}
+	}
+	return count, gpuType
+}
+
 // GetTemplate overload OpenStackGcp GetTemplate method to add GPU configuration
 func (s *Stack) GetTemplate(id string) (*abstract.HostTemplate, fail.Error) {
 	templates, err := s.ListTemplates(true)
@@ -152,9 +182,82 @@ func (s *Stack) GetTemplate(id string) (*abstract.HostTemplate, fail.Error) {
 
 // -------------SSH KEYS-------------------------------------------------------------------------------------------------
 
-// CreateKeyPair creates a key pair (no import)
+// CreateKeyPair creates a key pair (no import), of the type and size set in the configuration
 func (s *Stack) CreateKeyPair(name string) (*abstract.KeyPair, fail.Error) {
-	return abstract.NewKeyPair(name)
+	if s == nil || s.GcpConfig == nil {
+		return abstract.NewKeyPair(name)
+	}
+	return abstract.NewKeyPairWithType(name, s.GcpConfig.KeyType, s.GcpConfig.KeyBits)
+}
+
+// instanceServiceAccount returns the service account and the scopes the instances run with, checking the scopes
+// set in the configuration contain the required ones (the cloud-platform scope grants all of them)
+func (s *Stack) instanceServiceAccount() (*compute.ServiceAccount, fail.Error) {
+	sa := &compute.ServiceAccount{Email: defaultServiceAccount, Scopes: defaultInstanceScopes}
+	if s.GcpConfig == nil {
+		return sa, nil
+	}
+	if s.GcpConfig.ServiceAccount != "" {
+		sa.Email = s.GcpConfig.ServiceAccount
+	}
+	if len(s.GcpConfig.Scopes) == 0 {
+		return sa, nil
+	}
+
+	granted := map[string]bool{}
+	for _, scope := range s.GcpConfig.Scopes {
+		granted[scope] = true
+	}
+	if !granted[compute.CloudPlatformScope] {
+		var missing []string
+		for _, scope := range requiredInstanceScopes {
+			if !granted[scope] {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fail.InvalidParameterError(
+				"Scopes", fmt.Sprintf("missing required scopes: %s", strings.Join(missing, ", ")),
+			)
+		}
+	}
+	sa.Scopes = append([]string{}, s.GcpConfig.Scopes...)
+	return sa, nil
+}
+
+// hostKeyPair returns 'kp' if set, or a key pair generated for the host 'name' unless the configuration requires
+// the key pair to be supplied by the caller
+func (s *Stack) hostKeyPair(kp *abstract.KeyPair, name string) (*abstract.KeyPair, fail.Error) {
+	if kp != nil {
+		return kp, nil
+	}
+	if s.GcpConfig != nil && s.GcpConfig.RequireKeyPair {
+		return nil, fail.InvalidParameterError(
+			"request.KeyPair", "cannot be nil: generation of key pairs is disabled by RequireKeyPair",
+		)
+	}
+	kp, xerr := s.CreateKeyPair(name)
+	if xerr != nil {
+		return nil, fail.Errorf(fmt.Sprintf("failed to generate key pair: %s", xerr.Error()), xerr)
+	}
+	return kp, nil
+}
+
+// passwordLength returns the length of the passwords generated for the hosts
+func (s *Stack) passwordLength() int {
+	if s.GcpConfig == nil || s.GcpConfig.PasswordLength == 0 {
+		return defaultPasswordLength
+	}
+	return s.GcpConfig.PasswordLength
+}
+
+// generatePassword generates a password of the length set in the configuration
+func (s *Stack) generatePassword() (string, error) {
+	length := s.passwordLength()
+	if length > math.MaxUint8 {
+		return "", fail.InvalidParameterError("PasswordLength", fmt.Sprintf("cannot be over %d", math.MaxUint8))
+	}
+	return utils.GeneratePassword(uint8(length))
 }
 
 // GetKeyPair returns the key pair identified by id
@@ -180,6 +283,9 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 	networks := request.Networks
 	hostMustHavePublicIP := request.PublicIP
 
+	// a host with the same name may have existed, its ID must not be served anymore
+	s.hostIDs.forget(resourceName)
+
 	if len(networks) == 0 {
 		return nil, userData, fail.Errorf(
 			fmt.Sprintf(
@@ -189,18 +295,23 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 	}
 
 	if request.Password == "" {
-		password, err := utils.GeneratePassword(16)
+		password, err := s.generatePassword()
 		if err != nil {
 			return nil, userData, fail.Errorf(fmt.Sprintf("failed to generate password: %s", err.Error()), err)
 		}
 		request.Password = password
 	}
 
+	request.KeyPair, xerr = s.hostKeyPair(request.KeyPair, resourceName)
+	if xerr != nil {
+		return nil, userData, xerr
+	}
+
 	// The Default Network is the first of the provided list, by convention
 	defaultNetwork := request.Networks[0]
 	defaultNetworkID := defaultNetwork.ID
 	defaultGateway := request.DefaultGateway
-	isGateway := defaultGateway == nil && defaultNetwork.Name != abstract.SingleHostNetworkName
+	isGateway := request.IsGateway
 	defaultGatewayID := ""
 	defaultGatewayPrivateIP := ""
 	if defaultGateway != nil {
@@ -287,6 +398,16 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 		return nil, userData, err
 	}
 
+	// One network interface per requested network, the default one first
+	subnetworks := make([]string, 0, len(networks))
+	for _, n := range networks {
+		subnetworks = append(subnetworks, n.Name)
+	}
+	vpcs, err := s.subnetworkVPCs(subnetworks)
+	if err != nil {
+		return nil, userData, err
+	}
+
 	// --- Initializes abstract.Host ---
 
 	host = abstract.NewHost()
@@ -321,18 +442,23 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 		return nil, userData, err
 	}
 
+	serviceAccount, xerr := s.instanceServiceAccount()
+	if xerr != nil {
+		return nil, userData, xerr
+	}
+
 	// --- query provider for host creation ---
 
 	logrus.Debugf("requesting host resource creation...")
 	var desistError error
 
 	// Retry creation until success, for 10 minutes
-	retryErr := retry.WhileUnsuccessfulDelay5Seconds(
+	retryErr := s.RetryPolicy.WhileUnsuccessful(
 		func() error {
 			server, err := buildGcpMachine(
 				s.ComputeService, s.GcpConfig.ProjectID, request.ResourceName, rim.URL, s.GcpConfig.Region,
-				s.GcpConfig.Zone, s.GcpConfig.NetworkName, defaultNetwork.Name, string(userDataPhase1), isGateway,
-				template,
+				s.GcpConfig.Zone, vpcs, subnetworks, string(userDataPhase1), hostMustHavePublicIP, isGateway,
+				template, serviceAccount,
 			)
 			if err != nil {
 				if server != nil {
@@ -436,47 +562,225 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 	return host, userData, nil
 }
 
+// systemDiskType is the type of the system disk of the hosts (the default of GCP, buildGcpMachine sets none)
+const systemDiskType = "pd-standard"
+
+// defaultServiceAccount is the service account the instances run as when not set in the configuration
+const defaultServiceAccount = "default"
+
+var (
+	// defaultInstanceScopes are the scopes granted to the instances when not set in the configuration
+	defaultInstanceScopes = []string{compute.DevstorageFullControlScope, compute.ComputeScope}
+	// requiredInstanceScopes are the scopes the instances need for SafeScale to work
+	requiredInstanceScopes = []string{compute.ComputeScope}
+)
+
+// defaultPasswordLength is the length of the passwords generated for the hosts when not set in the configuration
+const defaultPasswordLength = 16
+
+// HostRequestFeasibility contains the outcome of ValidateHostRequest
+type HostRequestFeasibility struct {
+	// Template is the template requested, nil if not found
+	Template *abstract.HostTemplate
+	// Image is the image requested, nil if not found
+	Image *abstract.Image
+	// Zone is the availability zone the host would be created in
+	Zone string
+	// DiskType is the type of the system disk of the host
+	DiskType string
+	// Failures contains the error of each precondition not met, by precondition ("template", "image", "zone",
+	// "disk-type")
+	Failures map[string]error
+}
+
+// OK tells if the host request can be satisfied
+func (f *HostRequestFeasibility) OK() bool {
+	return len(f.Failures) == 0
+}
+
+// ValidateHostRequest checks the preconditions of the creation of the host described by request (template, image,
+// availability zone and type of the system disk) without creating anything
+// the preconditions not met are reported in the result; an error is returned only if the checks cannot be done
+func (s *Stack) ValidateHostRequest(request abstract.HostRequest) (*HostRequestFeasibility, fail.Error) {
+	result := &HostRequestFeasibility{DiskType: systemDiskType, Failures: map[string]error{}}
+
+	template, err := s.GetTemplate(request.TemplateID)
+	if err != nil {
+		result.Failures["template"] = err
+	} else {
+		result.Template = template
+	}
+
+	image, err := s.GetImage(request.ImageID)
+	if err != nil {
+		result.Failures["image"] = err
+	} else {
+		result.Image = image
+	}
+
+	zones, err := s.ListAvailabilityZones()
+	if err != nil {
+		return nil, err
+	}
+	result.Zone = s.GcpConfig.Zone
+	if result.Zone == "" {
+		// CreateHost would pick one of the zones, any available one will do
+		candidates := make([]string, 0, len(zones))
+		for zone, up := range zones {
+			if up {
+				candidates = append(candidates, zone)
+			}
+		}
+		if len(candidates) == 0 {
+			result.Failures["zone"] = fail.NotAvailableError("no availability zone is up")
+			return result, nil
+		}
+		sort.Strings(candidates)
+		result.Zone = candidates[0]
+	}
+	if up, ok := zones[result.Zone]; !ok {
+		result.Failures["zone"] = fail.NotFoundError(fmt.Sprintf("availability zone '%s' not found", result.Zone))
+		return result, nil
+	} else if !up {
+		result.Failures["zone"] = fail.NotAvailableError(fmt.Sprintf("availability zone '%s' is not up", result.Zone))
+		return result, nil
+	}
+
+	_, err = s.ComputeService.DiskTypes.Get(s.GcpConfig.ProjectID, result.Zone, systemDiskType).Do()
+	exists, err := existsFromGoogleError(err)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		result.Failures["disk-type"] = fail.NotFoundError(
+			fmt.Sprintf("disk type '%s' not available in zone '%s'", systemDiskType, result.Zone),
+		)
+	}
+	return result, nil
+}
+
+// HostCreationResult contains the outcome of the creation of one host requested to CreateHosts
+type HostCreationResult struct {
+	Host     *abstract.Host
+	UserData *userdata.Content
+	Err      error
+}
+
+// CreateHosts creates the hosts described by requests, at most maxConcurrency at the same time (no limit if
+// maxConcurrency <= 0)
+// results are in the same order as requests; the returned error aggregates the failures, without canceling
+// the creations that succeeded
+func (s *Stack) CreateHosts(requests []abstract.HostRequest, maxConcurrency int) ([]HostCreationResult, fail.Error) {
+	return createHosts(requests, maxConcurrency, s.CreateHost, s.DeleteHost)
+}
+
+// createHosts does the real work of CreateHosts, using 'create' and 'remove' to manage each host
+func createHosts(
+	requests []abstract.HostRequest, maxConcurrency int,
+	create func(abstract.HostRequest) (*abstract.Host, *userdata.Content, fail.Error), remove func(string) error,
+) ([]HostCreationResult, fail.Error) {
+	if maxConcurrency <= 0 || maxConcurrency > len(requests) {
+		maxConcurrency = len(requests)
+	}
+
+	results := make([]HostCreationResult, len(requests))
+	slots := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			request := requests[i]
+			host, userData, err := create(request)
+			if err != nil && host != nil && host.ID != "" && !request.KeepOnFailure {
+				logrus.Infof("Cleaning up on failure, deleting host '%s'", request.ResourceName)
+				if derr := remove(host.ID); derr != nil {
+					err = fail.AddConsequence(err, derr)
+				}
+				host = nil
+			}
+			results[i] = HostCreationResult{Host: host, UserData: userData, Err: err}
+		}(i)
+	}
+	wg.Wait()
+
+	var errs []error
+	for i, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fail.Wrap(r.Err, fmt.Sprintf("failed to create host '%s'", requests[i].ResourceName)))
+		}
+	}
+	if len(errs) > 0 {
+		return results, fail.ErrListError(errs)
+	}
+	return results, nil
+}
+
 // WaitHostReady waits an host achieve ready state
 // hostParam can be an ID of host, or an instance of *abstract.Host; any other type will return an utils.ErrInvalidParameter.
 func (s *Stack) WaitHostReady(hostParam interface{}, timeout time.Duration) (res *abstract.Host, xerr fail.Error) {
-	var host *abstract.Host
-	switch hostParam := hostParam.(type) {
-	case string:
-		host = abstract.NewHost()
-		host.ID = hostParam
-	case *abstract.Host:
-		host = hostParam
-	}
-	if host == nil {
-		return nil, fail.InvalidParameterError("hostParam", "must be a not-empty string or a *abstract.Host")
+	host, xerr := s.validateHostParam(hostParam)
+	if xerr != nil {
+		return nil, xerr
 	}
 
 	tracer := debug.NewTracer(nil, fmt.Sprintf("(%s)", host.ID), true).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &xerr)()
 
+	var probe HostProbe
+	if !s.StateOnlyReadiness {
+		probe = s.ReadinessProbe
+		if probe == nil {
+			probe = sshPortProbe
+		}
+	}
+	return waitHostReady(
+		host, func(h *abstract.Host) (*abstract.Host, fail.Error) {
+			return s.InspectHost(h)
+		}, probe, temporal.GetDefaultDelay(), timeout,
+	)
+}
+
+// waitHostReady does the real work of WaitHostReady: waits until 'inspect' reports the host as started then,
+// if 'probe' is not nil, until 'probe' succeeds
+func waitHostReady(
+	host *abstract.Host, inspect func(*abstract.Host) (*abstract.Host, fail.Error), probe HostProbe,
+	delay time.Duration, timeout time.Duration,
+) (*abstract.Host, fail.Error) {
 	retryErr := retry.WhileUnsuccessful(
 		func() error {
-			hostTmp, err := s.InspectHost(host)
+			hostTmp, err := inspect(host)
 			if err != nil {
 				return err
 			}
 
 			host = hostTmp
 			if host.LastState != hoststate.STARTED {
-				return fail.Errorf(fmt.Sprintf("not in ready state (current state: %s)", host.LastState.String()), nil)
+				return fail.NotAvailableError(fmt.Sprintf("not in ready state (current state: %s)", host.LastState.String()))
+			}
+			if probe != nil {
+				if err := probe(host); err != nil {
+					return fail.NotAvailableError(fmt.Sprintf("started but not reachable yet: %v", err))
+				}
 			}
 			return nil
 		},
-		temporal.GetDefaultDelay(),
+		delay,
 		timeout,
 	)
 	if retryErr != nil {
-		if _, ok := retryErr.(retry.ErrTimeout); ok {
-			return host, abstract.TimeoutError(
+		if timeoutErr, ok := retryErr.(retry.ErrTimeout); ok {
+			// keeps the last reason the host was not ready as cause, to help diagnosis
+			return host, fail.TimeoutError(
 				fmt.Sprintf(
 					"timeout waiting to get host '%s' information after %v", host.Name, timeout,
-				), timeout,
+				), timeout, timeoutErr.Cause(),
 			)
 		}
 		return host, retryErr
@@ -484,6 +788,19 @@ func (s *Stack) WaitHostReady(hostParam interface{}, timeout time.Duration) (res
 	return host, nil
 }
 
+// sshPortProbe checks the SSH port of the host accepts TCP connections
+func sshPortProbe(host *abstract.Host) error {
+	ip := host.GetAccessIP()
+	if ip == "" {
+		return fail.NotAvailableError(fmt.Sprintf("no IP address available for host '%s'", host.Name))
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, "22"), temporal.GetConnectionTimeout())
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 func publicAccess(isPublic bool) []*compute.AccessConfig {
 	if isPublic {
 		return []*compute.AccessConfig{
@@ -497,15 +814,81 @@ func publicAccess(isPublic bool) []*compute.AccessConfig {
 	return []*compute.AccessConfig{}
 }
 
+// subnetworkVPCs returns the URL of the VPC network of each subnetwork of 'subnetworks'; a single subnetwork is in
+// the VPC network of the stack, as all the subnetworks created by SafeScale
+func (s *Stack) subnetworkVPCs(subnetworks []string) ([]string, fail.Error) {
+	prefix := "https://www.googleapis.com/compute/v1/projects/" + s.GcpConfig.ProjectID
+	if len(subnetworks) == 1 {
+		return []string{prefix + "/global/networks/" + s.GcpConfig.NetworkName}, nil
+	}
+
+	vpcs := make([]string, 0, len(subnetworks))
+	for _, subnetwork := range subnetworks {
+		sn, err := s.ComputeService.Subnetworks.Get(s.GcpConfig.ProjectID, s.GcpConfig.Region, subnetwork).Fields("network").Do()
+		if err != nil {
+			if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == 404 {
+				return nil, abstract.ResourceNotFoundError("network", subnetwork)
+			}
+			return nil, fail.Errorf(fmt.Sprintf("cannot get network '%s': %v", subnetwork, err), err)
+		}
+		vpcs = append(vpcs, sn.Network)
+	}
+	return vpcs, nil
+}
+
+// buildNetworkInterfaces builds one network interface per subnetwork, in the VPC network of the subnetwork given at
+// the same index in 'vpcs'; the first one, the default network of the host, is the only one that may receive a
+// public access.
+// GCP requires the network interfaces of an instance to be in distinct VPC networks, so subnetworks sharing a VPC
+// network cannot be used together.
+func buildNetworkInterfaces(projectID string, region string, vpcs []string, subnetworks []string, isPublic bool) ([]*compute.NetworkInterface, fail.Error) {
+	if len(vpcs) != len(subnetworks) {
+		return nil, fail.InvalidParameterError("vpcs", "must contain the VPC network of each subnetwork")
+	}
+	prefix := "https://www.googleapis.com/compute/v1/projects/" + projectID
+
+	usedBy := map[string]string{}
+	nics := make([]*compute.NetworkInterface, 0, len(subnetworks))
+	for i, subnetwork := range subnetworks {
+		if other, ok := usedBy[vpcs[i]]; ok {
+			return nil, fail.InvalidRequestError(
+				fmt.Sprintf(
+					"networks '%s' and '%s' belong to the same VPC network '%s', a host cannot be attached to both",
+					other, subnetwork, getResourceNameFromSelfLink(genURL(vpcs[i])),
+				),
+			)
+		}
+		usedBy[vpcs[i]] = subnetwork
+
+		nics = append(
+			nics, &compute.NetworkInterface{
+				AccessConfigs: publicAccess(isPublic && i == 0),
+				Network:       vpcs[i],
+				Subnetwork:    prefix + "/regions/" + region + "/subnetworks/" + subnetwork,
+			},
+		)
+	}
+	return nics, nil
+}
+
 // buildGcpMachine ...
-func buildGcpMachine(service *compute.Service, projectID string, instanceName string, imageID string, region string, zone string, network string, subnetwork string, userdata string, isPublic bool, template *abstract.HostTemplate) (*abstract.Host, fail.Error) {
+func buildGcpMachine(service *compute.Service, projectID string, instanceName string, imageID string, region string, zone string, vpcs []string, subnetworks []string, userdata string, isPublic bool, isGateway bool, template *abstract.HostTemplate, serviceAccount *compute.ServiceAccount) (*abstract.Host, fail.Error) {
 	prefix := "https://www.googleapis.com/compute/v1/projects/" + projectID
 
+	if len(subnetworks) == 0 {
+		return nil, fail.InvalidParameterError("subnetworks", "cannot be empty")
+	}
+	nics, xerr := buildNetworkInterfaces(projectID, region, vpcs, subnetworks, isPublic)
+	if xerr != nil {
+		return nil, xerr
+	}
+
 	imageURL := imageID
 
+	// Hosts with a public IP reach internet by themselves, the others are routed through the gateway
 	tag := "nat"
 	if !isPublic {
-		tag = fmt.Sprintf("no-ip-%s", subnetwork)
+		tag = fmt.Sprintf("no-ip-%s", subnetworks[0])
 	}
 
 	// logrus.Warnf("Receiving a disk request of %d", template.DiskSize)
@@ -514,7 +897,7 @@ func buildGcpMachine(service *compute.Service, projectID string, instanceName st
 		Name:         instanceName,
 		Description:  "compute sample instance",
 		MachineType:  prefix + "/zones/" + zone + "/machineTypes/" + template.Name,
-		CanIpForward: isPublic,
+		CanIpForward: isGateway,
 		Tags: &compute.Tags{
 			Items: []string{tag},
 		},
@@ -530,22 +913,8 @@ func buildGcpMachine(service *compute.Service, projectID string, instanceName st
 				},
 			},
 		},
-		NetworkInterfaces: []*compute.NetworkInterface{
-			{
-				AccessConfigs: publicAccess(isPublic),
-				Network:       prefix + "/global/networks/" + network,
-				Subnetwork:    prefix + "/regions/" + region + "/subnetworks/" + subnetwork,
-			},
-		},
-		ServiceAccounts: []*compute.ServiceAccount{
-			{
-				Email: "default",
-				Scopes: []string{
-					compute.DevstorageFullControlScope,
-					compute.ComputeScope,
-				},
-			},
-		},
+		NetworkInterfaces: nics,
+		ServiceAccounts:   []*compute.ServiceAccount{serviceAccount},
 		Metadata: &compute.Metadata{
 			Items: []*compute.MetadataItems{
 				{
@@ -592,23 +961,88 @@ func buildGcpMachine(service *compute.Service, projectID string, instanceName st
 	return host, nil
 }
 
-// InspectHost returns the host identified by ref (name or id) or by a *abstract.Host containing an id
-func (s *Stack) InspectHost(hostParam interface{}) (host *abstract.Host, xerr fail.Error) {
+// checkInstance returns an error if the stack is nil or not initialized
+func (s *Stack) checkInstance() fail.Error {
+	if s == nil {
+		return fail.InvalidInstanceError()
+	}
+	if s.ComputeService == nil || s.GcpConfig == nil {
+		return fail.InvalidInstanceContentError("s", "is not initialized")
+	}
+	return nil
+}
+
+// validateHostParam checks the stack is usable and returns the host designated by 'hostParam', which must be a
+// not-empty ID of host or a not-nil *abstract.Host
+func (s *Stack) validateHostParam(hostParam interface{}) (*abstract.Host, fail.Error) {
+	if xerr := s.checkInstance(); xerr != nil {
+		return nil, xerr
+	}
+
 	switch hostParam := hostParam.(type) {
 	case string:
 		if hostParam == "" {
 			return nil, fail.InvalidParameterError("hostParam", "cannot be an empty string")
 		}
-		host = abstract.NewHost()
+		host := abstract.NewHost()
 		host.ID = hostParam
+		return host, nil
 	case *abstract.Host:
 		if hostParam == nil {
 			return nil, fail.InvalidParameterError("hostParam", "cannot be nil")
 		}
-		host = hostParam
+		return hostParam, nil
 	default:
 		return nil, fail.InvalidParameterError("hostParam", "must be a string or a *abstract.Host")
 	}
+}
+
+// interfacesIPs returns the private and public IPv4 and IPv6 addresses of the network interfaces of an instance,
+// by subnetwork; the IPv6 addresses are empty for the interfaces without IPv6
+func interfacesIPs(nics []*compute.NetworkInterface) []IPInSubnet {
+	var subnets []IPInSubnet
+	for _, nit := range nics {
+		if nit == nil {
+			continue
+		}
+		snet := genURL(nit.Subnetwork)
+		if utils.IsEmpty(snet) {
+			continue
+		}
+		pubIP := ""
+		for _, aco := range nit.AccessConfigs {
+			if aco != nil {
+				if aco.NatIP != "" {
+					pubIP = aco.NatIP
+				}
+			}
+		}
+		pubIPv6 := ""
+		for _, aco := range nit.Ipv6AccessConfigs {
+			if aco != nil && aco.ExternalIpv6 != "" {
+				pubIPv6 = aco.ExternalIpv6
+			}
+		}
+
+		subnets = append(
+			subnets, IPInSubnet{
+				Subnet:     snet,
+				IP:         nit.NetworkIP,
+				PublicIP:   pubIP,
+				IPv6:       nit.Ipv6Address,
+				PublicIPv6: pubIPv6,
+			},
+		)
+	}
+	return subnets
+}
+
+// InspectHost returns the host identified by ref (name or id) or by a *abstract.Host containing an id
+func (s *Stack) InspectHost(hostParam interface{}) (host *abstract.Host, xerr fail.Error) {
+	host, xerr = s.validateHostParam(hostParam)
+	if xerr != nil {
+		return nil, xerr
+	}
 
 	hostRef := host.Name
 	if hostRef == "" {
@@ -631,29 +1065,7 @@ func (s *Stack) InspectHost(hostParam interface{}) (host *abstract.Host, xerr fa
 
 	host.Name = gcpHost.Name
 
-	var subnets []IPInSubnet
-
-	for _, nit := range gcpHost.NetworkInterfaces {
-		snet := genURL(nit.Subnetwork)
-		if !utils.IsEmpty(snet) {
-			pubIP := ""
-			for _, aco := range nit.AccessConfigs {
-				if aco != nil {
-					if aco.NatIP != "" {
-						pubIP = aco.NatIP
-					}
-				}
-			}
-
-			subnets = append(
-				subnets, IPInSubnet{
-					Subnet:   snet,
-					IP:       nit.NetworkIP,
-					PublicIP: pubIP,
-				},
-			)
-		}
-	}
+	subnets := interfacesIPs(gcpHost.NetworkInterfaces)
 
 	var resouceNetworks []IPInSubnet
 	for _, sn := range subnets {
@@ -670,39 +1082,56 @@ func (s *Stack) InspectHost(hostParam interface{}) (host *abstract.Host, xerr fa
 
 		resouceNetworks = append(
 			resouceNetworks, IPInSubnet{
-				Subnet:   sn.Subnet,
-				Name:     psg.Name,
-				ID:       strconv.FormatUint(psg.Id, 10),
-				IP:       sn.IP,
-				PublicIP: sn.PublicIP,
+				Subnet:     sn.Subnet,
+				Name:       psg.Name,
+				ID:         strconv.FormatUint(psg.Id, 10),
+				IP:         sn.IP,
+				PublicIP:   sn.PublicIP,
+				IPv6:       sn.IPv6,
+				PublicIPv6: sn.PublicIPv6,
 			},
 		)
 	}
 
 	ip4bynetid := make(map[string]string)
+	ip6bynetid := make(map[string]string)
 	netnamebyid := make(map[string]string)
 	netidbyname := make(map[string]string)
 
 	ipv4 := ""
+	ipv6 := ""
 	for _, rn := range resouceNetworks {
 		ip4bynetid[rn.ID] = rn.IP
+		if rn.IPv6 != "" {
+			ip6bynetid[rn.ID] = rn.IPv6
+		}
 		netnamebyid[rn.ID] = rn.Name
 		netidbyname[rn.Name] = rn.ID
-		if rn.PublicIP != "" {
+		if rn.PublicIP != "" && ipv4 == "" {
 			ipv4 = rn.PublicIP
 		}
+		if rn.PublicIPv6 != "" && ipv6 == "" {
+			ipv6 = rn.PublicIPv6
+		}
 	}
 
 	err = host.Properties.LockForWrite(hostproperty.NetworkV1).ThenUse(
 		func(clonable data.Clonable) error {
 			hostNetworkV1 := clonable.(*propsv1.HostNetwork)
 			hostNetworkV1.IPv4Addresses = ip4bynetid
-			hostNetworkV1.IPv6Addresses = make(map[string]string)
+			hostNetworkV1.IPv6Addresses = ip6bynetid
 			hostNetworkV1.NetworksByID = netnamebyid
 			hostNetworkV1.NetworksByName = netidbyname
 			if hostNetworkV1.PublicIPv4 == "" {
 				hostNetworkV1.PublicIPv4 = ipv4
 			}
+			if hostNetworkV1.PublicIPv6 == "" {
+				hostNetworkV1.PublicIPv6 = ipv6
+			}
+			// Interfaces are built in the order of the requested networks, the first one being the default network
+			if hostNetworkV1.DefaultNetworkID == "" && len(resouceNetworks) > 0 {
+				hostNetworkV1.DefaultNetworkID = resouceNetworks[0].ID
+			}
 			return nil
 		},
 	)
@@ -761,12 +1190,19 @@ func stateConvert(gcpHostStatus string) (hoststate.Enum, fail.Error) {
 	case "TERMINATED":
 		return hoststate.STOPPED, nil
 	default:
-		return -1, fail.Errorf(fmt.Sprintf("unexpected host status: [%s]", gcpHostStatus), nil)
+		return hoststate.UNKNOWN, fail.Errorf(fmt.Sprintf("unexpected host status: [%s]", gcpHostStatus), nil)
 	}
 }
 
 // GetHostByName returns the host identified by ref (name or id)
 func (s *Stack) GetHostByName(name string) (*abstract.Host, fail.Error) {
+	if xerr := s.checkInstance(); xerr != nil {
+		return nil, xerr
+	}
+	if name == "" {
+		return nil, fail.InvalidParameterError("name", "cannot be an empty string")
+	}
+
 	hosts, err := s.ListHosts()
 	if err != nil {
 		return nil, err
@@ -781,6 +1217,139 @@ func (s *Stack) GetHostByName(name string) (*abstract.Host, fail.Error) {
 	return nil, abstract.ResourceNotFoundError("host", name)
 }
 
+// hostIDCache keeps the IDs of the hosts by name
+type hostIDCache struct {
+	lock   sync.Mutex
+	byName map[string]string
+}
+
+func (c *hostIDCache) get(name string) (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	id, ok := c.byName[name]
+	return id, ok
+}
+
+func (c *hostIDCache) set(name, id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.byName == nil {
+		c.byName = map[string]string{}
+	}
+	c.byName[name] = id
+}
+
+// forget removes the entries whose name or ID is 'ref'
+func (c *hostIDCache) forget(ref string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for name, id := range c.byName {
+		if name == ref || id == ref {
+			delete(c.byName, name)
+		}
+	}
+}
+
+// GetHostID returns the ID of the host named 'name'
+// IDs are cached; the cache entry of a host is invalidated when a host with the same name is created or
+// when the host is deleted
+func (s *Stack) GetHostID(name string) (string, fail.Error) {
+	if name == "" {
+		return "", fail.InvalidParameterError("name", "cannot be empty string")
+	}
+	if id, ok := s.hostIDs.get(name); ok {
+		return id, nil
+	}
+
+	resp, err := s.ComputeService.Instances.List(s.GcpConfig.ProjectID, s.GcpConfig.Zone).Filter(
+		fmt.Sprintf("name = %q", name),
+	).Fields("items(id,name)").Do()
+	if err != nil {
+		return "", fail.Errorf(fmt.Sprintf("cannot list hosts: %v", err), err)
+	}
+	for _, instance := range resp.Items {
+		if instance.Name == name {
+			id := strconv.FormatUint(instance.Id, 10)
+			s.hostIDs.set(name, id)
+			return id, nil
+		}
+	}
+	return "", abstract.ResourceNotFoundError("host", name)
+}
+
+// HostExists tells if the host identified by id exists, using a single Get of the instance
+func (s *Stack) HostExists(id string) (bool, fail.Error) {
+	if id == "" {
+		return false, fail.InvalidParameterError("id", "cannot be empty string")
+	}
+
+	_, err := s.ComputeService.Instances.Get(s.GcpConfig.ProjectID, s.GcpConfig.Zone, id).Fields("id").Do()
+	return existsFromGoogleError(err)
+}
+
+// GetHostPrivateIP returns the private IP of the host on its default network, reading only the network
+// interfaces of the instance (a single Get, without the resolution of the subnetworks done by InspectHost)
+func (s *Stack) GetHostPrivateIP(id string) (string, fail.Error) {
+	interfaces, err := s.getHostNetworkInterfaces(id)
+	if err != nil {
+		return "", err
+	}
+	for _, nit := range interfaces {
+		if nit != nil && nit.NetworkIP != "" {
+			return nit.NetworkIP, nil
+		}
+	}
+	return "", fail.NotFoundError(fmt.Sprintf("host '%s' has no private IP", id))
+}
+
+// GetHostPublicIP returns the public IP of the host, reading only the network interfaces of the instance
+func (s *Stack) GetHostPublicIP(id string) (string, fail.Error) {
+	interfaces, err := s.getHostNetworkInterfaces(id)
+	if err != nil {
+		return "", err
+	}
+	for _, nit := range interfaces {
+		if nit == nil {
+			continue
+		}
+		for _, aco := range nit.AccessConfigs {
+			if aco != nil && aco.NatIP != "" {
+				return aco.NatIP, nil
+			}
+		}
+	}
+	return "", fail.NotFoundError(fmt.Sprintf("host '%s' has no public IP", id))
+}
+
+// getHostNetworkInterfaces gets the network interfaces of the instance, and nothing else
+func (s *Stack) getHostNetworkInterfaces(id string) ([]*compute.NetworkInterface, fail.Error) {
+	if id == "" {
+		return nil, fail.InvalidParameterError("id", "cannot be empty string")
+	}
+
+	instance, err := s.ComputeService.Instances.Get(s.GcpConfig.ProjectID, s.GcpConfig.Zone, id).Fields(
+		"networkInterfaces(networkIP,accessConfigs(natIP))",
+	).Do()
+	if err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == 404 {
+			return nil, abstract.ResourceNotFoundError("host", id)
+		}
+		return nil, fail.Errorf(fmt.Sprintf("cannot get host '%s': %v", id, err), err)
+	}
+	return instance.NetworkInterfaces, nil
+}
+
+// existsFromGoogleError converts the error of a Get on a resource to the existence of this resource
+func existsFromGoogleError(err error) (bool, fail.Error) {
+	if err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == 404 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // DeleteHost deletes the host identified by id
 func (s *Stack) DeleteHost(id string) (err error) {
 	service := s.ComputeService
@@ -788,13 +1357,22 @@ func (s *Stack) DeleteHost(id string) (err error) {
 	zone := s.GcpConfig.Zone
 	instanceName := id
 
-	_, err = service.Instances.Get(projectID, zone, instanceName).Do()
-	if err != nil {
-		return err
-	}
+	defer s.hostIDs.forget(id)
 
+	// Deletes directly, no need to check the instance exists first
 	op, err := service.Instances.Delete(projectID, zone, instanceName).Do()
 	if err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == 404 {
+			logrus.Debugf("instance [%s] already deleted", instanceName)
+			return nil
+		}
+		// Gets the instance only to help diagnose the failure
+		inst, getErr := service.Instances.Get(projectID, zone, instanceName).Do()
+		if getErr != nil {
+			logrus.Debugf("failed to delete instance [%s], and failed to get it: %v", instanceName, getErr)
+		} else {
+			logrus.Debugf("failed to delete instance [%s] in status '%s'", instanceName, inst.Status)
+		}
 		return err
 	}
 
@@ -860,31 +1438,52 @@ func (s *Stack) ListHosts() ([]*abstract.Host, fail.Error) {
 	return hostList, nil
 }
 
-// StopHost stops the host identified by id
+// StopHost stops the host identified by id; stopping a host already stopped does nothing
 func (s *Stack) StopHost(id string) error {
-	service := s.ComputeService
-
-	op, err := service.Instances.Stop(s.GcpConfig.ProjectID, s.GcpConfig.Zone, id).Do()
+	state, err := s.getHostStatus(id)
 	if err != nil {
 		return err
 	}
+	if state == hoststate.STOPPED {
+		logrus.Debugf("host '%s' is already stopped", id)
+		return nil
+	}
+	return s.stopInstance(id)
+}
 
-	oco := OpContext{
-		Operation:    op,
-		ProjectID:    s.GcpConfig.ProjectID,
-		Service:      service,
-		DesiredState: "DONE",
+// StartHost starts the host identified by id; starting a host already started does nothing
+func (s *Stack) StartHost(id string) error {
+	state, err := s.getHostStatus(id)
+	if err != nil {
+		return err
+	}
+	if state == hoststate.STARTED {
+		logrus.Debugf("host '%s' is already started", id)
+		return nil
 	}
+	return s.startInstance(id)
+}
 
-	err = waitUntilOperationIsSuccessfulOrTimeout(oco, temporal.GetMinDelay(), temporal.GetHostTimeout())
-	return err
+// RebootHost reboot the host identified by id; a stopped host is only started
+func (s *Stack) RebootHost(id string) error {
+	state, err := s.getHostStatus(id)
+	if err != nil {
+		return err
+	}
+	if state != hoststate.STOPPED {
+		err = s.stopInstance(id)
+		if err != nil {
+			return err
+		}
+	}
+	return s.startInstance(id)
 }
 
-// StartHost starts the host identified by id
-func (s *Stack) StartHost(id string) error {
+// stopInstance stops the instance identified by id and waits for the end of the operation
+func (s *Stack) stopInstance(id string) error {
 	service := s.ComputeService
 
-	op, err := service.Instances.Start(s.GcpConfig.ProjectID, s.GcpConfig.Zone, id).Do()
+	op, err := service.Instances.Stop(s.GcpConfig.ProjectID, s.GcpConfig.Zone, id).Do()
 	if err != nil {
 		return err
 	}
@@ -896,15 +1495,14 @@ func (s *Stack) StartHost(id string) error {
 		DesiredState: "DONE",
 	}
 
-	err = waitUntilOperationIsSuccessfulOrTimeout(oco, temporal.GetMinDelay(), temporal.GetHostTimeout())
-	return err
+	return waitUntilOperationIsSuccessfulOrTimeout(oco, temporal.GetMinDelay(), temporal.GetHostTimeout())
 }
 
-// RebootHost reboot the host identified by id
-func (s *Stack) RebootHost(id string) error {
+// startInstance starts the instance identified by id and waits for the end of the operation
+func (s *Stack) startInstance(id string) error {
 	service := s.ComputeService
 
-	op, err := service.Instances.Stop(s.GcpConfig.ProjectID, s.GcpConfig.Zone, id).Do()
+	op, err := service.Instances.Start(s.GcpConfig.ProjectID, s.GcpConfig.Zone, id).Do()
 	if err != nil {
 		return err
 	}
@@ -916,25 +1514,23 @@ func (s *Stack) RebootHost(id string) error {
 		DesiredState: "DONE",
 	}
 
-	err = waitUntilOperationIsSuccessfulOrTimeout(oco, temporal.GetMinDelay(), temporal.GetHostTimeout())
-	if err != nil {
-		return err
-	}
+	return waitUntilOperationIsSuccessfulOrTimeout(oco, temporal.GetMinDelay(), temporal.GetHostTimeout())
+}
 
-	op, err = service.Instances.Start(s.GcpConfig.ProjectID, s.GcpConfig.Zone, id).Do()
-	if err != nil {
-		return err
+// getHostStatus returns the state of the host identified by id, reading only the status of the instance
+func (s *Stack) getHostStatus(id string) (hoststate.Enum, fail.Error) {
+	if id == "" {
+		return hoststate.ERROR, fail.InvalidParameterError("id", "cannot be empty string")
 	}
 
-	oco = OpContext{
-		Operation:    op,
-		ProjectID:    s.GcpConfig.ProjectID,
-		Service:      service,
-		DesiredState: "DONE",
+	instance, err := s.ComputeService.Instances.Get(s.GcpConfig.ProjectID, s.GcpConfig.Zone, id).Fields("status").Do()
+	if err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == 404 {
+			return hoststate.ERROR, abstract.ResourceNotFoundError("host", id)
+		}
+		return hoststate.ERROR, fail.Errorf(fmt.Sprintf("cannot get host '%s': %v", id, err), err)
 	}
-
-	err = waitUntilOperationIsSuccessfulOrTimeout(oco, temporal.GetMinDelay(), temporal.GetHostTimeout())
-	return err
+	return stateConvert(instance.Status)
 }
 
 // GetHostState returns the host identified by id
@@ -0,0 +1,1101 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hostproperty"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hoststate"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/userdata"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+// safescaleVPC is the URL of the VPC network of the fake stack
+const safescaleVPC = "https://www.googleapis.com/compute/v1/projects/my-project/global/networks/safescale"
+
+func TestBuildNetworkInterfaces(t *testing.T) {
+	otherVPC := "https://www.googleapis.com/compute/v1/projects/my-project/global/networks/backend"
+	nics, err := buildNetworkInterfaces(
+		"my-project", "europe-west1", []string{safescaleVPC, otherVPC}, []string{"net-front", "net-back"}, true,
+	)
+	require.Nil(t, err)
+	require.Len(t, nics, 2)
+
+	prefix := "https://www.googleapis.com/compute/v1/projects/my-project"
+	assert.Equal(t, safescaleVPC, nics[0].Network)
+	assert.Equal(t, prefix+"/regions/europe-west1/subnetworks/net-front", nics[0].Subnetwork)
+	assert.Equal(t, otherVPC, nics[1].Network)
+	assert.Equal(t, prefix+"/regions/europe-west1/subnetworks/net-back", nics[1].Subnetwork)
+
+	// Only the default network gets a public access
+	require.Len(t, nics[0].AccessConfigs, 1)
+	assert.Equal(t, "ONE_TO_ONE_NAT", nics[0].AccessConfigs[0].Type)
+	assert.Empty(t, nics[1].AccessConfigs)
+}
+
+func TestBuildNetworkInterfaces_Private(t *testing.T) {
+	nics, err := buildNetworkInterfaces("my-project", "europe-west1", []string{safescaleVPC}, []string{"net-front"}, false)
+	require.Nil(t, err)
+	require.Len(t, nics, 1)
+	assert.Empty(t, nics[0].AccessConfigs)
+}
+
+func TestBuildNetworkInterfaces_SameVPC(t *testing.T) {
+	// GCP rejects an instance with 2 network interfaces in the same VPC network
+	_, err := buildNetworkInterfaces(
+		"my-project", "europe-west1", []string{safescaleVPC, safescaleVPC}, []string{"net-front", "net-back"}, false,
+	)
+	assert.IsType(t, fail.ErrInvalidRequest{}, err)
+	assert.Contains(t, err.Error(), "'net-front' and 'net-back'")
+
+	_, err = buildNetworkInterfaces("my-project", "europe-west1", []string{safescaleVPC}, []string{"net-front", "net-back"}, false)
+	assert.IsType(t, fail.ErrInvalidParameter{}, err)
+}
+
+func TestSubnetworkVPCs(t *testing.T) {
+	api := &fakeComputeAPI{
+		getStatus: http.StatusOK,
+		found:     []string{"subnetworks"},
+		subnetworkVPCs: map[string]string{
+			"net-front": safescaleVPC,
+			"net-back":  "https://www.googleapis.com/compute/v1/projects/my-project/global/networks/backend",
+		},
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+	stack.GcpConfig.NetworkName = "safescale"
+
+	// a single subnetwork is in the VPC network of the stack
+	vpcs, err := stack.subnetworkVPCs([]string{"net-front"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{safescaleVPC}, vpcs)
+	assert.Empty(t, api.calls)
+
+	vpcs, err = stack.subnetworkVPCs([]string{"net-front", "net-back"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{safescaleVPC, api.subnetworkVPCs["net-back"]}, vpcs)
+
+	_, err = stack.subnetworkVPCs([]string{"net-front", "net-missing"})
+	assert.IsType(t, fail.ErrNotFound{}, err)
+}
+
+// fakeComputeAPI counts the calls done on instances by the GCP stack
+type fakeComputeAPI struct {
+	sync.Mutex
+	calls        []string
+	deleteStatus int
+	// getStatus is the status returned on Get of resources (404 if not set)
+	getStatus int
+	// found lists the kinds of resources found ("instances", "networks", "subnetworks") when getStatus is 200
+	found []string
+	// subnetworkVPCs contains the URL of the VPC network of the subnetworks returned by Get, by name; a subnetwork
+	// not in the map is not found if the map is set
+	subnetworkVPCs map[string]string
+	// instances contains the IDs of the instances returned by list, by name
+	instances map[string]uint64
+	// instance is the body returned on Get of an instance found (a minimal instance if not set)
+	instance string
+	// inserted contains the instances received by Insert
+	inserted []*compute.Instance
+	// catalog contains the bodies returned on List of images, machine types and zones and on Get of disk types, by
+	// kind ("images", "machineTypes", "zones", "diskTypes"); an empty list (404 for disk types) if not set
+	catalog map[string]string
+	// regionsStatus is the status returned on List of regions (200 if not set)
+	regionsStatus int
+	// tags contains the tags received by SetTags
+	tags *compute.Tags
+	// firewalls contains the names of the firewall rules returned by List
+	firewalls []string
+	// insertedFirewalls contains the firewall rules received by Insert
+	insertedFirewalls []*compute.Firewall
+	// rejectedFirewalls contains the names of the firewall rules failing to be inserted
+	rejectedFirewalls []string
+	// updatedFirewalls and deletedFirewalls contain the names of the firewall rules updated and deleted
+	updatedFirewalls []string
+	deletedFirewalls []string
+}
+
+func (f *fakeComputeAPI) get(w http.ResponseWriter, kind string) {
+	status := f.getStatus
+	if status == 0 {
+		status = http.StatusNotFound
+	}
+	if status == http.StatusOK {
+		for _, k := range f.found {
+			if k == kind {
+				if kind == "instances" && f.instance != "" {
+					_, _ = fmt.Fprint(w, f.instance)
+					return
+				}
+				_, _ = fmt.Fprint(w, `{"id": "1234"}`)
+				return
+			}
+		}
+		status = http.StatusNotFound
+	}
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `{"error": {"code": %d, "message": "failure"}}`, status)
+}
+
+func (f *fakeComputeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case strings.Contains(r.URL.Path, "/instances/") && r.Method == http.MethodDelete:
+		f.calls = append(f.calls, "delete")
+		if f.deleteStatus != http.StatusOK {
+			w.WriteHeader(f.deleteStatus)
+			_, _ = fmt.Fprintf(w, `{"error": {"code": %d, "message": "failure"}}`, f.deleteStatus)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"name": "op-delete", "status": "RUNNING"}`)
+	case strings.Contains(r.URL.Path, "/instances/") && r.Method == http.MethodGet:
+		f.calls = append(f.calls, "get")
+		f.get(w, "instances")
+	case strings.Contains(r.URL.Path, "/subnetworks/") && r.Method == http.MethodGet:
+		f.calls = append(f.calls, "get-subnetwork")
+		if f.subnetworkVPCs != nil {
+			name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			if vpc, ok := f.subnetworkVPCs[name]; ok {
+				_, _ = fmt.Fprintf(w, `{"id": "1234", "name": "%s", "network": "%s"}`, name, vpc)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error": {"code": 404, "message": "not found"}}`)
+			return
+		}
+		f.get(w, "subnetworks")
+	case strings.Contains(r.URL.Path, "/networks/") && r.Method == http.MethodGet:
+		f.calls = append(f.calls, "get-network")
+		f.get(w, "networks")
+	case strings.HasSuffix(r.URL.Path, "/instances") && r.Method == http.MethodPost:
+		f.calls = append(f.calls, "insert")
+		instance := &compute.Instance{}
+		if err := json.NewDecoder(r.Body).Decode(instance); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.inserted = append(f.inserted, instance)
+		_, _ = fmt.Fprint(w, `{"name": "op-insert", "status": "DONE"}`)
+	case strings.HasSuffix(r.URL.Path, "/instances") && r.Method == http.MethodGet:
+		f.calls = append(f.calls, "list")
+		var items []string
+		for name, id := range f.instances {
+			if strings.Contains(r.URL.Query().Get("filter"), fmt.Sprintf("%q", name)) {
+				items = append(items, fmt.Sprintf(`{"id": "%d", "name": "%s"}`, id, name))
+			}
+		}
+		_, _ = fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(items, ","))
+	case strings.Contains(r.URL.Path, "/instances/") && strings.HasSuffix(r.URL.Path, "/stop") && r.Method == http.MethodPost:
+		f.calls = append(f.calls, "stop")
+		_, _ = fmt.Fprint(w, `{"name": "op-stop", "status": "DONE"}`)
+	case strings.Contains(r.URL.Path, "/instances/") && strings.HasSuffix(r.URL.Path, "/start") && r.Method == http.MethodPost:
+		f.calls = append(f.calls, "start")
+		_, _ = fmt.Fprint(w, `{"name": "op-start", "status": "DONE"}`)
+	case strings.HasSuffix(r.URL.Path, "/global/images") && r.Method == http.MethodGet:
+		f.listCatalog(w, "images")
+	case strings.HasSuffix(r.URL.Path, "/machineTypes") && r.Method == http.MethodGet:
+		f.listCatalog(w, "machineTypes")
+	case strings.HasSuffix(r.URL.Path, "/regions") && r.Method == http.MethodGet:
+		f.calls = append(f.calls, "list-regions")
+		if f.regionsStatus != 0 && f.regionsStatus != http.StatusOK {
+			w.WriteHeader(f.regionsStatus)
+			_, _ = fmt.Fprintf(w, `{"error": {"code": %d, "message": "failure"}}`, f.regionsStatus)
+			return
+		}
+		f.listCatalog(w, "regions")
+	case strings.HasSuffix(r.URL.Path, "/zones") && r.Method == http.MethodGet:
+		f.listCatalog(w, "zones")
+	case strings.Contains(r.URL.Path, "/diskTypes/") && r.Method == http.MethodGet:
+		f.calls = append(f.calls, "get-disktype")
+		if body, ok := f.catalog["diskTypes"]; ok {
+			_, _ = fmt.Fprint(w, body)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprint(w, `{"error": {"code": 404, "message": "failure"}}`)
+	case strings.Contains(r.URL.Path, "/instances/") && strings.HasSuffix(r.URL.Path, "/setTags") && r.Method == http.MethodPost:
+		f.calls = append(f.calls, "set-tags")
+		f.tags = &compute.Tags{}
+		if err := json.NewDecoder(r.Body).Decode(f.tags); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"name": "op-set-tags", "status": "DONE"}`)
+	case strings.HasSuffix(r.URL.Path, "/global/firewalls") && r.Method == http.MethodPost:
+		f.calls = append(f.calls, "insert-firewall")
+		firewall := &compute.Firewall{}
+		if err := json.NewDecoder(r.Body).Decode(firewall); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if indexOf(firewall.Name, f.rejectedFirewalls) >= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.insertedFirewalls = append(f.insertedFirewalls, firewall)
+		_, _ = fmt.Fprint(w, `{"name": "op-insert-firewall", "status": "DONE"}`)
+	case strings.HasSuffix(r.URL.Path, "/global/firewalls") && r.Method == http.MethodGet:
+		f.calls = append(f.calls, "list-firewalls")
+		var items []string
+		for _, name := range f.firewalls {
+			items = append(items, fmt.Sprintf(`{"name": "%s"}`, name))
+		}
+		_, _ = fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(items, ","))
+	case strings.Contains(r.URL.Path, "/global/firewalls/") && r.Method == http.MethodPut:
+		f.updatedFirewalls = append(f.updatedFirewalls, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		_, _ = fmt.Fprint(w, `{"name": "op-update-firewall", "status": "DONE"}`)
+	case strings.Contains(r.URL.Path, "/global/firewalls/") && r.Method == http.MethodDelete:
+		f.deletedFirewalls = append(f.deletedFirewalls, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		_, _ = fmt.Fprint(w, `{"name": "op-delete-firewall", "status": "DONE"}`)
+	case strings.Contains(r.URL.Path, "/operations/"):
+		_, _ = fmt.Fprint(w, `{"name": "op-delete", "status": "DONE"}`)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeComputeAPI) listCatalog(w http.ResponseWriter, kind string) {
+	if body, ok := f.catalog[kind]; ok {
+		_, _ = fmt.Fprint(w, body)
+		return
+	}
+	_, _ = fmt.Fprint(w, `{"items": []}`)
+}
+
+func newFakeStack(t *testing.T, api *fakeComputeAPI) (*Stack, func()) {
+	srv := httptest.NewServer(api)
+	svc, err := compute.NewService(
+		context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()),
+	)
+	require.Nil(t, err)
+	return &Stack{
+		GcpConfig:      &stacks.GCPConfiguration{ProjectID: "my-project", Zone: "europe-west1-b"},
+		ComputeService: svc,
+	}, srv.Close
+}
+
+func TestDeleteHost_SingleDelete(t *testing.T) {
+	api := &fakeComputeAPI{deleteStatus: http.StatusOK}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	err := stack.DeleteHost("host-1")
+	require.Nil(t, err)
+	require.NotEmpty(t, api.calls)
+	assert.Equal(t, "delete", api.calls[0])
+	deletes := 0
+	for _, c := range api.calls {
+		if c == "delete" {
+			deletes++
+		}
+	}
+	assert.Equal(t, 1, deletes)
+}
+
+func TestDeleteHost_NotFoundIsSuccess(t *testing.T) {
+	api := &fakeComputeAPI{deleteStatus: http.StatusNotFound}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	err := stack.DeleteHost("host-1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"delete"}, api.calls)
+}
+
+func TestDeleteHost_UnexpectedError(t *testing.T) {
+	api := &fakeComputeAPI{deleteStatus: http.StatusInternalServerError}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	err := stack.DeleteHost("host-1")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"delete", "get"}, api.calls)
+}
+
+func TestCreateHosts_BoundedConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	create := func(request abstract.HostRequest) (*abstract.Host, *userdata.Content, fail.Error) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return &abstract.Host{ID: request.ResourceName + "-id", Name: request.ResourceName}, nil, nil
+	}
+	remove := func(id string) error {
+		t.Errorf("unexpected deletion of host '%s'", id)
+		return nil
+	}
+
+	var requests []abstract.HostRequest
+	for i := 0; i < 8; i++ {
+		requests = append(requests, abstract.HostRequest{ResourceName: fmt.Sprintf("host-%d", i)})
+	}
+	results, err := createHosts(requests, 3, create, remove)
+	require.Nil(t, err)
+	require.Len(t, results, 8)
+	for i, r := range results {
+		assert.Nil(t, r.Err)
+		assert.Equal(t, fmt.Sprintf("host-%d", i), r.Host.Name)
+	}
+	assert.True(t, maxInFlight <= 3)
+	assert.True(t, maxInFlight > 1)
+}
+
+func TestCreateHosts_PartialFailures(t *testing.T) {
+	create := func(request abstract.HostRequest) (*abstract.Host, *userdata.Content, fail.Error) {
+		switch request.ResourceName {
+		case "broken":
+			// host resource created on provider side, but failed afterwards
+			return &abstract.Host{ID: "broken-id"}, nil, fail.TimeoutError("host not ready", 0, nil)
+		case "kept":
+			return &abstract.Host{ID: "kept-id"}, nil, fail.TimeoutError("host not ready", 0, nil)
+		case "refused":
+			return nil, nil, fail.OverloadError("quota exceeded")
+		}
+		return &abstract.Host{ID: request.ResourceName + "-id", Name: request.ResourceName}, nil, nil
+	}
+	var (
+		lock    sync.Mutex
+		deleted []string
+	)
+	remove := func(id string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		deleted = append(deleted, id)
+		return nil
+	}
+
+	requests := []abstract.HostRequest{
+		{ResourceName: "ok-1"},
+		{ResourceName: "broken"},
+		{ResourceName: "refused"},
+		{ResourceName: "kept", KeepOnFailure: true},
+		{ResourceName: "ok-2"},
+	}
+	results, err := createHosts(requests, 2, create, remove)
+	require.NotNil(t, err)
+	require.Len(t, results, 5)
+
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, "ok-1", results[0].Host.Name)
+	assert.NotNil(t, results[1].Err)
+	assert.Nil(t, results[1].Host)
+	assert.NotNil(t, results[2].Err)
+	assert.NotNil(t, results[3].Err)
+	assert.Equal(t, "kept-id", results[3].Host.ID)
+	assert.Nil(t, results[4].Err)
+	assert.Equal(t, "ok-2", results[4].Host.Name)
+
+	assert.Equal(t, []string{"broken-id"}, deleted)
+	assert.Contains(t, err.Error(), "broken")
+	assert.Contains(t, err.Error(), "refused")
+	assert.Contains(t, err.Error(), "kept")
+	assert.NotContains(t, err.Error(), "ok-1")
+}
+
+func TestWaitHostReady_ProbeAfterState(t *testing.T) {
+	inspects := 0
+	inspect := func(host *abstract.Host) (*abstract.Host, fail.Error) {
+		inspects++
+		h := &abstract.Host{ID: host.ID, Name: "host", LastState: hoststate.STARTING}
+		if inspects > 1 {
+			h.LastState = hoststate.STARTED
+		}
+		return h, nil
+	}
+	probes := 0
+	probe := func(host *abstract.Host) error {
+		probes++
+		assert.Equal(t, hoststate.STARTED, host.LastState)
+		if probes <= 2 {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}
+
+	host, err := waitHostReady(&abstract.Host{ID: "host-id"}, inspect, probe, 10*time.Millisecond, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, hoststate.STARTED, host.LastState)
+	assert.Equal(t, 3, probes)
+	assert.Equal(t, 4, inspects)
+}
+
+func TestWaitHostReady_StateOnly(t *testing.T) {
+	inspect := func(host *abstract.Host) (*abstract.Host, fail.Error) {
+		return &abstract.Host{ID: host.ID, LastState: hoststate.STARTED}, nil
+	}
+
+	host, err := waitHostReady(&abstract.Host{ID: "host-id"}, inspect, nil, 10*time.Millisecond, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, hoststate.STARTED, host.LastState)
+}
+
+func TestWaitHostReady_ProbeNeverSucceeds(t *testing.T) {
+	inspect := func(host *abstract.Host) (*abstract.Host, fail.Error) {
+		return &abstract.Host{ID: host.ID, LastState: hoststate.STARTED}, nil
+	}
+	probe := func(host *abstract.Host) error {
+		return fmt.Errorf("connection refused")
+	}
+
+	_, err := waitHostReady(&abstract.Host{ID: "host-id"}, inspect, probe, 10*time.Millisecond, 100*time.Millisecond)
+	assert.NotNil(t, err)
+}
+
+func TestWaitHostReady_NeverStartedTimesOut(t *testing.T) {
+	inspect := func(host *abstract.Host) (*abstract.Host, fail.Error) {
+		return &abstract.Host{ID: host.ID, Name: "host", LastState: hoststate.STARTING}, nil
+	}
+
+	timeout := 100 * time.Millisecond
+	_, err := waitHostReady(&abstract.Host{ID: "host-id"}, inspect, nil, 10*time.Millisecond, timeout)
+	require.NotNil(t, err)
+	timeoutErr, ok := err.(fail.ErrTimeout)
+	require.True(t, ok)
+	assert.Equal(t, timeout, timeoutErr.Duration())
+	assert.IsType(t, fail.ErrNotAvailable{}, timeoutErr.Cause())
+	assert.Contains(t, timeoutErr.Cause().Error(), "not in ready state")
+}
+
+func TestWaitHostReady_ProbeNeverSucceedsTimesOut(t *testing.T) {
+	inspect := func(host *abstract.Host) (*abstract.Host, fail.Error) {
+		return &abstract.Host{ID: host.ID, LastState: hoststate.STARTED}, nil
+	}
+	probe := func(host *abstract.Host) error {
+		return fmt.Errorf("connection refused")
+	}
+
+	timeout := 100 * time.Millisecond
+	_, err := waitHostReady(&abstract.Host{ID: "host-id"}, inspect, probe, 10*time.Millisecond, timeout)
+	require.NotNil(t, err)
+	timeoutErr, ok := err.(fail.ErrTimeout)
+	require.True(t, ok)
+	assert.Equal(t, timeout, timeoutErr.Duration())
+	assert.IsType(t, fail.ErrNotAvailable{}, timeoutErr.Cause())
+}
+
+func TestHostParameterValidation(t *testing.T) {
+	api := &fakeComputeAPI{}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	var nilHost *abstract.Host
+	for _, param := range []interface{}{42, "", nilHost, nil} {
+		_, err := stack.InspectHost(param)
+		_, ok := err.(fail.ErrInvalidParameter)
+		assert.True(t, ok, "InspectHost(%#v) returned %v", param, err)
+
+		_, err = stack.WaitHostReady(param, time.Second)
+		_, ok = err.(fail.ErrInvalidParameter)
+		assert.True(t, ok, "WaitHostReady(%#v) returned %v", param, err)
+	}
+
+	_, err := stack.GetHostByName("")
+	_, ok := err.(fail.ErrInvalidParameter)
+	assert.True(t, ok)
+	assert.Empty(t, api.calls)
+}
+
+func TestHostParameterValidation_NilStack(t *testing.T) {
+	var stack *Stack
+
+	_, err := stack.InspectHost("host-id")
+	_, ok := err.(fail.ErrInvalidInstance)
+	assert.True(t, ok)
+
+	_, err = stack.WaitHostReady("host-id", time.Second)
+	_, ok = err.(fail.ErrInvalidInstance)
+	assert.True(t, ok)
+
+	_, err = stack.GetHostByName("host")
+	_, ok = err.(fail.ErrInvalidInstance)
+	assert.True(t, ok)
+
+	_, err = (&Stack{}).InspectHost("host-id")
+	_, ok = err.(fail.ErrInvalidInstanceContent)
+	assert.True(t, ok)
+}
+
+func TestStateConvert_Unexpected(t *testing.T) {
+	state, err := stateConvert("EXPLODING")
+	assert.NotNil(t, err)
+	assert.Equal(t, hoststate.UNKNOWN, state)
+}
+
+func TestHostExists(t *testing.T) {
+	// present
+	api := &fakeComputeAPI{getStatus: http.StatusOK, found: []string{"instances"}}
+	stack, closer := newFakeStack(t, api)
+	found, err := stack.HostExists("host-1")
+	closer()
+	require.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"get"}, api.calls)
+
+	// absent
+	api = &fakeComputeAPI{getStatus: http.StatusNotFound}
+	stack, closer = newFakeStack(t, api)
+	found, err = stack.HostExists("host-1")
+	closer()
+	require.Nil(t, err)
+	assert.False(t, found)
+
+	// error
+	api = &fakeComputeAPI{getStatus: http.StatusForbidden}
+	stack, closer = newFakeStack(t, api)
+	found, err = stack.HostExists("host-1")
+	closer()
+	assert.NotNil(t, err)
+	assert.False(t, found)
+}
+
+func TestNetworkExists(t *testing.T) {
+	// network present
+	api := &fakeComputeAPI{getStatus: http.StatusOK, found: []string{"networks"}}
+	stack, closer := newFakeStack(t, api)
+	found, err := stack.NetworkExists("net-1")
+	closer()
+	require.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"get-network"}, api.calls)
+
+	// subnetwork present
+	api = &fakeComputeAPI{getStatus: http.StatusOK, found: []string{"subnetworks"}}
+	stack, closer = newFakeStack(t, api)
+	found, err = stack.NetworkExists("net-1")
+	closer()
+	require.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"get-network", "get-subnetwork"}, api.calls)
+
+	// absent
+	api = &fakeComputeAPI{getStatus: http.StatusNotFound}
+	stack, closer = newFakeStack(t, api)
+	found, err = stack.NetworkExists("net-1")
+	closer()
+	require.Nil(t, err)
+	assert.False(t, found)
+
+	// error
+	api = &fakeComputeAPI{getStatus: http.StatusForbidden}
+	stack, closer = newFakeStack(t, api)
+	found, err = stack.NetworkExists("net-1")
+	closer()
+	assert.NotNil(t, err)
+	assert.False(t, found)
+	assert.Equal(t, []string{"get-network"}, api.calls)
+}
+
+func TestGetHostID_Cache(t *testing.T) {
+	api := &fakeComputeAPI{deleteStatus: http.StatusNotFound, instances: map[string]uint64{"host-1": 1234}}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	id, err := stack.GetHostID("host-1")
+	require.Nil(t, err)
+	assert.Equal(t, "1234", id)
+	assert.Equal(t, []string{"list"}, api.calls)
+
+	// second lookup is served by the cache
+	id, err = stack.GetHostID("host-1")
+	require.Nil(t, err)
+	assert.Equal(t, "1234", id)
+	assert.Equal(t, []string{"list"}, api.calls)
+
+	_, err = stack.GetHostID("unknown")
+	assert.NotNil(t, err)
+
+	// recreation of a host with the same name invalidates the cache
+	api.instances["host-1"] = 5678
+	_, _, err = stack.CreateHost(abstract.HostRequest{ResourceName: "host-1"})
+	require.NotNil(t, err)
+	api.calls = nil
+	id, err = stack.GetHostID("host-1")
+	require.Nil(t, err)
+	assert.Equal(t, "5678", id)
+	assert.Equal(t, []string{"list"}, api.calls)
+
+	// deletion invalidates the cache
+	err = stack.DeleteHost("5678")
+	require.Nil(t, err)
+	api.calls = nil
+	_, err = stack.GetHostID("host-1")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"list"}, api.calls)
+}
+
+const instanceWithInterfaces = `{"networkInterfaces": [
+	{"networkIP": "192.168.0.3", "subnetwork": "https://www.googleapis.com/compute/v1/projects/my-project/regions/europe-west1/subnetworks/net-1"},
+	{"networkIP": "10.0.0.3", "accessConfigs": [{"natIP": "35.1.2.3"}],
+	 "subnetwork": "https://www.googleapis.com/compute/v1/projects/my-project/regions/europe-west1/subnetworks/net-2"}
+]}`
+
+func TestGetHostIPs_SingleGet(t *testing.T) {
+	api := &fakeComputeAPI{getStatus: http.StatusOK, found: []string{"instances", "subnetworks"}, instance: instanceWithInterfaces}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	ip, err := stack.GetHostPrivateIP("host-1")
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.0.3", ip)
+	assert.Equal(t, []string{"get"}, api.calls)
+
+	api.calls = nil
+	ip, err = stack.GetHostPublicIP("host-1")
+	require.Nil(t, err)
+	assert.Equal(t, "35.1.2.3", ip)
+	assert.Equal(t, []string{"get"}, api.calls)
+}
+
+func TestGetHostIPs_Missing(t *testing.T) {
+	api := &fakeComputeAPI{
+		getStatus: http.StatusOK, found: []string{"instances"}, instance: `{"networkInterfaces": [{"networkIP": "192.168.0.3"}]}`,
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	_, err := stack.GetHostPublicIP("host-1")
+	assert.IsType(t, fail.ErrNotFound{}, err)
+
+	api.found = nil
+	_, err = stack.GetHostPrivateIP("host-1")
+	assert.IsType(t, fail.ErrNotFound{}, err)
+	assert.Equal(t, []string{"get", "get"}, api.calls)
+}
+
+func TestBuildGcpMachine_PublicHostIsNotGateway(t *testing.T) {
+	template := &abstract.HostTemplate{Name: "n1-standard-1", DiskSize: 10}
+	cases := []struct {
+		name                string
+		isPublic, isGateway bool
+		tag                 string
+	}{
+		{"public host", true, false, "nat"},
+		{"gateway", true, true, "nat"},
+		{"private host", false, false, "no-ip-net-1"},
+	}
+	for _, c := range cases {
+		api := &fakeComputeAPI{getStatus: http.StatusOK, found: []string{"instances"}}
+		stack, closer := newFakeStack(t, api)
+
+		_, err := buildGcpMachine(
+			stack.ComputeService, "my-project", "host-1", "image-url", "europe-west1", "europe-west1-b",
+			[]string{safescaleVPC},
+			[]string{"net-1"}, "#!/bin/bash", c.isPublic, c.isGateway, template,
+			&compute.ServiceAccount{Email: defaultServiceAccount, Scopes: defaultInstanceScopes},
+		)
+		closer()
+		require.Nil(t, err, c.name)
+		require.Len(t, api.inserted, 1, c.name)
+		instance := api.inserted[0]
+		assert.Equal(t, []string{c.tag}, instance.Tags.Items, c.name)
+		assert.Equal(t, c.isGateway, instance.CanIpForward, c.name)
+		require.Len(t, instance.NetworkInterfaces, 1, c.name)
+		assert.Equal(t, c.isPublic, len(instance.NetworkInterfaces[0].AccessConfigs) > 0, c.name)
+	}
+}
+
+func TestStopHost_AlreadyStopped(t *testing.T) {
+	api := &fakeComputeAPI{
+		getStatus: http.StatusOK, found: []string{"instances"}, instance: `{"id": "1234", "status": "TERMINATED"}`,
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	assert.Nil(t, stack.StopHost("my-host"))
+	assert.Equal(t, []string{"get"}, api.calls)
+}
+
+func TestStopHost_Running(t *testing.T) {
+	api := &fakeComputeAPI{
+		getStatus: http.StatusOK, found: []string{"instances"}, instance: `{"id": "1234", "status": "RUNNING"}`,
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	assert.Nil(t, stack.StopHost("my-host"))
+	assert.Equal(t, []string{"get", "stop"}, api.calls)
+}
+
+func TestStartHost_AlreadyStarted(t *testing.T) {
+	api := &fakeComputeAPI{
+		getStatus: http.StatusOK, found: []string{"instances"}, instance: `{"id": "1234", "status": "RUNNING"}`,
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	assert.Nil(t, stack.StartHost("my-host"))
+	assert.Equal(t, []string{"get"}, api.calls)
+}
+
+func TestRebootHost_Stopped(t *testing.T) {
+	api := &fakeComputeAPI{
+		getStatus: http.StatusOK, found: []string{"instances"}, instance: `{"id": "1234", "status": "TERMINATED"}`,
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	assert.Nil(t, stack.RebootHost("my-host"))
+	assert.Equal(t, []string{"get", "start"}, api.calls)
+}
+
+func TestStopHost_NotFound(t *testing.T) {
+	api := &fakeComputeAPI{}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	err := stack.StopHost("my-host")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"get"}, api.calls)
+}
+
+// satisfiableCatalog returns a catalog where the host request of TestValidateHostRequest can be satisfied
+func satisfiableCatalog() map[string]string {
+	return map[string]string{
+		"images":       `{"items": [{"id": "42", "name": "ubuntu-1804", "diskSizeGb": "10"}]}`,
+		"machineTypes": `{"items": [{"id": "7", "name": "n1-standard-1", "guestCpus": 1, "memoryMb": 3840}]}`,
+		"zones":        `{"items": [{"name": "europe-west1-b", "status": "UP"}, {"name": "europe-west1-c", "status": "DOWN"}]}`,
+		"diskTypes":    `{"name": "pd-standard"}`,
+	}
+}
+
+func TestValidateHostRequest(t *testing.T) {
+	request := abstract.HostRequest{ResourceName: "host", TemplateID: "7", ImageID: "42"}
+
+	api := &fakeComputeAPI{catalog: satisfiableCatalog()}
+	stack, stop := newFakeStack(t, api)
+	defer stop()
+
+	result, err := stack.ValidateHostRequest(request)
+	require.Nil(t, err)
+	assert.True(t, result.OK())
+	assert.Empty(t, result.Failures)
+	require.NotNil(t, result.Template)
+	assert.Equal(t, "n1-standard-1", result.Template.Name)
+	require.NotNil(t, result.Image)
+	assert.Equal(t, "ubuntu-1804", result.Image.Name)
+	assert.Equal(t, "europe-west1-b", result.Zone)
+	assert.Equal(t, "pd-standard", result.DiskType)
+	assert.NotContains(t, api.calls, "insert")
+}
+
+func TestValidateHostRequest_FailingPreconditions(t *testing.T) {
+	cases := map[string]struct {
+		request abstract.HostRequest
+		zone    string
+		catalog func(map[string]string)
+	}{
+		"template": {
+			request: abstract.HostRequest{TemplateID: "unknown", ImageID: "42"},
+		},
+		"image": {
+			request: abstract.HostRequest{TemplateID: "7", ImageID: "unknown"},
+		},
+		"zone": {
+			request: abstract.HostRequest{TemplateID: "7", ImageID: "42"},
+			zone:    "europe-west1-c",
+		},
+		"disk-type": {
+			request: abstract.HostRequest{TemplateID: "7", ImageID: "42"},
+			catalog: func(c map[string]string) { delete(c, "diskTypes") },
+		},
+	}
+	for precondition, tc := range cases {
+		api := &fakeComputeAPI{catalog: satisfiableCatalog()}
+		if tc.catalog != nil {
+			tc.catalog(api.catalog)
+		}
+		stack, stop := newFakeStack(t, api)
+		if tc.zone != "" {
+			stack.GcpConfig.Zone = tc.zone
+		}
+
+		result, err := stack.ValidateHostRequest(tc.request)
+		stop()
+		require.Nil(t, err, precondition)
+		assert.False(t, result.OK(), precondition)
+		assert.Len(t, result.Failures, 1, precondition)
+		assert.Contains(t, result.Failures, precondition)
+		assert.NotContains(t, api.calls, "insert", precondition)
+	}
+}
+
+func TestCheckProviderHealth(t *testing.T) {
+	api := &fakeComputeAPI{}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	health := stack.CheckProviderHealth()
+	assert.True(t, health.OK())
+	assert.True(t, health.Latency > 0)
+	assert.Equal(t, []string{"list-regions"}, api.calls)
+}
+
+func TestCheckProviderHealth_AuthFailure(t *testing.T) {
+	api := &fakeComputeAPI{regionsStatus: http.StatusUnauthorized}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	health := stack.CheckProviderHealth()
+	assert.False(t, health.OK())
+	assert.True(t, health.Reachable)
+	assert.False(t, health.Authenticated)
+	assert.NotEmpty(t, health.Error)
+}
+
+func TestCheckProviderHealth_Unreachable(t *testing.T) {
+	stack, closer := newFakeStack(t, &fakeComputeAPI{})
+	closer()
+
+	health := stack.CheckProviderHealth()
+	assert.False(t, health.Reachable)
+	assert.False(t, health.Authenticated)
+	assert.NotEmpty(t, health.Error)
+}
+
+func TestStack_GeneratePassword(t *testing.T) {
+	s := &Stack{GcpConfig: &stacks.GCPConfiguration{}}
+	password, err := s.generatePassword()
+	require.Nil(t, err)
+	assert.Len(t, password, defaultPasswordLength)
+
+	s.GcpConfig.PasswordLength = 24
+	password, err = s.generatePassword()
+	require.Nil(t, err)
+	assert.Len(t, password, 24)
+
+	s.GcpConfig.PasswordLength = 8
+	_, err = s.generatePassword()
+	assert.NotNil(t, err)
+	s.GcpConfig.PasswordLength = 300
+	_, err = s.generatePassword()
+	assert.NotNil(t, err)
+}
+
+func TestStack_CreateKeyPair_Ed25519(t *testing.T) {
+	s := &Stack{GcpConfig: &stacks.GCPConfiguration{KeyType: "ed25519"}}
+	kp, err := s.CreateKeyPair("kp")
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(kp.PublicKey, "ssh-ed25519 "))
+	assert.True(t, strings.HasPrefix(kp.Name, "kp_"))
+
+	s.GcpConfig.KeyType = "dsa"
+	_, err = s.CreateKeyPair("kp")
+	assert.NotNil(t, err)
+}
+
+func TestMachineTypeAccelerators(t *testing.T) {
+	count, gpuType := machineTypeAccelerators(&compute.MachineType{Name: "n1-standard-4"})
+	assert.Equal(t, 0, count)
+	assert.Empty(t, gpuType)
+
+	count, gpuType = machineTypeAccelerators(
+		&compute.MachineType{
+			Name: "a2-highgpu-2g",
+			Accelerators: []*compute.MachineTypeAccelerators{
+				{GuestAcceleratorCount: 2, GuestAcceleratorType: "nvidia-tesla-a100"},
+			},
+		},
+	)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "nvidia-tesla-a100", gpuType)
+}
+
+func TestInterfacesIPs(t *testing.T) {
+	prefix := "https://www.googleapis.com/compute/v1/projects/my-project/regions/europe-west1/subnetworks/"
+	subnets := interfacesIPs(
+		[]*compute.NetworkInterface{
+			{NetworkIP: "192.168.0.3", Subnetwork: prefix + "net-1"},
+			{
+				NetworkIP:   "10.0.0.3",
+				Ipv6Address: "fd20:1::3",
+				Subnetwork:  prefix + "net-2",
+				AccessConfigs: []*compute.AccessConfig{
+					{NatIP: "35.1.2.3"},
+				},
+				Ipv6AccessConfigs: []*compute.AccessConfig{
+					{ExternalIpv6: "2600:1900:4000::3"},
+				},
+			},
+			{NetworkIP: "172.16.0.3"},
+		},
+	)
+	require.Len(t, subnets, 2)
+	assert.Equal(t, "192.168.0.3", subnets[0].IP)
+	assert.Empty(t, subnets[0].IPv6)
+	assert.Empty(t, subnets[0].PublicIPv6)
+	assert.Equal(t, "10.0.0.3", subnets[1].IP)
+	assert.Equal(t, "35.1.2.3", subnets[1].PublicIP)
+	assert.Equal(t, "fd20:1::3", subnets[1].IPv6)
+	assert.Equal(t, "2600:1900:4000::3", subnets[1].PublicIPv6)
+}
+
+func TestInspectHost_IPv6(t *testing.T) {
+	subnet := "https://www.googleapis.com/compute/v1/projects/my-project/regions/europe-west1/subnetworks/net-1"
+	cases := []struct {
+		instance   string
+		ipv6       map[string]string
+		publicIPv6 string
+	}{
+		{
+			instance: `{"name": "host-1", "status": "RUNNING", "networkInterfaces": [{"networkIP": "192.168.0.3",
+				"ipv6Address": "fd20:1::3", "ipv6AccessConfigs": [{"externalIpv6": "2600:1900:4000::3"}],
+				"subnetwork": "` + subnet + `"}]}`,
+			ipv6:       map[string]string{"1234": "fd20:1::3"},
+			publicIPv6: "2600:1900:4000::3",
+		},
+		{
+			instance: `{"name": "host-1", "status": "RUNNING", "networkInterfaces": [{"networkIP": "192.168.0.3",
+				"subnetwork": "` + subnet + `"}]}`,
+			ipv6: map[string]string{},
+		},
+	}
+	for _, c := range cases {
+		api := &fakeComputeAPI{getStatus: http.StatusOK, found: []string{"instances", "subnetworks"}, instance: c.instance}
+		stack, closer := newFakeStack(t, api)
+
+		host, err := stack.InspectHost("host-1")
+		closer()
+		require.Nil(t, err)
+		err = host.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+			func(clonable data.Clonable) error {
+				hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+				assert.Equal(t, map[string]string{"1234": "192.168.0.3"}, hostNetworkV1.IPv4Addresses)
+				assert.Equal(t, c.ipv6, hostNetworkV1.IPv6Addresses)
+				assert.Equal(t, c.publicIPv6, hostNetworkV1.PublicIPv6)
+				return nil
+			},
+		)
+		require.Nil(t, err)
+	}
+}
+
+func TestStack_InstanceServiceAccount(t *testing.T) {
+	// defaults are kept for compatibility
+	sa, err := (&Stack{GcpConfig: &stacks.GCPConfiguration{}}).instanceServiceAccount()
+	require.Nil(t, err)
+	assert.Equal(t, "default", sa.Email)
+	assert.Equal(t, []string{compute.DevstorageFullControlScope, compute.ComputeScope}, sa.Scopes)
+
+	cfg := &stacks.GCPConfiguration{
+		ServiceAccount: "safescale@my-project.iam.gserviceaccount.com",
+		Scopes:         []string{compute.ComputeScope, compute.DevstorageReadOnlyScope},
+	}
+	sa, err = (&Stack{GcpConfig: cfg}).instanceServiceAccount()
+	require.Nil(t, err)
+	assert.Equal(t, "safescale@my-project.iam.gserviceaccount.com", sa.Email)
+	assert.Equal(t, []string{compute.ComputeScope, compute.DevstorageReadOnlyScope}, sa.Scopes)
+
+	cfg.Scopes = []string{compute.CloudPlatformScope}
+	_, err = (&Stack{GcpConfig: cfg}).instanceServiceAccount()
+	assert.Nil(t, err)
+
+	// a scope SafeScale needs is missing
+	cfg.Scopes = []string{compute.DevstorageReadOnlyScope}
+	_, err = (&Stack{GcpConfig: cfg}).instanceServiceAccount()
+	require.NotNil(t, err)
+	_, ok := err.(fail.ErrInvalidParameter)
+	assert.True(t, ok)
+	assert.Contains(t, err.Error(), compute.ComputeScope)
+}
+
+func TestBuildGcpMachine_ServiceAccount(t *testing.T) {
+	api := &fakeComputeAPI{getStatus: http.StatusOK, found: []string{"instances"}}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	sa := &compute.ServiceAccount{
+		Email: "safescale@my-project.iam.gserviceaccount.com", Scopes: []string{compute.ComputeScope},
+	}
+	_, err := buildGcpMachine(
+		stack.ComputeService, "my-project", "host-1", "image-url", "europe-west1", "europe-west1-b",
+		[]string{safescaleVPC},
+		[]string{"net-1"}, "#!/bin/bash", false, false, &abstract.HostTemplate{Name: "n1-standard-1"}, sa,
+	)
+	require.Nil(t, err)
+	require.Len(t, api.inserted, 1)
+	require.Len(t, api.inserted[0].ServiceAccounts, 1)
+	assert.Equal(t, sa.Email, api.inserted[0].ServiceAccounts[0].Email)
+	assert.Equal(t, sa.Scopes, api.inserted[0].ServiceAccounts[0].Scopes)
+}
+
+func TestStack_HostKeyPair(t *testing.T) {
+	supplied := &abstract.KeyPair{Name: "supplied", PublicKey: "ssh-ed25519 AAAA", PrivateKey: "private"}
+
+	// by default, a key pair is generated if none is supplied
+	s := &Stack{GcpConfig: &stacks.GCPConfiguration{}}
+	kp, err := s.hostKeyPair(nil, "host-1")
+	require.Nil(t, err)
+	require.NotNil(t, kp)
+	assert.True(t, strings.HasPrefix(kp.Name, "host-1_"))
+	assert.NotEmpty(t, kp.PrivateKey)
+	kp, err = s.hostKeyPair(supplied, "host-1")
+	require.Nil(t, err)
+	assert.Equal(t, supplied, kp)
+
+	// when required, the key pair must be supplied
+	s.GcpConfig.RequireKeyPair = true
+	_, err = s.hostKeyPair(nil, "host-1")
+	require.NotNil(t, err)
+	_, ok := err.(fail.ErrInvalidParameter)
+	assert.True(t, ok)
+	kp, err = s.hostKeyPair(supplied, "host-1")
+	require.Nil(t, err)
+	assert.Equal(t, supplied, kp)
+}
+
+func TestCreateHost_RequiredKeyPair(t *testing.T) {
+	api := &fakeComputeAPI{}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+	stack.GcpConfig.RequireKeyPair = true
+
+	_, _, err := stack.CreateHost(
+		abstract.HostRequest{ResourceName: "host-1", Networks: []*abstract.Network{{ID: "net-id"}}, PublicIP: true},
+	)
+	require.NotNil(t, err)
+	_, ok := err.(fail.ErrInvalidParameter)
+	assert.True(t, ok)
+	assert.Empty(t, api.calls)
+}
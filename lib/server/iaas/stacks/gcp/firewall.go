@@ -0,0 +1,285 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+// hostFirewallPriority is the priority of the firewall rules applying the security rules of hosts (the GCP default)
+const hostFirewallPriority = 1000
+
+// hostFirewallPrefix returns the prefix of the names of the firewall rules targeting the instances tagged 'tag';
+// the tag is hashed to respect the maximum length of the names of GCP resources
+func hostFirewallPrefix(tag string) string {
+	sum := sha1.Sum([]byte(tag))
+	return fmt.Sprintf("safescale-sg-%x", sum[:6])
+}
+
+// buildHostFirewalls converts the security rules, expected to be valid, into the GCP firewall rules targeting the
+// instances tagged 'tag'.
+// A GCP firewall rule only applies to one direction, so a rule applying to both directions gives 2 firewall rules.
+func buildHostFirewalls(projectID, network, tag string, rules []abstract.SecurityGroupRule) []*compute.Firewall {
+	prefix := hostFirewallPrefix(tag)
+	networkURL := fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/global/networks/%s", projectID, network)
+
+	var firewalls []*compute.Firewall
+	for i, rule := range rules {
+		ranges := rule.Targets
+		allowed := &compute.FirewallAllowed{IPProtocol: firewallProtocol(rule.Protocol, hasIPv6Range(ranges))}
+		switch {
+		case rule.PortFrom == 0:
+		case rule.PortTo == 0 || rule.PortTo == rule.PortFrom:
+			allowed.Ports = []string{strconv.Itoa(rule.PortFrom)}
+		default:
+			allowed.Ports = []string{fmt.Sprintf("%d-%d", rule.PortFrom, rule.PortTo)}
+		}
+
+		if rule.Direction == abstract.SecurityGroupRuleIngress || rule.Direction == abstract.SecurityGroupRuleBoth {
+			firewalls = append(firewalls, &compute.Firewall{
+				Name:         fmt.Sprintf("%s-%d-in", prefix, i),
+				Description:  rule.Description,
+				Network:      networkURL,
+				Direction:    "INGRESS",
+				Priority:     hostFirewallPriority,
+				TargetTags:   []string{tag},
+				Allowed:      []*compute.FirewallAllowed{allowed},
+				SourceRanges: ranges,
+			})
+		}
+		if rule.Direction == abstract.SecurityGroupRuleEgress || rule.Direction == abstract.SecurityGroupRuleBoth {
+			firewalls = append(firewalls, &compute.Firewall{
+				Name:              fmt.Sprintf("%s-%d-out", prefix, i),
+				Description:       rule.Description,
+				Network:           networkURL,
+				Direction:         "EGRESS",
+				Priority:          hostFirewallPriority,
+				TargetTags:        []string{tag},
+				Allowed:           []*compute.FirewallAllowed{allowed},
+				DestinationRanges: ranges,
+			})
+		}
+	}
+	return firewalls
+}
+
+// firewallProtocol returns the GCP name of the protocol of a rule; GCP knows ICMPv6 by its protocol number only
+func firewallProtocol(protocol string, ipv6 bool) string {
+	p := stacks.NormalizeProtocol(protocol, ipv6)
+	if p == stacks.ProtocolICMPv6 {
+		return "58"
+	}
+	return p
+}
+
+// hasIPv6Range tells if one of the CIDRs 'ranges' is an IPv6 one
+func hasIPv6Range(ranges []string) bool {
+	for _, r := range ranges {
+		ip, _, err := net.ParseCIDR(r)
+		if err == nil && ip.To4() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplySecurityRules makes the GCP firewall rules of the host identified by 'hostID' match 'rules': the missing
+// firewall rules are created, the existing ones updated and the ones not corresponding to a rule anymore deleted.
+// The firewall rules target a tag named after the host, added to the instance if needed.
+// A firewall rule failing to be applied doesn't prevent the others to be; the failures are returned together.
+func (s *Stack) ApplySecurityRules(hostID string, rules []abstract.SecurityGroupRule) fail.Error {
+	if xerr := s.checkInstance(); xerr != nil {
+		return xerr
+	}
+	if hostID == "" {
+		return fail.InvalidParameterError("hostID", "cannot be empty string")
+	}
+	for i, rule := range rules {
+		if xerr := rule.Validate(); xerr != nil {
+			return fail.InvalidParameterError("rules", fmt.Sprintf("rule #%d is invalid: %v", i, xerr))
+		}
+	}
+
+	instance, xerr := s.getInstanceForFirewall(hostID)
+	if xerr != nil {
+		return xerr
+	}
+	tag := instance.Name
+	if xerr = s.ensureInstanceTag(instance, tag); xerr != nil {
+		return xerr
+	}
+
+	existing, xerr := s.listHostFirewalls(tag)
+	if xerr != nil {
+		return xerr
+	}
+
+	service := s.ComputeService
+	var errs []error
+	for _, firewall := range buildHostFirewalls(s.GcpConfig.ProjectID, s.GcpConfig.NetworkName, tag, rules) {
+		var (
+			op  *compute.Operation
+			err error
+		)
+		if _, ok := existing[firewall.Name]; ok {
+			delete(existing, firewall.Name)
+			op, err = service.Firewalls.Update(s.GcpConfig.ProjectID, firewall.Name, firewall).Do()
+		} else {
+			op, err = service.Firewalls.Insert(s.GcpConfig.ProjectID, firewall).Do()
+		}
+		if err == nil {
+			err = s.waitFirewallOperation(op)
+		}
+		if err != nil {
+			errs = append(errs, fail.Errorf(fmt.Sprintf("failed to apply firewall rule '%s' of host '%s': %v", firewall.Name, hostID, err), err))
+		}
+	}
+
+	// Remaining firewall rules correspond to rules not applied anymore
+	for name := range existing {
+		if xerr = s.deleteFirewall(name); xerr != nil {
+			errs = append(errs, xerr)
+		}
+	}
+	if len(errs) > 0 {
+		return fail.ErrListError(errs)
+	}
+	return nil
+}
+
+// RemoveSecurityRules deletes the GCP firewall rules applying the security rules of the host identified by 'hostID'
+func (s *Stack) RemoveSecurityRules(hostID string) fail.Error {
+	if xerr := s.checkInstance(); xerr != nil {
+		return xerr
+	}
+	if hostID == "" {
+		return fail.InvalidParameterError("hostID", "cannot be empty string")
+	}
+
+	instance, xerr := s.getInstanceForFirewall(hostID)
+	if xerr != nil {
+		return xerr
+	}
+	existing, xerr := s.listHostFirewalls(instance.Name)
+	if xerr != nil {
+		return xerr
+	}
+
+	var errs []error
+	for name := range existing {
+		if xerr = s.deleteFirewall(name); xerr != nil {
+			errs = append(errs, xerr)
+		}
+	}
+	if len(errs) > 0 {
+		return fail.ErrListError(errs)
+	}
+	return nil
+}
+
+// getInstanceForFirewall returns the name and the tags of the instance identified by 'hostID'
+func (s *Stack) getInstanceForFirewall(hostID string) (*compute.Instance, fail.Error) {
+	instance, err := s.ComputeService.Instances.Get(s.GcpConfig.ProjectID, s.GcpConfig.Zone, hostID).Fields("name", "tags").Do()
+	if err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == 404 {
+			return nil, abstract.ResourceNotFoundError("host", hostID)
+		}
+		return nil, fail.Errorf(fmt.Sprintf("cannot get host '%s': %v", hostID, err), err)
+	}
+	return instance, nil
+}
+
+// ensureInstanceTag adds 'tag' to the tags of 'instance' if it is not already there
+func (s *Stack) ensureInstanceTag(instance *compute.Instance, tag string) fail.Error {
+	tags := instance.Tags
+	if tags == nil {
+		tags = &compute.Tags{}
+	}
+	if indexOf(tag, tags.Items) >= 0 {
+		return nil
+	}
+
+	newTags := &compute.Tags{
+		Items:       append(append([]string{}, tags.Items...), tag),
+		Fingerprint: tags.Fingerprint,
+	}
+	op, err := s.ComputeService.Instances.SetTags(s.GcpConfig.ProjectID, s.GcpConfig.Zone, instance.Name, newTags).Do()
+	if err == nil {
+		err = s.waitFirewallOperation(op)
+	}
+	if err != nil {
+		return fail.Errorf(fmt.Sprintf("failed to tag host '%s': %v", instance.Name, err), err)
+	}
+	return nil
+}
+
+// listHostFirewalls returns the firewall rules targeting the instances tagged 'tag', by name
+func (s *Stack) listHostFirewalls(tag string) (map[string]*compute.Firewall, fail.Error) {
+	prefix := hostFirewallPrefix(tag)
+	resp, err := s.ComputeService.Firewalls.List(s.GcpConfig.ProjectID).Filter(fmt.Sprintf(`name eq "%s-.*"`, prefix)).Do()
+	if err != nil {
+		return nil, fail.Errorf(fmt.Sprintf("failed to list the firewall rules of host '%s': %v", tag, err), err)
+	}
+
+	firewalls := map[string]*compute.Firewall{}
+	for _, firewall := range resp.Items {
+		if strings.HasPrefix(firewall.Name, prefix+"-") {
+			firewalls[firewall.Name] = firewall
+		}
+	}
+	return firewalls, nil
+}
+
+// deleteFirewall deletes the firewall rule named 'name'; a firewall rule already deleted is not an error
+func (s *Stack) deleteFirewall(name string) fail.Error {
+	op, err := s.ComputeService.Firewalls.Delete(s.GcpConfig.ProjectID, name).Do()
+	if err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == 404 {
+			logrus.Debugf("firewall rule '%s' already deleted", name)
+			return nil
+		}
+		return fail.Errorf(fmt.Sprintf("failed to delete firewall rule '%s': %v", name, err), err)
+	}
+	if err = s.waitFirewallOperation(op); err != nil {
+		return fail.Errorf(fmt.Sprintf("failed to delete firewall rule '%s': %v", name, err), err)
+	}
+	return nil
+}
+
+// waitFirewallOperation waits for the end of the operation 'op'
+func (s *Stack) waitFirewallOperation(op *compute.Operation) error {
+	oco := OpContext{
+		Operation:    op,
+		ProjectID:    s.GcpConfig.ProjectID,
+		Service:      s.ComputeService,
+		DesiredState: "DONE",
+	}
+	return waitUntilOperationIsSuccessfulOrTimeout(oco, temporal.GetMinDelay(), temporal.GetHostTimeout())
+}
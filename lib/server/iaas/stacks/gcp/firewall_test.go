@@ -0,0 +1,213 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/compute/v1"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+// firewallInstance is the body returned on Get of the instance 'host-1'
+const firewallInstance = `{"name": "host-1", "tags": {"items": ["nat"], "fingerprint": "fp"}}`
+
+func TestApplySecurityRules_TCPIngress(t *testing.T) {
+	prefix := hostFirewallPrefix("host-1")
+	api := &fakeComputeAPI{
+		getStatus: http.StatusOK,
+		found:     []string{"instances"},
+		instance:  firewallInstance,
+		firewalls: []string{prefix + "-3-out"},
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+	stack.GcpConfig.NetworkName = "safescale"
+
+	rules := []abstract.SecurityGroupRule{
+		{
+			Description: "web",
+			Direction:   abstract.SecurityGroupRuleIngress,
+			Protocol:    "TCP",
+			PortFrom:    8080,
+			PortTo:      8090,
+			Targets:     []string{"10.0.0.0/8"},
+		},
+	}
+	err := stack.ApplySecurityRules("host-1", rules)
+	require.Nil(t, err)
+
+	require.Len(t, api.insertedFirewalls, 1)
+	firewall := api.insertedFirewalls[0]
+	assert.Equal(t, prefix+"-0-in", firewall.Name)
+	assert.Equal(t, "web", firewall.Description)
+	assert.Equal(t, "https://www.googleapis.com/compute/v1/projects/my-project/global/networks/safescale", firewall.Network)
+	assert.Equal(t, "INGRESS", firewall.Direction)
+	assert.Equal(t, int64(hostFirewallPriority), firewall.Priority)
+	assert.Equal(t, []string{"host-1"}, firewall.TargetTags)
+	assert.Equal(t, []*compute.FirewallAllowed{{IPProtocol: "tcp", Ports: []string{"8080-8090"}}}, firewall.Allowed)
+	assert.Equal(t, []string{"10.0.0.0/8"}, firewall.SourceRanges)
+	assert.Empty(t, firewall.DestinationRanges)
+
+	// the host gets its own tag, the other tags being kept
+	require.NotNil(t, api.tags)
+	assert.Equal(t, []string{"nat", "host-1"}, api.tags.Items)
+	assert.Equal(t, "fp", api.tags.Fingerprint)
+
+	// the firewall rule not corresponding to a rule anymore is deleted
+	assert.Equal(t, []string{prefix + "-3-out"}, api.deletedFirewalls)
+	assert.Empty(t, api.updatedFirewalls)
+}
+
+func TestApplySecurityRules_UpdatesExisting(t *testing.T) {
+	prefix := hostFirewallPrefix("host-1")
+	api := &fakeComputeAPI{
+		getStatus: http.StatusOK,
+		found:     []string{"instances"},
+		instance:  `{"name": "host-1", "tags": {"items": ["nat", "host-1"], "fingerprint": "fp"}}`,
+		firewalls: []string{prefix + "-0-in", "other-rule"},
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	rules := []abstract.SecurityGroupRule{
+		{Direction: abstract.SecurityGroupRuleIngress, Protocol: "udp", PortFrom: 53, Targets: []string{"0.0.0.0/0"}},
+	}
+	err := stack.ApplySecurityRules("host-1", rules)
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{prefix + "-0-in"}, api.updatedFirewalls)
+	assert.Empty(t, api.insertedFirewalls)
+	assert.Empty(t, api.deletedFirewalls)
+	assert.Nil(t, api.tags)
+}
+
+func TestApplySecurityRules_InvalidRule(t *testing.T) {
+	api := &fakeComputeAPI{}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	invalid := [][]abstract.SecurityGroupRule{
+		{{Direction: abstract.SecurityGroupRuleIngress, Protocol: "gre", Targets: []string{"0.0.0.0/0"}}},
+		// targets are mandatory, and must be CIDRs
+		{{Direction: abstract.SecurityGroupRuleIngress, Protocol: "tcp", PortFrom: 22}},
+		{{Direction: abstract.SecurityGroupRuleIngress, Protocol: "tcp", PortFrom: 22, Targets: []string{"10.0.0.300/8"}}},
+	}
+	for _, rules := range invalid {
+		err := stack.ApplySecurityRules("host-1", rules)
+		assert.IsType(t, fail.ErrInvalidParameter{}, err)
+	}
+	assert.Empty(t, api.calls)
+}
+
+func TestApplySecurityRules_CollectsErrors(t *testing.T) {
+	prefix := hostFirewallPrefix("host-1")
+	api := &fakeComputeAPI{
+		getStatus:         http.StatusOK,
+		found:             []string{"instances"},
+		instance:          `{"name": "host-1", "tags": {"items": ["host-1"], "fingerprint": "fp"}}`,
+		firewalls:         []string{prefix + "-5-in"},
+		rejectedFirewalls: []string{prefix + "-0-in"},
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	rules := []abstract.SecurityGroupRule{
+		{Direction: abstract.SecurityGroupRuleIngress, Protocol: "tcp", PortFrom: 22, Targets: []string{"0.0.0.0/0"}},
+		{Direction: abstract.SecurityGroupRuleIngress, Protocol: "tcp", PortFrom: 443, Targets: []string{"0.0.0.0/0"}},
+	}
+	err := stack.ApplySecurityRules("host-1", rules)
+	require.NotNil(t, err)
+	errs, ok := err.(fail.ErrList)
+	require.True(t, ok)
+	require.Len(t, errs.Errors(), 1)
+	assert.Contains(t, errs.Errors()[0].Error(), prefix+"-0-in")
+
+	// the failure of the first rule doesn't prevent the other rules to be applied, nor the stale ones to be deleted
+	require.Len(t, api.insertedFirewalls, 1)
+	assert.Equal(t, prefix+"-1-in", api.insertedFirewalls[0].Name)
+	assert.Equal(t, []string{prefix + "-5-in"}, api.deletedFirewalls)
+}
+
+func TestApplySecurityRules_HostNotFound(t *testing.T) {
+	api := &fakeComputeAPI{}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	err := stack.ApplySecurityRules("host-1", nil)
+	assert.IsType(t, fail.ErrNotFound{}, err)
+}
+
+func TestRemoveSecurityRules(t *testing.T) {
+	prefix := hostFirewallPrefix("host-1")
+	api := &fakeComputeAPI{
+		getStatus: http.StatusOK,
+		found:     []string{"instances"},
+		instance:  firewallInstance,
+		firewalls: []string{prefix + "-0-in", "other-rule"},
+	}
+	stack, closer := newFakeStack(t, api)
+	defer closer()
+
+	err := stack.RemoveSecurityRules("host-1")
+	require.Nil(t, err)
+	assert.Equal(t, []string{prefix + "-0-in"}, api.deletedFirewalls)
+}
+
+func TestBuildHostFirewalls_BothDirections(t *testing.T) {
+	rules := []abstract.SecurityGroupRule{
+		{Direction: abstract.SecurityGroupRuleBoth, Targets: []string{"0.0.0.0/0"}},
+		{Direction: abstract.SecurityGroupRuleEgress, Protocol: "tcp", PortFrom: 443, Targets: []string{"10.0.0.0/8"}},
+	}
+	firewalls := buildHostFirewalls("my-project", "safescale", "host-1", rules)
+	require.Len(t, firewalls, 3)
+
+	prefix := hostFirewallPrefix("host-1")
+	assert.Equal(t, prefix+"-0-in", firewalls[0].Name)
+	assert.Equal(t, "INGRESS", firewalls[0].Direction)
+	assert.Equal(t, []string{"0.0.0.0/0"}, firewalls[0].SourceRanges)
+	assert.Equal(t, "all", firewalls[0].Allowed[0].IPProtocol)
+	assert.Empty(t, firewalls[0].Allowed[0].Ports)
+
+	assert.Equal(t, prefix+"-0-out", firewalls[1].Name)
+	assert.Equal(t, "EGRESS", firewalls[1].Direction)
+	assert.Equal(t, []string{"0.0.0.0/0"}, firewalls[1].DestinationRanges)
+	assert.Empty(t, firewalls[1].SourceRanges)
+
+	assert.Equal(t, prefix+"-1-out", firewalls[2].Name)
+	assert.Equal(t, []string{"443"}, firewalls[2].Allowed[0].Ports)
+	assert.Equal(t, []string{"10.0.0.0/8"}, firewalls[2].DestinationRanges)
+}
+
+func TestBuildHostFirewalls_ICMP(t *testing.T) {
+	rules := []abstract.SecurityGroupRule{
+		{Direction: abstract.SecurityGroupRuleIngress, Protocol: "icmp", Targets: []string{"10.0.0.0/8"}},
+		{Direction: abstract.SecurityGroupRuleIngress, Protocol: "icmp", Targets: []string{"fd00::/64"}},
+		{Direction: abstract.SecurityGroupRuleIngress, Protocol: "icmpv6", Targets: []string{"::/0"}},
+	}
+	firewalls := buildHostFirewalls("my-project", "safescale", "host-1", rules)
+	require.Len(t, firewalls, 3)
+	assert.Equal(t, "icmp", firewalls[0].Allowed[0].IPProtocol)
+	// GCP knows ICMPv6 by its protocol number
+	assert.Equal(t, "58", firewalls[1].Allowed[0].IPProtocol)
+	assert.Equal(t, "58", firewalls[2].Allowed[0].IPProtocol)
+}
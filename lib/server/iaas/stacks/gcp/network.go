@@ -44,6 +44,7 @@ func (s *Stack) CreateNetwork(req abstract.NetworkRequest) (*abstract.Network, f
 		Name:                  s.GcpConfig.NetworkName,
 		AutoCreateSubnetworks: false,
 		ForceSendFields:       []string{"AutoCreateSubnetworks"},
+		Mtu:                   int64(req.MTU),
 	}
 
 	compuService := s.ComputeService
@@ -52,6 +53,12 @@ func (s *Stack) CreateNetwork(req abstract.NetworkRequest) (*abstract.Network, f
 	recnet, err := compuService.Networks.Get(s.GcpConfig.ProjectID, ne.Name).Do()
 	if recnet != nil && err == nil {
 		recreateSafescaleNetwork = false
+		if req.MTU != 0 && recnet.Mtu != int64(req.MTU) {
+			logrus.Warnf(
+				"network '%s' already exists with MTU %d, MTU %d ignored for network '%s'", recnet.Name, recnet.Mtu,
+				req.MTU, req.Name,
+			)
+		}
 	} else if err != nil {
 		if gerr, ok := err.(*googleapi.Error); ok {
 			if gerr.Code != 404 {
@@ -250,6 +257,21 @@ func (s *Stack) GetNetwork(ref string) (*abstract.Network, fail.Error) {
 	return nil, abstract.ResourceNotFoundError("network", ref)
 }
 
+// NetworkExists tells if the network or subnetwork identified by id exists, without listing all the networks
+func (s *Stack) NetworkExists(id string) (bool, fail.Error) {
+	if id == "" {
+		return false, fail.InvalidParameterError("id", "cannot be empty string")
+	}
+
+	_, err := s.ComputeService.Networks.Get(s.GcpConfig.ProjectID, id).Fields("id").Do()
+	found, xerr := existsFromGoogleError(err)
+	if found || xerr != nil {
+		return found, xerr
+	}
+	_, err = s.ComputeService.Subnetworks.Get(s.GcpConfig.ProjectID, s.GcpConfig.Region, id).Fields("id").Do()
+	return existsFromGoogleError(err)
+}
+
 // GetNetworkByName returns the network identified by ref (id or name)
 func (s *Stack) GetNetworkByName(ref string) (*abstract.Network, fail.Error) {
 	nets, err := s.ListNetworks()
@@ -424,13 +446,15 @@ func (s *Stack) CreateGateway(req abstract.GatewayRequest, sizing *abstract.Sizi
 	}
 
 	hostReq := abstract.HostRequest{
-		ImageID:      req.ImageID,
-		KeyPair:      req.KeyPair,
-		HostName:     req.Name,
-		ResourceName: gwname,
-		TemplateID:   req.TemplateID,
-		Networks:     []*abstract.Network{req.Network},
-		PublicIP:     true,
+		ImageID:       req.ImageID,
+		KeyPair:       req.KeyPair,
+		HostName:      req.Name,
+		ResourceName:  gwname,
+		TemplateID:    req.TemplateID,
+		Networks:      []*abstract.Network{req.Network},
+		PublicIP:      true,
+		IsGateway:     true,
+		KeepOnFailure: req.KeepOnFailure,
 	}
 
 	if sizing != nil && sizing.MinDiskSize > 0 {
@@ -3,14 +3,21 @@ package gcp
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
 
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iam/v1"
 	"google.golang.org/api/option"
 
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
+	"github.com/CS-SI/SafeScale/lib/utils/retry"
 )
 
 // Stack ...
@@ -20,8 +27,20 @@ type Stack struct {
 	GcpConfig   *stacks.GCPConfiguration
 
 	ComputeService *compute.Service
+
+	// ReadinessProbe is run by WaitHostReady once the host is started; if nil, checks the SSH port is reachable
+	ReadinessProbe HostProbe
+	// StateOnlyReadiness tells WaitHostReady to consider a started host as ready, without running ReadinessProbe
+	StateOnlyReadiness bool
+	// RetryPolicy paces the retries of the requests to the provider
+	RetryPolicy retry.Policy
+
+	hostIDs hostIDCache
 }
 
+// HostProbe checks if a started host is really usable
+type HostProbe func(host *abstract.Host) error
+
 // GetConfigurationOptions ...
 func (s *Stack) GetConfigurationOptions() stacks.ConfigurationOptions {
 	return *s.Config
@@ -38,6 +57,10 @@ func New(auth stacks.AuthenticationOptions, localCfg stacks.GCPConfiguration, cf
 		Config:      &cfg,
 		AuthOptions: &auth,
 		GcpConfig:   &localCfg,
+		RetryPolicy: retry.DefaultPolicy(),
+	}
+	if _, xerr := stack.instanceServiceAccount(); xerr != nil {
+		return &Stack{}, xerr
 	}
 
 	d1, err := json.MarshalIndent(localCfg, "", "  ")
@@ -57,3 +80,34 @@ func New(auth stacks.AuthenticationOptions, localCfg stacks.GCPConfiguration, cf
 
 	return stack, nil
 }
+
+// CheckProviderHealth lists the regions of the project, a cheap authenticated request, to check the API of GCP is
+// reachable and the credentials are valid
+func (s *Stack) CheckProviderHealth() *abstract.ProviderHealth {
+	start := time.Now()
+	_, err := s.ComputeService.Regions.List(s.GcpConfig.ProjectID).MaxResults(1).Do()
+	return providerHealthFromGoogleError(err, time.Since(start))
+}
+
+// providerHealthFromGoogleError builds the health of the provider from the error returned by a request to GCP
+func providerHealthFromGoogleError(err error, latency time.Duration) *abstract.ProviderHealth {
+	health := &abstract.ProviderHealth{Latency: latency}
+	if err == nil {
+		health.Reachable = true
+		health.Authenticated = true
+		return health
+	}
+
+	health.Error = err.Error()
+	switch cerr := err.(type) {
+	case *googleapi.Error:
+		health.Reachable = true
+		health.Authenticated = cerr.Code != http.StatusUnauthorized && cerr.Code != http.StatusForbidden
+	case *url.Error:
+		// the token endpoint answered but refused the credentials
+		if _, ok := cerr.Err.(*oauth2.RetrieveError); ok {
+			health.Reachable = true
+		}
+	}
+	return health
+}
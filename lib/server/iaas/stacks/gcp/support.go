@@ -90,11 +90,13 @@ type SelfLink = url.URL
 
 // IPInSubnet ...
 type IPInSubnet struct {
-	Subnet   SelfLink
-	Name     string
-	ID       string
-	IP       string
-	PublicIP string
+	Subnet     SelfLink
+	Name       string
+	ID         string
+	IP         string
+	PublicIP   string
+	IPv6       string
+	PublicIPv6 string
 }
 
 func genURL(urlCand string) SelfLink {
@@ -15,4 +15,17 @@ type GCPConfiguration struct {
 	Region       string `json:"-"`
 	Zone         string `json:"-"`
 	NetworkName  string `json:"-"`
+	// PasswordLength is the length of the passwords generated for the hosts (16 if 0)
+	PasswordLength int `json:"-"`
+	// KeyType is the type of the keys generated by CreateKeyPair, "rsa" (the default) or "ed25519"
+	KeyType string `json:"-"`
+	// KeyBits is the size of the RSA keys generated by CreateKeyPair (2048 if 0)
+	KeyBits int `json:"-"`
+	// ServiceAccount is the email of the service account the instances run as ("default" if empty)
+	ServiceAccount string `json:"-"`
+	// Scopes are the OAuth scopes granted to the service account of the instances (storage full control and compute
+	// if empty)
+	Scopes []string `json:"-"`
+	// RequireKeyPair, if true, makes CreateHost refuse requests without key pair instead of generating one
+	RequireKeyPair bool `json:"-"`
 }
@@ -285,7 +285,7 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 	defaultNetwork := request.Networks[0]
 	defaultNetworkID := defaultNetwork.ID
 	defaultGateway := request.DefaultGateway
-	isGateway := defaultGateway == nil && defaultNetwork.Name != abstract.SingleHostNetworkName
+	isGateway := defaultGateway == nil && !defaultNetwork.IsSingleHost()
 	defaultGatewayID := ""
 	defaultGatewayPrivateIP := ""
 	if defaultGateway != nil {
@@ -586,7 +586,7 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 			return nil, userData, err
 		}
 
-		if defaultGateway == nil && defaultNetwork.Name != abstract.SingleHostNetworkName {
+		if defaultGateway == nil && !defaultNetwork.IsSingleHost() {
 			err = s.enableHostRouterMode(host)
 			if err != nil {
 				return nil, userData, fail.Errorf(
@@ -1114,7 +1114,7 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 			}
 
 			hostNetworkV1.DefaultNetworkID = request.Networks[0].ID
-			hostNetworkV1.IsGateway = request.DefaultGateway == nil && request.Networks[0].Name != abstract.SingleHostNetworkName
+			hostNetworkV1.IsGateway = request.DefaultGateway == nil && !request.Networks[0].IsSingleHost()
 			if request.DefaultGateway != nil {
 				hostNetworkV1.DefaultGatewayID = request.DefaultGateway.ID
 
@@ -327,13 +327,14 @@ func (s *Stack) CreateGateway(req abstract.GatewayRequest, sizing *abstract.Sizi
 	}
 
 	hostReq := abstract.HostRequest{
-		ImageID:      imageID,
-		KeyPair:      keyPair,
-		HostName:     req.Name,
-		ResourceName: gwName,
-		TemplateID:   templateID,
-		Networks:     []*abstract.Network{network},
-		PublicIP:     true,
+		ImageID:       imageID,
+		KeyPair:       keyPair,
+		HostName:      req.Name,
+		ResourceName:  gwName,
+		TemplateID:    templateID,
+		Networks:      []*abstract.Network{network},
+		PublicIP:      true,
+		KeepOnFailure: req.KeepOnFailure,
 	}
 	if sizing != nil && sizing.MinDiskSize > 0 {
 		hostReq.DiskSize = sizing.MinDiskSize
@@ -46,6 +46,7 @@ import (
 	converters "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties"
 	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/userdata"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
@@ -109,6 +110,15 @@ func (s *Stack) ListAvailabilityZones() (list map[string]bool, xerr fail.Error)
 	return azList, nil
 }
 
+// imageArch returns the CPU architecture of the image, from its "architecture" property if set (the property
+// Glance uses for it), else as mentioned in its name
+func imageArch(img images.Image) string {
+	if arch, ok := img.Properties["architecture"].(string); ok && arch != "" {
+		return stacks.NormalizeArch(arch)
+	}
+	return stacks.GuessImageArch(img.Name, "")
+}
+
 // ListImages lists available OS images
 func (s *Stack) ListImages() (imgList []abstract.Image, xerr fail.Error) {
 	tracer := debug.NewTracer(nil, "", true).WithStopwatch().GoingIn()
@@ -129,8 +139,14 @@ func (s *Stack) ListImages() (imgList []abstract.Image, xerr fail.Error) {
 			}
 
 			for _, img := range imageList {
-				imgList = append(imgList, abstract.Image{ID: img.ID, Name: img.Name, DiskSize: int64(img.MinDiskGigabytes)})
-
+				imgList = append(
+					imgList, abstract.Image{
+						ID:       img.ID,
+						Name:     img.Name,
+						DiskSize: int64(img.MinDiskGigabytes),
+						Arch:     imageArch(img),
+					},
+				)
 			}
 			return true, nil
 		},
@@ -655,7 +671,7 @@ func (s *Stack) CreateHost(request abstract.HostRequest) (host *abstract.Host, u
 	defaultNetwork := request.Networks[0]
 	defaultNetworkID := defaultNetwork.ID
 	defaultGateway := request.DefaultGateway
-	isGateway := defaultGateway == nil && defaultNetwork.Name != abstract.SingleHostNetworkName
+	isGateway := defaultGateway == nil && !defaultNetwork.IsSingleHost()
 	defaultGatewayID := ""
 	// defaultGatewayPrivateIP := ""
 	if defaultGateway != nil {
@@ -29,6 +29,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
@@ -345,14 +346,15 @@ func (s *Stack) CreateGateway(req abstract.GatewayRequest, sizing *abstract.Sizi
 		return nil, userData, fail.Errorf(fmt.Sprintf("failed to generate password: %s", err.Error()), err)
 	}
 	hostReq := abstract.HostRequest{
-		ImageID:      req.ImageID,
-		KeyPair:      req.KeyPair,
-		HostName:     req.Name,
-		ResourceName: gwname,
-		TemplateID:   req.TemplateID,
-		Networks:     []*abstract.Network{req.Network},
-		PublicIP:     true,
-		Password:     password,
+		ImageID:       req.ImageID,
+		KeyPair:       req.KeyPair,
+		HostName:      req.Name,
+		ResourceName:  gwname,
+		TemplateID:    req.TemplateID,
+		Networks:      []*abstract.Network{req.Network},
+		PublicIP:      true,
+		Password:      password,
+		KeepOnFailure: req.KeepOnFailure,
 	}
 	if sizing != nil && sizing.MinDiskSize > 0 {
 		hostReq.DiskSize = sizing.MinDiskSize
@@ -877,3 +879,72 @@ func (s *Stack) DeleteVIP(vip *abstract.VirtualIP) error {
 	}
 	return ports.Delete(s.NetworkClient, vip.ID).ExtractErr()
 }
+
+// MigrateHost moves the interface of the host identified by 'hostID' from the network 'fromNetworkID' to the network
+// 'toNetworkID' and returns the private IP of the host in the destination network.
+// The interface in the destination network is attached before the ones in the origin network are detached, so the
+// host is never left without interface; if the detach fails, the new interface is detached to leave the host as it was.
+func (s *Stack) MigrateHost(hostID, fromNetworkID, toNetworkID string) (string, fail.Error) {
+	if s == nil {
+		return "", fail.InvalidInstanceError()
+	}
+	if hostID == "" {
+		return "", fail.InvalidParameterError("hostID", "cannot be empty string")
+	}
+	if fromNetworkID == "" {
+		return "", fail.InvalidParameterError("fromNetworkID", "cannot be empty string")
+	}
+	if toNetworkID == "" {
+		return "", fail.InvalidParameterError("toNetworkID", "cannot be empty string")
+	}
+
+	defer debug.NewTracer(nil, fmt.Sprintf("(%s, %s, %s)", hostID, fromNetworkID, toNetworkID), true).WithStopwatch().GoingIn().OnExitTrace()()
+
+	hostPorts, err := s.listPorts(ports.ListOpts{DeviceID: hostID, NetworkID: fromNetworkID})
+	if err != nil {
+		return "", fail.Wrap(TranslateError(err), fmt.Sprintf("failed to list the ports of host '%s'", hostID))
+	}
+	if len(hostPorts) == 0 {
+		return "", fail.NotFoundError(fmt.Sprintf("host '%s' has no interface in network '%s'", hostID, fromNetworkID))
+	}
+
+	nic, err := attachinterfaces.Create(
+		s.ComputeClient, hostID, attachinterfaces.CreateOpts{NetworkID: toNetworkID},
+	).Extract()
+	if err != nil {
+		return "", fail.Wrap(
+			TranslateError(err), fmt.Sprintf(
+				"failed to attach host '%s' to network '%s': %s", hostID, toNetworkID, ProviderErrorToString(err),
+			),
+		)
+	}
+	for _, p := range hostPorts {
+		err = attachinterfaces.Delete(s.ComputeClient, hostID, p.ID).ExtractErr()
+		if err != nil {
+			var xerr fail.Error = fail.Wrap(
+				TranslateError(err), fmt.Sprintf(
+					"failed to detach host '%s' from network '%s': %s", hostID, fromNetworkID, ProviderErrorToString(err),
+				),
+			)
+			derr := attachinterfaces.Delete(s.ComputeClient, hostID, nic.PortID).ExtractErr()
+			if derr != nil {
+				xerr = fail.AddConsequence(xerr, derr)
+			}
+			return "", xerr
+		}
+	}
+	return interfaceIP(nic.FixedIPs), nil
+}
+
+// interfaceIP returns the first IPv4 address of 'fixedIPs', or the first address if there is no IPv4 one
+func interfaceIP(fixedIPs []attachinterfaces.FixedIP) string {
+	for _, ip := range fixedIPs {
+		if ipversion.IPv4.Is(ip.IPAddress) {
+			return ip.IPAddress
+		}
+	}
+	if len(fixedIPs) > 0 {
+		return fixedIPs[0].IPAddress
+	}
+	return ""
+}
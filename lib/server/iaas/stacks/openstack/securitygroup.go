@@ -18,13 +18,17 @@ package openstack
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
 
 	secgroups "github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
 	secrules "github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"github.com/gophercloud/gophercloud/pagination"
+	"github.com/sirupsen/logrus"
 
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
 )
 
@@ -67,6 +71,54 @@ func (s *Stack) getDefaultSecurityGroup() (*secgroups.SecGroup, fail.Error) {
 	return sg, nil
 }
 
+// validateRuleTargets checks the remote IP prefix of a rule is a valid CIDR or IP address of the ether type
+// of the rule
+func validateRuleTargets(ruleOpts secrules.CreateOpts) error {
+	target := ruleOpts.RemoteIPPrefix
+	if target == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(target)
+	if ip == nil {
+		var err error
+		ip, _, err = net.ParseCIDR(target)
+		if err != nil {
+			return fail.InvalidRequestError(fmt.Sprintf("invalid rule target '%s': not a CIDR nor an IP address", target))
+		}
+	}
+	isIPv4 := ip.To4() != nil
+	switch ruleOpts.EtherType {
+	case secrules.EtherType4:
+		if !isIPv4 {
+			return fail.InvalidRequestError(fmt.Sprintf("invalid rule target '%s': not an IPv4 target in an IPv4 rule", target))
+		}
+	case secrules.EtherType6:
+		if isIPv4 {
+			return fail.InvalidRequestError(fmt.Sprintf("invalid rule target '%s': not an IPv6 target in an IPv6 rule", target))
+		}
+	}
+	return nil
+}
+
+// validateRule checks a rule is valid before submitting it to the provider
+func validateRule(ruleOpts secrules.CreateOpts) error {
+	if ruleOpts.SecGroupID == "" {
+		return fail.InvalidRequestError("invalid rule: missing security group ID")
+	}
+	return validateRuleTargets(ruleOpts)
+}
+
+// createRule validates then creates a rule in a security group
+func (s *Stack) createRule(ruleOpts secrules.CreateOpts) error {
+	err := validateRule(ruleOpts)
+	if err != nil {
+		return err
+	}
+	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	return err
+}
+
 // createTCPRules creates TCP rules to configure the default security group
 func (s *Stack) createTCPRules(groupID string) error {
 	// Open TCP Ports
@@ -80,7 +132,7 @@ func (s *Stack) createTCPRules(groupID string) error {
 		RemoteIPPrefix: "0.0.0.0/0",
 	}
 
-	_, err := secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	err := s.createRule(ruleOpts)
 	if err != nil {
 		return err
 	}
@@ -93,7 +145,7 @@ func (s *Stack) createTCPRules(groupID string) error {
 		Protocol:       secrules.ProtocolTCP,
 		RemoteIPPrefix: "::/0",
 	}
-	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	err = s.createRule(ruleOpts)
 	if err != nil {
 		return err
 	}
@@ -108,7 +160,7 @@ func (s *Stack) createTCPRules(groupID string) error {
 		Protocol:       secrules.ProtocolTCP,
 		RemoteIPPrefix: "0.0.0.0/0",
 	}
-	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	err = s.createRule(ruleOpts)
 	if err != nil {
 		return err
 	}
@@ -121,7 +173,7 @@ func (s *Stack) createTCPRules(groupID string) error {
 		Protocol:       secrules.ProtocolTCP,
 		RemoteIPPrefix: "::/0",
 	}
-	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	err = s.createRule(ruleOpts)
 	return err
 }
 
@@ -137,7 +189,7 @@ func (s *Stack) createUDPRules(groupID string) error {
 		Protocol:       secrules.ProtocolUDP,
 		RemoteIPPrefix: "0.0.0.0/0",
 	}
-	_, err := secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	err := s.createRule(ruleOpts)
 	if err != nil {
 		return err
 	}
@@ -150,7 +202,7 @@ func (s *Stack) createUDPRules(groupID string) error {
 		Protocol:       secrules.ProtocolUDP,
 		RemoteIPPrefix: "::/0",
 	}
-	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	err = s.createRule(ruleOpts)
 	if err != nil {
 		return err
 	}
@@ -165,7 +217,7 @@ func (s *Stack) createUDPRules(groupID string) error {
 		Protocol:       secrules.ProtocolUDP,
 		RemoteIPPrefix: "0.0.0.0/0",
 	}
-	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	err = s.createRule(ruleOpts)
 	if err != nil {
 		return err
 	}
@@ -178,57 +230,202 @@ func (s *Stack) createUDPRules(groupID string) error {
 		Protocol:       secrules.ProtocolUDP,
 		RemoteIPPrefix: "::/0",
 	}
-	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	err = s.createRule(ruleOpts)
 	return err
 }
 
+// icmpProtocol returns the ICMP protocol to use in a rule for the ether type (see stacks.NormalizeProtocol)
+func icmpProtocol(etherType secrules.RuleEtherType) secrules.RuleProtocol {
+	return secrules.RuleProtocol(stacks.NormalizeProtocol(stacks.ProtocolICMP, etherType == secrules.EtherType6))
+}
+
+// icmpRules builds the ICMP rules of the default security group
+func icmpRules(groupID string) []secrules.CreateOpts {
+	var rules []secrules.CreateOpts
+	// Inbound == ingress == coming from Outside, Outbound = egress == going to Outside
+	for _, direction := range []secrules.RuleDirection{secrules.DirIngress, secrules.DirEgress} {
+		rules = append(
+			rules,
+			secrules.CreateOpts{
+				Direction:      direction,
+				EtherType:      secrules.EtherType4,
+				SecGroupID:     groupID,
+				Protocol:       icmpProtocol(secrules.EtherType4),
+				RemoteIPPrefix: "0.0.0.0/0",
+			},
+			secrules.CreateOpts{
+				Direction:      direction,
+				EtherType:      secrules.EtherType6,
+				SecGroupID:     groupID,
+				Protocol:       icmpProtocol(secrules.EtherType6),
+				RemoteIPPrefix: "::/0",
+			},
+		)
+	}
+	return rules
+}
+
 // createICMPRules creates ICMP rules inside the default security group
 func (s *Stack) createICMPRules(groupID string) error {
-	// Inbound == ingress == coming from Outside
-	ruleOpts := secrules.CreateOpts{
-		Direction:      secrules.DirIngress,
-		EtherType:      secrules.EtherType4,
-		SecGroupID:     groupID,
-		Protocol:       secrules.ProtocolICMP,
-		RemoteIPPrefix: "0.0.0.0/0",
+	for _, ruleOpts := range icmpRules(groupID) {
+		err := s.createRule(ruleOpts)
+		if err != nil {
+			return err
+		}
 	}
-	_, err := secrules.Create(s.NetworkClient, ruleOpts).Extract()
-	if err != nil {
-		return err
+	return nil
+}
+
+// ruleCreateOpts converts the security rule 'rule', expected to be valid, into rules of the security group
+// 'groupID'; an OpenStack rule applies to one direction and one target, so a rule may give several of them
+func ruleCreateOpts(groupID string, rule abstract.SecurityGroupRule) []secrules.CreateOpts {
+	var directions []secrules.RuleDirection
+	switch rule.Direction {
+	case abstract.SecurityGroupRuleIngress:
+		directions = []secrules.RuleDirection{secrules.DirIngress}
+	case abstract.SecurityGroupRuleEgress:
+		directions = []secrules.RuleDirection{secrules.DirEgress}
+	default:
+		directions = []secrules.RuleDirection{secrules.DirIngress, secrules.DirEgress}
+	}
+	portTo := rule.PortTo
+	if portTo == 0 {
+		portTo = rule.PortFrom
 	}
-	ruleOpts = secrules.CreateOpts{
-		Direction:      secrules.DirIngress,
-		EtherType:      secrules.EtherType6,
-		SecGroupID:     groupID,
-		Protocol:       secrules.ProtocolICMP,
-		RemoteIPPrefix: "::/0",
+
+	var opts []secrules.CreateOpts
+	for _, direction := range directions {
+		for _, target := range rule.Targets {
+			etherType := secrules.EtherType4
+			if ip, _, err := net.ParseCIDR(target); err == nil && ip.To4() == nil {
+				etherType = secrules.EtherType6
+			}
+			protocol := stacks.NormalizeProtocol(rule.Protocol, etherType == secrules.EtherType6)
+			if protocol == stacks.ProtocolAll {
+				// OpenStack designates all the protocols by an empty protocol
+				protocol = ""
+			}
+			opts = append(
+				opts, secrules.CreateOpts{
+					Direction:      direction,
+					EtherType:      etherType,
+					SecGroupID:     groupID,
+					PortRangeMin:   rule.PortFrom,
+					PortRangeMax:   portTo,
+					Protocol:       secrules.RuleProtocol(protocol),
+					RemoteIPPrefix: target,
+				},
+			)
+		}
+	}
+	return opts
+}
+
+// CreateSecurityGroup creates a security group named 'name' containing 'rules'
+func (s *Stack) CreateSecurityGroup(name, description string, rules []abstract.SecurityGroupRule) (_ *abstract.SecurityGroup, xerr fail.Error) {
+	if s == nil {
+		return nil, fail.InvalidInstanceError()
 	}
-	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
+	if name == "" {
+		return nil, fail.InvalidParameterError("name", "cannot be empty string")
+	}
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fail.InvalidParameterError("rules", fmt.Sprintf("rule #%d is invalid: %v", i, err))
+		}
+	}
+
+	group, err := secgroups.Create(s.NetworkClient, secgroups.CreateOpts{Name: name, Description: description}).Extract()
 	if err != nil {
-		return err
+		return nil, fail.Wrap(TranslateError(err), fmt.Sprintf("failed to create security group '%s'", name))
 	}
 
-	// Outbound = egress == going to Outside
-	ruleOpts = secrules.CreateOpts{
-		Direction:      secrules.DirEgress,
-		EtherType:      secrules.EtherType4,
-		SecGroupID:     groupID,
-		Protocol:       secrules.ProtocolICMP,
-		RemoteIPPrefix: "0.0.0.0/0",
+	// Starting from here, deletes the security group if exiting with error
+	defer func() {
+		if xerr != nil {
+			if derr := s.DeleteSecurityGroup(group.ID); derr != nil {
+				xerr = fail.AddConsequence(xerr, derr)
+			}
+		}
+	}()
+
+	for _, rule := range rules {
+		for _, ruleOpts := range ruleCreateOpts(group.ID, rule) {
+			if err = s.createRule(ruleOpts); err != nil {
+				return nil, fail.Wrap(
+					TranslateError(err), fmt.Sprintf("failed to add rule to security group '%s'", name),
+				)
+			}
+		}
 	}
-	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
+
+	return &abstract.SecurityGroup{
+		ID:          group.ID,
+		Name:        group.Name,
+		Description: description,
+		Rules:       rules,
+	}, nil
+}
+
+// DeleteSecurityGroup deletes the security group identified by 'id'; a security group already deleted is not an error
+func (s *Stack) DeleteSecurityGroup(id string) fail.Error {
+	if s == nil {
+		return fail.InvalidInstanceError()
+	}
+	if id == "" {
+		return fail.InvalidParameterError("id", "cannot be empty string")
+	}
+
+	err := secgroups.Delete(s.NetworkClient, id).ExtractErr()
 	if err != nil {
-		return err
+		if _, ok := TranslateError(err).(fail.ErrNotFound); ok {
+			logrus.Debugf("security group '%s' already deleted", id)
+			return nil
+		}
+		return fail.Wrap(TranslateError(err), fmt.Sprintf("failed to delete security group '%s'", id))
 	}
-	ruleOpts = secrules.CreateOpts{
-		Direction:      secrules.DirEgress,
-		EtherType:      secrules.EtherType6,
-		SecGroupID:     groupID,
-		Protocol:       secrules.ProtocolICMP,
-		RemoteIPPrefix: "::/0",
+	return nil
+}
+
+// BindSecurityGroupToHost adds the security group identified by 'sgID' to the ports of the host identified by 'hostID'
+func (s *Stack) BindSecurityGroupToHost(sgID, hostID string) fail.Error {
+	if s == nil {
+		return fail.InvalidInstanceError()
 	}
-	_, err = secrules.Create(s.NetworkClient, ruleOpts).Extract()
-	return err
+	if sgID == "" {
+		return fail.InvalidParameterError("sgID", "cannot be empty string")
+	}
+	if hostID == "" {
+		return fail.InvalidParameterError("hostID", "cannot be empty string")
+	}
+
+	hostPorts, err := s.listPorts(ports.ListOpts{DeviceID: hostID})
+	if err != nil {
+		return fail.Wrap(TranslateError(err), fmt.Sprintf("failed to list the ports of host '%s'", hostID))
+	}
+	for _, p := range hostPorts {
+		groups := appendSecurityGroup(p.SecurityGroups, sgID)
+		if len(groups) == len(p.SecurityGroups) {
+			continue
+		}
+		_, err = ports.Update(s.NetworkClient, p.ID, ports.UpdateOpts{SecurityGroups: &groups}).Extract()
+		if err != nil {
+			return fail.Wrap(
+				TranslateError(err), fmt.Sprintf("failed to bind security group '%s' to host '%s'", sgID, hostID),
+			)
+		}
+	}
+	return nil
+}
+
+// appendSecurityGroup returns 'groups' with 'sgID' appended, if not already there
+func appendSecurityGroup(groups []string, sgID string) []string {
+	for _, g := range groups {
+		if g == sgID {
+			return groups
+		}
+	}
+	return append(append([]string{}, groups...), sgID)
 }
 
 // InitDefaultSecurityGroup create an open Security Group
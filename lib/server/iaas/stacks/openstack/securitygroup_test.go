@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"testing"
+
+	secrules "github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+func TestICMPProtocol(t *testing.T) {
+	assert.Equal(t, secrules.ProtocolICMP, icmpProtocol(secrules.EtherType4))
+	assert.Equal(t, secrules.ProtocolIPv6ICMP, icmpProtocol(secrules.EtherType6))
+	assert.Equal(t, secrules.RuleProtocol("ipv6-icmp"), icmpProtocol(secrules.EtherType6))
+}
+
+func TestICMPRules(t *testing.T) {
+	rules := icmpRules("sg-id")
+	assert.Len(t, rules, 4)
+	for _, r := range rules {
+		assert.Equal(t, "sg-id", r.SecGroupID)
+		switch r.EtherType {
+		case secrules.EtherType4:
+			assert.Equal(t, secrules.ProtocolICMP, r.Protocol)
+			assert.Equal(t, "0.0.0.0/0", r.RemoteIPPrefix)
+		case secrules.EtherType6:
+			assert.Equal(t, secrules.ProtocolIPv6ICMP, r.Protocol)
+			assert.Equal(t, "::/0", r.RemoteIPPrefix)
+		default:
+			t.Errorf("unexpected ether type '%s'", r.EtherType)
+		}
+	}
+}
+
+func TestValidateRuleTargets(t *testing.T) {
+	rule := secrules.CreateOpts{SecGroupID: "sg-id", EtherType: secrules.EtherType4, RemoteIPPrefix: "0.0.0./0"}
+	err := validateRule(rule)
+	if assert.NotNil(t, err) {
+		_, ok := err.(fail.ErrInvalidRequest)
+		assert.True(t, ok)
+		assert.Contains(t, err.Error(), "0.0.0./0")
+	}
+
+	// ether type mismatch
+	rule.RemoteIPPrefix = "::/0"
+	err = validateRule(rule)
+	if assert.NotNil(t, err) {
+		_, ok := err.(fail.ErrInvalidRequest)
+		assert.True(t, ok)
+	}
+	rule = secrules.CreateOpts{SecGroupID: "sg-id", EtherType: secrules.EtherType6, RemoteIPPrefix: "10.0.0.0/8"}
+	assert.NotNil(t, validateRule(rule))
+
+	// valid targets
+	for _, r := range []secrules.CreateOpts{
+		{SecGroupID: "sg-id", EtherType: secrules.EtherType4, RemoteIPPrefix: "0.0.0.0/0"},
+		{SecGroupID: "sg-id", EtherType: secrules.EtherType4, RemoteIPPrefix: "192.168.1.12"},
+		{SecGroupID: "sg-id", EtherType: secrules.EtherType6, RemoteIPPrefix: "2001:db8::/32"},
+		{SecGroupID: "sg-id", EtherType: secrules.EtherType6, RemoteIPPrefix: "2001:db8::1"},
+		{SecGroupID: "sg-id", EtherType: secrules.EtherType4},
+	} {
+		assert.Nil(t, validateRule(r), r.RemoteIPPrefix)
+	}
+
+	// default rules are valid
+	for _, r := range icmpRules("sg-id") {
+		assert.Nil(t, validateRule(r))
+	}
+	assert.NotNil(t, validateRule(secrules.CreateOpts{EtherType: secrules.EtherType4}))
+}
+
+func TestRuleCreateOpts(t *testing.T) {
+	rule := abstract.SecurityGroupRule{
+		Direction: abstract.SecurityGroupRuleBoth,
+		Protocol:  "TCP",
+		PortFrom:  22,
+		Targets:   []string{"10.0.0.0/8", "fd00::/64"},
+	}
+	opts := ruleCreateOpts("sg-id", rule)
+	require.Len(t, opts, 4)
+	assert.Equal(t, secrules.DirIngress, opts[0].Direction)
+	assert.Equal(t, secrules.EtherType4, opts[0].EtherType)
+	assert.Equal(t, secrules.ProtocolTCP, opts[0].Protocol)
+	assert.Equal(t, 22, opts[0].PortRangeMin)
+	assert.Equal(t, 22, opts[0].PortRangeMax)
+	assert.Equal(t, secrules.EtherType6, opts[1].EtherType)
+	assert.Equal(t, "fd00::/64", opts[1].RemoteIPPrefix)
+	assert.Equal(t, secrules.DirEgress, opts[2].Direction)
+	for _, o := range opts {
+		assert.Nil(t, validateRule(o))
+	}
+
+	// the default rules give the same rules as the ones of the default security group
+	var icmp []secrules.CreateOpts
+	for _, r := range stacks.DefaultICMPRules() {
+		icmp = append(icmp, ruleCreateOpts("sg-id", r)...)
+	}
+	assert.ElementsMatch(t, icmpRules("sg-id"), icmp)
+
+	// all the protocols are designated by an empty protocol
+	all := ruleCreateOpts("sg-id", abstract.SecurityGroupRule{Direction: abstract.SecurityGroupRuleEgress, Targets: []string{"0.0.0.0/0"}})
+	require.Len(t, all, 1)
+	assert.Equal(t, secrules.RuleProtocol(""), all[0].Protocol)
+	assert.Equal(t, secrules.DirEgress, all[0].Direction)
+}
+
+func TestAppendSecurityGroup(t *testing.T) {
+	groups := []string{"sg-default"}
+	assert.Equal(t, []string{"sg-default", "sg-net"}, appendSecurityGroup(groups, "sg-net"))
+	assert.Equal(t, []string{"sg-default"}, groups)
+	assert.Equal(t, []string{"sg-default"}, appendSecurityGroup(groups, "sg-default"))
+}
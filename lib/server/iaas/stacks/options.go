@@ -120,6 +120,10 @@ type ConfigurationOptions struct {
 	// DefaultImage names the image to use when not specified by the user
 	DefaultImage string
 
+	// DefaultImages names the images to use when not specified by the user, keyed by CPU architecture
+	// ("arm64", "x86_64", ...) optionally suffixed by the IP version ("arm64/ipv6"); DefaultImage is the fallback
+	DefaultImages map[string]string
+
 	// MetadataBucket contains the name of the Object Storage bucket that will store metadata
 	MetadataBucket string
 
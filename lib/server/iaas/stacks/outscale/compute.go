@@ -35,6 +35,7 @@ import (
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hoststate"
 	propertiesv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/userdata"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/stacks"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
@@ -77,6 +78,7 @@ func (s *Stack) ListImages(bool) ([]abstract.Image, fail.Error) {
 				Name:        normalizeImageName(omi.ImageName),
 				URL:         omi.FileLocation,
 				StorageType: omi.RootDeviceType,
+				Arch:        stacks.NormalizeArch(omi.Architecture),
 			},
 		)
 	}
@@ -782,7 +784,7 @@ func (s *Stack) initHostProperties(request *abstract.HostRequest, host *abstract
 		}
 		return request.Networks[0]
 	}()
-	isGateway := request.DefaultGateway == nil && defaultNet != nil && defaultNet.Name != abstract.SingleHostNetworkName
+	isGateway := request.DefaultGateway == nil && defaultNet != nil && !defaultNet.IsSingleHost()
 	defaultGatewayID := func() string {
 		if request.DefaultGateway != nil {
 			return request.DefaultGateway.ID
@@ -25,6 +25,7 @@ type ComputeConfiguration struct {
 	Subregion               string
 	Service                 string
 	DefaultImage            string
+	DefaultImages           map[string]string
 	DefaultVolumeSpeed      volumespeed.Enum
 	DefaultTenancy          string
 	DNSList                 []string
@@ -115,6 +116,7 @@ func New(options *ConfigurationOptions) (*Stack, fail.Error) {
 			AutoHostNetworkInterfaces: false,
 			VolumeSpeeds:              volumeSpeeds,
 			DefaultImage:              options.Compute.DefaultImage,
+			DefaultImages:             options.Compute.DefaultImages,
 			MetadataBucket:            options.Metadata.Bucket,
 			OperatorUsername:          options.Compute.OperatorUsername,
 			BlacklistImageRegexp:      options.Compute.BlacklistImageRegexp,
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stacks
+
+import "strings"
+
+const (
+	// ProtocolAll designates all the protocols in a security rule
+	ProtocolAll = "all"
+	// ProtocolICMP is the ICMP protocol of IPv4
+	ProtocolICMP = "icmp"
+	// ProtocolICMPv6 is the ICMP protocol of IPv6; IPv6 rules must use it, "icmp" being rejected or ignored for them
+	ProtocolICMPv6 = "ipv6-icmp"
+)
+
+// NormalizeProtocol returns the generic name of the protocol of a security rule applying to IPv4 or IPv6 traffic:
+// the name is lower-cased, ProtocolAll if empty, and the spellings of ICMP ("icmp", "icmpv6", "ipv6-icmp", "58")
+// give ProtocolICMP or ProtocolICMPv6 depending on 'ipv6'. Stacks map the result to the values of their provider.
+func NormalizeProtocol(protocol string, ipv6 bool) string {
+	switch p := strings.ToLower(strings.TrimSpace(protocol)); p {
+	case "":
+		return ProtocolAll
+	case ProtocolICMP, "icmpv6", ProtocolICMPv6, "58":
+		if ipv6 {
+			return ProtocolICMPv6
+		}
+		return ProtocolICMP
+	default:
+		return p
+	}
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stacks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeProtocol(t *testing.T) {
+	assert.Equal(t, ProtocolAll, NormalizeProtocol("", false))
+	assert.Equal(t, "tcp", NormalizeProtocol("TCP", false))
+	assert.Equal(t, "udp", NormalizeProtocol("udp", true))
+
+	for _, icmp := range []string{"icmp", "ICMP", "icmpv6", "ipv6-icmp", "58"} {
+		assert.Equal(t, ProtocolICMP, NormalizeProtocol(icmp, false), icmp)
+		assert.Equal(t, ProtocolICMPv6, NormalizeProtocol(icmp, true), icmp)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stacks
+
+import "github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+
+// anywhere contains the targets of the default rules, one per IP version
+var anywhere = []string{"0.0.0.0/0", "::/0"}
+
+// defaultRules returns the rules allowing the traffic of 'protocol' on the ports 'portFrom' to 'portTo', in both
+// directions, one rule per IP version
+func defaultRules(description, protocol string, portFrom, portTo int) []abstract.SecurityGroupRule {
+	rules := make([]abstract.SecurityGroupRule, 0, len(anywhere))
+	for _, target := range anywhere {
+		rules = append(
+			rules, abstract.SecurityGroupRule{
+				Description: description,
+				Direction:   abstract.SecurityGroupRuleBoth,
+				Protocol:    protocol,
+				PortFrom:    portFrom,
+				PortTo:      portTo,
+				Targets:     []string{target},
+			},
+		)
+	}
+	return rules
+}
+
+// DefaultTCPRules returns the rules of the default security group allowing all the TCP traffic
+func DefaultTCPRules() []abstract.SecurityGroupRule {
+	return defaultRules("all TCP ports", "tcp", 1, 65535)
+}
+
+// DefaultUDPRules returns the rules of the default security group allowing all the UDP traffic
+func DefaultUDPRules() []abstract.SecurityGroupRule {
+	return defaultRules("all UDP ports", "udp", 1, 65535)
+}
+
+// DefaultICMPRules returns the rules of the default security group allowing all the ICMP traffic, ICMPv6 for
+// the IPv6 rule
+func DefaultICMPRules() []abstract.SecurityGroupRule {
+	return defaultRules("ICMP", ProtocolICMP, 0, 0)
+}
+
+// DefaultSecurityGroupRules returns the rules of the default security group of a network: all the TCP, UDP and
+// ICMP traffic is allowed, filtering being done on each host by its firewall
+func DefaultSecurityGroupRules() []abstract.SecurityGroupRule {
+	rules := DefaultTCPRules()
+	rules = append(rules, DefaultUDPRules()...)
+	return append(rules, DefaultICMPRules()...)
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stacks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+)
+
+func TestDefaultSecurityGroupRules(t *testing.T) {
+	rules := DefaultSecurityGroupRules()
+	assert.Len(t, rules, 6)
+	for _, rule := range rules {
+		assert.Nil(t, rule.Validate())
+		assert.Equal(t, abstract.SecurityGroupRuleBoth, rule.Direction)
+		assert.Len(t, rule.Targets, 1)
+	}
+
+	tcp := DefaultTCPRules()
+	assert.Equal(t, "tcp", tcp[0].Protocol)
+	assert.Equal(t, 1, tcp[0].PortFrom)
+	assert.Equal(t, 65535, tcp[0].PortTo)
+	assert.Equal(t, []string{"0.0.0.0/0"}, tcp[0].Targets)
+	assert.Equal(t, []string{"::/0"}, tcp[1].Targets)
+
+	icmp := DefaultICMPRules()
+	assert.Equal(t, ProtocolICMP, icmp[1].Protocol)
+	assert.Equal(t, 0, icmp[1].PortFrom)
+	// the IPv6 rule of ICMP applies to ICMPv6
+	assert.Equal(t, ProtocolICMPv6, NormalizeProtocol(icmp[1].Protocol, true))
+}
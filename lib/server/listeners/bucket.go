@@ -222,7 +222,7 @@ func (s *BucketListener) Mount(ctx context.Context, in *pb.BucketMountingPoint)
 	handler := BucketHandler(tenant.Service)
 	err = handler.Mount(ctx, bucketName, hostName, in.GetPath())
 	if err != nil {
-		return &googleprotobuf.Empty{}, status.Errorf(codes.Internal, getUserMessage(err))
+		return &googleprotobuf.Empty{}, errorStatus(ctx, err)
 	}
 	return &googleprotobuf.Empty{}, nil
 }
@@ -250,7 +250,7 @@ func (s *BucketListener) Unmount(ctx context.Context, in *pb.BucketMountingPoint
 	handler := BucketHandler(tenant.Service)
 	err = handler.Unmount(ctx, bucketName, hostName)
 	if err != nil {
-		return &googleprotobuf.Empty{}, status.Errorf(codes.Internal, getUserMessage(err))
+		return &googleprotobuf.Empty{}, errorStatus(ctx, err)
 	}
 	return &googleprotobuf.Empty{}, nil
 }
@@ -1,6 +1,11 @@
 package listeners
 
 import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
 )
 
@@ -29,3 +34,16 @@ func adaptedUserMessage(err error) string {
 
 	return adapted
 }
+
+// errorStatus converts the error returned by a handler into a gRPC status carrying its kind and its causes (see
+// fail.ToGRPCStatus); if the context of the request has been canceled or has expired, the status tells so instead
+func errorStatus(ctx context.Context, err error) error {
+	cause := fail.Cause(err)
+	switch {
+	case cause == context.Canceled || (ctx != nil && ctx.Err() == context.Canceled):
+		return status.Errorf(codes.Canceled, getUserMessage(err))
+	case cause == context.DeadlineExceeded || (ctx != nil && ctx.Err() == context.DeadlineExceeded):
+		return status.Errorf(codes.DeadlineExceeded, getUserMessage(err))
+	}
+	return fail.ToGRPCStatus(err).Err()
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+func TestErrorStatus(t *testing.T) {
+	err := errorStatus(context.Background(), fail.NotFoundError("network 'net' not found"))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = errorStatus(ctx, fail.AbortedError("creation of network net", ctx.Err()))
+	assert.Equal(t, codes.Canceled, status.Code(err))
+
+	// the handler may notice the cancellation before the listener does
+	err = errorStatus(context.Background(), fail.AbortedError("creation of network net", context.Canceled))
+	assert.Equal(t, codes.Canceled, status.Code(err))
+
+	err = errorStatus(context.Background(), fail.AbortedError("creation of network net", context.DeadlineExceeded))
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+// failingHealthServer is a gRPC service returning the error of a handler as the listeners do
+type failingHealthServer struct {
+	healthpb.HealthServer
+	err error
+}
+
+func (s *failingHealthServer) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return nil, errorStatus(ctx, s.err)
+}
+
+// callFailingServer returns the error received by a client of safescaled when a listener fails with 'err'
+func callFailingServer(t *testing.T, err error) error {
+	lis, xerr := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, xerr)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, &failingHealthServer{err: err})
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	conn := srvutils.GetConnection("127.0.0.1", lis.Addr().(*net.TCPAddr).Port)
+	defer func() {
+		_ = conn.Close()
+	}()
+	_, xerr = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	return xerr
+}
+
+func TestErrorStatus_RoundTrip(t *testing.T) {
+	err := callFailingServer(
+		t, fail.AbortedError("creation of network net", fail.TimeoutError("gateway not ready", 0, nil)),
+	)
+	require.NotNil(t, err)
+	assert.IsType(t, fail.ErrAborted{}, err)
+	assert.IsType(t, fail.ErrTimeout{}, fail.Cause(err))
+}
+
+func TestErrorStatus_InvalidParameter(t *testing.T) {
+	st := status.Convert(errorStatus(context.Background(), fail.InvalidParameterError("cidr", "is not a valid CIDR")))
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = append(violations, badRequest.GetFieldViolations()...)
+		}
+	}
+	require.Len(t, violations, 1)
+	assert.Equal(t, "cidr", violations[0].GetField())
+
+	// the client gets back the parameter in violation
+	err := callFailingServer(t, fail.InvalidParameterError("cidr", "is not a valid CIDR"))
+	require.NotNil(t, err)
+	xerr, ok := err.(fail.ErrInvalidParameter)
+	require.True(t, ok)
+	assert.Equal(t, "cidr", xerr.Parameter())
+}
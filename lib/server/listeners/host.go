@@ -98,7 +98,7 @@ func (s *HostListener) Start(ctx context.Context, in *pb.Reference) (empty *goog
 	handler := HostHandler(tenant.Service)
 	err = handler.Start(ctx, ref)
 	if err != nil {
-		return empty, status.Errorf(codes.Internal, getUserMessage(err))
+		return empty, errorStatus(ctx, err)
 	}
 
 	log.Infof("Host '%s' successfully started", ref)
@@ -136,7 +136,7 @@ func (s *HostListener) Stop(ctx context.Context, in *pb.Reference) (empty *googl
 	handler := HostHandler(tenant.Service)
 	err = handler.Stop(ctx, ref)
 	if err != nil {
-		return empty, status.Errorf(codes.Internal, getUserMessage(err))
+		return empty, errorStatus(ctx, err)
 	}
 
 	log.Infof("Host '%s' stopped", ref)
@@ -175,7 +175,7 @@ func (s *HostListener) Reboot(ctx context.Context, in *pb.Reference) (empty *goo
 	handler := HostHandler(tenant.Service)
 	err = handler.Reboot(ctx, ref)
 	if err != nil {
-		return empty, status.Errorf(codes.Internal, getUserMessage(err))
+		return empty, errorStatus(ctx, err)
 	}
 
 	log.Infof("Host '%s' successfully rebooted.", ref)
@@ -208,7 +208,7 @@ func (s *HostListener) List(ctx context.Context, in *pb.HostListRequest) (hl *pb
 	handler := HostHandler(tenant.Service)
 	hosts, err := handler.List(ctx, all)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	// Map abstract.Host to pb.Host
@@ -284,7 +284,7 @@ func (s *HostListener) Create(ctx context.Context, in *pb.HostDefinition) (h *pb
 		in.KeepOnFailure,
 	)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	if host == nil {
 		return nil, status.Errorf(codes.Internal, "host operation failure with nil result and nil error")
@@ -332,7 +332,7 @@ func (s *HostListener) Resize(ctx context.Context, in *pb.HostDefinition) (h *pb
 		float32(in.GetCpuFreq()),
 	)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	log.Infof("Host '%s' resized", name)
 	return srvutils.ToPBHost(host)
@@ -371,7 +371,7 @@ func (s *HostListener) Status(ctx context.Context, in *pb.Reference) (ht *pb.Hos
 	handler := HostHandler(tenant.Service)
 	host, err := handler.ForceInspect(ctx, ref)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	return srvutils.ToHostStatus(host)
 }
@@ -448,7 +448,7 @@ func (s *HostListener) Delete(ctx context.Context, in *pb.Reference) (empty *goo
 	handler := HostHandler(tenant.Service)
 	err = handler.Delete(ctx, ref)
 	if err != nil {
-		return empty, status.Errorf(codes.Internal, getUserMessage(err))
+		return empty, errorStatus(ctx, err)
 	}
 	log.Infof("Host '%s' successfully deleted.", ref)
 	return empty, nil
@@ -490,7 +490,7 @@ func (s *HostListener) SSH(ctx context.Context, in *pb.Reference) (sc *pb.SshCon
 		if _, ok := err.(fail.ErrNotFound); ok {
 			return nil, status.Errorf(codes.Internal, fmt.Sprintf("host '%s' not found", ref))
 		}
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	return srvutils.ToPBSshConfig(sshConfig)
 }
@@ -63,7 +63,7 @@ func (s *ImageListener) List(ctx context.Context, in *pb.ImageListRequest) (il *
 	handler := ImageHandler(currentTenant.Service)
 	images, err := handler.List(ctx, in.GetAll())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	// Map abstract.Image to pb.Image
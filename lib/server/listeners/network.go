@@ -100,20 +100,24 @@ func (s *NetworkListener) Create(ctx context.Context, in *pb.NetworkDefinition)
 	}
 
 	handler := NetworkHandler(tenant.Service)
-	network, err := handler.Create(
-		ctx,
-		networkName,
-		in.GetCidr(),
-		ipversion.IPv4,
-		*sizing,
-		gwImageID,
-		gwName,
-		in.FailOver,
-		in.Domain,
-		in.KeepOnFailure,
+	ingressPorts := make([]int, 0, len(in.GetAdditionalIngressPorts()))
+	for _, port := range in.GetAdditionalIngressPorts() {
+		ingressPorts = append(ingressPorts, int(port))
+	}
+	network, err := handler.CreateWithOptions(
+		ctx, networkName, in.GetCidr(), ipversion.IPv4, *sizing, handlers.NetworkCreateOptions{
+			OS:                     gwImageID,
+			GatewayName:            gwName,
+			Failover:               in.GetFailOver(),
+			Domain:                 in.GetDomain(),
+			KeepOnFailure:          in.GetKeepOnFailure(),
+			AdditionalIngressPorts: ingressPorts,
+			MTU:                    int(in.GetMtu()),
+			ExpectedHostCount:      int(in.GetExpectedHostCount()),
+		},
 	)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	if network == nil {
 		return nil, status.Errorf(codes.Internal, "network operation failure with nil result and nil error")
@@ -152,7 +156,7 @@ func (s *NetworkListener) List(ctx context.Context, in *pb.NetworkListRequest) (
 	handler := NetworkHandler(tenant.Service)
 	networks, err := handler.List(ctx, in.GetAll())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	// Map abstract.Network to pb.Network
@@ -203,7 +207,7 @@ func (s *NetworkListener) Inspect(ctx context.Context, in *pb.Reference) (net *p
 	handler := NetworkHandler(currentTenant.Service)
 	network, err := handler.Inspect(ctx, ref)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	if network == nil {
 		return nil, status.Errorf(codes.NotFound, fmt.Sprintf("cannot inspect network '%s': not found", ref))
@@ -245,7 +249,7 @@ func (s *NetworkListener) Delete(ctx context.Context, in *pb.Reference) (buf *go
 	handler := NetworkHandler(currentTenant.Service)
 	err = handler.Delete(ctx, ref)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	log.Infof("Network '%s' successfully deleted.", ref)
@@ -285,7 +289,7 @@ func (s *NetworkListener) Destroy(ctx context.Context, in *pb.Reference) (buf *g
 	handler := NetworkHandler(currentTenant.Service)
 	err = handler.Destroy(ctx, ref)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	log.Infof("Network '%s' successfully deleted.", ref)
@@ -307,7 +307,7 @@ func (s *ShareListener) Inspect(ctx context.Context, in *pb.Reference) (sml *pb.
 	host, share, mounts, err := handler.Inspect(ctx, shareRef)
 	if err != nil {
 		err := fail.Wrap(err, fmt.Sprintf("cannot inspect share '%s'", shareRef)+adaptedUserMessage(err))
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	if host == nil {
 		return nil, abstract.ResourceNotFoundError("share", shareRef)
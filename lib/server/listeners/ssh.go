@@ -79,7 +79,7 @@ func (s *SSHListener) Run(ctx context.Context, in *pb.SshCommand) (sr *pb.SshRes
 	handler := SSHHandler(tenant.Service)
 	retcode, stdout, stderr, err := handler.Run(ctx, host, command, outputs.DISPLAY)
 	if err != nil {
-		err = status.Errorf(codes.Internal, getUserMessage(err))
+		err = errorStatus(ctx, err)
 	}
 	return &pb.SshResponse{
 		Status:    int32(retcode),
@@ -119,7 +119,7 @@ func (s *SSHListener) Copy(ctx context.Context, in *pb.SshCopyCommand) (sr *pb.S
 	handler := SSHHandler(tenant.Service)
 	retcode, stdout, stderr, err := handler.Copy(ctx, source, dest)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	if retcode != 0 {
 		return nil, fmt.Errorf(
@@ -64,7 +64,7 @@ func (s *TemplateListener) List(ctx context.Context, in *pb.TemplateListRequest)
 	handler := TemplateHandler(tenant.Service)
 	templates, err := handler.List(ctx, all)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	// Map abstract.Host to pb.Host
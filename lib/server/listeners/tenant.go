@@ -87,7 +87,7 @@ func (s *TenantListener) List(ctx context.Context, in *googleprotobuf.Empty) (li
 
 	tenants, err := iaas.GetTenantNames()
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	var tl []*pb.Tenant
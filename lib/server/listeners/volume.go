@@ -79,7 +79,7 @@ func (s *VolumeListener) List(ctx context.Context, in *pb.VolumeListRequest) (_
 	handler := VolumeHandler(tenant.Service)
 	volumes, err := handler.List(ctx, in.GetAll())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	// Map abstract.Volume to pb.Volume
@@ -132,7 +132,7 @@ func (s *VolumeListener) Create(ctx context.Context, in *pb.VolumeDefinition) (_
 	handler := VolumeHandler(tenant.Service)
 	vol, err := handler.Create(ctx, name, int(size), volumespeed.Enum(speed))
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	if vol == nil {
 		return nil, status.Errorf(codes.Internal, "volume operation failure with nil result and nil error")
@@ -159,7 +159,7 @@ func (s *VolumeListener) Expand(ctx context.Context, in *pb.VolumeSizeChange) (*
 
 	err := handler.Expand(ctx, volumeName, hostName, in.ChangeSize, in.ChangeSizeType)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	log.Println(fmt.Sprintf("Volume '%s' expanded", volumeName))
@@ -183,7 +183,7 @@ func (s *VolumeListener) Shrink(ctx context.Context, in *pb.VolumeSizeChange) (*
 
 	err := handler.Shrink(ctx, volumeName, hostName, in.ChangeSize, in.ChangeSizeType)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 
 	log.Println(fmt.Sprintf("Volume '%s' shrinked", volumeName))
@@ -247,7 +247,7 @@ func (s *VolumeListener) Attach(ctx context.Context, in *pb.VolumeAttachment) (_
 	handler := VolumeHandler(tenant.Service)
 	_, err = handler.Attach(ctx, volumeRef, hostRef, mountPath, filesystem, doNotFormat)
 	if err != nil {
-		return empty, status.Errorf(codes.Internal, getUserMessage(err))
+		return empty, errorStatus(ctx, err)
 	}
 
 	return empty, nil
@@ -294,7 +294,7 @@ func (s *VolumeListener) Detach(ctx context.Context, in *pb.VolumeDetachment) (_
 	handler := VolumeHandler(tenant.Service)
 	err = handler.Detach(ctx, volumeRef, hostRef)
 	if err != nil {
-		return empty, status.Errorf(codes.Internal, getUserMessage(err))
+		return empty, errorStatus(ctx, err)
 	}
 
 	log.Infof("Volume '%s' detached from '%s'", volumeRef, hostRef)
@@ -378,7 +378,7 @@ func (s *VolumeListener) Inspect(ctx context.Context, in *pb.Reference) (_ *pb.V
 	handler := VolumeHandler(tenant.Service)
 	volume, mounts, err := handler.Inspect(ctx, ref)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, getUserMessage(err))
+		return nil, errorStatus(ctx, err)
 	}
 	if volume == nil {
 		return nil, status.Errorf(codes.NotFound, fmt.Sprintf("cannot inspect volume '%s': volume not found", ref))
@@ -16,6 +16,12 @@
 
 package metadata
 
+import (
+	"time"
+
+	"github.com/CS-SI/SafeScale/lib/utils/retry"
+)
+
 const (
 	// ByIDFolderName tells in what folder to put 'byID' information
 	ByIDFolderName = "byID"
@@ -24,3 +30,10 @@ const (
 	// BucketNamePrefix is the beginning of the name of the bucket for Metadata
 	BucketNamePrefix = "0.safescale"
 )
+
+// retryPolicy returns the policy used to retry the reads of metadata; replaced in tests
+var retryPolicy = retry.DefaultPolicy
+
+// defaultRouteIPCacheTTL is the time during which Network.GetDefaultRouteIP returns the IP it found without reading
+// the metadata again; replaced in tests
+var defaultRouteIPCacheTTL = 10 * time.Second
@@ -405,7 +405,7 @@ func LoadHost(svc iaas.Service, ref string) (mh *Host, err error) {
 		return nil, err
 	}
 
-	retryErr := retry.WhileUnsuccessfulDelay1Second(
+	retryErr := retryPolicy().WhileUnsuccessful(
 		func() error {
 			innerErr := mh.ReadByReference(ref)
 			if innerErr != nil {
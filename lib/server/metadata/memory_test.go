@@ -0,0 +1,42 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/objectstorage"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/objectstorage/objectstoragetest"
+	"github.com/CS-SI/SafeScale/lib/utils/crypt"
+)
+
+// memoryService is an iaas.Service storing metadata in an in-memory bucket
+type memoryService struct {
+	iaas.Service
+	bucket *objectstoragetest.Bucket
+}
+
+func newMemoryService() *memoryService {
+	return &memoryService{bucket: objectstoragetest.NewBucket()}
+}
+
+func (s *memoryService) GetMetadataBucket() objectstorage.Bucket {
+	return s.bucket
+}
+
+func (s *memoryService) GetMetadataKey() *crypt.Key {
+	return nil
+}
@@ -18,6 +18,10 @@ package metadata
 
 import (
 	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/graymeta/stow"
 
@@ -27,6 +31,8 @@ import (
 
 	"github.com/CS-SI/SafeScale/lib/server/iaas"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hostproperty"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/ipversion"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/networkproperty"
 	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
@@ -48,6 +54,11 @@ type Network struct {
 	// inside *metadata.Folder
 	name *string
 	id   *string
+
+	// routeIP caches the result of GetDefaultRouteIP until routeIPExpiry; reset by Carry, Write and Reload
+	routeIPLock   sync.Mutex
+	routeIP       string
+	routeIPExpiry time.Time
 }
 
 // NewNetwork creates an instance of Network
@@ -63,8 +74,16 @@ func NewNetwork(svc iaas.Service) (_ *Network, err error) {
 	}, nil
 }
 
-// GetService returns the provider service used
+// IsNull tells if the instance is a null value, not linked to metadata
+func (m *Network) IsNull() bool {
+	return m == nil || m.item == nil
+}
+
+// GetService returns the provider service used, or nil if the instance is null
 func (m *Network) GetService() iaas.Service {
+	if m.IsNull() {
+		return nil
+	}
 	return m.item.GetService()
 }
 
@@ -121,6 +140,7 @@ func (m *Network) Carry(network *abstract.Network) (_ *Network, err error) {
 	m.item.Carry(network)
 	m.id = &network.ID
 	m.name = &network.Name
+	m.forgetDefaultRouteIP()
 	// m.inside = metadata.NewFolder(m.item.GetService(), strings.Trim(m.item.GetPath()+"/"+*m.id, "/"))
 	return m, nil
 }
@@ -135,7 +155,11 @@ func (m *Network) Get() (_ *abstract.Network, err error) {
 	if m.item == nil {
 		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
 	}
-	return m.item.Get().(*abstract.Network), nil
+	network, ok := m.item.Get().(*abstract.Network)
+	if !ok || network == nil {
+		return nil, fail.InvalidInstanceContentError("m.item", "does not carry a network")
+	}
+	return network, nil
 }
 
 // Write updates the metadata corresponding to the network in the Object Storage
@@ -149,10 +173,11 @@ func (m *Network) Write() (err error) {
 		return fail.InvalidInstanceContentError("m.item", "cannot be nil")
 	}
 
-	tracer := debug.NewTracer(nil, "", true).GoingIn()
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
+	m.forgetDefaultRouteIP()
 	err1 := m.item.WriteInto(ByIDFolderName, *m.id)
 	err2 := m.item.WriteInto(ByNameFolderName, *m.name)
 
@@ -173,10 +198,11 @@ func (m *Network) Reload() (err error) {
 		return fail.InvalidInstanceError()
 	}
 
-	tracer := debug.NewTracer(nil, "", true).GoingIn()
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
+	m.forgetDefaultRouteIP()
 	err = m.ReadByID(*m.id)
 	if err != nil {
 		if _, ok := err.(fail.ErrNotFound); ok {
@@ -201,7 +227,7 @@ func (m *Network) ReadByReference(ref string) (err error) {
 		return fail.InvalidParameterError("ref", "cannot be empty string")
 	}
 
-	tracer := debug.NewTracer(nil, "('"+ref+"')", true).GoingIn()
+	tracer := debug.NewTracer(nil, "('"+ref+"')", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogErrorWithLevel(tracer.TraceMessage(""), &err, logrus.TraceLevel)()
 
@@ -293,7 +319,7 @@ func (m *Network) ReadByID(id string) (err error) {
 		return fail.InvalidParameterError("id", "cannot be empty string")
 	}
 
-	tracer := debug.NewTracer(nil, "("+id+")", true).GoingIn()
+	tracer := debug.NewTracer(nil, "("+id+")", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -312,7 +338,7 @@ func (m *Network) ReadByName(name string) (err error) {
 		return fail.InvalidParameterError("name", "cannot be empty string")
 	}
 
-	tracer := debug.NewTracer(nil, "('"+name+"')", true).GoingIn()
+	tracer := debug.NewTracer(nil, "('"+name+"')", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -330,7 +356,7 @@ func (m *Network) Delete() (err error) {
 		return fail.InvalidInstanceContentError("m.item", "cannot be nil")
 	}
 
-	tracer := debug.NewTracer(nil, "", true).GoingIn()
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -363,7 +389,7 @@ func (m *Network) Browse(callback func(*abstract.Network) error) (err error) {
 		return fail.InvalidParameterError("callback", "cannot be nil")
 	}
 
-	tracer := debug.NewTracer(nil, "", true).GoingIn()
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -379,8 +405,50 @@ func (m *Network) Browse(callback func(*abstract.Network) error) (err error) {
 	)
 }
 
-// AttachHost links host ID to the network
-func (m *Network) AttachHost(host *abstract.Host) (err error) {
+// BrowsePaged returns at most 'pageSize' networks, in the order of their IDs, starting after 'cursor' (from the
+// first network if empty), and the cursor to pass to get the next page (empty string when there are no more networks)
+// The cursor is stable across calls: networks added or removed meanwhile, including the one the cursor refers to,
+// don't make the next page skip or repeat networks
+func (m *Network) BrowsePaged(cursor string, pageSize int) (networks []*abstract.Network, next string, err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return nil, "", fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return nil, "", fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+	if pageSize <= 0 {
+		return nil, "", fail.InvalidParameterError("pageSize", "must be greater than 0")
+	}
+
+	tracer := debug.NewTracer(
+		nil, fmt.Sprintf("('%s', %d)", cursor, pageSize), debug.ShouldTrace("metadata.network"),
+	).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	next, err = m.item.BrowseIntoPage(
+		ByIDFolderName, cursor, pageSize, func(buf []byte) error {
+			network := abstract.Network{}
+			err := (&network).Deserialize(buf)
+			if err != nil {
+				return err
+			}
+			networks = append(networks, &network)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	return networks, next, nil
+}
+
+// AttachHost links host ID to the network; attaching an already attached host does nothing.
+// If 'verify' is true, the host must have an interface on the network according to its NetworkV1 property,
+// otherwise a fail.ErrInconsistent is returned.
+func (m *Network) AttachHost(host *abstract.Host, verify bool) (err error) {
 	defer fail.OnPanic(&err)()
 
 	if m == nil {
@@ -393,7 +461,7 @@ func (m *Network) AttachHost(host *abstract.Host) (err error) {
 		return fail.InvalidParameterError("host", "cannot be nil")
 	}
 
-	tracer := debug.NewTracer(nil, "("+host.Name+")", true).GoingIn()
+	tracer := debug.NewTracer(nil, fmt.Sprintf("(%s, %v)", host.Name, verify), debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -401,7 +469,49 @@ func (m *Network) AttachHost(host *abstract.Host) (err error) {
 	if err != nil {
 		return err
 	}
-	return network.Properties.LockForWrite(networkproperty.HostsV1).ThenUse(
+	if verify {
+		err = checkHostOnNetwork(network, host)
+		if err != nil {
+			return err
+		}
+	}
+	attached := false
+	err = network.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			_, attached = clonable.(*propsv1.NetworkHosts).ByID[host.ID]
+			return nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if attached {
+		logrus.Debugf("host '%s' is already attached to network '%s'", host.Name, network.Name)
+		return nil
+	}
+
+	// Reserves the IP of the host in the network, if already known, before recording the host, so that a collision
+	// leaves the network unchanged
+	ip := ""
+	if host.Properties != nil {
+		err = host.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+			func(clonable data.Clonable) error {
+				ip = clonable.(*propsv1.HostNetwork).IPv4Addresses[network.ID]
+				return nil
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if ip != "" {
+		err = m.ReserveIP(ip, host.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = network.Properties.LockForWrite(networkproperty.HostsV1).ThenUse(
 		func(clonable data.Clonable) error {
 			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
 			networkHostsV1.ByID[host.ID] = host.Name
@@ -409,6 +519,34 @@ func (m *Network) AttachHost(host *abstract.Host) (err error) {
 			return nil
 		},
 	)
+	if err != nil && ip != "" {
+		if derr := m.ReleaseIP(ip); derr != nil {
+			err = fail.AddConsequence(err, derr)
+		}
+	}
+	return err
+}
+
+// checkHostOnNetwork returns a fail.ErrInconsistent if the NetworkV1 property of 'host' doesn't reference 'network'
+func checkHostOnNetwork(network *abstract.Network, host *abstract.Host) error {
+	found := false
+	if host.Properties != nil {
+		err := host.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(
+			func(clonable data.Clonable) error {
+				_, found = clonable.(*propsv1.HostNetwork).NetworksByID[network.ID]
+				return nil
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fail.InconsistentError(
+			fmt.Sprintf("host '%s' has no interface on network '%s'", host.Name, network.Name),
+		)
+	}
+	return nil
 }
 
 // DetachHost unlinks host ID from network
@@ -425,7 +563,7 @@ func (m *Network) DetachHost(hostID string) (err error) {
 		return fail.InvalidParameterError("hostID", "cannot be empty string")
 	}
 
-	tracer := debug.NewTracer(nil, "('"+hostID+"')", true).GoingIn()
+	tracer := debug.NewTracer(nil, "('"+hostID+"')", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -447,185 +585,1379 @@ func (m *Network) DetachHost(hostID string) (err error) {
 	if err != nil {
 		return err
 	}
-	return nil
+	return network.Properties.LockForWrite(networkproperty.IPsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkIPsV1 := clonable.(*propsv1.NetworkIPs)
+			for ip, owner := range networkIPsV1.ByIP {
+				if owner == hostID {
+					delete(networkIPsV1.ByIP, ip)
+				}
+			}
+			return nil
+		},
+	)
 }
 
-// ListHosts returns the list of abstract.Host attached to the network (excluding gateway)
-func (m *Network) ListHosts() (list []*abstract.Host, err error) {
+// ReserveIP records that the private IP 'ip' of the network is used by the resource identified by 'ownerID'
+// Returns fail.ErrDuplicate if the IP is already reserved by another resource
+func (m *Network) ReserveIP(ip string, ownerID string) (err error) {
 	defer fail.OnPanic(&err)()
 
 	if m == nil {
-		return nil, fail.InvalidInstanceError()
+		return fail.InvalidInstanceError()
 	}
 	if m.item == nil {
-		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
+		return fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+	if ownerID == "" {
+		return fail.InvalidParameterError("ownerID", "cannot be empty string")
 	}
 
-	tracer := debug.NewTracer(nil, "", true).GoingIn()
+	tracer := debug.NewTracer(nil, fmt.Sprintf("('%s', '%s')", ip, ownerID), debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
 	network, err := m.Get()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	err = network.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+	return network.Properties.LockForWrite(networkproperty.IPsV1).ThenUse(
 		func(clonable data.Clonable) error {
-			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
-			for id := range networkHostsV1.ByID {
-				mh, err := LoadHost(m.item.GetService(), id)
-				if err != nil {
-					return err
-				}
-				if mh != nil {
-					mhm, merr := mh.Get()
-					if merr != nil {
-						return merr
-					}
-					list = append(list, mhm)
-				} else {
-					logrus.Warnf("Host metadata for '%s' not found!", id)
-				}
-			}
-			return nil
+			return reserveIP(clonable.(*propsv1.NetworkIPs), network.CIDR, ip, ownerID)
 		},
 	)
+}
+
+// ReleaseIP forgets the reservation of the private IP 'ip' of the network
+func (m *Network) ReleaseIP(ip string) (err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+	if ip == "" {
+		return fail.InvalidParameterError("ip", "cannot be empty string")
+	}
+
+	tracer := debug.NewTracer(nil, "('"+ip+"')", debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	network, err := m.Get()
 	if err != nil {
-		logrus.Errorf("Error listing hosts: %+v", err)
+		return err
 	}
-	return list, nil
+	return network.Properties.LockForWrite(networkproperty.IPsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			delete(clonable.(*propsv1.NetworkIPs).ByIP, ip)
+			return nil
+		},
+	)
 }
 
-// Acquire waits until the write lock is available, then locks the metadata
-func (m *Network) Acquire() {
-	m.item.Acquire()
+// reserveIP does the real work of ReserveIP on the property of the network of CIDR 'cidr'
+func reserveIP(networkIPsV1 *propsv1.NetworkIPs, cidr string, ip string, ownerID string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return fail.InvalidParameterError("ip", fmt.Sprintf("'%s' is not a valid IP address", ip))
+	}
+	if cidr != "" {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fail.InconsistentError(fmt.Sprintf("invalid CIDR '%s' for network: %v", cidr, err))
+		}
+		if !ipnet.Contains(parsed) {
+			return fail.InvalidParameterError("ip", fmt.Sprintf("'%s' is not in network CIDR '%s'", ip, cidr))
+		}
+	}
+	if owner, ok := networkIPsV1.ByIP[ip]; ok && owner != ownerID {
+		return fail.DuplicateError(fmt.Sprintf("IP '%s' is already used by '%s'", ip, owner))
+	}
+	networkIPsV1.ByIP[ip] = ownerID
+	return nil
 }
 
-// Release unlocks the metadata
-func (m *Network) Release() {
-	m.item.Release()
+// SetCreationSpec records in the network the effective parameters used to create it
+// Note: the metadata is not written, it's up to the caller to call Write()
+func (m *Network) SetCreationSpec(spec *propsv1.NetworkCreationSpec) (err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+	if spec == nil {
+		return fail.InvalidParameterError("spec", "cannot be nil")
+	}
+
+	network, err := m.Get()
+	if err != nil {
+		return err
+	}
+	return network.Properties.LockForWrite(networkproperty.CreationSpecV1).ThenUse(
+		func(clonable data.Clonable) error {
+			clonable.(*propsv1.NetworkCreationSpec).Replace(spec)
+			return nil
+		},
+	)
 }
 
-// SaveNetwork saves the Network definition in Object Storage
-func SaveNetwork(svc iaas.Service, net *abstract.Network) (mn *Network, err error) {
+// GetCreationSpec returns a copy of the effective parameters used to create the network
+// Returns fail.ErrNotFound if the network has been created before these parameters were recorded
+func (m *Network) GetCreationSpec() (spec *propsv1.NetworkCreationSpec, err error) {
 	defer fail.OnPanic(&err)()
 
-	if svc == nil {
-		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	if m == nil {
+		return nil, fail.InvalidInstanceError()
 	}
-	if net == nil {
-		return nil, fail.InvalidParameterError("net", "cannot be nil")
+	if m.item == nil {
+		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
 	}
 
-	tracer := debug.NewTracer(nil, "", true).GoingIn()
-	defer tracer.OnExitTrace()()
-	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
-
-	mn, err = NewNetwork(svc)
+	network, err := m.Get()
 	if err != nil {
 		return nil, err
 	}
-
-	mnm, err := mn.Carry(net)
+	err = network.Properties.LockForRead(networkproperty.CreationSpecV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkCreationSpecV1 := clonable.(*propsv1.NetworkCreationSpec)
+			if networkCreationSpecV1.CIDR == "" {
+				return fail.NotFoundError(fmt.Sprintf("no creation parameters recorded for network '%s'", network.Name))
+			}
+			spec = networkCreationSpecV1.Clone().(*propsv1.NetworkCreationSpec)
+			return nil
+		},
+	)
 	if err != nil {
 		return nil, err
 	}
+	return spec, nil
+}
 
-	return mn, mnm.Write()
+// ListHosts returns the list of abstract.Host attached to the network (excluding gateway)
+func (m *Network) ListHosts() (list []*abstract.Host, err error) {
+	list, err = m.ListHostsFiltered(nil, nil)
+	if err != nil {
+		logrus.Errorf("Error listing hosts: %+v", err)
+	}
+	return list, nil
 }
 
-// RemoveNetwork removes the Network definition from Object Storage
-func RemoveNetwork(svc iaas.Service, net *abstract.Network) (err error) {
+// ListHostsFiltered returns the list of abstract.Host attached to the network (excluding gateway) satisfying
+// the filters:
+//   - 'selector' is called with the ID and the name of each host, before loading its metadata; only the hosts
+//     for which it returns true are loaded (nil selects all hosts)
+//   - 'predicate' is called on each loaded host; only the hosts for which it returns true are listed
+//     (nil accepts all loaded hosts)
+func (m *Network) ListHostsFiltered(
+	selector func(id, name string) bool, predicate func(*abstract.Host) bool,
+) (list []*abstract.Host, err error) {
 	defer fail.OnPanic(&err)()
 
-	if svc == nil {
-		return fail.InvalidParameterError("svc", "cannot be nil")
+	if m == nil {
+		return nil, fail.InvalidInstanceError()
 	}
-	if net == nil {
-		return fail.InvalidParameterError("net", "cannot be nil")
+	if m.item == nil {
+		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
 	}
 
-	tracer := debug.NewTracer(nil, "(<iaas.Service>, "+net.Name+")", true).GoingIn()
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
-	aNet, err := NewNetwork(svc)
+	network, err := m.Get()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	err = network.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
+			var innerErr error
+			list, innerErr = filterNetworkHosts(
+				networkHostsV1, selector, predicate, func(id string) (*abstract.Host, error) {
+					mh, err := LoadHost(m.item.GetService(), id)
+					if err != nil {
+						return nil, err
+					}
+					if mh == nil {
+						logrus.Warnf("Host metadata for '%s' not found!", id)
+						return nil, nil
+					}
+					return mh.Get()
+				},
+			)
+			return innerErr
+		},
+	)
+	return list, err
+}
 
-	aNetm, err := aNet.Carry(net)
-	if err != nil {
-		return err
+// filterNetworkHosts loads, using 'loader', the hosts of the network selected by 'selector' and returns those
+// satisfying 'predicate'
+// A nil host returned by the loader is skipped
+func filterNetworkHosts(
+	networkHostsV1 *propsv1.NetworkHosts, selector func(id, name string) bool, predicate func(*abstract.Host) bool,
+	loader func(id string) (*abstract.Host, error),
+) ([]*abstract.Host, error) {
+	var list []*abstract.Host
+	for id, name := range networkHostsV1.ByID {
+		if selector != nil && !selector(id, name) {
+			continue
+		}
+		host, err := loader(id)
+		if err != nil {
+			return list, err
+		}
+		if host == nil {
+			continue
+		}
+		if predicate != nil && !predicate(host) {
+			continue
+		}
+		list = append(list, host)
 	}
-
-	return aNetm.Delete()
+	return list, nil
 }
 
-// LoadNetwork gets the Network definition from Object Storage
-// logic: Read by ID; if error is ErrNotFound then read by name; if error is ErrNotFound return this error
-//        In case of any other error, abort the retry to propagate the error
-//        If retry times out, return errNotFound
-func LoadNetwork(svc iaas.Service, ref string) (mn *Network, err error) {
+// ReconcileHosts removes from the network the hosts whose metadata no longer exists (deleted out-of-band),
+// and returns the IDs of the pruned hosts
+func (m *Network) ReconcileHosts() (pruned []string, err error) {
 	defer fail.OnPanic(&err)()
 
-	if svc == nil {
-		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	if m == nil {
+		return nil, fail.InvalidInstanceError()
 	}
-	if ref == "" {
-		return nil, fail.InvalidParameterError("ref", "cannot be empty string")
+	if m.item == nil {
+		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
 	}
 
-	tracer := debug.NewTracer(nil, "(<iaas.Service>, '"+ref+"')", true).GoingIn()
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
-	defer fail.OnExitLogErrorWithLevel(tracer.TraceMessage(""), &err, logrus.TraceLevel)()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
-	mn, err = NewNetwork(svc)
+	network, err := m.Get()
 	if err != nil {
 		return nil, err
 	}
-	retryErr := retry.WhileUnsuccessfulDelay1Second(
-		func() error {
-			innerErr := mn.ReadByReference(ref)
-			if innerErr != nil {
-				if _, ok := innerErr.(fail.ErrNotFound); ok {
-					return retry.AbortedError("no metadata found", innerErr)
-				}
-
-				if innerErr == stow.ErrNotFound { // FIXME: Remove stow dependency
-					return retry.AbortedError("no metadata found", innerErr)
-				}
-
-				return innerErr
-			}
-
-			return nil
+	svc := m.item.GetService()
+	err = network.Properties.LockForWrite(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			var innerErr error
+			pruned, innerErr = pruneVanishedHosts(
+				clonable.(*propsv1.NetworkHosts), func(id string) error {
+					_, err := LoadHost(svc, id)
+					return err
+				},
+			)
+			return innerErr
 		},
-		2*temporal.GetDefaultDelay(),
 	)
-	if retryErr != nil {
-		switch realErr := retryErr.(type) {
-		case retry.ErrAborted:
-			return nil, realErr.Cause()
-		case fail.ErrTimeout:
-			return nil, realErr
-		default:
-			return nil, fail.Cause(realErr)
-		}
-	}
-
-	ok, err := mn.OK()
+	if err != nil {
+		return nil, err
+	}
+	if len(pruned) > 0 {
+		err = m.Write()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
+
+// pruneVanishedHosts removes from networkHostsV1 the hosts for which 'loader' returns fail.ErrNotFound,
+// and returns their IDs; any other error from 'loader' stops the reconciliation
+func pruneVanishedHosts(networkHostsV1 *propsv1.NetworkHosts, loader func(string) error) ([]string, error) {
+	var pruned []string
+	for id, name := range networkHostsV1.ByID {
+		err := loader(id)
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(fail.ErrNotFound); !ok {
+			return nil, err
+		}
+		logrus.Warnf("host '%s' (%s) no longer exists, detaching it from network", name, id)
+		delete(networkHostsV1.ByName, name)
+		delete(networkHostsV1.ByID, id)
+		pruned = append(pruned, id)
+	}
+	return pruned, nil
+}
+
+// RepairHostIndex reconciles the ByID and ByName indexes of the hosts attached to the network, that may have drifted
+// apart (for example after a crash between the updates of the two maps); entries are resolved against the metadata of
+// the hosts, entries that cannot be resolved are dropped. The whole repair is done under a single lock of the property.
+// Returns the list of the repairs made
+func (m *Network) RepairHostIndex() (repairs []string, err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	network, err := m.Get()
+	if err != nil {
+		return nil, err
+	}
+	svc := m.item.GetService()
+	err = network.Properties.LockForWrite(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			var innerErr error
+			repairs, innerErr = repairHostIndex(
+				clonable.(*propsv1.NetworkHosts), func(ref string) (*abstract.Host, error) {
+					mh, err := LoadHost(svc, ref)
+					if err != nil {
+						return nil, err
+					}
+					return mh.Get()
+				},
+			)
+			return innerErr
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(repairs) > 0 {
+		err = m.Write()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return repairs, nil
+}
+
+// repairHostIndex makes ByID and ByName of networkHostsV1 mirror each other, using 'resolve' to find the host
+// referenced by an inconsistent entry; an entry for which 'resolve' returns fail.ErrNotFound is dropped, any other
+// error from 'resolve' stops the repair
+func repairHostIndex(
+	networkHostsV1 *propsv1.NetworkHosts, resolve func(string) (*abstract.Host, error),
+) ([]string, error) {
+	var repairs []string
+
+	// resolveEntry returns the host referenced by 'id' (or by 'name' if 'id' is unknown), nil if it doesn't exist
+	resolveEntry := func(id, name string) (*abstract.Host, error) {
+		for _, ref := range []string{id, name} {
+			if ref == "" {
+				continue
+			}
+			host, err := resolve(ref)
+			if err == nil {
+				return host, nil
+			}
+			if _, ok := err.(fail.ErrNotFound); !ok {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(networkHostsV1.ByID))
+	for id := range networkHostsV1.ByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		name := networkHostsV1.ByID[id]
+		if networkHostsV1.ByName[name] == id {
+			continue
+		}
+		host, err := resolveEntry(id, "")
+		if err != nil {
+			return repairs, err
+		}
+		if host == nil {
+			delete(networkHostsV1.ByID, id)
+			repairs = append(repairs, fmt.Sprintf("dropped host ID '%s': host not found", id))
+			continue
+		}
+		if host.Name != name {
+			networkHostsV1.ByID[id] = host.Name
+			repairs = append(repairs, fmt.Sprintf("renamed host ID '%s' from '%s' to '%s'", id, name, host.Name))
+		}
+		if networkHostsV1.ByName[host.Name] != id {
+			networkHostsV1.ByName[host.Name] = id
+			repairs = append(repairs, fmt.Sprintf("indexed host name '%s' to ID '%s'", host.Name, id))
+		}
+	}
+
+	names := make([]string, 0, len(networkHostsV1.ByName))
+	for name := range networkHostsV1.ByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		id := networkHostsV1.ByName[name]
+		if networkHostsV1.ByID[id] == name {
+			continue
+		}
+		delete(networkHostsV1.ByName, name)
+		host, err := resolveEntry(id, name)
+		if err != nil {
+			return repairs, err
+		}
+		if host == nil {
+			repairs = append(repairs, fmt.Sprintf("dropped host name '%s': host not found", name))
+			continue
+		}
+		if _, ok := networkHostsV1.ByID[host.ID]; ok {
+			// the host is indexed by ID under its current name, 'name' was a stale entry
+			repairs = append(repairs, fmt.Sprintf("dropped stale host name '%s'", name))
+			continue
+		}
+		networkHostsV1.ByID[host.ID] = host.Name
+		networkHostsV1.ByName[host.Name] = host.ID
+		repairs = append(repairs, fmt.Sprintf("indexed host ID '%s' to name '%s'", host.ID, host.Name))
+	}
+
+	if len(repairs) > 0 {
+		logrus.Warnf("repaired host index of network: %v", repairs)
+	}
+	return repairs, nil
+}
+
+// GetGatewayE returns the primary (if primary is true) or secondary gateway of the network
+// exists is false with a nil error when no such gateway is configured for the network;
+// a non-nil error means the gateway is configured but its metadata could not be loaded; a fail.ErrNotFound is
+// returned only if the metadata of the gateway are still not visible after a short retry window
+func (m *Network) GetGatewayE(primary bool) (host *abstract.Host, exists bool, err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return nil, false, fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return nil, false, fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, fmt.Sprintf("(%v)", primary), debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	network, err := m.Get()
+	if err != nil {
+		return nil, false, err
+	}
+	svc := m.item.GetService()
+	return getNetworkGateway(
+		network, primary, retryWhileNotFound(
+			func(ref string) (*abstract.Host, error) {
+				mh, err := LoadHost(svc, ref)
+				if err != nil {
+					return nil, err
+				}
+				return mh.Get()
+			}, 2*temporal.GetDefaultDelay(),
+		),
+	)
+}
+
+// retryWhileNotFound returns a loader retrying 'loader' following the retry policy while it returns fail.ErrNotFound,
+// for at most 'timeout' (unless the policy defines its own); right after the creation of a network, the metadata of
+// its gateways may not be visible yet in an eventually consistent Object Storage
+func retryWhileNotFound(
+	loader func(string) (*abstract.Host, error), timeout time.Duration,
+) func(string) (*abstract.Host, error) {
+	return func(ref string) (host *abstract.Host, err error) {
+		retryErr := retryPolicy().WhileUnsuccessful(
+			func() error {
+				host, err = loader(ref)
+				if err != nil {
+					if _, ok := err.(fail.ErrNotFound); ok {
+						return err
+					}
+					return retry.AbortedError("", err)
+				}
+				return nil
+			},
+			timeout,
+		)
+		if retryErr != nil {
+			// err is the error of the last try, a fail.ErrNotFound if the metadata never became visible
+			return nil, err
+		}
+		return host, nil
+	}
+}
+
+// getNetworkGateway does the real work of GetGatewayE, using 'loader' to read the metadata of the gateway
+func getNetworkGateway(
+	network *abstract.Network, primary bool, loader func(string) (*abstract.Host, error),
+) (*abstract.Host, bool, error) {
+	gwID := network.GatewayID
+	if !primary {
+		gwID = network.SecondaryGatewayID
+	}
+	return getNetworkGatewayByID(network, gwID, loader)
+}
+
+// getNetworkGatewayByID loads with 'loader' the gateway 'gwID' of 'network'; the boolean tells if the gateway is
+// configured ('gwID' not empty)
+func getNetworkGatewayByID(
+	network *abstract.Network, gwID string, loader func(string) (*abstract.Host, error),
+) (*abstract.Host, bool, error) {
+	if gwID == "" {
+		return nil, false, nil
+	}
+
+	host, err := loader(gwID)
+	if err != nil {
+		return nil, true, err
+	}
+	if host == nil {
+		return nil, true, fail.InconsistentError(
+			fmt.Sprintf("metadata of gateway '%s' of network '%s' is empty", gwID, network.Name),
+		)
+	}
+	return host, true, nil
+}
+
+// GetGateway returns the primary (if primary is true) or secondary gateway of the network,
+// or a fail.ErrNotFound if such a gateway is not configured
+func (m *Network) GetGateway(primary bool) (*abstract.Host, error) {
+	host, exists, err := m.GetGatewayE(primary)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		kind := "primary"
+		if !primary {
+			kind = "secondary"
+		}
+		return nil, fail.NotFoundError(fmt.Sprintf("no %s gateway configured for the network", kind))
+	}
+	return host, nil
+}
+
+// SafeGetGateway returns the primary (if primary is true) or secondary gateway of the network,
+// or nil if not configured or if its metadata could not be loaded (the error is logged); nil on a null instance
+func (m *Network) SafeGetGateway(primary bool) *abstract.Host {
+	if m.IsNull() {
+		return nil
+	}
+	host, _, err := m.GetGatewayE(primary)
+	if err != nil {
+		logrus.Warnf("failed to get gateway of network: %v", err)
+		return nil
+	}
+	return host
+}
+
+// GetGatewayByIndex returns the gateway of index 'index' of the network, in the order of creation (0 is the primary
+// gateway, 1 the secondary one), or a fail.ErrNotFound if the network doesn't have such a gateway
+func (m *Network) GetGatewayByIndex(index int) (_ *abstract.Host, err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+	if index < 0 {
+		return nil, fail.InvalidParameterError("index", "cannot be negative")
+	}
+
+	tracer := debug.NewTracer(nil, fmt.Sprintf("(%d)", index), debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	network, err := m.Get()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := GatewayIDsOf(network)
+	if err != nil {
+		return nil, err
+	}
+	if index >= len(ids) {
+		return nil, fail.NotFoundError(
+			fmt.Sprintf("network '%s' has no gateway of index %d (%d gateways)", network.Name, index, len(ids)),
+		)
+	}
+	svc := m.item.GetService()
+	host, _, err := getNetworkGatewayByID(
+		network, ids[index], retryWhileNotFound(
+			func(ref string) (*abstract.Host, error) {
+				mh, err := LoadHost(svc, ref)
+				if err != nil {
+					return nil, err
+				}
+				return mh.Get()
+			}, 2*temporal.GetDefaultDelay(),
+		),
+	)
+	return host, err
+}
+
+// SetGatewayIDs records 'ids' as the IDs of the gateways of the network, in order of creation; GatewayID and
+// SecondaryGatewayID are updated with the first two
+// Note: the metadata is not written, it's up to the caller to call Write()
+func (m *Network) SetGatewayIDs(ids []string) (err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+
+	network, err := m.Get()
+	if err != nil {
+		return err
+	}
+	network.GatewayID, network.SecondaryGatewayID = "", ""
+	if len(ids) > 0 {
+		network.GatewayID = ids[0]
+	}
+	if len(ids) > 1 {
+		network.SecondaryGatewayID = ids[1]
+	}
+	return network.Properties.LockForWrite(networkproperty.GatewaysV1).ThenUse(
+		func(clonable data.Clonable) error {
+			clonable.(*propsv1.NetworkGateways).Replace(&propsv1.NetworkGateways{IDs: ids})
+			return nil
+		},
+	)
+}
+
+// GatewayIDsOf returns the IDs of the gateways of 'network', in order of creation; for networks created before
+// the gateways were recorded in property GatewaysV1, GatewayID and SecondaryGatewayID are used
+func GatewayIDsOf(network *abstract.Network) ([]string, error) {
+	if network == nil {
+		return nil, fail.InvalidParameterError("network", "cannot be nil")
+	}
+
+	var ids []string
+	if network.Properties != nil {
+		err := network.Properties.LockForRead(networkproperty.GatewaysV1).ThenUse(
+			func(clonable data.Clonable) error {
+				ids = clonable.(*propsv1.NetworkGateways).IDs
+				return nil
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) > 0 {
+		return ids, nil
+	}
+	for _, id := range []string{network.GatewayID, network.SecondaryGatewayID} {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// GetGatewayPhaseStatus returns the progress of the configuration of the primary (if primary is true) or secondary
+// gateway of the network, as recorded in its metadata
+func (m *Network) GetGatewayPhaseStatus(primary bool) (status *propsv1.HostGatewayPhases, err error) {
+	defer fail.OnPanic(&err)()
+
+	if m.IsNull() {
+		return nil, fail.InvalidInstanceError()
+	}
+
+	gw, err := m.GetGateway(primary)
+	if err != nil {
+		return nil, err
+	}
+	err = gw.Properties.LockForRead(hostproperty.GatewayPhasesV1).ThenUse(
+		func(clonable data.Clonable) error {
+			status = clonable.Clone().(*propsv1.HostGatewayPhases)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// GetDefaultRouteIP returns the IP used as default route by the hosts of the network: the private IP of the VIP if
+// there is one, the private IP of the primary gateway otherwise. The IP is kept for defaultRouteIPCacheTTL, so the
+// repeated calls done while configuring hosts don't read the metadata of the gateway each time.
+func (m *Network) GetDefaultRouteIP() (ip string, err error) {
+	defer fail.OnPanic(&err)()
+
+	if m.IsNull() {
+		return "", fail.InvalidInstanceError()
+	}
+
+	m.routeIPLock.Lock()
+	defer m.routeIPLock.Unlock()
+
+	if m.routeIP != "" && time.Now().Before(m.routeIPExpiry) {
+		return m.routeIP, nil
+	}
+
+	network, err := m.Get()
+	if err != nil {
+		return "", err
+	}
+	ip, err = getDefaultRouteIP(network, func(primary bool) (*abstract.Host, error) {
+		return m.GetGateway(primary)
+	})
+	if err != nil {
+		return "", err
+	}
+	m.routeIP = ip
+	m.routeIPExpiry = time.Now().Add(defaultRouteIPCacheTTL)
+	return ip, nil
+}
+
+// getDefaultRouteIP does the real work of GetDefaultRouteIP, using 'gateway' to get the gateways of the network
+func getDefaultRouteIP(network *abstract.Network, gateway func(bool) (*abstract.Host, error)) (string, error) {
+	if network.VIP != nil && network.VIP.PrivateIP != "" {
+		return network.VIP.PrivateIP, nil
+	}
+	gw, err := gateway(true)
+	if err != nil {
+		return "", err
+	}
+	ip := gw.GetPrivateIP()
+	if ip == "" {
+		return "", fail.InconsistentError(
+			fmt.Sprintf("gateway '%s' of network '%s' has no private IP", gw.Name, network.Name),
+		)
+	}
+	return ip, nil
+}
+
+// forgetDefaultRouteIP resets the cache of GetDefaultRouteIP
+func (m *Network) forgetDefaultRouteIP() {
+	m.routeIPLock.Lock()
+	m.routeIP = ""
+	m.routeIPExpiry = time.Time{}
+	m.routeIPLock.Unlock()
+}
+
+// NetworkSummary is an aggregated view of a network, built from a single read of its metadata
+type NetworkSummary struct {
+	ID                 string
+	Name               string
+	CIDR               string
+	Domain             string
+	IPVersion          ipversion.Enum
+	GatewayID          string
+	GatewayIP          string
+	SecondaryGatewayID string
+	SecondaryGatewayIP string
+	VIP                *abstract.VirtualIP
+	HostCount          int
+	Subnetworks        []abstract.SubNetwork
+}
+
+// Summary reloads the metadata of the network once and returns an aggregated view of it;
+// the private IPs of the gateways are taken from the IP reservations of the network
+func (m *Network) Summary() (summary *NetworkSummary, err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	err = m.Reload()
+	if err != nil {
+		return nil, err
+	}
+	network, err := m.Get()
+	if err != nil {
+		return nil, err
+	}
+	return summarizeNetwork(network)
+}
+
+// summarizeNetwork builds the NetworkSummary of 'network' without any access to the Object Storage
+func summarizeNetwork(network *abstract.Network) (*NetworkSummary, error) {
+	summary := &NetworkSummary{
+		ID:                 network.ID,
+		Name:               network.Name,
+		CIDR:               network.CIDR,
+		Domain:             network.Domain,
+		IPVersion:          network.IPVersion,
+		GatewayID:          network.GatewayID,
+		SecondaryGatewayID: network.SecondaryGatewayID,
+		VIP:                network.VIP,
+		Subnetworks:        network.Subnetworks,
+	}
+	err := network.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			summary.HostCount = len(clonable.(*propsv1.NetworkHosts).ByID)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = network.Properties.LockForRead(networkproperty.IPsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			for ip, owner := range clonable.(*propsv1.NetworkIPs).ByIP {
+				switch owner {
+				case network.GatewayID:
+					summary.GatewayIP = ip
+				case network.SecondaryGatewayID:
+					summary.SecondaryGatewayIP = ip
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Kinds of problems reported by the verification of the integrity of network metadata
+const (
+	// IntegrityNameMismatch tells the metadata read by ID and by name differ
+	IntegrityNameMismatch = "name-mismatch"
+	// IntegrityMissingGateway tells a gateway referenced by the network has no metadata
+	IntegrityMissingGateway = "missing-gateway"
+	// IntegrityInvalidVIP tells the VIP of the network cannot be found
+	IntegrityInvalidVIP = "invalid-vip"
+	// IntegrityInconsistentHosts tells the hosts attached to the network are not consistent
+	IntegrityInconsistentHosts = "inconsistent-hosts"
+	// IntegrityUnreadable tells the metadata (or part of it) cannot be read
+	IntegrityUnreadable = "unreadable"
+)
+
+// IntegrityProblem describes an inconsistency found in the metadata of a network
+type IntegrityProblem struct {
+	NetworkID   string
+	NetworkName string
+	Kind        string
+	Detail      string
+}
+
+// IntegrityReport lists the problems found by the verification of the integrity of network metadata
+type IntegrityReport struct {
+	Checked  int
+	Problems []IntegrityProblem
+}
+
+// OK tells if no problem has been found
+func (r *IntegrityReport) OK() bool {
+	return r == nil || len(r.Problems) == 0
+}
+
+// VerifyIntegrity reloads then checks the metadata of the network: the content read by ID and by name must be the same,
+// the gateways must have metadata, the VIP (if any) must own its IP address in the network, and the hosts
+// attached to the network must be consistent and exist. The problems found are returned in a report; an error
+// is returned only if the verification itself cannot be done.
+// Note: the providers offer no way to inspect a VIP, so the VIP is checked against the IP reservations of the network
+func (m *Network) VerifyIntegrity() (report *IntegrityReport, err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	err = m.Reload()
+	if err != nil {
+		return nil, err
+	}
+	network, err := m.Get()
+	if err != nil {
+		return nil, err
+	}
+	report = &IntegrityReport{}
+	report.Problems = verifyNetworkIntegrity(network, m.readNetworkFrom, m.hostExists)
+	report.Checked = 1
+	return report, nil
+}
+
+// readNetworkFrom reads the network stored in the folder 'folder' under the name 'name', without changing the instance
+func (m *Network) readNetworkFrom(folder, name string) (*abstract.Network, error) {
+	item, err := metadata.NewItem(m.item.GetService(), networksFolderName)
+	if err != nil {
+		return nil, err
+	}
+	network := abstract.NewNetwork()
+	err = item.ReadFrom(
+		folder, name, func(buf []byte) (serialize.Serializable, error) {
+			ierr := network.Deserialize(buf)
+			if ierr != nil {
+				return nil, ierr
+			}
+			return network, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+// hostExists tells if the metadata of the host 'id' exists
+func (m *Network) hostExists(id string) (bool, error) {
+	_, err := LoadHost(m.item.GetService(), id)
+	if err != nil {
+		if _, ok := err.(fail.ErrNotFound); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// verifyNetworkIntegrity does the real work of VerifyIntegrity; 'read' reads a network from a folder of the metadata,
+// 'hostExists' tells if a host has metadata
+func verifyNetworkIntegrity(
+	network *abstract.Network, read func(string, string) (*abstract.Network, error),
+	hostExists func(string) (bool, error),
+) []IntegrityProblem {
+	var problems []IntegrityProblem
+	report := func(kind, format string, args ...interface{}) {
+		problems = append(
+			problems, IntegrityProblem{
+				NetworkID:   network.ID,
+				NetworkName: network.Name,
+				Kind:        kind,
+				Detail:      fmt.Sprintf(format, args...),
+			},
+		)
+	}
+
+	byID, err := read(ByIDFolderName, network.ID)
+	if err != nil {
+		report(IntegrityUnreadable, "failed to read metadata by ID: %v", err)
+	}
+	byName, err := read(ByNameFolderName, network.Name)
+	if err != nil {
+		report(IntegrityUnreadable, "failed to read metadata by name: %v", err)
+	}
+	if byID != nil && byName != nil {
+		idContent, err1 := byID.Serialize()
+		nameContent, err2 := byName.Serialize()
+		if err1 != nil || err2 != nil {
+			report(IntegrityUnreadable, "failed to compare metadata by ID and by name")
+		} else if string(idContent) != string(nameContent) {
+			report(IntegrityNameMismatch, "metadata by ID and by name differ")
+		}
+	}
+
+	gwIDs, err := GatewayIDsOf(network)
+	if err != nil {
+		report(IntegrityUnreadable, "failed to read the gateways of the network: %v", err)
+	}
+	for _, gwID := range gwIDs {
+		found, err := hostExists(gwID)
+		switch {
+		case err != nil:
+			report(IntegrityUnreadable, "failed to read metadata of gateway '%s': %v", gwID, err)
+		case !found:
+			report(IntegrityMissingGateway, "gateway '%s' has no metadata", gwID)
+		}
+	}
+
+	if network.VIP != nil {
+		err := network.Properties.LockForRead(networkproperty.IPsV1).ThenUse(
+			func(clonable data.Clonable) error {
+				if network.VIP.ID == "" || network.VIP.PrivateIP == "" {
+					report(IntegrityInvalidVIP, "VIP has no ID or no IP address")
+					return nil
+				}
+				if owner := clonable.(*propsv1.NetworkIPs).ByIP[network.VIP.PrivateIP]; owner != network.VIP.ID {
+					report(
+						IntegrityInvalidVIP, "IP address '%s' of VIP '%s' is not reserved for it", network.VIP.PrivateIP,
+						network.VIP.ID,
+					)
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			report(IntegrityUnreadable, "failed to read IP reservations: %v", err)
+		}
+	}
+
+	err = network.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
+			for id, name := range networkHostsV1.ByID {
+				if networkHostsV1.ByName[name] != id {
+					report(IntegrityInconsistentHosts, "host '%s' (%s) is not registered by name", name, id)
+				}
+				found, err := hostExists(id)
+				switch {
+				case err != nil:
+					report(IntegrityUnreadable, "failed to read metadata of host '%s': %v", id, err)
+				case !found:
+					report(IntegrityInconsistentHosts, "host '%s' (%s) has no metadata", name, id)
+				}
+			}
+			for name, id := range networkHostsV1.ByName {
+				if _, ok := networkHostsV1.ByID[id]; !ok {
+					report(IntegrityInconsistentHosts, "host '%s' (%s) is not registered by ID", name, id)
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		report(IntegrityUnreadable, "failed to read attached hosts: %v", err)
+	}
+	return problems
+}
+
+// Export returns the serialized metadata of the network, including all its properties, for backup purpose
+func (m *Network) Export() (_ []byte, err error) {
+	defer fail.OnPanic(&err)()
+
+	if m == nil {
+		return nil, fail.InvalidInstanceError()
+	}
+	if m.item == nil {
+		return nil, fail.InvalidInstanceContentError("m.item", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	network, err := m.Get()
+	if err != nil {
+		return nil, err
+	}
+	return network.Serialize()
+}
+
+// Acquire waits until the write lock is available, then locks the metadata; does nothing on a null instance
+func (m *Network) Acquire() {
+	if m.IsNull() {
+		return
+	}
+	m.item.Acquire()
+}
+
+// Release unlocks the metadata; does nothing on a null instance
+func (m *Network) Release() {
+	if m.IsNull() {
+		return
+	}
+	m.item.Release()
+}
+
+// SaveNetwork saves the Network definition in Object Storage
+func SaveNetwork(svc iaas.Service, net *abstract.Network) (mn *Network, err error) {
+	defer fail.OnPanic(&err)()
+
+	if svc == nil {
+		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+	if net == nil {
+		return nil, fail.InvalidParameterError("net", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	mn, err = NewNetwork(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	mnm, err := mn.Carry(net)
+	if err != nil {
+		return nil, err
+	}
+
+	return mn, mnm.Write()
+}
+
+// SaveOrUpdateNetwork saves the metadata of the network, or updates them if they already exist, so it can be called
+// several times for the same network; existing metadata must describe the same network (same ID and same name),
+// otherwise a fail.ErrInconsistent is returned
+func SaveOrUpdateNetwork(svc iaas.Service, net *abstract.Network) (mn *Network, err error) {
+	defer fail.OnPanic(&err)()
+
+	if svc == nil {
+		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+	if net == nil {
+		return nil, fail.InvalidParameterError("net", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	mn, err = NewNetwork(svc)
+	if err != nil {
+		return nil, err
+	}
+	err = checkNetworkIdentity(net, mn.readNetworkFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	mnm, err := mn.Carry(net)
+	if err != nil {
+		return nil, err
+	}
+	return mn, mnm.Write()
+}
+
+// checkNetworkIdentity verifies the metadata already stored by ID and by name for 'network', if any, describe
+// the same network; 'read' reads a network from a folder of the metadata
+func checkNetworkIdentity(network *abstract.Network, read func(string, string) (*abstract.Network, error)) error {
+	for folder, key := range map[string]string{ByIDFolderName: network.ID, ByNameFolderName: network.Name} {
+		stored, err := read(folder, key)
+		if err != nil {
+			if _, ok := err.(fail.ErrNotFound); ok || err == stow.ErrNotFound { // FIXME: Remove stow dependency
+				continue
+			}
+			return err
+		}
+		if stored.ID != network.ID || stored.Name != network.Name {
+			return fail.InconsistentError(
+				fmt.Sprintf(
+					"cannot save network '%s' (%s): metadata already exist for network '%s' (%s)", network.Name,
+					network.ID, stored.Name, stored.ID,
+				),
+			)
+		}
+	}
+	return nil
+}
+
+// ImportNetwork recreates the metadata of a network from the content returned by Export, without touching
+// the provider resources
+// Returns fail.ErrDuplicate if metadata of the network already exist
+func ImportNetwork(svc iaas.Service, content []byte) (mn *Network, err error) {
+	defer fail.OnPanic(&err)()
+
+	if svc == nil {
+		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+	if len(content) == 0 {
+		return nil, fail.InvalidParameterError("content", "cannot be empty")
+	}
+
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	network := abstract.NewNetwork()
+	err = network.Deserialize(content)
+	if err != nil {
+		return nil, fail.SyntaxError(fmt.Sprintf("failed to decode network metadata: %v", err))
+	}
+	if network.ID == "" || network.Name == "" {
+		return nil, fail.InvalidParameterError("content", "must contain the ID and the name of the network")
+	}
+
+	_, err = LoadNetwork(svc, network.ID)
+	if err == nil {
+		return nil, fail.DuplicateError(fmt.Sprintf("metadata of network '%s' already exist", network.Name))
+	}
+	if _, ok := err.(fail.ErrNotFound); !ok {
+		return nil, err
+	}
+
+	gwIDs, err := GatewayIDsOf(network)
+	if err != nil {
+		return nil, err
+	}
+	for _, gwID := range gwIDs {
+		if _, err := LoadHost(svc, gwID); err != nil {
+			logrus.Warnf("gateway '%s' of imported network '%s' not found in metadata: %v", gwID, network.Name, err)
+		}
+	}
+
+	return SaveNetwork(svc, network)
+}
+
+// RemoveNetwork removes the Network definition from Object Storage
+func RemoveNetwork(svc iaas.Service, net *abstract.Network) (err error) {
+	defer fail.OnPanic(&err)()
+
+	if svc == nil {
+		return fail.InvalidParameterError("svc", "cannot be nil")
+	}
+	if net == nil {
+		return fail.InvalidParameterError("net", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, "(<iaas.Service>, "+net.Name+")", debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	aNet, err := NewNetwork(svc)
+	if err != nil {
+		return err
+	}
+
+	aNetm, err := aNet.Carry(net)
+	if err != nil {
+		return err
+	}
+
+	return aNetm.Delete()
+}
+
+// NetworkExists tells if SafeScale metadata exists for a network named 'name'; cheaper than LoadNetwork, the
+// metadata is not read and there is no retry
+func NetworkExists(svc iaas.Service, name string) (_ bool, err error) {
+	defer fail.OnPanic(&err)()
+
+	if svc == nil {
+		return false, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+	if name == "" {
+		return false, fail.InvalidParameterError("name", "cannot be empty string")
+	}
+
+	mn, err := NewNetwork(svc)
+	if err != nil {
+		return false, err
+	}
+	return mn.item.Exists(ByNameFolderName, name)
+}
+
+// LoadNetwork gets the Network definition from Object Storage
+// logic: Read by ID; if error is ErrNotFound then read by name; if error is ErrNotFound return this error
+//        In case of any other error, abort the retry to propagate the error
+//        If retry times out, return errNotFound
+func LoadNetwork(svc iaas.Service, ref string) (mn *Network, err error) {
+	defer fail.OnPanic(&err)()
+
+	if svc == nil {
+		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+	if ref == "" {
+		return nil, fail.InvalidParameterError("ref", "cannot be empty string")
+	}
+
+	tracer := debug.NewTracer(nil, "(<iaas.Service>, '"+ref+"')", debug.ShouldTrace("metadata.network")).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogErrorWithLevel(tracer.TraceMessage(""), &err, logrus.TraceLevel)()
+
+	mn, err = NewNetwork(svc)
+	if err != nil {
+		return nil, err
+	}
+	retryErr := retryPolicy().WhileUnsuccessful(
+		func() error {
+			innerErr := mn.ReadByReference(ref)
+			if innerErr != nil {
+				if _, ok := innerErr.(fail.ErrNotFound); ok {
+					return retry.AbortedError("no metadata found", innerErr)
+				}
+
+				if innerErr == stow.ErrNotFound { // FIXME: Remove stow dependency
+					return retry.AbortedError("no metadata found", innerErr)
+				}
+
+				// the timeout keeps as cause the error returned here, so the caller can recover why the reads failed
+				return readFailure(innerErr)
+			}
+
+			return nil
+		},
+		2*temporal.GetDefaultDelay(),
+	)
+	if retryErr != nil {
+		switch realErr := retryErr.(type) {
+		case retry.ErrAborted:
+			return nil, realErr.Cause()
+		case fail.ErrTimeout:
+			return nil, realErr
+		default:
+			return nil, fail.Cause(realErr)
+		}
+	}
+
+	ok, err := mn.OK()
 	if err != nil {
 		return nil, err
 	}
 
-	if !ok {
-		return nil, fail.NotFoundError(fmt.Sprintf("reference %s not found", ref))
-	}
-
+	if !ok {
+		return nil, fail.NotFoundError(fmt.Sprintf("reference %s not found", ref))
+	}
+
 	return mn, nil
 }
 
+// readFailure returns the error explaining why a read by reference failed: the first error of the list of the reads
+// by ID and by name which is not a "not found", 'err' itself otherwise
+func readFailure(err error) error {
+	list, ok := err.(fail.ErrList)
+	if !ok {
+		return err
+	}
+	for _, e := range list.Errors() {
+		if _, ok := e.(fail.ErrNotFound); ok || e == stow.ErrNotFound { // FIXME: Remove stow dependency
+			continue
+		}
+		return e
+	}
+	return err
+}
+
+// VerifyNetworksIntegrity verifies the integrity of the metadata of all the networks (see Network.VerifyIntegrity)
+func VerifyNetworksIntegrity(svc iaas.Service) (report *IntegrityReport, err error) {
+	defer fail.OnPanic(&err)()
+
+	if svc == nil {
+		return nil, fail.InvalidParameterError("svc", "cannot be nil")
+	}
+
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	mn, err := NewNetwork(svc)
+	if err != nil {
+		return nil, err
+	}
+	report = &IntegrityReport{}
+	err = mn.Browse(
+		func(network *abstract.Network) error {
+			report.Checked++
+			report.Problems = append(report.Problems, verifyNetworkIntegrity(network, mn.readNetworkFrom, mn.hostExists)...)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
 // Gateway links Object Storage folder and Network
 type Gateway struct {
 	host      *Host
@@ -644,7 +1976,7 @@ func NewGateway(svc iaas.Service, networkID string) (gw *Gateway, err error) {
 		return nil, fail.InvalidParameterError("networkID", "cannot be empty string")
 	}
 
-	tracer := debug.NewTracer(nil, "(<iaas.Service>, '"+networkID+"')", true).GoingIn()
+	tracer := debug.NewTracer(nil, "(<iaas.Service>, '"+networkID+"')", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -725,7 +2057,7 @@ func (mg *Gateway) Read() (err error) {
 		return fail.InvalidParameterError("mg.network", "cannot be nil")
 	}
 
-	tracer := debug.NewTracer(nil, "", true).GoingIn()
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -761,7 +2093,7 @@ func (mg *Gateway) Reload() (err error) {
 		return fail.InvalidInstanceError()
 	}
 
-	tracer := debug.NewTracer(nil, "", true).GoingIn()
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -793,7 +2125,7 @@ func (mg *Gateway) Delete() (err error) {
 		return fail.InvalidParameterError("mg.host", "cannot be nil")
 	}
 
-	tracer := debug.NewTracer(nil, "", true).GoingIn()
+	tracer := debug.NewTracer(nil, "", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -836,7 +2168,7 @@ func LoadGateway(svc iaas.Service, networkID string) (mg *Gateway, err error) {
 		return nil, fail.InvalidParameterError("networkID", "cannot be empty string")
 	}
 
-	tracer := debug.NewTracer(nil, "(<iaas.Service>, '"+networkID+"')", true).GoingIn()
+	tracer := debug.NewTracer(nil, "(<iaas.Service>, '"+networkID+"')", debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -845,7 +2177,7 @@ func LoadGateway(svc iaas.Service, networkID string) (mg *Gateway, err error) {
 		return nil, err
 	}
 
-	retryErr := retry.WhileUnsuccessfulDelay1Second(
+	retryErr := retryPolicy().WhileUnsuccessful(
 		func() error {
 			innerErr := mg.Read()
 			if innerErr != nil {
@@ -886,7 +2218,7 @@ func SaveGateway(svc iaas.Service, host *abstract.Host, networkID string) (mg *G
 		return nil, fail.InvalidParameterError("networkID", "cannot be empty string")
 	}
 
-	tracer := debug.NewTracer(nil, fmt.Sprintf("(<iaas.Service>, %s, '%s'", host.Name, networkID), true).GoingIn()
+	tracer := debug.NewTracer(nil, fmt.Sprintf("(<iaas.Service>, %s, '%s'", host.Name, networkID), debug.ShouldTrace("metadata.network")).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer fail.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -0,0 +1,1087 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hostproperty"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/hoststate"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/abstract/enums/networkproperty"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/abstract/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+	"github.com/CS-SI/SafeScale/lib/utils/retry"
+)
+
+func TestGetNetworkGateway(t *testing.T) {
+	network := abstract.NewNetwork()
+	network.Name = "net"
+	network.GatewayID = "gw-id"
+
+	loaded := map[string]*abstract.Host{
+		"gw-id": {ID: "gw-id", Name: "gw-net"},
+	}
+	loader := func(ref string) (*abstract.Host, error) {
+		if host, ok := loaded[ref]; ok {
+			return host, nil
+		}
+		return nil, fail.TimeoutError("failed to read metadata", 0, nil)
+	}
+
+	// configured
+	host, exists, err := getNetworkGateway(network, true, loader)
+	require.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "gw-net", host.Name)
+
+	// not configured
+	host, exists, err = getNetworkGateway(network, false, loader)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	assert.Nil(t, host)
+
+	// configured but failing to load
+	network.SecondaryGatewayID = "gw2-id"
+	host, exists, err = getNetworkGateway(network, false, loader)
+	assert.NotNil(t, err)
+	assert.True(t, exists)
+	assert.Nil(t, host)
+	_, ok := err.(fail.ErrTimeout)
+	assert.True(t, ok)
+}
+
+func TestPruneVanishedHosts(t *testing.T) {
+	networkHosts := propsv1.NewNetworkHosts()
+	networkHosts.ByID["live-id"] = "live"
+	networkHosts.ByName["live"] = "live-id"
+	networkHosts.ByID["ghost-id"] = "ghost"
+	networkHosts.ByName["ghost"] = "ghost-id"
+
+	loader := func(id string) error {
+		if id == "live-id" {
+			return nil
+		}
+		return fail.NotFoundError("reference " + id + " not found")
+	}
+
+	pruned, err := pruneVanishedHosts(networkHosts, loader)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"ghost-id"}, pruned)
+	assert.Equal(t, map[string]string{"live-id": "live"}, networkHosts.ByID)
+	assert.Equal(t, map[string]string{"live": "live-id"}, networkHosts.ByName)
+
+	// Other errors must not prune anything
+	networkHosts.ByID["ghost-id"] = "ghost"
+	networkHosts.ByName["ghost"] = "ghost-id"
+	pruned, err = pruneVanishedHosts(
+		networkHosts, func(id string) error {
+			return fail.TimeoutError("failed to read metadata", 0, nil)
+		},
+	)
+	assert.NotNil(t, err)
+	assert.Empty(t, pruned)
+	assert.Len(t, networkHosts.ByID, 2)
+}
+
+func TestRepairHostIndex(t *testing.T) {
+	networkHosts := propsv1.NewNetworkHosts()
+	// consistent entry
+	networkHosts.ByID["ok-id"] = "ok"
+	networkHosts.ByName["ok"] = "ok-id"
+	// crash between the two updates of AttachHost
+	networkHosts.ByID["half-id"] = "half"
+	// ... and of DetachHost
+	networkHosts.ByName["orphan"] = "orphan-id"
+	// vanished host
+	networkHosts.ByID["ghost-id"] = "ghost"
+
+	known := map[string]*abstract.Host{
+		"ok-id":     {ID: "ok-id", Name: "ok"},
+		"half-id":   {ID: "half-id", Name: "half"},
+		"orphan-id": {ID: "orphan-id", Name: "orphan"},
+	}
+	resolve := func(ref string) (*abstract.Host, error) {
+		if host, ok := known[ref]; ok {
+			return host, nil
+		}
+		return nil, fail.NotFoundError("reference " + ref + " not found")
+	}
+
+	repairs, err := repairHostIndex(networkHosts, resolve)
+	require.Nil(t, err)
+	assert.Len(t, repairs, 3)
+	expected := map[string]string{"ok-id": "ok", "half-id": "half", "orphan-id": "orphan"}
+	assert.Equal(t, expected, networkHosts.ByID)
+	assert.Equal(t, map[string]string{"ok": "ok-id", "half": "half-id", "orphan": "orphan-id"}, networkHosts.ByName)
+
+	// a consistent index needs no repair
+	repairs, err = repairHostIndex(networkHosts, resolve)
+	require.Nil(t, err)
+	assert.Empty(t, repairs)
+
+	// other errors stop the repair
+	networkHosts.ByID["other-id"] = "other"
+	_, err = repairHostIndex(
+		networkHosts, func(string) (*abstract.Host, error) {
+			return nil, fail.TimeoutError("failed to read metadata", 0, nil)
+		},
+	)
+	assert.NotNil(t, err)
+	assert.Equal(t, "other", networkHosts.ByID["other-id"])
+}
+
+func TestNetwork_RepairHostIndex(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	err := network.Properties.LockForWrite(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
+			networkHostsV1.ByID["host-id"] = "host"
+			networkHostsV1.ByName["stale"] = "host-id"
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+	_, err = SaveHost(svc, connectedHost(t, "host-id", "host", "net-id", "192.168.1.10"))
+	require.Nil(t, err)
+
+	repairs, err := mn.RepairHostIndex()
+	require.Nil(t, err)
+	assert.Len(t, repairs, 2)
+
+	// the repair has been saved
+	mn, err = LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	network, err = mn.Get()
+	require.Nil(t, err)
+	err = network.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
+			assert.Equal(t, map[string]string{"host-id": "host"}, networkHostsV1.ByID)
+			assert.Equal(t, map[string]string{"host": "host-id"}, networkHostsV1.ByName)
+			return nil
+		},
+	)
+	require.Nil(t, err)
+}
+
+func TestReserveIP(t *testing.T) {
+	networkIPs := propsv1.NewNetworkIPs()
+
+	// gateway and VIP
+	require.Nil(t, reserveIP(networkIPs, "192.168.1.0/24", "192.168.1.2", "gw-id"))
+	require.Nil(t, reserveIP(networkIPs, "192.168.1.0/24", "192.168.1.254", "vip-id"))
+	assert.Equal(t, map[string]string{"192.168.1.2": "gw-id", "192.168.1.254": "vip-id"}, networkIPs.ByIP)
+
+	// reserving again for the same owner is harmless
+	assert.Nil(t, reserveIP(networkIPs, "192.168.1.0/24", "192.168.1.2", "gw-id"))
+
+	// duplicate reservation by another resource is rejected
+	err := reserveIP(networkIPs, "192.168.1.0/24", "192.168.1.254", "host-id")
+	if assert.NotNil(t, err) {
+		_, ok := err.(fail.ErrDuplicate)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, "vip-id", networkIPs.ByIP["192.168.1.254"])
+
+	// IP outside of the network
+	err = reserveIP(networkIPs, "192.168.1.0/24", "10.0.0.1", "host-id")
+	if assert.NotNil(t, err) {
+		_, ok := err.(fail.ErrInvalidParameter)
+		assert.True(t, ok)
+	}
+	assert.NotNil(t, reserveIP(networkIPs, "192.168.1.0/24", "not-an-ip", "host-id"))
+}
+
+func TestNetwork_ExportImport(t *testing.T) {
+	svc := newMemoryService()
+
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	network.GatewayID = "gw-id"
+	err := network.Properties.LockForWrite(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
+			networkHostsV1.ByID["host-id"] = "host"
+			networkHostsV1.ByName["host"] = "host-id"
+			return nil
+		},
+	)
+	require.Nil(t, err)
+
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+	content, err := mn.Export()
+	require.Nil(t, err)
+
+	err = RemoveNetwork(svc, network)
+	require.Nil(t, err)
+	assert.Empty(t, svc.bucket.Objects)
+
+	_, err = ImportNetwork(svc, content)
+	require.Nil(t, err)
+
+	// Importing twice is refused
+	_, err = ImportNetwork(svc, content)
+	assert.IsType(t, fail.ErrDuplicate{}, err)
+
+	mn, err = LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	restored, err := mn.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "net-id", restored.ID)
+	assert.Equal(t, "net", restored.Name)
+	assert.Equal(t, "192.168.1.0/24", restored.CIDR)
+	assert.Equal(t, "gw-id", restored.GatewayID)
+	err = restored.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
+			assert.Equal(t, map[string]string{"host-id": "host"}, networkHostsV1.ByID)
+			assert.Equal(t, map[string]string{"host": "host-id"}, networkHostsV1.ByName)
+			return nil
+		},
+	)
+	require.Nil(t, err)
+}
+
+func TestFilterNetworkHosts(t *testing.T) {
+	networkHosts := propsv1.NewNetworkHosts()
+	for _, name := range []string{"master-1", "worker-1", "worker-2", "worker-3"} {
+		networkHosts.ByID[name+"-id"] = name
+		networkHosts.ByName[name] = name + "-id"
+	}
+	loaded := map[string]int{}
+	loader := func(id string) (*abstract.Host, error) {
+		loaded[id]++
+		host := abstract.NewHost()
+		host.ID = id
+		host.Name = networkHosts.ByID[id]
+		if id == "worker-3-id" {
+			host.LastState = hoststate.STOPPED
+		} else {
+			host.LastState = hoststate.STARTED
+		}
+		return host, nil
+	}
+	isWorker := func(id, name string) bool {
+		return strings.HasPrefix(name, "worker-")
+	}
+	isStarted := func(host *abstract.Host) bool {
+		return host.LastState == hoststate.STARTED
+	}
+
+	list, err := filterNetworkHosts(networkHosts, isWorker, isStarted, loader)
+	require.Nil(t, err)
+	var names []string
+	for _, h := range list {
+		names = append(names, h.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"worker-1", "worker-2"}, names)
+	// hosts not selected are not loaded
+	assert.Equal(t, map[string]int{"worker-1-id": 1, "worker-2-id": 1, "worker-3-id": 1}, loaded)
+
+	// no filter returns all hosts
+	list, err = filterNetworkHosts(networkHosts, nil, nil, loader)
+	require.Nil(t, err)
+	assert.Len(t, list, 4)
+
+	// loading failure is reported
+	_, err = filterNetworkHosts(
+		networkHosts, nil, nil, func(id string) (*abstract.Host, error) {
+			return nil, fail.TimeoutError("failed to read metadata", 0, nil)
+		},
+	)
+	assert.NotNil(t, err)
+}
+
+// connectedHost returns a host having an interface with IP 'ip' on the network 'networkID'
+func connectedHost(t *testing.T, id, name, networkID, ip string) *abstract.Host {
+	host := abstract.NewHost()
+	host.ID = id
+	host.Name = name
+	if networkID != "" {
+		err := host.Properties.LockForWrite(hostproperty.NetworkV1).ThenUse(
+			func(clonable data.Clonable) error {
+				hostNetworkV1 := clonable.(*propsv1.HostNetwork)
+				hostNetworkV1.NetworksByID[networkID] = "net"
+				hostNetworkV1.NetworksByName["net"] = networkID
+				hostNetworkV1.IPv4Addresses[networkID] = ip
+				return nil
+			},
+		)
+		require.Nil(t, err)
+	}
+	return host
+}
+
+func attachedHosts(t *testing.T, mn *Network) map[string]string {
+	network, err := mn.Get()
+	require.Nil(t, err)
+	var byID map[string]string
+	err = network.Properties.LockForRead(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			byID = clonable.(*propsv1.NetworkHosts).ByID
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	return byID
+}
+
+func TestNetwork_BrowsePaged(t *testing.T) {
+	svc := newMemoryService()
+	for i := 0; i < 50; i++ {
+		network := abstract.NewNetwork()
+		network.ID = fmt.Sprintf("net-id-%02d", i)
+		network.Name = fmt.Sprintf("net-%02d", i)
+		network.CIDR = fmt.Sprintf("10.0.%d.0/24", i)
+		_, err := SaveNetwork(svc, network)
+		require.Nil(t, err)
+	}
+	mn, err := NewNetwork(svc)
+	require.Nil(t, err)
+
+	seen := map[string]bool{}
+	cursor := ""
+	pages := 0
+	for {
+		page, next, err := mn.BrowsePaged(cursor, 10)
+		require.Nil(t, err)
+		require.True(t, len(page) <= 10)
+		for _, network := range page {
+			assert.False(t, seen[network.ID], "network '%s' returned twice", network.ID)
+			seen[network.ID] = true
+		}
+		pages++
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, 5, pages)
+	assert.Len(t, seen, 50)
+
+	// a network added before the cursor is not returned again, one added after is
+	page, next, err := mn.BrowsePaged("", 10)
+	require.Nil(t, err)
+	require.Len(t, page, 10)
+	for _, id := range []string{"net-id-00a", "net-id-49a"} {
+		network := abstract.NewNetwork()
+		network.ID = id
+		network.Name = "added-" + id
+		network.CIDR = "10.1.0.0/24"
+		_, err = SaveNetwork(svc, network)
+		require.Nil(t, err)
+	}
+	var rest []string
+	for cursor = next; cursor != ""; {
+		page, cursor, err = mn.BrowsePaged(cursor, 10)
+		require.Nil(t, err)
+		for _, network := range page {
+			rest = append(rest, network.ID)
+		}
+	}
+	assert.Len(t, rest, 41)
+	assert.NotContains(t, rest, "net-id-00a")
+	assert.Contains(t, rest, "net-id-49a")
+}
+
+func TestNetwork_AttachHost(t *testing.T) {
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	mn, err := SaveNetwork(newMemoryService(), network)
+	require.Nil(t, err)
+
+	// genuinely connected host
+	host := connectedHost(t, "host-id", "host", "net-id", "192.168.1.10")
+	require.Nil(t, mn.AttachHost(host, true))
+	assert.Equal(t, map[string]string{"host-id": "host"}, attachedHosts(t, mn))
+
+	// re-attaching is a no-op
+	require.Nil(t, mn.AttachHost(host, true))
+	assert.Equal(t, map[string]string{"host-id": "host"}, attachedHosts(t, mn))
+
+	// host without interface on the network is refused in verify mode
+	other := connectedHost(t, "other-id", "other", "another-net-id", "10.0.0.10")
+	err = mn.AttachHost(other, true)
+	assert.IsType(t, fail.ErrInconsistent{}, err)
+	assert.Equal(t, map[string]string{"host-id": "host"}, attachedHosts(t, mn))
+
+	// ... but accepted when not verified
+	require.Nil(t, mn.AttachHost(other, false))
+	assert.Len(t, attachedHosts(t, mn), 2)
+}
+
+func TestNetwork_AttachHost_IPCollision(t *testing.T) {
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	mn, err := SaveNetwork(newMemoryService(), network)
+	require.Nil(t, err)
+	require.Nil(t, mn.ReserveIP("192.168.1.254", "vip-id"))
+
+	// the IP of the host is already reserved: the host is not attached
+	host := connectedHost(t, "host-id", "host", "net-id", "192.168.1.254")
+	err = mn.AttachHost(host, true)
+	assert.IsType(t, fail.ErrDuplicate{}, err)
+	assert.Empty(t, attachedHosts(t, mn))
+
+	// an IP out of the CIDR is refused the same way
+	host = connectedHost(t, "host-id", "host", "net-id", "10.0.0.10")
+	err = mn.AttachHost(host, true)
+	assert.IsType(t, fail.ErrInvalidParameter{}, err)
+	assert.Empty(t, attachedHosts(t, mn))
+
+	// once the collision is solved, the host can be attached
+	host = connectedHost(t, "host-id", "host", "net-id", "192.168.1.10")
+	require.Nil(t, mn.AttachHost(host, true))
+	assert.Equal(t, map[string]string{"host-id": "host"}, attachedHosts(t, mn))
+}
+
+func TestNetwork_Summary(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	network.GatewayID = "gw-id"
+	network.SecondaryGatewayID = "gw2-id"
+	network.VIP = &abstract.VirtualIP{ID: "vip-id", PrivateIP: "192.168.1.254"}
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+	require.Nil(t, mn.ReserveIP("192.168.1.2", "gw-id"))
+	require.Nil(t, mn.ReserveIP("192.168.1.3", "gw2-id"))
+	require.Nil(t, mn.ReserveIP("192.168.1.254", "vip-id"))
+	require.Nil(t, mn.AttachHost(connectedHost(t, "host-id", "host", "net-id", "192.168.1.10"), true))
+	require.Nil(t, mn.Write())
+
+	svc.bucket.Reads = nil
+	summary, err := mn.Summary()
+	require.Nil(t, err)
+	assert.Len(t, svc.bucket.Reads, 1)
+
+	current, err := mn.Get()
+	require.Nil(t, err)
+	assert.Equal(t, current.ID, summary.ID)
+	assert.Equal(t, current.CIDR, summary.CIDR)
+	assert.Equal(t, current.GatewayID, summary.GatewayID)
+	assert.Equal(t, current.SecondaryGatewayID, summary.SecondaryGatewayID)
+	assert.Equal(t, "192.168.1.2", summary.GatewayIP)
+	assert.Equal(t, "192.168.1.3", summary.SecondaryGatewayIP)
+	assert.Equal(t, current.VIP, summary.VIP)
+	assert.Equal(t, len(attachedHosts(t, mn)), summary.HostCount)
+	assert.Equal(t, 1, summary.HostCount)
+}
+
+func TestLoadNetwork_RetryPolicy(t *testing.T) {
+	defer func(previous func() retry.Policy) { retryPolicy = previous }(retryPolicy)
+	retryPolicy = func() retry.Policy {
+		return retry.Policy{BaseDelay: 20 * time.Millisecond, Factor: 2}
+	}
+
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	_, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	// 2 transient failures: the reads are retried after 20ms then 40ms
+	svc.bucket.Reads = nil
+	svc.bucket.ReadErrors = 2
+	begin := time.Now()
+	mn, err := LoadNetwork(svc, "net-id")
+	require.Nil(t, err)
+	assert.True(t, time.Since(begin) >= 60*time.Millisecond)
+	assert.Len(t, svc.bucket.Reads, 3)
+	loaded, err := mn.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "net", loaded.Name)
+}
+
+// authError is the error of a provider rejecting the credentials
+type authError struct{}
+
+func (authError) Error() string {
+	return "invalid credentials"
+}
+
+func TestLoadNetwork_TimeoutKeepsCause(t *testing.T) {
+	defer func(previous func() retry.Policy) { retryPolicy = previous }(retryPolicy)
+	retryPolicy = func() retry.Policy {
+		return retry.Policy{BaseDelay: 10 * time.Millisecond, Timeout: 50 * time.Millisecond}
+	}
+
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	_, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	svc.bucket.ReadError = authError{}
+	_, err = LoadNetwork(svc, "net")
+	require.NotNil(t, err)
+	_, ok := err.(fail.ErrTimeout)
+	assert.True(t, ok)
+	assert.Equal(t, authError{}, fail.Cause(err))
+	var cause authError
+	assert.True(t, errors.As(err, &cause))
+}
+
+func TestReadFailure(t *testing.T) {
+	notFound := fail.NotFoundError("not found")
+	err := readFailure(fail.ErrListError([]error{notFound, authError{}}))
+	assert.Equal(t, authError{}, err)
+
+	list := fail.ErrListError([]error{notFound, notFound})
+	assert.Equal(t, list, readFailure(list))
+	assert.Equal(t, authError{}, readFailure(authError{}))
+}
+
+func problemKinds(report *IntegrityReport) []string {
+	var kinds []string
+	for _, p := range report.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// integrityNetwork saves a consistent HA network with one attached host, and the metadata of its gateways and host
+func integrityNetwork(t *testing.T, svc *memoryService) *Network {
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	network.GatewayID = "gw-id"
+	network.SecondaryGatewayID = "gw2-id"
+	network.VIP = &abstract.VirtualIP{ID: "vip-id", PrivateIP: "192.168.1.254"}
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+	require.Nil(t, mn.ReserveIP("192.168.1.254", "vip-id"))
+	host := connectedHost(t, "host-id", "host", "net-id", "192.168.1.10")
+	require.Nil(t, mn.AttachHost(host, true))
+	require.Nil(t, mn.Write())
+	for _, h := range []*abstract.Host{host, connectedHost(t, "gw-id", "gw", "", ""), connectedHost(t, "gw2-id", "gw2", "", "")} {
+		_, err = SaveHost(svc, h)
+		require.Nil(t, err)
+	}
+	return mn
+}
+
+func TestNetwork_VerifyIntegrity(t *testing.T) {
+	svc := newMemoryService()
+	mn := integrityNetwork(t, svc)
+
+	report, err := mn.VerifyIntegrity()
+	require.Nil(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, 1, report.Checked)
+}
+
+func TestNetwork_VerifyIntegrity_NameMismatch(t *testing.T) {
+	svc := newMemoryService()
+	mn := integrityNetwork(t, svc)
+
+	network, err := mn.Get()
+	require.Nil(t, err)
+	network.CIDR = "10.0.0.0/8"
+	require.Nil(t, mn.item.WriteInto(ByNameFolderName, network.Name))
+
+	report, err := mn.VerifyIntegrity()
+	require.Nil(t, err)
+	assert.Equal(t, []string{IntegrityNameMismatch}, problemKinds(report))
+}
+
+func TestNetwork_VerifyIntegrity_MissingGateway(t *testing.T) {
+	svc := newMemoryService()
+	mn := integrityNetwork(t, svc)
+
+	mh, err := LoadHost(svc, "gw2-id")
+	require.Nil(t, err)
+	require.Nil(t, mh.Delete())
+
+	report, err := mn.VerifyIntegrity()
+	require.Nil(t, err)
+	assert.Equal(t, []string{IntegrityMissingGateway}, problemKinds(report))
+	assert.Contains(t, report.Problems[0].Detail, "gw2-id")
+	assert.Equal(t, "net", report.Problems[0].NetworkName)
+}
+
+func TestNetwork_VerifyIntegrity_InvalidVIP(t *testing.T) {
+	svc := newMemoryService()
+	mn := integrityNetwork(t, svc)
+	require.Nil(t, mn.ReleaseIP("192.168.1.254"))
+	require.Nil(t, mn.Write())
+
+	report, err := mn.VerifyIntegrity()
+	require.Nil(t, err)
+	assert.Equal(t, []string{IntegrityInvalidVIP}, problemKinds(report))
+}
+
+func TestNetwork_VerifyIntegrity_InconsistentHosts(t *testing.T) {
+	svc := newMemoryService()
+	mn := integrityNetwork(t, svc)
+
+	network, err := mn.Get()
+	require.Nil(t, err)
+	err = network.Properties.LockForWrite(networkproperty.HostsV1).ThenUse(
+		func(clonable data.Clonable) error {
+			networkHostsV1 := clonable.(*propsv1.NetworkHosts)
+			delete(networkHostsV1.ByName, "host")
+			networkHostsV1.ByName["ghost"] = "ghost-id"
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	require.Nil(t, mn.Write())
+
+	report, err := mn.VerifyIntegrity()
+	require.Nil(t, err)
+	assert.Equal(t, []string{IntegrityInconsistentHosts, IntegrityInconsistentHosts}, problemKinds(report))
+}
+
+func TestNetwork_VerifyIntegrity_VanishedHost(t *testing.T) {
+	svc := newMemoryService()
+	mn := integrityNetwork(t, svc)
+
+	mh, err := LoadHost(svc, "host-id")
+	require.Nil(t, err)
+	require.Nil(t, mh.Delete())
+
+	report, err := mn.VerifyIntegrity()
+	require.Nil(t, err)
+	assert.Equal(t, []string{IntegrityInconsistentHosts}, problemKinds(report))
+}
+
+func TestVerifyNetworksIntegrity(t *testing.T) {
+	svc := newMemoryService()
+	integrityNetwork(t, svc)
+
+	other := abstract.NewNetwork()
+	other.ID = "other-id"
+	other.Name = "other"
+	other.GatewayID = "missing-gw-id"
+	_, err := SaveNetwork(svc, other)
+	require.Nil(t, err)
+
+	report, err := VerifyNetworksIntegrity(svc)
+	require.Nil(t, err)
+	assert.Equal(t, 2, report.Checked)
+	require.Equal(t, []string{IntegrityMissingGateway}, problemKinds(report))
+	assert.Equal(t, "other-id", report.Problems[0].NetworkID)
+}
+
+func TestSaveOrUpdateNetwork(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net-safescale"
+	network.CIDR = "192.168.0.0/24"
+
+	// First save
+	_, err := SaveOrUpdateNetwork(svc, network)
+	require.Nil(t, err)
+	mn, err := LoadNetwork(svc, "net-safescale")
+	require.Nil(t, err)
+	loaded, err := mn.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.0.0/24", loaded.CIDR)
+
+	// Second save of the same network updates it
+	updated := abstract.NewNetwork()
+	updated.ID = "net-id"
+	updated.Name = "net-safescale"
+	updated.CIDR = "192.168.1.0/24"
+	_, err = SaveOrUpdateNetwork(svc, updated)
+	require.Nil(t, err)
+	mn, err = LoadNetwork(svc, "net-id")
+	require.Nil(t, err)
+	loaded, err = mn.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.0/24", loaded.CIDR)
+	assert.Len(t, svc.bucket.Objects, 2)
+}
+
+func TestSaveOrUpdateNetwork_IdentityMismatch(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net-safescale"
+	_, err := SaveOrUpdateNetwork(svc, network)
+	require.Nil(t, err)
+
+	// Same name, other ID
+	other := abstract.NewNetwork()
+	other.ID = "other-id"
+	other.Name = "net-safescale"
+	_, err = SaveOrUpdateNetwork(svc, other)
+	assert.IsType(t, fail.ErrInconsistent{}, err)
+
+	// Same ID, other name
+	renamed := abstract.NewNetwork()
+	renamed.ID = "net-id"
+	renamed.Name = "renamed"
+	_, err = SaveOrUpdateNetwork(svc, renamed)
+	assert.IsType(t, fail.ErrInconsistent{}, err)
+
+	mn, err := LoadNetwork(svc, "net-safescale")
+	require.Nil(t, err)
+	loaded, err := mn.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "net-id", loaded.ID)
+	assert.Len(t, svc.bucket.Objects, 2)
+}
+
+func TestRetryWhileNotFound(t *testing.T) {
+	defer func(previous func() retry.Policy) { retryPolicy = previous }(retryPolicy)
+	retryPolicy = func() retry.Policy {
+		return retry.Policy{BaseDelay: 10 * time.Millisecond}
+	}
+
+	calls := 0
+	loader := retryWhileNotFound(
+		func(ref string) (*abstract.Host, error) {
+			calls++
+			if calls == 1 {
+				return nil, fail.NotFoundError("host '" + ref + "' not found")
+			}
+			return &abstract.Host{ID: ref}, nil
+		}, time.Second,
+	)
+	host, err := loader("gw-id")
+	require.Nil(t, err)
+	assert.Equal(t, "gw-id", host.ID)
+	assert.Equal(t, 2, calls)
+
+	// Other errors are not retried
+	calls = 0
+	loader = retryWhileNotFound(
+		func(ref string) (*abstract.Host, error) {
+			calls++
+			return nil, fail.InconsistentError("corrupted")
+		}, time.Second,
+	)
+	_, err = loader("gw-id")
+	assert.IsType(t, fail.ErrInconsistent{}, err)
+	assert.Equal(t, 1, calls)
+
+	// The metadata never become visible
+	loader = retryWhileNotFound(
+		func(ref string) (*abstract.Host, error) {
+			return nil, fail.NotFoundError("host '" + ref + "' not found")
+		}, 50*time.Millisecond,
+	)
+	_, err = loader("gw-id")
+	assert.IsType(t, fail.ErrNotFound{}, err)
+}
+
+func TestNetwork_GetGateway_EventualConsistency(t *testing.T) {
+	defer func(previous func() retry.Policy) { retryPolicy = previous }(retryPolicy)
+	retryPolicy = func() retry.Policy {
+		return retry.Policy{BaseDelay: 10 * time.Millisecond}
+	}
+
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.GatewayID = "gw-id"
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	// The metadata of the gateway are not visible at once
+	misses := 0
+	svc.bucket.Hide = func(name string) bool {
+		if strings.Contains(name, "gw-id") && misses < 2 {
+			misses++
+			return true
+		}
+		return false
+	}
+	_, err = SaveHost(svc, connectedHost(t, "gw-id", "gw", "", ""))
+	require.Nil(t, err)
+
+	gw, err := mn.GetGateway(true)
+	require.Nil(t, err)
+	assert.Equal(t, "gw-id", gw.ID)
+	assert.Equal(t, 2, misses)
+}
+
+// nullNetwork returns a Network not linked to metadata
+func nullNetwork() *Network {
+	return &Network{}
+}
+
+func TestNetwork_NullInstance(t *testing.T) {
+	for _, m := range []*Network{nil, nullNetwork()} {
+		assert.True(t, m.IsNull())
+		assert.NotPanics(
+			t, func() {
+				assert.Nil(t, m.GetService())
+				path, err := m.GetPath()
+				assert.NotNil(t, err)
+				assert.Empty(t, path)
+				network, err := m.Get()
+				assert.NotNil(t, err)
+				assert.Nil(t, network)
+				host, exists, err := m.GetGatewayE(true)
+				assert.NotNil(t, err)
+				assert.False(t, exists)
+				assert.Nil(t, host)
+				host, err = m.GetGateway(false)
+				assert.NotNil(t, err)
+				assert.Nil(t, host)
+				assert.Nil(t, m.SafeGetGateway(true))
+				assert.Nil(t, m.SafeGetGateway(false))
+				summary, err := m.Summary()
+				assert.NotNil(t, err)
+				assert.Nil(t, summary)
+				m.Acquire()
+				m.Release()
+			},
+		)
+	}
+}
+
+func TestNetwork_NotCarrying(t *testing.T) {
+	m, err := NewNetwork(newMemoryService())
+	require.Nil(t, err)
+	assert.False(t, m.IsNull())
+
+	assert.NotPanics(
+		t, func() {
+			network, err := m.Get()
+			assert.IsType(t, fail.ErrInvalidInstanceContent{}, err)
+			assert.Nil(t, network)
+			assert.Nil(t, m.SafeGetGateway(true))
+		},
+	)
+}
+
+func TestNetwork_GetDefaultRouteIP_Cached(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	network.GatewayID = "gw-id"
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+	gw := connectedHost(t, "gw-id", "gw", "net-id", "192.168.1.1")
+	_, err = SaveHost(svc, gw)
+	require.Nil(t, err)
+
+	ip, err := mn.GetDefaultRouteIP()
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.1", ip)
+
+	// Repeated calls don't read the metadata of the gateway again
+	reads := len(svc.bucket.Reads)
+	for i := 0; i < 5; i++ {
+		ip, err = mn.GetDefaultRouteIP()
+		require.Nil(t, err)
+		assert.Equal(t, "192.168.1.1", ip)
+	}
+	assert.Len(t, svc.bucket.Reads, reads)
+
+	// Writing the network invalidates the cache
+	network.VIP = &abstract.VirtualIP{PrivateIP: "192.168.1.254"}
+	require.Nil(t, mn.Write())
+	ip, err = mn.GetDefaultRouteIP()
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.254", ip)
+}
+
+func TestNetwork_GetDefaultRouteIP_Expiry(t *testing.T) {
+	defer func(previous time.Duration) { defaultRouteIPCacheTTL = previous }(defaultRouteIPCacheTTL)
+	defaultRouteIPCacheTTL = 0
+
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.GatewayID = "gw-id"
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+	_, err = SaveHost(svc, connectedHost(t, "gw-id", "gw", "net-id", "192.168.1.1"))
+	require.Nil(t, err)
+
+	_, err = mn.GetDefaultRouteIP()
+	require.Nil(t, err)
+	reads := len(svc.bucket.Reads)
+	_, err = mn.GetDefaultRouteIP()
+	require.Nil(t, err)
+	assert.True(t, len(svc.bucket.Reads) > reads)
+}
+
+func TestNetwork_GetDefaultRouteIP_NoGateway(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	_, err = mn.GetDefaultRouteIP()
+	_, ok := err.(fail.ErrNotFound)
+	assert.True(t, ok)
+	assert.Equal(t, "", mn.routeIP)
+}
+
+func TestNetwork_GetGatewayPhaseStatus(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.GatewayID = "gw-id"
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+	gw := connectedHost(t, "gw-id", "gw", "net-id", "192.168.1.1")
+	err = gw.Properties.LockForWrite(hostproperty.GatewayPhasesV1).ThenUse(
+		func(clonable data.Clonable) error {
+			phases := clonable.(*propsv1.HostGatewayPhases)
+			phases.Completed = []string{"init"}
+			phases.Current = "configuration"
+			phases.Error = "failed to run phase2"
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	_, err = SaveHost(svc, gw)
+	require.Nil(t, err)
+
+	status, err := mn.GetGatewayPhaseStatus(true)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"init"}, status.Completed)
+	assert.Equal(t, "configuration", status.Current)
+	assert.True(t, status.Failed())
+
+	_, err = mn.GetGatewayPhaseStatus(false)
+	_, ok := err.(fail.ErrNotFound)
+	assert.True(t, ok)
+}
+
+func TestNetwork_CreationSpec(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.CIDR = "192.168.1.0/24"
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	// networks created before the parameters were recorded have no spec
+	_, err = mn.GetCreationSpec()
+	_, ok := err.(fail.ErrNotFound)
+	assert.True(t, ok)
+
+	spec := &propsv1.NetworkCreationSpec{
+		CIDR:                   "192.168.1.0/24",
+		AdditionalIngressPorts: []int{443},
+		ImageID:                "img-id",
+		TemplateID:             "tpl-id",
+		Sizing:                 propsv1.NetworkGatewaySizing{MinCores: 2},
+	}
+	require.Nil(t, mn.SetCreationSpec(spec))
+	require.Nil(t, mn.Write())
+	spec.AdditionalIngressPorts[0] = 80
+
+	mn, err = LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	stored, err := mn.GetCreationSpec()
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.0/24", stored.CIDR)
+	assert.Equal(t, []int{443}, stored.AdditionalIngressPorts)
+	assert.Equal(t, "img-id", stored.ImageID)
+	assert.Equal(t, "tpl-id", stored.TemplateID)
+	assert.Equal(t, 2, stored.Sizing.MinCores)
+}
+
+func TestGatewayIDsOf(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	network.GatewayID = "gw-id"
+	network.SecondaryGatewayID = "gw2-id"
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+
+	// networks created before the gateways were recorded in a property
+	ids, err := GatewayIDsOf(network)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"gw-id", "gw2-id"}, ids)
+
+	require.Nil(t, mn.SetGatewayIDs([]string{"gw-id", "gw2-id", "gw3-id"}))
+	require.Nil(t, mn.Write())
+	mn, err = LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	network, err = mn.Get()
+	require.Nil(t, err)
+	ids, err = GatewayIDsOf(network)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"gw-id", "gw2-id", "gw3-id"}, ids)
+	assert.Equal(t, "gw-id", network.GatewayID)
+	assert.Equal(t, "gw2-id", network.SecondaryGatewayID)
+}
+
+func TestNetwork_GetGatewayByIndex(t *testing.T) {
+	svc := newMemoryService()
+	network := abstract.NewNetwork()
+	network.ID = "net-id"
+	network.Name = "net"
+	mn, err := SaveNetwork(svc, network)
+	require.Nil(t, err)
+	for i, id := range []string{"gw-id", "gw2-id", "gw3-id"} {
+		gw := connectedHost(t, id, strings.TrimSuffix(id, "-id"), "net-id", fmt.Sprintf("192.168.1.%d", i+1))
+		_, err = SaveHost(svc, gw)
+		require.Nil(t, err)
+	}
+	require.Nil(t, mn.SetGatewayIDs([]string{"gw-id", "gw2-id", "gw3-id"}))
+
+	gw, err := mn.GetGatewayByIndex(2)
+	require.Nil(t, err)
+	assert.Equal(t, "gw3", gw.Name)
+
+	_, err = mn.GetGatewayByIndex(3)
+	assert.IsType(t, fail.ErrNotFound{}, err)
+	_, err = mn.GetGatewayByIndex(-1)
+	assert.IsType(t, fail.ErrInvalidParameter{}, err)
+}
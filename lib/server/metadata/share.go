@@ -482,7 +482,7 @@ func LoadShare(svc iaas.Service, ref string) (share string, err error) {
 		return "", err
 	}
 
-	retryErr := retry.WhileUnsuccessfulDelay1Second(
+	retryErr := retryPolicy().WhileUnsuccessful(
 		func() error {
 			innerErr := ms.ReadByReference(ref)
 			if innerErr != nil {
@@ -412,7 +412,7 @@ func LoadVolume(svc iaas.Service, ref string) (mv *Volume, err error) {
 		return nil, err
 	}
 
-	retryErr := retry.WhileUnsuccessfulDelay1Second(
+	retryErr := retryPolicy().WhileUnsuccessful(
 		func() error {
 			innerErr := mv.ReadByReference(ref)
 			if innerErr != nil {
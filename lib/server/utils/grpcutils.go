@@ -17,6 +17,7 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -24,20 +25,31 @@ import (
 	"google.golang.org/grpc"
 
 	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
 )
 
-// GetConnection returns a connection to GRPC server
+// GetConnection returns a connection to GRPC server; the errors returned by the server are converted back to the
+// errors of package fail (see fail.FromGRPCStatus)
 func GetConnection(host string, port int) *grpc.ClientConn {
 	address := fmt.Sprintf("%s:%d", host, port)
 
 	// Set up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithUnaryInterceptor(errorInterceptor))
 	if err != nil {
 		log.Fatalf("failed to connect to safescaled (%s:%d): %v", host, port, err)
 	}
 	return conn
 }
 
+// errorInterceptor converts the error of a call to the GRPC server to the corresponding error of package fail,
+// restoring its causes
+func errorInterceptor(
+	ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	return fail.FromGRPCStatus(invoker(ctx, method, req, reply, cc, opts...))
+}
+
 // GetReference return a reference from the name or id given in the pb.Reference
 func GetReference(in *pb.Reference) string {
 	var ref string
@@ -0,0 +1,31 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package copypolicy
+
+//go:generate stringer -type=Enum
+
+// Enum ...
+type Enum int
+
+const (
+	// OVERWRITE replaces the destination if it already exists (default)
+	OVERWRITE Enum = iota
+	// SKIPIFEXISTS leaves the destination untouched if it already exists
+	SKIPIFEXISTS
+	// FAILIFEXISTS refuses to copy if the destination already exists
+	FAILIFEXISTS
+)
@@ -0,0 +1,33 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runphase
+
+//go:generate stringer -type=Enum
+
+// Enum tells at which step the execution of a remote command stopped
+type Enum int
+
+const (
+	// CONNECT when the host could not be reached
+	CONNECT Enum = iota
+	// START when the command could not be launched
+	START
+	// WAIT when the command has been launched but its completion could not be observed
+	WAIT
+	// COMPLETED when the command ran to its end (whatever its exit code)
+	COMPLETED
+)
@@ -19,6 +19,7 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -95,6 +96,11 @@ func (r *response) Failure(err error) error {
 
 // Display ...
 func (r *response) Display() {
+	r.displayTo(os.Stdout)
+}
+
+// displayTo writes the response as JSON to 'w'
+func (r *response) displayTo(w io.Writer) {
 	out, err := json.Marshal(r.getDisplayResponse())
 	if err != nil {
 		logrus.Error("lib/utils/response.go: Response.Display(): failed to marshal the Response")
@@ -117,7 +123,7 @@ func (r *response) Display() {
 	}
 
 	out, _ = json.Marshal(mapped)
-	fmt.Println(string(out))
+	_, _ = fmt.Fprintln(w, string(out))
 }
 
 // getDisplayResponse ...
@@ -152,3 +158,12 @@ func SuccessResponse(result interface{}) error {
 	_ = r.Success(result)
 	return nil
 }
+
+// SuccessResponseTo writes the success response containing 'result' to 'w' instead of the standard output
+func SuccessResponseTo(w io.Writer, result interface{}) error {
+	r := newResponse()
+	r.Status = cmdstatus.SUCCESS
+	r.Result = result
+	r.displayTo(w)
+	return nil
+}
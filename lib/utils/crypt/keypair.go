@@ -17,23 +17,59 @@
 package crypt
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 
 	"golang.org/x/crypto/ssh"
 
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
 )
 
+const (
+	// KeyTypeRSA is the type of RSA keys
+	KeyTypeRSA = "rsa"
+	// KeyTypeEd25519 is the type of Ed25519 keys
+	KeyTypeEd25519 = "ed25519"
+
+	// DefaultRSAKeyBits is the size of the RSA keys generated if not specified
+	DefaultRSAKeyBits = 2048
+)
+
 // GenerateRSAKeyPair creates a key pair
 func GenerateRSAKeyPair(name string) (privKey string, pubKey string, err error) {
+	return GenerateKeyPair(name, KeyTypeRSA, DefaultRSAKeyBits)
+}
+
+// GenerateKeyPair creates a key pair of type 'keyType' (KeyTypeRSA if empty); 'bits' is the size of RSA keys
+// (DefaultRSAKeyBits if 0), ignored for Ed25519 keys
+// The public key is in authorized_keys format, the private key in PEM format
+func GenerateKeyPair(name string, keyType string, bits int) (privKey string, pubKey string, err error) {
 	if name == "" {
 		return "", "", fail.InvalidParameterError("name", "cannot be empty string")
 	}
 
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	switch keyType {
+	case "", KeyTypeRSA:
+		if bits == 0 {
+			bits = DefaultRSAKeyBits
+		}
+		if bits < DefaultRSAKeyBits {
+			return "", "", fail.InvalidParameterError("bits", fmt.Sprintf("cannot be under %d", DefaultRSAKeyBits))
+		}
+		return generateRSAKeyPair(bits)
+	case KeyTypeEd25519:
+		return generateEd25519KeyPair()
+	default:
+		return "", "", fail.InvalidParameterError("keyType", fmt.Sprintf("unsupported key type '%s'", keyType))
+	}
+}
+
+func generateRSAKeyPair(bits int) (privKey string, pubKey string, err error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
 	if err != nil {
 		return "", "", err
 	}
@@ -53,3 +89,27 @@ func GenerateRSAKeyPair(name string) (privKey string, pubKey string, err error)
 	)
 	return string(priKeyPem), string(pubBytes), nil
 }
+
+func generateEd25519KeyPair() (privKey string, pubKey string, err error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	pub, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return "", "", err
+	}
+	pubBytes := ssh.MarshalAuthorizedKey(pub)
+
+	priBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", "", err
+	}
+	priKeyPem := pem.EncodeToMemory(
+		&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: priBytes,
+		},
+	)
+	return string(priKeyPem), string(pubBytes), nil
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crypt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGenerateKeyPair_Ed25519(t *testing.T) {
+	privKey, pubKey, err := GenerateKeyPair("kp", KeyTypeEd25519, 0)
+	require.Nil(t, err)
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pubKey))
+	require.Nil(t, err)
+	assert.Equal(t, ssh.KeyAlgoED25519, pub.Type())
+
+	signer, err := ssh.ParsePrivateKey([]byte(privKey))
+	require.Nil(t, err)
+	assert.Equal(t, pub.Marshal(), signer.PublicKey().Marshal())
+}
+
+func TestGenerateKeyPair_RSA(t *testing.T) {
+	_, pubKey, err := GenerateKeyPair("kp", KeyTypeRSA, 3072)
+	require.Nil(t, err)
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pubKey))
+	require.Nil(t, err)
+	assert.Equal(t, ssh.KeyAlgoRSA, pub.Type())
+
+	_, _, err = GenerateKeyPair("kp", KeyTypeRSA, 1024)
+	assert.NotNil(t, err)
+	_, _, err = GenerateKeyPair("kp", "dsa", 0)
+	assert.NotNil(t, err)
+	_, _, err = GenerateKeyPair("", KeyTypeEd25519, 0)
+	assert.NotNil(t, err)
+}
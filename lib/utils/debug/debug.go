@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
 )
@@ -82,3 +83,19 @@ func IfTrace(key string) bool {
 	}
 	return false
 }
+
+var defaultSettingsOnce sync.Once
+
+// ShouldTrace tells if traces of the subsystem 'key' ("<key>" or "<key>.<subkey>") have to be emitted
+// If no trace settings have been registered yet, registers empty settings, enabling only the traces listed
+// in environment variable SAFESCALE_TRACE
+func ShouldTrace(key string) bool {
+	defaultSettingsOnce.Do(
+		func() {
+			if settings == nil {
+				_ = RegisterTraceSettings("{}")
+			}
+		},
+	)
+	return IfTrace(key)
+}
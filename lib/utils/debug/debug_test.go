@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package debug
+
+import (
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func tracedFunction(key string) {
+	tracer := NewTracer(nil, "", ShouldTrace(key)).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	tracer.Trace("working")
+}
+
+func TestShouldTrace(t *testing.T) {
+	_ = os.Unsetenv("SAFESCALE_TRACE")
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	level := logrus.GetLevel()
+	logrus.SetLevel(logrus.TraceLevel)
+	defer logrus.SetLevel(level)
+
+	// subsystem trace off
+	assert.False(t, ShouldTrace("handlers.network"))
+	tracedFunction("handlers.network")
+	assert.Empty(t, hook.AllEntries())
+
+	// subsystem trace on
+	saved := settings
+	defer func() { settings = saved }()
+	settings = map[string]map[string]struct{}{"handlers": {"network": {}}}
+	assert.True(t, ShouldTrace("handlers.network"))
+	assert.False(t, ShouldTrace("metadata.network"))
+	tracedFunction("handlers.network")
+	assert.Len(t, hook.AllEntries(), 3)
+
+	hook.Reset()
+	tracedFunction("metadata.network")
+	assert.Empty(t, hook.AllEntries())
+}
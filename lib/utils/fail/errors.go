@@ -100,6 +100,22 @@ func IsGRPCTimeout(err error) bool {
 	return grpcstatus.Code(err) == codes.DeadlineExceeded
 }
 
+// DefaultMaxFormattedConsequences is the default number of consequences written in the message of an error
+const DefaultMaxFormattedConsequences = 32
+
+// maxFormattedConsequences is the number of consequences written in the message of an error
+var maxFormattedConsequences int32 = DefaultMaxFormattedConsequences
+
+// SetMaxFormattedConsequences sets the number of consequences written in the message of an error; the others
+// are replaced by a marker telling how many have been omitted (they remain available with Consequences())
+// A value <= 0 restores the default
+func SetMaxFormattedConsequences(max int) {
+	if max <= 0 {
+		max = DefaultMaxFormattedConsequences
+	}
+	atomic.StoreInt32(&maxFormattedConsequences, int32(max))
+}
+
 // ErrCore ...
 type ErrCore struct {
 	message      string
@@ -120,20 +136,29 @@ func (e ErrCore) CauseFormatter() string {
 
 	lenConseq := len(e.Consequences())
 	if lenConseq > 0 {
+		shown := e.Consequences()
+		if max := int(atomic.LoadInt32(&maxFormattedConsequences)); lenConseq > max {
+			shown = shown[:max]
+		}
 		msgFinal += "[with consequences {"
-		for ind, con := range e.Consequences() {
+		for ind, con := range shown {
 			msgFinal += con.Error()
-			if ind+1 < lenConseq {
+			if ind+1 < len(shown) {
 				msgFinal += ";"
 			}
 		}
+		if omitted := lenConseq - len(shown); omitted > 0 {
+			msgFinal += fmt.Sprintf(";(%d more consequences omitted)", omitted)
+		}
 		msgFinal += "}]"
 	}
 
 	return msgFinal
 }
 
-// Reset imports content of error err to receiving error e
+// Reset imports content of error err (message, cause and consequences) to receiving error e
+// ErrCore carries no other context: the kind of the error, and so its gRPC code, is given by the type embedding
+// the ErrCore, which is kept by the callers doing 'e.ErrCore = e.ErrCore.Reset(...)'
 func (e ErrCore) Reset(err error) ErrCore {
 	if err != nil {
 		if cerr, ok := err.(ErrCore); ok {
@@ -150,6 +175,11 @@ func (e ErrCore) Cause() error {
 	return e.cause
 }
 
+// Unwrap returns the cause of the error, so errors.Is and errors.As go through the chain of causes
+func (e ErrCore) Unwrap() error {
+	return e.cause
+}
+
 func (e ErrCore) Message() string {
 	return e.message
 }
@@ -287,6 +317,11 @@ func (e ErrTimeout) AddConsequence(err error) error {
 	return e
 }
 
+// Duration returns the duration after which the timeout occurred
+func (e ErrTimeout) Duration() time.Duration {
+	return e.dur
+}
+
 // TimeoutError ...
 func TimeoutError(msg string, timeout time.Duration, cause error) ErrTimeout {
 	return ErrTimeout{
@@ -398,6 +433,64 @@ func InvalidRequestError(msg string) ErrInvalidRequest {
 	}
 }
 
+// ErrInvalidCIDR when a CIDR is malformed
+type ErrInvalidCIDR struct {
+	ErrCore
+	cidr string
+}
+
+// AddConsequence adds an error 'err' to the list of consequences
+func (e ErrInvalidCIDR) AddConsequence(err error) error {
+	e.ErrCore = e.ErrCore.Reset(e.ErrCore.AddConsequence(err))
+	return e
+}
+
+// CIDR returns the malformed CIDR
+func (e ErrInvalidCIDR) CIDR() string {
+	return e.cidr
+}
+
+// InvalidCIDRError creates a ErrInvalidCIDR error
+func InvalidCIDRError(cidr string, cause error) ErrInvalidCIDR {
+	return ErrInvalidCIDR{
+		ErrCore: ErrCore{
+			message:      fmt.Sprintf("invalid CIDR '%s'", cidr),
+			cause:        cause,
+			consequences: []error{},
+		},
+		cidr: cidr,
+	}
+}
+
+// ErrRoutableCIDR when a CIDR is publicly routable where a private one is expected
+type ErrRoutableCIDR struct {
+	ErrCore
+	cidr string
+}
+
+// AddConsequence adds an error 'err' to the list of consequences
+func (e ErrRoutableCIDR) AddConsequence(err error) error {
+	e.ErrCore = e.ErrCore.Reset(e.ErrCore.AddConsequence(err))
+	return e
+}
+
+// CIDR returns the routable CIDR
+func (e ErrRoutableCIDR) CIDR() string {
+	return e.cidr
+}
+
+// RoutableCIDRError creates a ErrRoutableCIDR error
+func RoutableCIDRError(cidr string) ErrRoutableCIDR {
+	return ErrRoutableCIDR{
+		ErrCore: ErrCore{
+			message:      fmt.Sprintf("CIDR '%s' is routable; please provide a not routable CIDR (RFC1918)", cidr),
+			cause:        nil,
+			consequences: []error{},
+		},
+		cidr: cidr,
+	}
+}
+
 // ErrUnauthorized when action is done without being authenticated first
 type ErrUnauthorized struct {
 	ErrCore
@@ -567,6 +660,11 @@ func (e ErrList) Error() string {
 	return spew.Sdump(e.errors)
 }
 
+// Errors returns the errors of the list
+func (e ErrList) Errors() []error {
+	return e.errors
+}
+
 // AddConsequence adds an error 'err' to the list of consequences
 func (e ErrList) AddConsequence(err error) error {
 	e.ErrCore = e.ErrCore.Reset(e.ErrCore.AddConsequence(err))
@@ -614,6 +712,8 @@ func InvalidInstanceError() ErrInvalidInstance {
 // ErrInvalidParameter ...
 type ErrInvalidParameter struct {
 	ErrCore
+	what string
+	why  string
 }
 
 // AddConsequence adds an error 'err' to the list of consequences
@@ -622,6 +722,16 @@ func (e ErrInvalidParameter) AddConsequence(err error) error {
 	return e
 }
 
+// Parameter returns the name of the invalid parameter
+func (e ErrInvalidParameter) Parameter() string {
+	return e.what
+}
+
+// Reason returns why the parameter is invalid
+func (e ErrInvalidParameter) Reason() string {
+	return e.why
+}
+
 // InvalidParameterError creates a ErrInvalidParameter error
 func InvalidParameterError(what, why string) ErrInvalidParameter {
 	return ErrInvalidParameter{
@@ -630,6 +740,8 @@ func InvalidParameterError(what, why string) ErrInvalidParameter {
 			cause:        nil,
 			consequences: []error{},
 		},
+		what: what,
+		why:  why,
 	}
 }
 
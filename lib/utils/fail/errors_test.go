@@ -147,3 +147,25 @@ func TestRecognizeErrCore(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestConsequencesFormattingIsCapped(t *testing.T) {
+	SetMaxFormattedConsequences(3)
+	defer SetMaxFormattedConsequences(0)
+
+	var err error = NotFoundError("network 'net' not found")
+	for i := 0; i < 10; i++ {
+		err = AddConsequence(err, fmt.Errorf("cleanup %d failed", i))
+	}
+
+	msg := err.Error()
+	require.Contains(t, msg, "cleanup 2 failed")
+	require.NotContains(t, msg, "cleanup 3 failed")
+	require.Contains(t, msg, "(7 more consequences omitted)")
+	require.Len(t, Consequences(err), 10)
+
+	// under the cap, nothing is omitted
+	SetMaxFormattedConsequences(0)
+	msg = err.Error()
+	require.Contains(t, msg, "cleanup 9 failed")
+	require.NotContains(t, msg, "omitted")
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fail
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// grpcDetailsDomain identifies the status details carrying SafeScale error causes
+const grpcDetailsDomain = "safescale"
+
+// grpcCode returns the gRPC code corresponding to the kind of err
+func grpcCode(err error) codes.Code {
+	switch err.(type) {
+	case ErrNotFound:
+		return codes.NotFound
+	case ErrTimeout:
+		return codes.DeadlineExceeded
+	case ErrAborted:
+		return codes.Aborted
+	case ErrDuplicate:
+		return codes.AlreadyExists
+	case ErrInvalidRequest, ErrInvalidParameter, ErrSyntax, ErrInvalidCIDR, ErrRoutableCIDR:
+		return codes.InvalidArgument
+	case ErrNotAvailable:
+		return codes.Unavailable
+	case ErrUnauthorized:
+		return codes.Unauthenticated
+	case ErrForbidden:
+		return codes.PermissionDenied
+	case ErrOverload, ErrOverflow:
+		return codes.ResourceExhausted
+	case ErrNotImplemented:
+		return codes.Unimplemented
+	case ErrInconsistent, ErrInvalidInstance, ErrInvalidInstanceContent, ErrRuntimePanic:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
+
+// errorFromGRPCCode creates an error of the kind corresponding to code, with message msg and cause 'cause'
+func errorFromGRPCCode(code codes.Code, msg string, cause error) error {
+	core := ErrCore{message: msg, cause: cause, consequences: []error{}}
+	switch code {
+	case codes.NotFound:
+		return ErrNotFound{ErrCore: core}
+	case codes.DeadlineExceeded:
+		return ErrTimeout{ErrCore: core}
+	case codes.Aborted:
+		return ErrAborted{ErrCore: core}
+	case codes.AlreadyExists:
+		return ErrDuplicate{ErrCore: core}
+	case codes.InvalidArgument:
+		return ErrInvalidRequest{ErrCore: core}
+	case codes.Unavailable:
+		return ErrNotAvailable{ErrCore: core}
+	case codes.Unauthenticated:
+		return ErrUnauthorized{ErrCore: core}
+	case codes.PermissionDenied:
+		return ErrForbidden{ErrCore: core}
+	case codes.ResourceExhausted:
+		return ErrOverload{ErrCore: core}
+	case codes.Unimplemented:
+		return ErrNotImplemented{ErrCore: core}
+	case codes.Internal:
+		return ErrInconsistent{ErrCore: core}
+	default:
+		return ErrUnknown{ErrCore: core}
+	}
+}
+
+// ToGRPCStatus converts err to a gRPC status; the chain of causes of err is stored in the details of the status,
+// along with the name of the parameter in violation for ErrInvalidParameter (as google.rpc.BadRequest)
+func ToGRPCStatus(err error) *grpcstatus.Status {
+	if err == nil {
+		return grpcstatus.New(codes.OK, "")
+	}
+	if st, ok := grpcstatus.FromError(err); ok {
+		return st
+	}
+
+	st := grpcstatus.New(grpcCode(err), grpcMessage(err))
+	if badRequest := grpcBadRequest(err); badRequest != nil {
+		if withDetails, xerr := st.WithDetails(badRequest); xerr == nil {
+			st = withDetails
+		}
+	}
+	for current := err; ; {
+		c, ok := current.(causer)
+		if !ok || c.Cause() == nil {
+			break
+		}
+		current = c.Cause()
+		withDetails, xerr := st.WithDetails(
+			&errdetails.ErrorInfo{
+				Reason:   grpcCode(current).String(),
+				Domain:   grpcDetailsDomain,
+				Metadata: map[string]string{"message": grpcMessage(current)},
+			},
+		)
+		if xerr != nil {
+			// details are a bonus, keep the status as is
+			break
+		}
+		st = withDetails
+	}
+	return st
+}
+
+// grpcBadRequest returns the field violation describing the invalid parameter of err, nil if err doesn't carry one
+func grpcBadRequest(err error) *errdetails.BadRequest {
+	if e, ok := err.(ErrInvalidParameter); ok && e.Parameter() != "" {
+		return &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: e.Parameter(), Description: e.Reason()},
+			},
+		}
+	}
+	return nil
+}
+
+// grpcMessage returns the message of err without its causes (already carried by the status details)
+func grpcMessage(err error) string {
+	if ImplementsCauser(err) {
+		return Message(err)
+	}
+	return err.Error()
+}
+
+// FromGRPCStatus converts an error received from gRPC to the corresponding error of this package,
+// restoring the chain of causes if the status carries them; an invalid argument carrying a field violation
+// becomes an ErrInvalidParameter on the parameter in violation
+func FromGRPCStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := grpcstatus.FromError(err)
+	if !ok {
+		return err
+	}
+	if st.Code() == codes.OK {
+		return nil
+	}
+
+	var (
+		causes     []*errdetails.ErrorInfo
+		violations []*errdetails.BadRequest_FieldViolation
+	)
+	for _, v := range st.Details() {
+		switch detail := v.(type) {
+		case *errdetails.ErrorInfo:
+			if detail.GetDomain() == grpcDetailsDomain {
+				causes = append(causes, detail)
+			}
+		case *errdetails.BadRequest:
+			violations = append(violations, detail.GetFieldViolations()...)
+		}
+	}
+
+	var cause error
+	for i := len(causes) - 1; i >= 0; i-- {
+		code := codes.Unknown
+		for c := codes.OK; c <= codes.Unauthenticated; c++ {
+			if c.String() == causes[i].GetReason() {
+				code = c
+				break
+			}
+		}
+		cause = errorFromGRPCCode(code, causes[i].GetMetadata()["message"], cause)
+	}
+	if st.Code() == codes.InvalidArgument && len(violations) > 0 {
+		return ErrInvalidParameter{
+			ErrCore: ErrCore{message: st.Message(), cause: cause, consequences: []error{}},
+			what:    violations[0].GetField(),
+			why:     violations[0].GetDescription(),
+		}
+	}
+	return errorFromGRPCCode(st.Code(), st.Message(), cause)
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fail
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestGRPCStatus_AbortedWithCause(t *testing.T) {
+	err := AbortedError("stop requested", TimeoutError("host not ready", 2*time.Minute, nil))
+
+	st := ToGRPCStatus(err)
+	assert.Equal(t, codes.Aborted, st.Code())
+	assert.Equal(t, "aborted: stop requested", st.Message())
+
+	back := FromGRPCStatus(st.Err())
+	aborted, ok := back.(ErrAborted)
+	require.True(t, ok)
+	assert.Equal(t, "aborted: stop requested", aborted.Message())
+
+	cause, ok := aborted.Cause().(ErrTimeout)
+	require.True(t, ok)
+	assert.Equal(t, "host not ready", cause.Message())
+	assert.Nil(t, cause.Cause())
+}
+
+func TestGRPCStatus_NestedCauses(t *testing.T) {
+	err := AbortedError("", NotFoundErrorWithCause("no host", fmt.Errorf("stow: not found")))
+
+	back := FromGRPCStatus(ToGRPCStatus(err).Err())
+	require.IsType(t, ErrAborted{}, back)
+	notFound, ok := back.(ErrAborted).Cause().(ErrNotFound)
+	require.True(t, ok)
+	unknown, ok := notFound.Cause().(ErrUnknown)
+	require.True(t, ok)
+	assert.Equal(t, "stow: not found", unknown.Message())
+}
+
+func TestFromGRPCStatus_WithoutDetails(t *testing.T) {
+	back := FromGRPCStatus(grpcstatus.Error(codes.Aborted, "aborted by user"))
+	aborted, ok := back.(ErrAborted)
+	require.True(t, ok)
+	assert.Equal(t, "aborted by user", aborted.Message())
+	assert.Nil(t, aborted.Cause())
+
+	assert.Nil(t, FromGRPCStatus(nil))
+	plain := fmt.Errorf("not a gRPC error")
+	assert.Equal(t, plain, FromGRPCStatus(plain))
+}
+
+func TestGRPCStatus_CodeSurvivesReset(t *testing.T) {
+	var err error = NotFoundErrorWithCause("no host", fmt.Errorf("stow: not found"))
+	err = AddConsequence(err, fmt.Errorf("cleanup failed"))
+
+	notFound, ok := err.(ErrNotFound)
+	require.True(t, ok)
+	assert.Equal(t, "no host", notFound.Message())
+	assert.Len(t, notFound.Consequences(), 1)
+	assert.Equal(t, codes.NotFound, ToGRPCStatus(err).Code())
+}
+
+func TestGRPCStatus_InvalidParameter(t *testing.T) {
+	err := InvalidParameterError("cidr", "must be a private range")
+	assert.Equal(t, "cidr", err.Parameter())
+	assert.Equal(t, "must be a private range", err.Reason())
+
+	st := ToGRPCStatus(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+
+	back := FromGRPCStatus(st.Err())
+	invalid, ok := back.(ErrInvalidParameter)
+	require.True(t, ok)
+	assert.Equal(t, "cidr", invalid.Parameter())
+	assert.Equal(t, "must be a private range", invalid.Reason())
+	assert.Equal(t, st.Message(), invalid.Message())
+}
+
+func TestGRPCStatus_InvalidRequestWithoutParameter(t *testing.T) {
+	back := FromGRPCStatus(ToGRPCStatus(InvalidRequestError("no gateway")).Err())
+	invalid, ok := back.(ErrInvalidRequest)
+	require.True(t, ok)
+	assert.Equal(t, "no gateway", invalid.Message())
+}
@@ -20,7 +20,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
 
 	"github.com/CS-SI/SafeScale/lib/server/iaas"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/objectstorage"
@@ -28,6 +34,37 @@ import (
 	"github.com/CS-SI/SafeScale/lib/utils/fail"
 )
 
+// defaultReadConcurrency is the default maximum number of reads of metadata in progress at the same time for a service
+const defaultReadConcurrency = 16
+
+var (
+	// readLimiters contains the semaphores bounding the reads in progress, by iaas.Service
+	readLimiters sync.Map
+	// readConcurrency returns the maximum number of reads of metadata in progress at the same time for a service
+	readConcurrency = getReadConcurrencyFromEnv
+)
+
+// getReadConcurrencyFromEnv returns the value of SAFESCALE_METADATA_READ_CONCURRENCY if valid, defaultReadConcurrency otherwise
+func getReadConcurrencyFromEnv() int {
+	if candidate := os.Getenv("SAFESCALE_METADATA_READ_CONCURRENCY"); candidate != "" {
+		n, err := strconv.Atoi(candidate)
+		if err == nil && n > 0 {
+			return n
+		}
+		logrus.Warnf("Error parsing variable: [%s]", "SAFESCALE_METADATA_READ_CONCURRENCY")
+	}
+	return defaultReadConcurrency
+}
+
+// readLimiter returns the semaphore bounding the reads of metadata in progress for 'svc', shared by all its folders
+func readLimiter(svc iaas.Service) chan struct{} {
+	if limiter, ok := readLimiters.Load(svc); ok {
+		return limiter.(chan struct{})
+	}
+	limiter, _ := readLimiters.LoadOrStore(svc, make(chan struct{}, readConcurrency()))
+	return limiter.(chan struct{})
+}
+
 // Folder describes a metadata folder
 type Folder struct {
 	// path contains the base path where to read/write record in Object Storage
@@ -106,6 +143,40 @@ func (f *Folder) Search(path string, name string) error {
 	return fail.NotFoundError(fmt.Sprintf("failed to find '%s'", fullPath))
 }
 
+// Exists tells if the object named 'name' is inside the ObjectStorage folder, listing only the objects starting
+// with 'name' and without reading any content
+func (f *Folder) Exists(path string, name string) (bool, error) {
+	absPath := strings.Trim(f.absolutePath(path), "/")
+	list, err := f.listPrefix(absPath, name)
+	if err != nil {
+		return false, err
+	}
+	fullPath := name
+	if absPath != "" {
+		fullPath = absPath + "/" + name
+	}
+	for _, item := range list {
+		if item == fullPath {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listPrefix lists the objects of the folder 'absPath' whose name starts with 'prefix'
+// The Object Storage concatenates path and prefix as-is, so the separator between the folder and the prefix
+// is added here, the prefix still being passed to the Object Storage as part of the path
+func (f *Folder) listPrefix(absPath string, prefix string) ([]string, error) {
+	if prefix != "" {
+		absPath = strings.TrimRight(absPath, "/")
+		if absPath != "" {
+			absPath += "/"
+		}
+		absPath += prefix
+	}
+	return f.service.GetMetadataBucket().List(absPath, objectstorage.NoPrefix)
+}
+
 // Delete removes metadata passed as parameter
 func (f *Folder) Delete(path string, name string) error {
 	err := f.service.GetMetadataBucket().DeleteObject(f.absolutePath(path, name))
@@ -131,7 +202,7 @@ func (f *Folder) Read(path string, name string, callback FolderDecoderCallback)
 	}
 
 	var buffer bytes.Buffer
-	_, err = f.service.GetMetadataBucket().ReadObject(f.absolutePath(path, name), &buffer, 0, 0)
+	err = f.readObject(f.absolutePath(path, name), &buffer)
 	if err != nil {
 		if _, ok := err.(fail.ErrNotFound); ok {
 			return fail.NotFoundError(fmt.Sprintf("failed to read '%s/%s' in Metadata Storage: %v", path, name, err))
@@ -158,6 +229,17 @@ func (f *Folder) Read(path string, name string, callback FolderDecoderCallback)
 	return nil
 }
 
+// readObject reads the object 'name' from Object Storage into 'buffer'; the number of reads in progress at the same
+// time is bounded for the service, the slot being released before the content is used, so callbacks can read too
+func (f *Folder) readObject(name string, buffer *bytes.Buffer) error {
+	limiter := readLimiter(f.service)
+	limiter <- struct{}{}
+	defer func() { <-limiter }()
+
+	_, err := f.service.GetMetadataBucket().ReadObject(name, buffer, 0, 0)
+	return err
+}
+
 // Write writes the content in Object Storage
 func (f *Folder) Write(path string, name string, content []byte) error {
 	var (
@@ -181,10 +263,18 @@ func (f *Folder) Write(path string, name string, content []byte) error {
 
 // Browse browses the content of a specific path in Metadata and executes 'cb' on each entry
 func (f *Folder) Browse(path string, callback FolderDecoderCallback) error {
-	list, err := f.service.GetMetadataBucket().List(f.absolutePath(path), objectstorage.NoPrefix)
+	return f.BrowsePrefix(path, objectstorage.NoPrefix, callback)
+}
+
+// BrowsePrefix browses, in lexical order, the entries of a specific path in Metadata whose name starts
+// with 'prefix' and executes 'cb' on each of them
+// The prefix is passed to the Object Storage, so entries not matching it are not read
+func (f *Folder) BrowsePrefix(path string, prefix string, callback FolderDecoderCallback) error {
+	list, err := f.listPrefix(f.absolutePath(path), prefix)
 	if err != nil {
 		return fail.Wrap(err, "Error browsing metadata: listing objects")
 	}
+	sort.Strings(list)
 
 	// Special case where there is only an empty folder...
 	if len(list) == 1 && list[0] == f.absolutePath(path) {
@@ -192,45 +282,93 @@ func (f *Folder) Browse(path string, callback FolderDecoderCallback) error {
 	}
 
 	for _, i := range list {
-		var buffer bytes.Buffer
-		_, err = f.service.GetMetadataBucket().ReadObject(i, &buffer, 0, 0)
+		err = f.browseEntry(i, callback)
 		if err != nil {
-			return fail.Wrap(err, "Error browsing metadata: reading from buffer")
+			return err
 		}
-		data := buffer.Bytes()
-		if f.crypt {
-			dal := len(data)
+	}
+	return nil
+}
 
-			data, err = crypt.Decrypt(data, f.cryptKey)
-			if err != nil {
-				if dal > 0 {
-					return fail.ForbiddenError(fmt.Sprintf("problem decrypting data with the key provided in (tenants.metadata.CryptKey): %s", err))
-				}
-				return err
-			}
+// BrowsePage browses, in lexical order, at most 'limit' entries of a specific path in Metadata whose name comes after
+// 'after' (from the first entry if empty), and executes 'callback' on each of them
+// Returns the name to pass as 'after' to browse the next page, or an empty string if there are no more entries;
+// as entries are compared by name, the page is stable even if the entry named 'after' has been removed meanwhile
+func (f *Folder) BrowsePage(path string, after string, limit int, callback FolderDecoderCallback) (string, error) {
+	if limit <= 0 {
+		return "", fail.InvalidParameterError("limit", "must be greater than 0")
+	}
+
+	folderPath := f.absolutePath(path)
+	list, err := f.service.GetMetadataBucket().List(folderPath, objectstorage.NoPrefix)
+	if err != nil {
+		return "", fail.Wrap(err, "Error browsing metadata: listing objects")
+	}
+	sort.Strings(list)
+
+	var from string
+	if after != "" {
+		from = f.absolutePath(path, after)
+	}
+	read := 0
+	for _, i := range list {
+		if i == folderPath || (from != "" && i <= from) {
+			continue
+		}
+		if read == limit {
+			return strings.TrimPrefix(from, strings.TrimRight(folderPath, "/")+"/"), nil
+		}
+		err = f.browseEntry(i, callback)
+		if err != nil {
+			return "", err
 		}
+		from = i
+		read++
+	}
+	return "", nil
+}
 
-		err = callback(data)
+// browseEntry reads the entry 'name', decrypts it if needed, and executes 'callback' on its content
+func (f *Folder) browseEntry(name string, callback FolderDecoderCallback) error {
+	var buffer bytes.Buffer
+	err := f.readObject(name, &buffer)
+	if err != nil {
+		return fail.Wrap(err, "Error browsing metadata: reading from buffer")
+	}
+	data := buffer.Bytes()
+	if f.crypt {
+		dal := len(data)
+
+		data, err = crypt.Decrypt(data, f.cryptKey)
 		if err != nil {
-			if _, ok := err.(*json.SyntaxError); ok && strings.Contains(err.Error(), "invalid character") {
-				if f.crypt {
-					err = fail.SyntaxError(
-						fmt.Sprintf(
-							"seems metadata '%s' is crypted but there was a problem decrypting with the key provided in (tenants.metadata.CryptKey)", i,
-						),
-					)
-					return err
-				} else {
-					err = fail.SyntaxError(
-						fmt.Sprintf(
-							"seems metadata '%s' is unencrypted but a encryption key is provided in (tenants.metadata.CryptKey), this leads to a decryption error; please remove decryption key", i,
-						),
-					)
-					return err
-				}
+			if dal > 0 {
+				return fail.ForbiddenError(fmt.Sprintf("problem decrypting data with the key provided in (tenants.metadata.CryptKey): %s", err))
+			}
+			return err
+		}
+	}
+
+	err = callback(data)
+	if err != nil {
+		if _, ok := err.(*json.SyntaxError); ok && strings.Contains(err.Error(), "invalid character") {
+			if f.crypt {
+				err = fail.SyntaxError(
+					fmt.Sprintf(
+						"seems metadata '%s' is crypted but there was a problem decrypting with the key provided in (tenants.metadata.CryptKey)", name,
+					),
+				)
+				return err
+			} else {
+				err = fail.SyntaxError(
+					fmt.Sprintf(
+						"seems metadata '%s' is unencrypted but a encryption key is provided in (tenants.metadata.CryptKey), this leads to a decryption error; please remove decryption key", name,
+					),
+				)
+				return err
 			}
-			return fail.Wrap(err, "Error browsing metadata: running callback")
 		}
+		return fail.Wrap(err, "Error browsing metadata: running callback")
 	}
+
 	return nil
 }
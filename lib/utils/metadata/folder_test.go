@@ -0,0 +1,220 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/objectstorage"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/objectstorage/objectstoragetest"
+	"github.com/CS-SI/SafeScale/lib/utils/crypt"
+)
+
+// fakeService is an iaas.Service providing only what a metadata Folder needs
+type fakeService struct {
+	iaas.Service
+	bucket objectstorage.Bucket
+}
+
+func (s *fakeService) GetMetadataBucket() objectstorage.Bucket {
+	return s.bucket
+}
+
+func (s *fakeService) GetMetadataKey() *crypt.Key {
+	return nil
+}
+
+func newFakeFolder(t *testing.T) (*Folder, *objectstoragetest.Bucket) {
+	bucket := objectstoragetest.NewBucket()
+	for _, name := range []string{"net-b2", "net-a1", "gw-c3", "net-a2"} {
+		bucket.Objects["networks/byID/"+name] = []byte(name)
+	}
+	f, err := NewFolder(&fakeService{bucket: bucket}, "networks")
+	require.Nil(t, err)
+	return f, bucket
+}
+
+func TestFolder_BrowsePrefix(t *testing.T) {
+	f, bucket := newFakeFolder(t)
+	// an object of a sibling folder whose name starts like the folder browsed
+	bucket.Objects["networks/byIDs/net-a3"] = []byte("net-a3")
+
+	var found []string
+	err := f.BrowsePrefix(
+		"byID", "net-a", func(buf []byte) error {
+			found = append(found, string(buf))
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"net-a1", "net-a2"}, found)
+	assert.Equal(t, []string{"networks/byID/net-a1", "networks/byID/net-a2"}, bucket.Reads)
+}
+
+func TestFolder_Exists(t *testing.T) {
+	f, bucket := newFakeFolder(t)
+
+	found, err := f.Exists("byID", "net-a1")
+	require.Nil(t, err)
+	assert.True(t, found)
+
+	// a prefix of an existing name is not a match
+	found, err = f.Exists("byID", "net-a")
+	require.Nil(t, err)
+	assert.False(t, found)
+	assert.Empty(t, bucket.Reads)
+}
+
+func TestFolder_Browse(t *testing.T) {
+	f, bucket := newFakeFolder(t)
+
+	var found []string
+	err := f.Browse(
+		"byID", func(buf []byte) error {
+			found = append(found, string(buf))
+			return nil
+		},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"gw-c3", "net-a1", "net-a2", "net-b2"}, found)
+	assert.Len(t, bucket.Reads, 4)
+}
+
+func TestFolder_BrowsePage(t *testing.T) {
+	f, bucket := newFakeFolder(t)
+
+	var found []string
+	collect := func(buf []byte) error {
+		found = append(found, string(buf))
+		return nil
+	}
+	next, err := f.BrowsePage("byID", "", 3, collect)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"gw-c3", "net-a1", "net-a2"}, found)
+	assert.Equal(t, "net-a2", next)
+	assert.Len(t, bucket.Reads, 3)
+
+	// the entry the cursor refers to may have been removed meanwhile
+	delete(bucket.Objects, "networks/byID/net-a2")
+	found = nil
+	next, err = f.BrowsePage("byID", next, 3, collect)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"net-b2"}, found)
+	assert.Empty(t, next)
+
+	_, err = f.BrowsePage("byID", "", 0, collect)
+	assert.NotNil(t, err)
+}
+
+// concurrentBucket is an in-memory bucket recording the maximum number of reads in progress at the same time
+type concurrentBucket struct {
+	*objectstoragetest.Bucket
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (b *concurrentBucket) ReadObject(name string, target io.Writer, from int64, to int64) (objectstorage.Object, error) {
+	b.mu.Lock()
+	b.inFlight++
+	if b.inFlight > b.max {
+		b.max = b.inFlight
+	}
+	content := b.Objects[name]
+	b.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	b.mu.Lock()
+	b.inFlight--
+	b.mu.Unlock()
+	_, err := target.Write(content)
+	return nil, err
+}
+
+func TestFolder_ReadConcurrencyIsBounded(t *testing.T) {
+	defer func(previous func() int) { readConcurrency = previous }(readConcurrency)
+	readConcurrency = func() int { return 3 }
+
+	bucket := &concurrentBucket{Bucket: objectstoragetest.NewBucket()}
+	for i := 0; i < 10; i++ {
+		bucket.Objects[fmt.Sprintf("networks/byID/network-%d", i)] = []byte("network")
+		bucket.Objects[fmt.Sprintf("hosts/byID/host-%d", i)] = []byte("host")
+	}
+	svc := &fakeService{bucket: bucket}
+
+	// The bound is shared by all the folders of the service
+	networks, err := NewFolder(svc, "networks")
+	require.Nil(t, err)
+	hosts, err := NewFolder(svc, "hosts")
+	require.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, f := range []*Folder{networks, hosts} {
+			wg.Add(1)
+			go func(f *Folder, i int) {
+				defer wg.Done()
+				err := f.Read(
+					"byID", fmt.Sprintf("%s-%d", strings.TrimSuffix(f.GetPath(), "s"), i), func([]byte) error {
+						return nil
+					},
+				)
+				assert.Nil(t, err)
+			}(f, i)
+		}
+	}
+	wg.Wait()
+	assert.True(t, bucket.max <= 3, "%d reads in progress at the same time", bucket.max)
+	assert.True(t, bucket.max > 1)
+}
+
+func TestFolder_BrowseCallbackCanRead(t *testing.T) {
+	defer func(previous func() int) { readConcurrency = previous }(readConcurrency)
+	readConcurrency = func() int { return 1 }
+
+	f, _ := newFakeFolder(t)
+	var found []string
+	err := f.Browse(
+		"byID", func(buf []byte) error {
+			// would dead-lock if the slot of the browse read were still held
+			return f.Read(
+				"byID", string(buf), func(inner []byte) error {
+					found = append(found, string(inner))
+					return nil
+				},
+			)
+		},
+	)
+	require.Nil(t, err)
+	assert.Len(t, found, 4)
+}
+
+func TestReadLimiter_PerService(t *testing.T) {
+	svc1, svc2 := &fakeService{}, &fakeService{}
+	assert.True(t, readLimiter(svc1) == readLimiter(svc1))
+	assert.False(t, readLimiter(svc1) == readLimiter(svc2))
+}
@@ -27,10 +27,11 @@ import (
 
 // Item is an entry in the ObjectStorage
 type Item struct {
-	payload serialize.Serializable
-	folder  *Folder
-	written bool
-	lock    *sync.Mutex
+	payload  serialize.Serializable
+	folder   *Folder
+	written  bool
+	migrated bool
+	lock     *sync.Mutex
 }
 
 // ItemDecoderCallback ...
@@ -76,6 +77,12 @@ func (i *Item) Written() bool {
 	return i.written
 }
 
+// Migrated tells if the content read from Object Storage has been upgraded to the current schema version
+// and not written back yet
+func (i *Item) Migrated() bool {
+	return i.migrated
+}
+
 // Carry links metadata with cluster struct
 func (i *Item) Carry(data serialize.Serializable) *Item {
 	i.payload = data
@@ -86,6 +93,7 @@ func (i *Item) Carry(data serialize.Serializable) *Item {
 func (i *Item) Reset() *Item {
 	i.payload = nil
 	i.written = false
+	i.migrated = false
 	return i
 }
 
@@ -120,6 +128,17 @@ func (i *Item) DeleteFrom(path string, name string) error {
 	return nil
 }
 
+// Exists tells if the metadata 'name' exists in the subfolder 'path', without reading it
+func (i *Item) Exists(path string, name string) (bool, error) {
+	if name == "" {
+		return false, fail.InvalidParameterError("name", "cannot be empty string")
+	}
+	if path == "" {
+		path = "."
+	}
+	return i.folder.Exists(path, name)
+}
+
 // Delete removes a metadata
 func (i *Item) Delete(name string) error {
 	return i.DeleteFrom(".", name)
@@ -127,10 +146,17 @@ func (i *Item) Delete(name string) error {
 
 // ReadFrom reads metadata of item from Object Storage in a subfolder
 func (i *Item) ReadFrom(path string, name string, callback ItemDecoderCallback) error {
-	var data serialize.Serializable
+	var (
+		data     serialize.Serializable
+		migrated bool
+	)
 	err := i.folder.Read(
 		path, name, func(buf []byte) error {
 			var err error
+			buf, migrated, err = SchemaRegistry.migrate(i.folder.GetPath(), buf)
+			if err != nil {
+				return err
+			}
 			data, err = callback(buf)
 			if err != nil {
 				return fail.Errorf("Item.ReadFrom()", err)
@@ -143,6 +169,7 @@ func (i *Item) ReadFrom(path string, name string, callback ItemDecoderCallback)
 	}
 	i.payload = data
 	i.written = true
+	i.migrated = migrated
 	return nil
 }
 
@@ -163,11 +190,16 @@ func (i *Item) WriteInto(path string, name string) error {
 	if err != nil {
 		return err
 	}
+	data, err = SchemaRegistry.stamp(i.folder.GetPath(), data)
+	if err != nil {
+		return err
+	}
 	err = i.folder.Write(path, name, data)
 	if err != nil {
 		return err
 	}
 	i.written = true
+	i.migrated = false
 	return nil
 }
 
@@ -185,7 +217,7 @@ func (i *Item) BrowseInto(path string, callback func([]byte) error) error {
 	if path == "" {
 		path = "."
 	}
-	return i.folder.Browse(path, callback)
+	return i.folder.Browse(path, i.upgradeBeforeCallback(callback))
 }
 
 // Browse walks through folder of item and executes a callback for each entry
@@ -193,6 +225,44 @@ func (i *Item) Browse(callback func([]byte) error) error {
 	return i.BrowseInto(".", callback)
 }
 
+// BrowseIntoPrefix walks through a subfolder of item folder and executes a callback for each entry
+// whose name starts with 'prefix'
+func (i *Item) BrowseIntoPrefix(path string, prefix string, callback func([]byte) error) error {
+	if callback == nil {
+		return fail.InvalidParameterError("callback", "cannot be nil!")
+	}
+
+	if path == "" {
+		path = "."
+	}
+	return i.folder.BrowsePrefix(path, prefix, i.upgradeBeforeCallback(callback))
+}
+
+// BrowseIntoPage walks through at most 'limit' entries of a subfolder of item folder coming after the entry 'after',
+// and executes a callback for each of them; returns the name of the entry to start the next page after
+// (empty string if there are no more entries)
+func (i *Item) BrowseIntoPage(path string, after string, limit int, callback func([]byte) error) (string, error) {
+	if callback == nil {
+		return "", fail.InvalidParameterError("callback", "cannot be nil!")
+	}
+
+	if path == "" {
+		path = "."
+	}
+	return i.folder.BrowsePage(path, after, limit, i.upgradeBeforeCallback(callback))
+}
+
+// upgradeBeforeCallback returns a callback upgrading content to the current schema version before calling 'callback'
+func (i *Item) upgradeBeforeCallback(callback func([]byte) error) func([]byte) error {
+	return func(buf []byte) error {
+		buf, _, err := SchemaRegistry.migrate(i.folder.GetPath(), buf)
+		if err != nil {
+			return err
+		}
+		return callback(buf)
+	}
+}
+
 // Acquire waits until the lock is available, then locks the metadata
 func (i *Item) Acquire() {
 	i.lock.Lock()
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/lib/utils/fail"
+)
+
+const (
+	// SchemaVersionField is the name of the JSON field stamping the schema version of the metadata content
+	SchemaVersionField = "schema_version"
+	// defaultSchemaVersion is the schema version of metadata content written without stamp
+	defaultSchemaVersion = 1
+)
+
+// Migrator upgrades the decoded JSON content of a metadata from the previous schema version to the one
+// it has been registered for
+type Migrator func(map[string]interface{}) error
+
+// schemaRegistry contains, for each kind of metadata (ie the path of the item folder), the migrators
+// indexed by the schema version they upgrade to
+type schemaRegistry map[string]map[int]Migrator
+
+// Register registers a migrator upgrading content of 'kind' to schema version 'version' (from 'version'-1)
+// The current schema version of a kind is the highest version registered
+func (r schemaRegistry) Register(kind string, version int, migrator Migrator) {
+	if version <= defaultSchemaVersion {
+		panic(fmt.Sprintf("invalid schema version %d for metadata '%s': must be greater than %d", version, kind, defaultSchemaVersion))
+	}
+	if migrator == nil {
+		panic(fmt.Sprintf("invalid migrator for metadata '%s': cannot be nil", kind))
+	}
+	if _, found := r[kind]; !found {
+		r[kind] = map[int]Migrator{}
+	}
+	r[kind][version] = migrator
+}
+
+// CurrentVersion returns the current schema version of 'kind'
+func (r schemaRegistry) CurrentVersion(kind string) int {
+	current := defaultSchemaVersion
+	for v := range r[kind] {
+		if v > current {
+			current = v
+		}
+	}
+	return current
+}
+
+// stamp adds the current schema version of 'kind' to the JSON content
+func (r schemaRegistry) stamp(kind string, content []byte) ([]byte, error) {
+	current := r.CurrentVersion(kind)
+	if current == defaultSchemaVersion {
+		return content, nil
+	}
+
+	var decoded map[string]interface{}
+	err := json.Unmarshal(content, &decoded)
+	if err != nil {
+		return nil, fail.Wrap(err, "failed to stamp schema version of metadata")
+	}
+	decoded[SchemaVersionField] = current
+	return json.Marshal(decoded)
+}
+
+// migrate upgrades the JSON content of 'kind' to its current schema version if needed
+// returns the content to decode and a bool telling if a migration occurred
+func (r schemaRegistry) migrate(kind string, content []byte) ([]byte, bool, error) {
+	current := r.CurrentVersion(kind)
+	if current == defaultSchemaVersion {
+		return content, false, nil
+	}
+
+	var decoded map[string]interface{}
+	err := json.Unmarshal(content, &decoded)
+	if err != nil {
+		// Not a JSON object; let the decoder of the item deal with it
+		return content, false, nil
+	}
+
+	version := defaultSchemaVersion
+	if anon, ok := decoded[SchemaVersionField]; ok {
+		if casted, ok := anon.(float64); ok {
+			version = int(casted)
+		}
+	}
+	if version > current {
+		return nil, false, fail.SyntaxError(
+			fmt.Sprintf(
+				"metadata '%s' uses schema version %d, more recent than the one supported (%d)", kind, version,
+				current,
+			),
+		)
+	}
+	if version == current {
+		return content, false, nil
+	}
+
+	for v := version + 1; v <= current; v++ {
+		migrator, ok := r[kind][v]
+		if !ok {
+			return nil, false, fail.InconsistentError(
+				fmt.Sprintf("no migrator registered to upgrade metadata '%s' to schema version %d", kind, v),
+			)
+		}
+		err = migrator(decoded)
+		if err != nil {
+			return nil, false, fail.Wrap(
+				err, fmt.Sprintf("failed to upgrade metadata '%s' to schema version %d", kind, v),
+			)
+		}
+	}
+	decoded[SchemaVersionField] = current
+	migrated, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, false, err
+	}
+	return migrated, true, nil
+}
+
+// SchemaRegistry allows to register migrations of metadata content between schema versions
+var SchemaRegistry = struct{ schemaRegistry }{schemaRegistry: schemaRegistry{}}
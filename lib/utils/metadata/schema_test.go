@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/objectstorage/objectstoragetest"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+// ruleV2 is the version 2 of a metadata content where field 'port' (v1) became 'ports'
+type ruleV2 struct {
+	Name  string `json:"name"`
+	Ports []int  `json:"ports"`
+}
+
+func (r *ruleV2) Serialize() ([]byte, error) {
+	return serialize.ToJSON(r)
+}
+
+func (r *ruleV2) Deserialize(buf []byte) error {
+	return serialize.FromJSON(buf, r)
+}
+
+func TestItem_SchemaMigration(t *testing.T) {
+	SchemaRegistry.Register(
+		"rules", 2, func(content map[string]interface{}) error {
+			if port, ok := content["port"]; ok {
+				content["ports"] = []interface{}{port}
+				delete(content, "port")
+			}
+			return nil
+		},
+	)
+	defer delete(SchemaRegistry.schemaRegistry, "rules")
+
+	bucket := objectstoragetest.NewBucket()
+	bucket.Objects["rules/http"] = []byte(`{"name":"http","port":80,"schema_version":1}`)
+	item, err := NewItem(&fakeService{bucket: bucket}, "rules")
+	require.Nil(t, err)
+
+	err = item.Read(
+		"http", func(buf []byte) (serialize.Serializable, error) {
+			r := &ruleV2{}
+			return r, r.Deserialize(buf)
+		},
+	)
+	require.Nil(t, err)
+	assert.True(t, item.Migrated())
+	rule := item.Get().(*ruleV2)
+	assert.Equal(t, "http", rule.Name)
+	assert.Equal(t, []int{80}, rule.Ports)
+
+	// Writing back stamps the current schema version
+	err = item.Write("http")
+	require.Nil(t, err)
+	assert.False(t, item.Migrated())
+	var written map[string]interface{}
+	err = json.Unmarshal(bucket.Objects["rules/http"], &written)
+	require.Nil(t, err)
+	assert.Equal(t, float64(2), written[SchemaVersionField])
+	assert.NotContains(t, written, "port")
+}
+
+func TestSchemaRegistry_Migrate(t *testing.T) {
+	SchemaRegistry.Register(
+		"things", 2, func(content map[string]interface{}) error {
+			return nil
+		},
+	)
+	defer delete(SchemaRegistry.schemaRegistry, "things")
+
+	// Unstamped content is considered as version 1
+	out, migrated, err := SchemaRegistry.migrate("things", []byte(`{"a":1}`))
+	require.Nil(t, err)
+	assert.True(t, migrated)
+	assert.Contains(t, string(out), `"schema_version":2`)
+
+	// Up to date content is left untouched
+	in := []byte(`{"a":1,"schema_version":2}`)
+	out, migrated, err = SchemaRegistry.migrate("things", in)
+	require.Nil(t, err)
+	assert.False(t, migrated)
+	assert.Equal(t, in, out)
+
+	// Content more recent than supported is refused
+	_, _, err = SchemaRegistry.migrate("things", []byte(`{"a":1,"schema_version":3}`))
+	assert.NotNil(t, err)
+
+	// Kinds without migration are left untouched
+	in = []byte(`{"a":1}`)
+	out, migrated, err = SchemaRegistry.migrate("others", in)
+	require.Nil(t, err)
+	assert.False(t, migrated)
+	assert.Equal(t, in, out)
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package retry
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+// Policy describes how the tries of an action are spaced in time and for how long they go on.
+// The zero value retries every second, without limit other than the timeout given by the call site.
+type Policy struct {
+	// BaseDelay is the delay after the first unsuccessful try (1 second if not set)
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between 2 tries; no cap if not set
+	MaxDelay time.Duration
+	// Factor multiplies the delay after each unsuccessful try; values lower than 1 keep the delay constant
+	Factor float64
+	// Timeout caps the total duration of the tries: the timeout of the call site is used if shorter,
+	// and Timeout is used if the call site gives none; if not set, the timeout of the call site is used as is
+	Timeout time.Duration
+	// Jitter is the fraction of the delay randomly added or removed, between 0 and 1
+	Jitter float64
+}
+
+var (
+	defaultPolicy     Policy
+	defaultPolicyOnce sync.Once
+)
+
+// DefaultPolicy returns the policy configured by the environment, read once: SAFESCALE_RETRY_BASE_DELAY,
+// SAFESCALE_RETRY_MAX_DELAY and SAFESCALE_RETRY_TIMEOUT are durations, SAFESCALE_RETRY_BACKOFF_FACTOR and
+// SAFESCALE_RETRY_JITTER are floats. Without configuration, tries are done every temporal.GetMinDelay().
+// SAFESCALE_RETRY_TIMEOUT never extends the timeout of a call site, it only shortens it (see Policy.Timeout)
+func DefaultPolicy() Policy {
+	defaultPolicyOnce.Do(func() {
+		defaultPolicy = Policy{
+			BaseDelay: temporal.GetTimeoutFromEnv("SAFESCALE_RETRY_BASE_DELAY", temporal.GetMinDelay()),
+			MaxDelay:  temporal.GetTimeoutFromEnv("SAFESCALE_RETRY_MAX_DELAY", 0),
+			Factor:    getFloatFromEnv("SAFESCALE_RETRY_BACKOFF_FACTOR", 1),
+			Timeout:   temporal.GetTimeoutFromEnv("SAFESCALE_RETRY_TIMEOUT", 0),
+			Jitter:    getFloatFromEnv("SAFESCALE_RETRY_JITTER", 0),
+		}
+	})
+	return defaultPolicy
+}
+
+// getFloatFromEnv returns the value of the environment variable 'key' as a float64, or 'value' if not set or invalid
+func getFloatFromEnv(key string, value float64) float64 {
+	if candidate := os.Getenv(key); candidate != "" {
+		f, err := strconv.ParseFloat(candidate, 64)
+		if err != nil {
+			logrus.Warnf("Error parsing variable: [%s]", key)
+			return value
+		}
+		return f
+	}
+	return value
+}
+
+// Delay returns the delay to wait after the try number 'count' (starting from 1)
+func (p Policy) Delay(count uint) time.Duration {
+	delay := float64(p.BaseDelay)
+	if delay <= 0 {
+		delay = float64(time.Second)
+	}
+	if p.Factor > 1 && count > 1 {
+		delay *= math.Pow(p.Factor, float64(count-1))
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// Officer returns an Officer sleeping the delays of the policy
+func (p Policy) Officer() *Officer {
+	return &Officer{
+		Block: func(t Try) {
+			time.Sleep(p.Delay(t.Count))
+		},
+	}
+}
+
+// GetTimeout returns the timeout 'timeout' of the call site capped by the timeout of the policy;
+// the timeout of the policy is returned if 'timeout' is not set
+func (p Policy) GetTimeout(timeout time.Duration) time.Duration {
+	if p.Timeout > 0 && (timeout <= 0 || p.Timeout < timeout) {
+		return p.Timeout
+	}
+	return timeout
+}
+
+// WhileUnsuccessful retries 'run' following the policy while it is unsuccessful;
+// 'timeout' is capped by the timeout of the policy, if any (see GetTimeout)
+func (p Policy) WhileUnsuccessful(run func() error, timeout time.Duration) error {
+	var arbiter Arbiter
+	if timeout = p.GetTimeout(timeout); timeout <= 0 {
+		arbiter = Unsuccessful()
+	} else {
+		arbiter = PrevailDone(Unsuccessful(), Timeout(timeout))
+	}
+	return action{
+		Arbiter: arbiter,
+		Officer: p.Officer(),
+		Run:     run,
+		First:   nil,
+		Last:    nil,
+		Notify:  nil,
+	}.loop()
+}
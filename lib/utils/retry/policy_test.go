@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package retry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Delay(t *testing.T) {
+	p := Policy{BaseDelay: 100 * time.Millisecond, Factor: 2, MaxDelay: 500 * time.Millisecond}
+	assert.Equal(t, 100*time.Millisecond, p.Delay(1))
+	assert.Equal(t, 200*time.Millisecond, p.Delay(2))
+	assert.Equal(t, 400*time.Millisecond, p.Delay(3))
+	assert.Equal(t, 500*time.Millisecond, p.Delay(4))
+
+	// zero value is a constant delay of 1 second
+	assert.Equal(t, time.Second, Policy{}.Delay(1))
+	assert.Equal(t, time.Second, Policy{}.Delay(10))
+
+	jittered := Policy{BaseDelay: 100 * time.Millisecond, Jitter: 0.5}
+	for i := uint(1); i < 20; i++ {
+		d := jittered.Delay(i)
+		assert.True(t, d >= 50*time.Millisecond && d <= 150*time.Millisecond, "delay %s out of bounds", d)
+	}
+}
+
+func TestPolicy_GetTimeout(t *testing.T) {
+	assert.Equal(t, time.Minute, Policy{}.GetTimeout(time.Minute))
+	assert.Equal(t, time.Second, Policy{Timeout: time.Second}.GetTimeout(time.Minute))
+	// the timeout of the policy never extends the one of the call site
+	assert.Equal(t, time.Second, Policy{Timeout: time.Minute}.GetTimeout(time.Second))
+	// without timeout from the call site, the one of the policy is used
+	assert.Equal(t, time.Minute, Policy{Timeout: time.Minute}.GetTimeout(0))
+	assert.Equal(t, time.Duration(0), Policy{}.GetTimeout(0))
+}
+
+func TestPolicy_WhileUnsuccessful(t *testing.T) {
+	p := Policy{BaseDelay: 20 * time.Millisecond, Factor: 2}
+	tries := 0
+	begin := time.Now()
+	err := p.WhileUnsuccessful(
+		func() error {
+			tries++
+			if tries < 3 {
+				return fmt.Errorf("not yet")
+			}
+			return nil
+		}, time.Second,
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, tries)
+	assert.True(t, time.Since(begin) >= 60*time.Millisecond)
+
+	// a shorter timeout of the policy prevails on the one of the call site
+	p.Timeout = 50 * time.Millisecond
+	err = p.WhileUnsuccessful(func() error { return fmt.Errorf("never") }, time.Hour)
+	assert.IsType(t, ErrTimeout{}, err)
+}
@@ -137,12 +137,6 @@ func (x *JSONProperties) LockForRead(key string) *SyncedJSONProperty {
 	if x == nil {
 		panic("Calling utils.serialize.JSONProperties::LockForRead() from nil pointer!")
 	}
-	if x.Properties == nil {
-		panic("x.Properties is nil!")
-	}
-	if x.module == "" {
-		panic("x.module is empty!")
-	}
 	if key == "" {
 		panic("key is empty!")
 	}
@@ -152,6 +146,14 @@ func (x *JSONProperties) LockForRead(key string) *SyncedJSONProperty {
 	x.Lock()
 	defer x.Unlock()
 
+	// checked under lock, SetModule may run concurrently
+	if x.Properties == nil {
+		panic("x.Properties is nil!")
+	}
+	if x.module == "" {
+		panic("x.module is empty!")
+	}
+
 	var (
 		item  *jsonProperty
 		found bool
@@ -177,12 +179,6 @@ func (x *JSONProperties) LockForWrite(key string) *SyncedJSONProperty {
 	if x == nil {
 		panic("Calling x.LockForWrite() with x==nil!")
 	}
-	if x.Properties == nil {
-		panic("x.jsonProperties is nil!")
-	}
-	if x.module == "" {
-		panic("x.module is empty!")
-	}
 	if key == "" {
 		panic("key is empty!")
 	}
@@ -190,6 +186,14 @@ func (x *JSONProperties) LockForWrite(key string) *SyncedJSONProperty {
 	x.Lock()
 	defer x.Unlock()
 
+	// checked under lock, SetModule may run concurrently
+	if x.Properties == nil {
+		panic("x.jsonProperties is nil!")
+	}
+	if x.module == "" {
+		panic("x.module is empty!")
+	}
+
 	// FIXME Zero can panic, look at deferred code
 
 	var (
@@ -210,22 +214,22 @@ func (x *JSONProperties) LockForWrite(key string) *SyncedJSONProperty {
 }
 
 // SetModule allows to change the module of the JSONProperties (used to "contextualize" Property Types)
+// The module is checked and set under lock, so concurrent first uses agree on a single module
 func (x *JSONProperties) SetModule(module string) {
-	if module != "" && x.module == module {
-		return
-	}
-	if x.module != "" {
-		panic("x.SetModule() cannot be changed if x.module is already set!")
-	}
 	if module == "" {
 		panic("module is empty!")
 	}
+
 	x.Lock()
 	defer x.Unlock()
 
-	if x.module == "" {
-		x.module = module
+	if x.module == module {
+		return
+	}
+	if x.module != "" {
+		panic("x.SetModule() cannot be changed if x.module is already set!")
 	}
+	x.module = module
 }
 
 // MarshalJSON implements json.Marshaller
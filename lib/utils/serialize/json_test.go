@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://csgroup.eu
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serialize
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+)
+
+// counter is a property counting the writes done on it
+type counter struct {
+	Value int
+}
+
+func (c *counter) Clone() data.Clonable {
+	clone := *c
+	return &clone
+}
+
+func (c *counter) Replace(p data.Clonable) data.Clonable {
+	*c = *p.(*counter)
+	return c
+}
+
+func init() {
+	PropertyTypeRegistry.Register("serialize.test", "counter", &counter{})
+}
+
+func TestJSONProperties_ConcurrentFirstUse(t *testing.T) {
+	x := &JSONProperties{Properties: jsonProperties{}}
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			x.SetModule("serialize.test")
+			err := x.LockForWrite("counter").ThenUse(
+				func(clonable data.Clonable) error {
+					clonable.(*counter).Value++
+					return nil
+				},
+			)
+			assert.Nil(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			x.SetModule("serialize.test")
+			err := x.LockForRead("counter").ThenUse(
+				func(clonable data.Clonable) error {
+					return nil
+				},
+			)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// A single property has been created, holding all the writes
+	require.Len(t, x.Properties, 1)
+	assert.Equal(t, writers, x.Properties["counter"].Data.(*counter).Value)
+	assert.Equal(t, "serialize.test", x.module)
+}
+
+func TestJSONProperties_SetModule(t *testing.T) {
+	x := NewJSONProperties("serialize.test")
+	assert.NotPanics(t, func() { x.SetModule("serialize.test") })
+	assert.Panics(t, func() { x.SetModule("other") })
+	assert.Panics(t, func() { x.SetModule("") })
+}
@@ -39,6 +39,9 @@ const (
 	// HostCleanupTimeout is the default timeout of host teardown operations
 	HostCleanupTimeout = 10 * time.Minute
 
+	// NetworkCleanupTimeout is the default time to wait for a network to disappear after its deletion timed out
+	NetworkCleanupTimeout = DefaultContextTimeout
+
 	// DefaultConnectionTimeout is the default connection timeout
 	DefaultConnectionTimeout = 30 * time.Second
 
@@ -107,6 +110,11 @@ func GetHostCleanupTimeout() time.Duration {
 	return GetTimeoutFromEnv("SAFESCALE_HOST_CLEANUP_TIMEOUT", HostCleanupTimeout)
 }
 
+// GetNetworkCleanupTimeout ...
+func GetNetworkCleanupTimeout() time.Duration {
+	return GetTimeoutFromEnv("SAFESCALE_NETWORK_CLEANUP_TIMEOUT", NetworkCleanupTimeout)
+}
+
 // GetConnectSSHTimeout ...
 func GetConnectSSHTimeout() time.Duration {
 	return GetTimeoutFromEnv("SAFESCALE_SSH_CONNECT_TIMEOUT", DefaultSSHConnectionTimeout)